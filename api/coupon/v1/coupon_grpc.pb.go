@@ -0,0 +1,289 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v25.3.0
+// source: coupon.proto
+
+package couponv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	CouponService_CreateCoupon_FullMethodName     = "/coupon.v1.CouponService/CreateCoupon"
+	CouponService_ClaimCoupon_FullMethodName      = "/coupon.v1.CouponService/ClaimCoupon"
+	CouponService_GetCouponDetails_FullMethodName = "/coupon.v1.CouponService/GetCouponDetails"
+	CouponService_ListClaims_FullMethodName       = "/coupon.v1.CouponService/ListClaims"
+	CouponService_WatchCoupon_FullMethodName      = "/coupon.v1.CouponService/WatchCoupon"
+)
+
+// CouponServiceClient is the client API for CouponService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CouponServiceClient interface {
+	CreateCoupon(ctx context.Context, in *CreateCouponRequest, opts ...grpc.CallOption) (*Coupon, error)
+	ClaimCoupon(ctx context.Context, in *ClaimCouponRequest, opts ...grpc.CallOption) (*ClaimCouponResponse, error)
+	GetCouponDetails(ctx context.Context, in *GetCouponDetailsRequest, opts ...grpc.CallOption) (*CouponDetails, error)
+	ListClaims(ctx context.Context, in *ListClaimsRequest, opts ...grpc.CallOption) (*ListClaimsResponse, error)
+	// WatchCoupon streams the coupon's stock and status: the current state
+	// first, then every change. It ends when the server shuts down.
+	WatchCoupon(ctx context.Context, in *WatchCouponRequest, opts ...grpc.CallOption) (CouponService_WatchCouponClient, error)
+}
+
+type couponServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCouponServiceClient(cc grpc.ClientConnInterface) CouponServiceClient {
+	return &couponServiceClient{cc}
+}
+
+func (c *couponServiceClient) CreateCoupon(ctx context.Context, in *CreateCouponRequest, opts ...grpc.CallOption) (*Coupon, error) {
+	out := new(Coupon)
+	err := c.cc.Invoke(ctx, CouponService_CreateCoupon_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *couponServiceClient) ClaimCoupon(ctx context.Context, in *ClaimCouponRequest, opts ...grpc.CallOption) (*ClaimCouponResponse, error) {
+	out := new(ClaimCouponResponse)
+	err := c.cc.Invoke(ctx, CouponService_ClaimCoupon_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *couponServiceClient) GetCouponDetails(ctx context.Context, in *GetCouponDetailsRequest, opts ...grpc.CallOption) (*CouponDetails, error) {
+	out := new(CouponDetails)
+	err := c.cc.Invoke(ctx, CouponService_GetCouponDetails_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *couponServiceClient) ListClaims(ctx context.Context, in *ListClaimsRequest, opts ...grpc.CallOption) (*ListClaimsResponse, error) {
+	out := new(ListClaimsResponse)
+	err := c.cc.Invoke(ctx, CouponService_ListClaims_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *couponServiceClient) WatchCoupon(ctx context.Context, in *WatchCouponRequest, opts ...grpc.CallOption) (CouponService_WatchCouponClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CouponService_ServiceDesc.Streams[0], CouponService_WatchCoupon_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &couponServiceWatchCouponClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CouponService_WatchCouponClient interface {
+	Recv() (*StockUpdate, error)
+	grpc.ClientStream
+}
+
+type couponServiceWatchCouponClient struct {
+	grpc.ClientStream
+}
+
+func (x *couponServiceWatchCouponClient) Recv() (*StockUpdate, error) {
+	m := new(StockUpdate)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// CouponServiceServer is the server API for CouponService service.
+// All implementations must embed UnimplementedCouponServiceServer
+// for forward compatibility
+type CouponServiceServer interface {
+	CreateCoupon(context.Context, *CreateCouponRequest) (*Coupon, error)
+	ClaimCoupon(context.Context, *ClaimCouponRequest) (*ClaimCouponResponse, error)
+	GetCouponDetails(context.Context, *GetCouponDetailsRequest) (*CouponDetails, error)
+	ListClaims(context.Context, *ListClaimsRequest) (*ListClaimsResponse, error)
+	// WatchCoupon streams the coupon's stock and status: the current state
+	// first, then every change. It ends when the server shuts down.
+	WatchCoupon(*WatchCouponRequest, CouponService_WatchCouponServer) error
+	mustEmbedUnimplementedCouponServiceServer()
+}
+
+// UnimplementedCouponServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedCouponServiceServer struct {
+}
+
+func (UnimplementedCouponServiceServer) CreateCoupon(context.Context, *CreateCouponRequest) (*Coupon, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateCoupon not implemented")
+}
+func (UnimplementedCouponServiceServer) ClaimCoupon(context.Context, *ClaimCouponRequest) (*ClaimCouponResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ClaimCoupon not implemented")
+}
+func (UnimplementedCouponServiceServer) GetCouponDetails(context.Context, *GetCouponDetailsRequest) (*CouponDetails, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCouponDetails not implemented")
+}
+func (UnimplementedCouponServiceServer) ListClaims(context.Context, *ListClaimsRequest) (*ListClaimsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListClaims not implemented")
+}
+func (UnimplementedCouponServiceServer) WatchCoupon(*WatchCouponRequest, CouponService_WatchCouponServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchCoupon not implemented")
+}
+func (UnimplementedCouponServiceServer) mustEmbedUnimplementedCouponServiceServer() {}
+
+// UnsafeCouponServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CouponServiceServer will
+// result in compilation errors.
+type UnsafeCouponServiceServer interface {
+	mustEmbedUnimplementedCouponServiceServer()
+}
+
+func RegisterCouponServiceServer(s grpc.ServiceRegistrar, srv CouponServiceServer) {
+	s.RegisterService(&CouponService_ServiceDesc, srv)
+}
+
+func _CouponService_CreateCoupon_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateCouponRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CouponServiceServer).CreateCoupon(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CouponService_CreateCoupon_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CouponServiceServer).CreateCoupon(ctx, req.(*CreateCouponRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CouponService_ClaimCoupon_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClaimCouponRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CouponServiceServer).ClaimCoupon(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CouponService_ClaimCoupon_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CouponServiceServer).ClaimCoupon(ctx, req.(*ClaimCouponRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CouponService_GetCouponDetails_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCouponDetailsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CouponServiceServer).GetCouponDetails(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CouponService_GetCouponDetails_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CouponServiceServer).GetCouponDetails(ctx, req.(*GetCouponDetailsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CouponService_ListClaims_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListClaimsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CouponServiceServer).ListClaims(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CouponService_ListClaims_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CouponServiceServer).ListClaims(ctx, req.(*ListClaimsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CouponService_WatchCoupon_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchCouponRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CouponServiceServer).WatchCoupon(m, &couponServiceWatchCouponServer{stream})
+}
+
+type CouponService_WatchCouponServer interface {
+	Send(*StockUpdate) error
+	grpc.ServerStream
+}
+
+type couponServiceWatchCouponServer struct {
+	grpc.ServerStream
+}
+
+func (x *couponServiceWatchCouponServer) Send(m *StockUpdate) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// CouponService_ServiceDesc is the grpc.ServiceDesc for CouponService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CouponService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "coupon.v1.CouponService",
+	HandlerType: (*CouponServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateCoupon",
+			Handler:    _CouponService_CreateCoupon_Handler,
+		},
+		{
+			MethodName: "ClaimCoupon",
+			Handler:    _CouponService_ClaimCoupon_Handler,
+		},
+		{
+			MethodName: "GetCouponDetails",
+			Handler:    _CouponService_GetCouponDetails_Handler,
+		},
+		{
+			MethodName: "ListClaims",
+			Handler:    _CouponService_ListClaims_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchCoupon",
+			Handler:       _CouponService_WatchCoupon_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "coupon.proto",
+}
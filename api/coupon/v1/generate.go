@@ -0,0 +1,4 @@
+// Package couponv1 holds the generated gRPC API for the coupon service
+package couponv1
+
+//go:generate protoc -I . --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative coupon.proto
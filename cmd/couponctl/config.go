@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the couponctl configuration file
+//
+//	current_target: local
+//	targets:
+//	  local:
+//	    url: http://localhost:8080
+//	  production:
+//	    url: https://coupons.example.com
+//	    api_key: ck_live_...
+type Config struct {
+	CurrentTarget string            `yaml:"current_target"`
+	Targets       map[string]Target `yaml:"targets"`
+}
+
+// Target is a coupon API endpoint and the credentials used to call it
+type Target struct {
+	URL    string `yaml:"url"`
+	APIKey string `yaml:"api_key"`
+}
+
+// defaultConfigPath returns $COUPONCTL_CONFIG or ~/.config/couponctl/config.yaml
+func defaultConfigPath() string {
+	if path := os.Getenv("COUPONCTL_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "couponctl", "config.yaml")
+}
+
+// loadConfig reads the config file; a missing file yields an empty config
+func loadConfig(path string) (*Config, error) {
+	cfg := &Config{Targets: map[string]Target{}}
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if cfg.Targets == nil {
+		cfg.Targets = map[string]Target{}
+	}
+	return cfg, nil
+}
+
+// resolveTarget picks the target to talk to
+// Precedence: -url flag, COUPONCTL_URL, -target flag, current_target, http://localhost:8080
+// The API key follows the same order with COUPONCTL_API_KEY
+func (c *Config) resolveTarget(name, url string) (Target, error) {
+	var target Target
+
+	if name == "" {
+		name = c.CurrentTarget
+	}
+	if name != "" {
+		t, ok := c.Targets[name]
+		if !ok {
+			return Target{}, fmt.Errorf("unknown target %q", name)
+		}
+		target = t
+	}
+
+	if env := os.Getenv("COUPONCTL_URL"); env != "" {
+		target.URL = env
+	}
+	if url != "" {
+		target.URL = url
+	}
+	if env := os.Getenv("COUPONCTL_API_KEY"); env != "" {
+		target.APIKey = env
+	}
+	if target.URL == "" {
+		target.URL = "http://localhost:8080"
+	}
+
+	return target, nil
+}
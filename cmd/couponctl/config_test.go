@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestLoadConfig checks a missing file is an empty config and a bad one an error
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	cfg, err := loadConfig(filepath.Join(dir, "missing.yaml"))
+	if err != nil || cfg.CurrentTarget != "" || cfg.Targets == nil {
+		t.Errorf("missing file = %+v, %v; want an empty config", cfg, err)
+	}
+
+	bad := filepath.Join(dir, "bad.yaml")
+	if err := os.WriteFile(bad, []byte("targets: [unclosed"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(bad); err == nil {
+		t.Error("bad config was accepted")
+	}
+
+	good := filepath.Join(dir, "config.yaml")
+	data := "current_target: prod\ntargets:\n  prod:\n    url: https://coupons.example.com\n    api_key: ck_live_1\n"
+	if err := os.WriteFile(good, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = loadConfig(good)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Target{URL: "https://coupons.example.com", APIKey: "ck_live_1"}); cfg.CurrentTarget != "prod" || cfg.Targets["prod"] != want {
+		t.Errorf("config = %+v, want current target prod with %+v", cfg, want)
+	}
+}
+
+// TestResolveTarget checks flags override the environment, which overrides the config file
+func TestResolveTarget(t *testing.T) {
+	cfg := &Config{
+		CurrentTarget: "prod",
+		Targets: map[string]Target{
+			"prod":    {URL: "https://prod.example.com", APIKey: "ck_prod"},
+			"staging": {URL: "https://staging.example.com", APIKey: "ck_staging"},
+		},
+	}
+
+	for _, tc := range []struct {
+		name      string
+		cfg       *Config
+		target    string
+		url       string
+		envURL    string
+		envAPIKey string
+		want      Target
+	}{
+		{"default", &Config{}, "", "", "", "", Target{URL: "http://localhost:8080"}},
+		{"current target", cfg, "", "", "", "", cfg.Targets["prod"]},
+		{"target flag", cfg, "staging", "", "", "", cfg.Targets["staging"]},
+		{"environment", cfg, "staging", "", "http://env:8080", "ck_env", Target{URL: "http://env:8080", APIKey: "ck_env"}},
+		{"url flag", cfg, "staging", "http://flag:8080", "http://env:8080", "", Target{URL: "http://flag:8080", APIKey: "ck_staging"}},
+	} {
+		t.Setenv("COUPONCTL_URL", tc.envURL)
+		t.Setenv("COUPONCTL_API_KEY", tc.envAPIKey)
+		got, err := tc.cfg.resolveTarget(tc.target, tc.url)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%s: target = %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+
+	if _, err := cfg.resolveTarget("nope", ""); err == nil {
+		t.Error("unknown target was accepted")
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"coupon-system/pkg/client"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+const usage = `couponctl - administer the coupon system
+
+Usage:
+  couponctl [global flags] <command> [flags] [args]
+
+Commands:
+  list                                 list all coupons
+  get <name>                           show a coupon and its stock
+  create <name> -amount N [-expires-at RFC3339]
+                                       create a coupon
+  pause <name>                         stop a coupon from being claimed
+  resume <name>                        allow a paused coupon to be claimed again
+  restock <name> -amount N             add stock to a coupon
+  claims <name>                        list the claims for a coupon
+  export coupons|claims [name] [-format csv|json] [-out file]
+                                       export coupons or a coupon's claims
+
+Global flags:
+`
+
+func main() {
+	global := flag.NewFlagSet("couponctl", flag.ExitOnError)
+	configPath := global.String("config", defaultConfigPath(), "path to the config file")
+	targetName := global.String("target", "", "target from the config file (default: current_target)")
+	targetURL := global.String("url", "", "API base URL, overrides the target's url")
+	output := global.String("o", formatTable, "output format: table or json")
+	global.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		global.PrintDefaults()
+	}
+	_ = global.Parse(os.Args[1:])
+
+	if global.NArg() == 0 {
+		global.Usage()
+		os.Exit(2)
+	}
+
+	if err := validFormat(*output, formatTable, formatJSON); err != nil {
+		fatal(err)
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fatal(err)
+	}
+	target, err := cfg.resolveTarget(*targetName, *targetURL)
+	if err != nil {
+		fatal(err)
+	}
+
+	api := client.New(target.URL, client.Options{APIKey: target.APIKey})
+	cli := &cli{ctx: context.Background(), api: api, format: *output, out: os.Stdout}
+	if err := cli.run(global.Arg(0), global.Args()[1:]); err != nil {
+		fatal(err)
+	}
+}
+
+// cli holds the state shared by all commands
+type cli struct {
+	ctx    context.Context
+	api    *client.Client
+	format string
+	out    io.Writer
+}
+
+// run dispatches a command
+func (c *cli) run(command string, args []string) error {
+	switch command {
+	case "list":
+		return c.list(args)
+	case "get":
+		return c.get(args)
+	case "create":
+		return c.create(args)
+	case "pause":
+		return c.setActive(args, "pause")
+	case "resume":
+		return c.setActive(args, "resume")
+	case "restock":
+		return c.restock(args)
+	case "claims":
+		return c.claims(args)
+	case "export":
+		return c.export(args)
+	default:
+		return fmt.Errorf("unknown command %q (run couponctl -h for usage)", command)
+	}
+}
+
+func (c *cli) list(args []string) error {
+	if _, err := parseArgs(flag.NewFlagSet("list", flag.ExitOnError), args, 0); err != nil {
+		return err
+	}
+
+	coupons, err := c.api.ListCoupons(c.ctx)
+	if err != nil {
+		return err
+	}
+	return printCoupons(c.out, c.format, coupons)
+}
+
+func (c *cli) get(args []string) error {
+	pos, err := parseArgs(flag.NewFlagSet("get", flag.ExitOnError), args, 1)
+	if err != nil {
+		return err
+	}
+
+	details, err := c.api.GetCoupon(c.ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	return printCoupon(c.out, c.format, details)
+}
+
+func (c *cli) create(args []string) error {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	amount := fs.Int("amount", 0, "coupon amount in cents (required)")
+	expiresAt := fs.String("expires-at", "", "expiry time in RFC3339 (default: 30 days)")
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *amount <= 0 {
+		return errors.New("-amount must be greater than 0")
+	}
+
+	req := client.CreateCouponRequest{Name: pos[0], Amount: int32(*amount), ExpiresAt: *expiresAt}
+	coupon, err := c.api.CreateCoupon(c.ctx, &req)
+	if err != nil {
+		return err
+	}
+	return printCoupons(c.out, c.format, []*client.Coupon{coupon})
+}
+
+func (c *cli) setActive(args []string, action string) error {
+	pos, err := parseArgs(flag.NewFlagSet(action, flag.ExitOnError), args, 1)
+	if err != nil {
+		return err
+	}
+
+	setActive := c.api.PauseCoupon
+	if action == "resume" {
+		setActive = c.api.ResumeCoupon
+	}
+	coupon, err := setActive(c.ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	return printCoupons(c.out, c.format, []*client.Coupon{coupon})
+}
+
+func (c *cli) restock(args []string) error {
+	fs := flag.NewFlagSet("restock", flag.ExitOnError)
+	amount := fs.Int("amount", 0, "stock to add (required)")
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *amount <= 0 {
+		return errors.New("-amount must be greater than 0")
+	}
+
+	coupon, err := c.api.RestockCoupon(c.ctx, pos[0], int32(*amount))
+	if err != nil {
+		return err
+	}
+	return printCoupons(c.out, c.format, []*client.Coupon{coupon})
+}
+
+func (c *cli) claims(args []string) error {
+	pos, err := parseArgs(flag.NewFlagSet("claims", flag.ExitOnError), args, 1)
+	if err != nil {
+		return err
+	}
+
+	claims, err := c.api.ListClaims(c.ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	return printClaims(c.out, c.format, claims)
+}
+
+func (c *cli) export(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", formatCSV, "export format: csv or json")
+	outPath := fs.String("out", "", "write to a file instead of stdout")
+	pos, err := parseArgs(fs, args, -1)
+	if err != nil {
+		return err
+	}
+	if err := validFormat(*format, formatCSV, formatJSON); err != nil {
+		return err
+	}
+
+	out := c.out
+	if *outPath != "" {
+		f, err := os.Create(*outPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	switch {
+	case len(pos) == 1 && pos[0] == "coupons":
+		coupons, err := c.api.ListCoupons(c.ctx)
+		if err != nil {
+			return err
+		}
+		return printCoupons(out, *format, coupons)
+	case len(pos) == 2 && pos[0] == "claims":
+		claims, err := c.api.ListClaims(c.ctx, pos[1])
+		if err != nil {
+			return err
+		}
+		return printClaims(out, *format, claims)
+	default:
+		return errors.New("usage: couponctl export coupons | couponctl export claims <name>")
+	}
+}
+
+// parseArgs parses flags that may appear before or after positional arguments
+// and checks the number of positional arguments (-1 means any)
+func parseArgs(fs *flag.FlagSet, args []string, want int) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	if want >= 0 && len(positional) != want {
+		return nil, fmt.Errorf("%s: expected %d argument(s), got %d", fs.Name(), want, len(positional))
+	}
+	return positional, nil
+}
+
+// fatal prints an error and exits
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "couponctl:", err)
+	os.Exit(1)
+}
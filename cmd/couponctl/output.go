@@ -0,0 +1,111 @@
+package main
+
+import (
+	"coupon-system/internal/model"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Output formats
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+)
+
+// printJSON writes v as indented JSON
+func printJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// printCoupons writes a list of coupons in the requested format
+func printCoupons(w io.Writer, format string, coupons []*model.Coupon) error {
+	switch format {
+	case formatJSON:
+		return printJSON(w, coupons)
+	case formatCSV:
+		rows := [][]string{{"name", "amount", "remaining_amount", "is_active", "created_at", "expires_at"}}
+		for _, c := range coupons {
+			rows = append(rows, []string{
+				c.Name,
+				strconv.Itoa(int(c.Amount)),
+				strconv.Itoa(int(c.RemainingAmount)),
+				strconv.FormatBool(c.IsActive),
+				c.CreatedAt.Format(time.RFC3339),
+				c.ExpiresAt.Format(time.RFC3339),
+			})
+		}
+		return csv.NewWriter(w).WriteAll(rows)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tAMOUNT\tREMAINING\tSTATUS\tEXPIRES")
+	for _, c := range coupons {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\n",
+			c.Name, c.Amount, c.RemainingAmount, status(c.IsActive), c.ExpiresAt.Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
+
+// printCoupon writes a single coupon's details in the requested format
+func printCoupon(w io.Writer, format string, details *model.CouponDetailsResponse) error {
+	if format == formatJSON {
+		return printJSON(w, details)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Name:\t%s\n", details.Name)
+	fmt.Fprintf(tw, "Amount:\t%d\n", details.Amount)
+	fmt.Fprintf(tw, "Remaining:\t%d\n", details.RemainingAmount)
+	fmt.Fprintf(tw, "Status:\t%s\n", status(details.IsActive))
+	fmt.Fprintf(tw, "Expires:\t%s\n", details.ExpiresAt.Format(time.RFC3339))
+	fmt.Fprintf(tw, "Claims:\t%d\n", len(details.ClaimedBy))
+	return tw.Flush()
+}
+
+// printClaims writes a list of claims in the requested format
+func printClaims(w io.Writer, format string, claims []*model.Claim) error {
+	switch format {
+	case formatJSON:
+		return printJSON(w, claims)
+	case formatCSV:
+		rows := [][]string{{"user_id", "coupon_name", "created_at"}}
+		for _, c := range claims {
+			rows = append(rows, []string{c.UserID, c.CouponName, c.CreatedAt.Format(time.RFC3339)})
+		}
+		return csv.NewWriter(w).WriteAll(rows)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "USER\tCOUPON\tCLAIMED AT")
+	for _, c := range claims {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", c.UserID, c.CouponName, c.CreatedAt.Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
+
+// status renders is_active for tables
+func status(active bool) string {
+	if active {
+		return "active"
+	}
+	return "paused"
+}
+
+// validFormat reports whether format is one of the allowed formats
+func validFormat(format string, allowed ...string) error {
+	for _, a := range allowed {
+		if format == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported output format %q (use %s)", format, strings.Join(allowed, ", "))
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"coupon-system/internal/model"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	created = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	expires = time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	coupons = []*model.Coupon{
+		{Name: "SPRING", Amount: 100, RemainingAmount: 40, IsActive: true, CreatedAt: created, ExpiresAt: expires},
+		{Name: "WINTER", Amount: 5, RemainingAmount: 0, IsActive: false, CreatedAt: created, ExpiresAt: expires},
+	}
+)
+
+// TestPrintCoupons checks the table, CSV and JSON renderings of a coupon list
+func TestPrintCoupons(t *testing.T) {
+	var out bytes.Buffer
+	if err := printCoupons(&out, formatTable, coupons); err != nil {
+		t.Fatal(err)
+	}
+	want := "NAME    AMOUNT  REMAINING  STATUS  EXPIRES\n" +
+		"SPRING  100     40         active  2026-02-01T00:00:00Z\n" +
+		"WINTER  5       0          paused  2026-02-01T00:00:00Z\n"
+	if out.String() != want {
+		t.Errorf("table:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if err := printCoupons(&out, formatCSV, coupons); err != nil {
+		t.Fatal(err)
+	}
+	want = "name,amount,remaining_amount,is_active,created_at,expires_at\n" +
+		"SPRING,100,40,true,2026-01-01T00:00:00Z,2026-02-01T00:00:00Z\n" +
+		"WINTER,5,0,false,2026-01-01T00:00:00Z,2026-02-01T00:00:00Z\n"
+	if out.String() != want {
+		t.Errorf("csv:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if err := printCoupons(&out, formatJSON, coupons); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []*model.Coupon
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || len(decoded) != 2 || decoded[1].Name != "WINTER" {
+		t.Errorf("json = %s, %v; want both coupons", out.String(), err)
+	}
+}
+
+// TestPrintCouponAndClaims checks the coupon details and claim list renderings
+func TestPrintCouponAndClaims(t *testing.T) {
+	var out bytes.Buffer
+	details := &model.CouponDetailsResponse{Name: "SPRING", Amount: 100, RemainingAmount: 98, IsActive: true, ExpiresAt: expires, ClaimedBy: []string{"a", "b"}}
+	if err := printCoupon(&out, formatTable, details); err != nil {
+		t.Fatal(err)
+	}
+	want := "Name:       SPRING\nAmount:     100\nRemaining:  98\nStatus:     active\nExpires:    2026-02-01T00:00:00Z\nClaims:     2\n"
+	if out.String() != want {
+		t.Errorf("details:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	claims := []*model.Claim{{UserID: "a", CouponName: "SPRING", CreatedAt: created}, {UserID: "b,c", CouponName: "SPRING", CreatedAt: created}}
+	out.Reset()
+	if err := printClaims(&out, formatCSV, claims); err != nil {
+		t.Fatal(err)
+	}
+	want = "user_id,coupon_name,created_at\na,SPRING,2026-01-01T00:00:00Z\n\"b,c\",SPRING,2026-01-01T00:00:00Z\n"
+	if out.String() != want {
+		t.Errorf("claims csv:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if err := printClaims(&out, formatTable, claims); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 3 || !strings.HasPrefix(lines[0], "USER") {
+		t.Errorf("claims table:\n%s", out.String())
+	}
+}
+
+// TestValidFormat checks formats outside the allowed list are rejected
+func TestValidFormat(t *testing.T) {
+	if err := validFormat(formatJSON, formatTable, formatJSON); err != nil {
+		t.Errorf("json: %v", err)
+	}
+	if err := validFormat(formatCSV, formatTable, formatJSON); err == nil || !strings.Contains(err.Error(), "table, json") {
+		t.Errorf("csv: err = %v, want the allowed formats listed", err)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"coupon-system/internal/repository"
+	"coupon-system/internal/seed"
+	"coupon-system/pkg/config"
+	"coupon-system/pkg/database"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// wiper drops every collection; *database.MongoDB implements it
+type wiper interface {
+	Reset(ctx context.Context) error
+}
+
+// namedFixture pairs a fixture with the scenario or file it came from
+type namedFixture struct {
+	name    string
+	fixture *seed.Fixture
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: seed [-wipe] [-scenario a,b] [-file fixture.yaml]\n\n")
+		flag.PrintDefaults()
+	}
+	scenarios := flag.String("scenario", "flash_sale,double_dip,expired",
+		"comma-separated list of built-in scenarios to load ("+strings.Join(seed.Scenarios(), ", ")+")")
+	file := flag.String("file", "", "load a fixture from a JSON or YAML file instead of the built-in scenarios")
+	wipe := flag.Bool("wipe", false, "drop all collections before seeding")
+	flag.Parse()
+
+	// Same configuration as the server
+	mongoURI := config.GetEnv("MONGO_URI", "mongodb://localhost:27017")
+	dbName := config.GetEnv("MONGO_DB", "coupon_system")
+
+	fixtures, err := loadFixtures(*scenarios, *file)
+	if err != nil {
+		log.Fatalf("Failed to load fixtures: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	mongoDB, err := database.Connect(ctx, mongoURI, dbName)
+	if err != nil {
+		log.Fatalf("Failed to connect to MongoDB: %v", err)
+	}
+	defer func() {
+		if err := mongoDB.Disconnect(context.Background()); err != nil {
+			log.Printf("Error disconnecting from MongoDB: %v", err)
+		}
+	}()
+
+	log.Printf("🌱 Seeding %s on %s", dbName, mongoURI)
+
+	// Seed through the same stock layout the server uses
+	couponRepo := repository.NewShardedCouponRepository(mongoDB.Database,
+		repository.NewCouponRepository(mongoDB.Database), int32(config.GetEnvInt("STOCK_SHARDS", 0)))
+	seeder := seed.NewSeeder(couponRepo, repository.NewClaimRepository(mongoDB.Database))
+
+	var db wiper
+	if *wipe {
+		db = mongoDB
+	}
+	if err := run(ctx, db, seeder, fixtures); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run wipes the database when db is not nil, then applies the fixtures in order
+func run(ctx context.Context, db wiper, seeder *seed.Seeder, fixtures []namedFixture) error {
+	if db != nil {
+		if err := db.Reset(ctx); err != nil {
+			return fmt.Errorf("failed to wipe database: %w", err)
+		}
+		log.Println("🧹 Wiped all collections")
+	}
+
+	for _, nf := range fixtures {
+		result, err := seeder.Apply(ctx, nf.fixture)
+		if err != nil {
+			return fmt.Errorf("failed to seed %s: %w", nf.name, err)
+		}
+		log.Printf("✅ %s: %d coupons created, %d skipped; %d claims created, %d skipped",
+			nf.name, result.CouponsCreated, result.CouponsSkipped, result.ClaimsCreated, result.ClaimsSkipped)
+	}
+	return nil
+}
+
+// loadFixtures resolves the -file or -scenario flags into fixtures, in order
+func loadFixtures(scenarios, file string) ([]namedFixture, error) {
+	if file != "" {
+		fixture, err := seed.LoadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		return []namedFixture{{name: file, fixture: fixture}}, nil
+	}
+
+	var fixtures []namedFixture
+	for _, name := range strings.Split(scenarios, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		fixture, err := seed.Scenario(name)
+		if err != nil {
+			return nil, err
+		}
+		fixtures = append(fixtures, namedFixture{name: name, fixture: fixture})
+	}
+	if len(fixtures) == 0 {
+		return nil, fmt.Errorf("no scenarios given")
+	}
+	return fixtures, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"coupon-system/internal/repository/repotest"
+	"coupon-system/internal/seed"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestLoadFixtures checks how the -scenario and -file flags are resolved
+func TestLoadFixtures(t *testing.T) {
+	fixtures, err := loadFixtures(" flash_sale, ,expired,", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, nf := range fixtures {
+		names = append(names, nf.name)
+	}
+	if !reflect.DeepEqual(names, []string{"flash_sale", "expired"}) {
+		t.Errorf("scenarios = %v, want [flash_sale expired]", names)
+	}
+
+	if _, err := loadFixtures("flash_sale,nope", ""); err == nil {
+		t.Error("unknown scenario was accepted")
+	}
+	if _, err := loadFixtures(" , ", ""); err == nil {
+		t.Error("empty scenario list was accepted")
+	}
+
+	// A file replaces the scenarios
+	path := filepath.Join(t.TempDir(), "fixture.yaml")
+	if err := os.WriteFile(path, []byte("coupons:\n  - name: FILE\n    amount: 1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fixtures, err = loadFixtures("flash_sale", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) != 1 || fixtures[0].name != path || fixtures[0].fixture.Coupons[0].Name != "FILE" {
+		t.Errorf("fixtures = %+v, want only %s", fixtures, path)
+	}
+	if _, err := loadFixtures("", filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("missing file was accepted")
+	}
+}
+
+// recordingWiper records the coupons present when it is asked to wipe
+type recordingWiper struct {
+	coupons *repotest.CouponRepository
+	seen    []int // Coupons present at each wipe
+	err     error
+}
+
+func (w *recordingWiper) Reset(ctx context.Context) error {
+	list, _ := w.coupons.ListCoupons(ctx)
+	w.seen = append(w.seen, len(list))
+	return w.err
+}
+
+// TestRunWipe checks -wipe runs before anything is seeded, and that a failed
+// wipe stops the run before anything is written
+func TestRunWipe(t *testing.T) {
+	ctx := context.Background()
+	fixtures, err := loadFixtures("expired", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	coupons := repotest.NewCouponRepository()
+	seeder := seed.NewSeeder(coupons, repotest.NewClaimRepository())
+	db := &recordingWiper{coupons: coupons}
+	if err := run(ctx, db, seeder, fixtures); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := coupons.ListCoupons(ctx); !reflect.DeepEqual(db.seen, []int{0}) || len(list) != 2 {
+		t.Errorf("coupons at wipe = %v, after seeding = %d; want [0] and 2", db.seen, len(list))
+	}
+
+	// Without -wipe nothing is wiped
+	if err := run(ctx, nil, seeder, fixtures); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.seen) != 1 {
+		t.Errorf("wiped %d times, want only with -wipe", len(db.seen))
+	}
+
+	coupons = repotest.NewCouponRepository()
+	db = &recordingWiper{coupons: coupons, err: errors.New("boom")}
+	if err := run(ctx, db, seed.NewSeeder(coupons, repotest.NewClaimRepository()), fixtures); !errors.Is(err, db.err) {
+		t.Errorf("run with a failing wipe = %v, want the wipe error", err)
+	}
+	if list, _ := coupons.ListCoupons(ctx); len(list) != 0 {
+		t.Errorf("%d coupons seeded after a failed wipe, want 0", len(list))
+	}
+}
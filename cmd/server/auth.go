@@ -0,0 +1,278 @@
+package main
+
+import (
+	"coupon-system/internal/auth"
+	"coupon-system/internal/model"
+	"coupon-system/internal/validation"
+	apperrors "coupon-system/pkg/errors"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiKeyHeader carries the caller's API key
+const apiKeyHeader = "X-API-Key"
+
+// apiKeyContextKey is where allow stores the authenticated key for later handlers
+const apiKeyContextKey = "api_key"
+
+// tokenQuery carries a token from POST /api/stream-tokens on routes browsers open directly
+const tokenQuery = "token"
+
+// Role sets of the API routes; admins may call every route
+var (
+	anyRole     = []model.APIKeyRole{model.RoleSupport, model.RoleReadOnly, model.RoleClaimer}
+	readers     = []model.APIKeyRole{model.RoleSupport, model.RoleReadOnly}
+	claimers    = []model.APIKeyRole{model.RoleSupport, model.RoleClaimer}
+	supportOnly = []model.APIKeyRole{model.RoleSupport}
+	adminOnly   = []model.APIKeyRole{}
+)
+
+// authorizer guards API routes with API keys
+// A nil authorizer (AUTH_ENABLED=false) lets every request through.
+type authorizer struct {
+	keys *auth.Manager
+}
+
+// newAuthorizer returns an authorizer for keys, or nil if keys is nil
+func newAuthorizer(keys *auth.Manager) *authorizer {
+	if keys == nil {
+		return nil
+	}
+	return &authorizer{keys: keys}
+}
+
+// allow lets requests through whose API key has one of the roles, and audits every request
+// Requests without a valid key get 401, keys without the role 403.
+func (a *authorizer) allow(roles []model.APIKeyRole) gin.HandlerFunc {
+	return a.guard(roles, false)
+}
+
+// allowToken is allow for the streams and the WebSocket, which browsers open
+// without custom headers: they may pass a token from POST /api/stream-tokens
+// as ?token= instead of the X-API-Key header
+func (a *authorizer) allowToken(roles []model.APIKeyRole) gin.HandlerFunc {
+	return a.guard(roles, true)
+}
+
+// guard authenticates and audits requests for allow and allowToken
+func (a *authorizer) guard(roles []model.APIKeyRole, acceptToken bool) gin.HandlerFunc {
+	if a == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return func(c *gin.Context) {
+		secret := c.GetHeader(apiKeyHeader)
+		var token string
+		if acceptToken && secret == "" {
+			token = c.Query(tokenQuery)
+		}
+		entry := &model.APIKeyAuditEntry{
+			Method:   c.Request.Method,
+			Route:    c.FullPath(),
+			ClientIP: c.ClientIP(),
+			At:       time.Now(),
+		}
+		if secret != "" {
+			entry.KeyPrefix = auth.Prefix(secret)
+		}
+
+		var key *model.APIKey
+		var err error
+		if token != "" {
+			key, err = a.keys.AuthenticateToken(c.Request.Context(), token)
+		} else {
+			key, err = a.keys.Authenticate(c.Request.Context(), secret)
+		}
+		if key != nil {
+			entry.KeyID = &key.ID
+			entry.KeyName = key.Name
+			entry.KeyPrefix = key.Prefix
+			entry.Role = key.Role
+		}
+		switch {
+		case err != nil:
+			entry.Outcome = model.AuditUnauthenticated
+			c.Error(err)
+			c.Abort()
+		case !key.Allows(roles...):
+			entry.Outcome = model.AuditForbidden
+			c.Error(apperrors.ErrForbidden.WithMessage("API key role " + string(key.Role) + " is not allowed to call " + c.Request.Method + " " + c.FullPath()))
+			c.Abort()
+		default:
+			entry.Outcome = model.AuditAllowed
+			c.Set(apiKeyContextKey, key)
+			c.Next()
+		}
+
+		// Render first so the audit log has the status the client gets
+		renderProblem(c)
+		entry.Status = c.Writer.Status()
+		a.keys.Record(entry)
+	}
+}
+
+// callerKey returns the API key that authenticated the request
+// It is nil when authentication is disabled.
+func callerKey(c *gin.Context) *model.APIKey {
+	key, _ := c.Get(apiKeyContextKey)
+	k, _ := key.(*model.APIKey)
+	return k
+}
+
+// callerID names the caller without its secret, for rate limits and
+// idempotency keys: the authenticated key's ID, or a hash of the X-API-Key
+// header when authentication is disabled. Empty when no key was sent.
+func callerID(c *gin.Context) string {
+	if key := callerKey(c); key != nil {
+		return key.ID.Hex()
+	}
+	secret := c.GetHeader(apiKeyHeader)
+	if secret == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:8])
+}
+
+// apiKeyCreatedResponse includes the key's secret, which is only shown once
+type apiKeyCreatedResponse struct {
+	*model.APIKey
+	Key string `json:"key"`
+}
+
+// registerKeyRoutes adds the API key management endpoints to the API
+// They take no Idempotency-Key: a stored response would keep the key's secret.
+func registerKeyRoutes(api *gin.RouterGroup, keys *auth.Manager, allow gin.HandlerFunc) {
+	api.POST("/keys", allow, createKeyHandler(keys))
+	api.GET("/keys", allow, listKeysHandler(keys))
+	api.GET("/keys/:id", allow, getKeyHandler(keys))
+	api.POST("/keys/:id/rotate", allow, rotateKeyHandler(keys))
+	api.DELETE("/keys/:id", allow, revokeKeyHandler(keys))
+	api.GET("/audit", allow, auditLogHandler(keys))
+}
+
+// streamTokenHandler handles POST /api/stream-tokens
+// The token stands for the caller's own key, so it has the same role.
+func streamTokenHandler(keys *auth.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, expiresAt := keys.IssueToken(callerKey(c))
+		c.JSON(http.StatusCreated, model.StreamToken{Token: token, ExpiresAt: expiresAt})
+	}
+}
+
+// createKeyHandler handles POST /api/keys
+func createKeyHandler(keys *auth.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.CreateAPIKeyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(bindError(err))
+			return
+		}
+
+		key, secret, err := keys.Create(c.Request.Context(), &req)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.Header("Location", "/api/keys/"+key.ID.Hex())
+		c.JSON(http.StatusCreated, apiKeyCreatedResponse{APIKey: key, Key: secret})
+	}
+}
+
+// listKeysHandler handles GET /api/keys
+func listKeysHandler(keys *auth.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := keys.List(c.Request.Context())
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, list)
+	}
+}
+
+// getKeyHandler handles GET /api/keys/:id
+func getKeyHandler(keys *auth.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, err := keys.Get(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, key)
+	}
+}
+
+// rotateKeyHandler handles POST /api/keys/:id/rotate
+// The body is optional; without one the old key works for AUTH_ROTATION_GRACE.
+func rotateKeyHandler(keys *auth.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.RotateAPIKeyRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.Error(bindError(err))
+				return
+			}
+		}
+		var grace time.Duration
+		if req.GracePeriod != "" {
+			parsed, err := time.ParseDuration(req.GracePeriod)
+			if err != nil || parsed < 0 {
+				var invalid validation.Errors
+				invalid.Add("grace_period", "must be a duration such as 1h or 30m")
+				c.Error(invalid.Err())
+				return
+			}
+			grace = parsed
+		}
+
+		key, secret, err := keys.Rotate(c.Request.Context(), c.Param("id"), grace)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.Header("Location", "/api/keys/"+key.ID.Hex())
+		c.JSON(http.StatusCreated, apiKeyCreatedResponse{APIKey: key, Key: secret})
+	}
+}
+
+// revokeKeyHandler handles DELETE /api/keys/:id
+// Keys are kept, marked revoked, so the audit log can still name them.
+func revokeKeyHandler(keys *auth.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, err := keys.Revoke(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, key)
+	}
+}
+
+// auditLogHandler handles GET /api/audit?key_id=...&limit=100
+func auditLogHandler(keys *auth.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+		if err != nil || limit < 1 || limit > 1000 {
+			c.Error(apperrors.ErrInvalidRequest.WithMessage("limit must be between 1 and 1000"))
+			return
+		}
+
+		entries, err := keys.Audit(c.Request.Context(), c.Query("key_id"), limit)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, entries)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"coupon-system/internal/auth"
+	"coupon-system/internal/auth/authtest"
+	"coupon-system/internal/metrics"
+	"coupon-system/internal/model"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// TestRouteRolesAreEnforced checks every documented route refuses requests
+// without a key and keys whose role it does not list
+func TestRouteRolesAreEnforced(t *testing.T) {
+	registry := metrics.NewRegistry()
+	keys := auth.NewManager(authtest.NewRepository(), auth.Options{}, registry)
+	router := newAuthRouter(t, keys, registry)
+
+	secrets := make(map[model.APIKeyRole]string)
+	for _, role := range []model.APIKeyRole{model.RoleSupport, model.RoleReadOnly, model.RoleClaimer} {
+		_, secret, err := keys.Create(context.Background(), &model.CreateAPIKeyRequest{Name: string(role), Role: role})
+		if err != nil {
+			t.Fatal(err)
+		}
+		secrets[role] = secret
+	}
+
+	for _, r := range router.Routes() {
+		rd, ok := routeDocs[r.Method+" "+r.Path]
+		if !ok || rd.Public {
+			continue
+		}
+		path := strings.ReplaceAll(r.Path, ":", "")
+
+		if rec := serveWithKey(router, r.Method, path, ""); rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s without a key = %d, want 401", r.Method, r.Path, rec.Code)
+		}
+		for role, secret := range secrets {
+			if (&model.APIKey{Role: role}).Allows(rd.Roles...) {
+				continue // Allowed roles reach handlers without dependencies
+			}
+			if rec := serveWithKey(router, r.Method, path, secret); rec.Code != http.StatusForbidden {
+				t.Errorf("%s %s with a %s key = %d, want 403", r.Method, r.Path, role, rec.Code)
+			}
+		}
+	}
+}
+
+// TestKeyLifecycle issues, uses, rotates and revokes a key through the API and reads the audit log
+func TestKeyLifecycle(t *testing.T) {
+	registry := metrics.NewRegistry()
+	keys := auth.NewManager(authtest.NewRepository(), auth.Options{AuditInterval: time.Hour}, registry)
+	admin := "test-admin-key-0123456789"
+	if err := keys.Bootstrap(context.Background(), admin); err != nil {
+		t.Fatal(err)
+	}
+	keys.Start()
+	router := newAuthRouter(t, keys, registry)
+
+	create := func(secret, body string) (int, apiKeyCreatedResponse) {
+		req := httptest.NewRequest(http.MethodPost, "/api/keys", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(apiKeyHeader, secret)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		var created apiKeyCreatedResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &created)
+		return rec.Code, created
+	}
+
+	code, support := create(admin, `{"name": "helpdesk", "role": "support"}`)
+	if code != http.StatusCreated || support.APIKey == nil || !strings.HasPrefix(support.Key, "csk_") {
+		t.Fatalf("create = %d %+v", code, support)
+	}
+	if code, _ := create(support.Key, `{"name": "escalate", "role": "admin"}`); code != http.StatusForbidden {
+		t.Errorf("support creating a key = %d, want 403", code)
+	}
+	if code, _ := create(admin, `{"name": "bad", "role": "owner"}`); code != http.StatusBadRequest {
+		t.Errorf("create with unknown role = %d, want 400", code)
+	}
+
+	// Rotating keeps the old key working for the grace period
+	rec := serveWithKey(router, http.MethodPost, "/api/keys/"+support.ID.Hex()+"/rotate", admin)
+	var rotated apiKeyCreatedResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &rotated); rec.Code != http.StatusCreated || err != nil {
+		t.Fatalf("rotate = %d %s", rec.Code, rec.Body)
+	}
+	for _, secret := range []string{support.Key, rotated.Key} {
+		if rec := serveWithKey(router, http.MethodGet, "/api/keys/"+rotated.ID.Hex(), secret); rec.Code != http.StatusForbidden {
+			t.Errorf("support key reading keys = %d, want 403", rec.Code)
+		}
+	}
+
+	// Revocation applies to the next request
+	if rec := serveWithKey(router, http.MethodDelete, "/api/keys/"+rotated.ID.Hex(), admin); rec.Code != http.StatusOK {
+		t.Fatalf("revoke = %d %s", rec.Code, rec.Body)
+	}
+	rec = serveWithKey(router, http.MethodGet, "/api/keys/"+rotated.ID.Hex(), rotated.Key)
+	if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "revoked") {
+		t.Errorf("revoked key = %d %s, want 401", rec.Code, rec.Body)
+	}
+
+	if err := keys.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	rec = serveWithKey(router, http.MethodGet, "/api/audit?key_id="+rotated.ID.Hex(), admin)
+	var entries []model.APIKeyAuditEntry
+	if err := json.Unmarshal(rec.Body.Bytes(), &entries); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("audit = %d %s", rec.Code, rec.Body)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("audit of rotated key = %+v, want 2 entries", entries)
+	}
+	latest := entries[0]
+	if latest.Outcome != model.AuditUnauthenticated || latest.Status != http.StatusUnauthorized || latest.Route != "/api/keys/:id" || latest.KeyName != "helpdesk" {
+		t.Errorf("latest entry = %+v", latest)
+	}
+	if entries[1].Outcome != model.AuditForbidden || entries[1].Status != http.StatusForbidden {
+		t.Errorf("first entry = %+v", entries[1])
+	}
+}
+
+// TestStreamTokens checks a token from POST /api/stream-tokens opens the
+// WebSocket with its key's role and is refused everywhere else
+func TestStreamTokens(t *testing.T) {
+	registry := metrics.NewRegistry()
+	keys := auth.NewManager(authtest.NewRepository(), auth.Options{}, registry)
+	router := newAuthRouter(t, keys, registry)
+
+	issue := func(role model.APIKeyRole) string {
+		t.Helper()
+		_, secret, err := keys.Create(context.Background(), &model.CreateAPIKeyRequest{Name: string(role), Role: role})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := serveWithKey(router, http.MethodPost, "/api/stream-tokens", secret)
+		var token model.StreamToken
+		_ = json.Unmarshal(rec.Body.Bytes(), &token)
+		if rec.Code != http.StatusCreated || token.Token == "" || !token.ExpiresAt.After(time.Now()) {
+			t.Fatalf("issue %s token = %d %s", role, rec.Code, rec.Body)
+		}
+		return token.Token
+	}
+	claimer := issue(model.RoleClaimer)
+	readOnly := issue(model.RoleReadOnly)
+
+	// The role still applies, so only the claimer's token opens the socket
+	server := httptest.NewServer(router)
+	defer server.Close()
+	for _, tt := range []struct {
+		token string
+		want  bool
+	}{
+		{claimer, true},
+		{readOnly, false},
+		{claimer + "0", false},
+	} {
+		ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/ws?token="+tt.token, "", server.URL)
+		if err == nil {
+			ws.Close()
+		}
+		if connected := err == nil; connected != tt.want {
+			t.Errorf("token %.12s...: connected = %v, want %v (%v)", tt.token, connected, tt.want, err)
+		}
+	}
+
+	if rec := serveWithKey(router, http.MethodGet, "/api/coupons/FLASH?token="+claimer, ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("token on another route = %d, want 401", rec.Code)
+	}
+	if rec := serveWithKey(router, http.MethodGet, "/api/ws", claimer); rec.Code != http.StatusUnauthorized {
+		t.Errorf("token as an API key = %d, want 401", rec.Code)
+	}
+}
+
+func serveWithKey(router *gin.Engine, method, path, secret string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if secret != "" {
+		req.Header.Set(apiKeyHeader, secret)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"coupon-system/internal/faults"
+	apperrors "coupon-system/pkg/errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerFaultRoutes exposes the fault injector under /debug/faults
+// Only registered when FAULT_INJECTION_ENABLED=true; never enable it in production.
+//
+//	GET    /debug/faults          active faults and the methods they can target
+//	PUT    /debug/faults/:method  set a fault, e.g. {"error": "network", "probability": 0.1}
+//	DELETE /debug/faults/:method  clear one fault
+//	DELETE /debug/faults          clear all faults
+func registerFaultRoutes(router *gin.Engine, injector *faults.Injector, allow gin.HandlerFunc) {
+	debug := router.Group("/debug/faults", allow)
+
+	debug.GET("", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"faults":  injector.Faults(),
+			"methods": injector.Methods(),
+		})
+	})
+
+	debug.PUT("/:method", func(c *gin.Context) {
+		var fault faults.Fault
+		if err := c.ShouldBindJSON(&fault); err != nil {
+			c.Error(bindError(err))
+			return
+		}
+		if err := injector.Set(c.Param("method"), fault); err != nil {
+			c.Error(apperrors.ErrInvalidRequest.WithMessage(err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, fault)
+	})
+
+	debug.DELETE("/:method", func(c *gin.Context) {
+		injector.Clear(c.Param("method"))
+		c.Status(http.StatusNoContent)
+	})
+
+	debug.DELETE("", func(c *gin.Context) {
+		injector.ClearAll()
+		c.Status(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"coupon-system/internal/cache"
+	apperrors "coupon-system/pkg/errors"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// idempotencyHeader carries the client's key for safely retrying a request
+const idempotencyHeader = "Idempotency-Key"
+
+// idempotencyRecord is the stored outcome of a request
+// While the first request is still running only RequestHash is set
+type idempotencyRecord struct {
+	RequestHash string `json:"request_hash"`
+	Done        bool   `json:"done"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// recordingWriter keeps a copy of the response body
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// idempotency replays the stored response when a request is retried with the
+// same Idempotency-Key, so a client can safely retry claims and cancellations
+// after a timeout. Keys are scoped to the caller and the route; reusing a key
+// with a different path or body is rejected with 422, and a retry while the first request is still
+// running gets 409. The in-progress marker expires after lockTTL so a crashed
+// request does not block its key for the whole ttl, which only applies to the
+// stored response. Server errors are not stored so the request can be retried.
+// Requests without the header are not affected.
+func idempotency(store cache.Cache, ttl, lockTTL time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyHeader)
+		if key == "" || store == nil {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.Error(apperrors.ErrInvalidRequest)
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha256.Sum256(append([]byte(c.Request.URL.Path+"\n"), body...))
+		requestHash := hex.EncodeToString(sum[:])
+		// Scoped to the caller, so one client cannot replay another's response by guessing its key
+		cacheKey := "idempotency:" + callerID(c) + ":" + c.Request.Method + ":" + c.FullPath() + ":" + key
+
+		ctx := c.Request.Context()
+		pending, _ := json.Marshal(idempotencyRecord{RequestHash: requestHash})
+		stored, err := store.SetNX(ctx, cacheKey, pending, lockTTL)
+		if err != nil {
+			// Without the cache the request is processed as if no key was sent
+			log.Printf("Idempotency: cache unavailable: %v", err)
+			c.Next()
+			return
+		}
+
+		if !stored {
+			replayIdempotent(c, store, cacheKey, requestHash)
+			return
+		}
+
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		renderProblem(c)
+
+		if writer.Status() >= http.StatusInternalServerError {
+			if err := store.Delete(ctx, cacheKey); err != nil {
+				log.Printf("Idempotency: failed to release key: %v", err)
+			}
+			return
+		}
+
+		record, _ := json.Marshal(idempotencyRecord{
+			RequestHash: requestHash,
+			Done:        true,
+			Status:      writer.Status(),
+			ContentType: writer.Header().Get("Content-Type"),
+			Body:        writer.body.Bytes(),
+		})
+		if err := store.Set(ctx, cacheKey, record, ttl); err != nil {
+			log.Printf("Idempotency: failed to store response: %v", err)
+		}
+	}
+}
+
+// replayIdempotent answers a retried request from its stored record
+func replayIdempotent(c *gin.Context, store cache.Cache, cacheKey, requestHash string) {
+	data, found, err := store.Get(c.Request.Context(), cacheKey)
+	var record idempotencyRecord
+	if err != nil || !found || json.Unmarshal(data, &record) != nil {
+		c.Error(apperrors.ErrRequestInProgress)
+		c.Abort()
+		return
+	}
+
+	switch {
+	case record.RequestHash != requestHash:
+		c.Error(apperrors.ErrIdempotencyKeyUsed)
+		c.Abort()
+	case !record.Done:
+		c.Error(apperrors.ErrRequestInProgress)
+		c.Abort()
+	default:
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(record.Status, record.ContentType, record.Body)
+		c.Abort()
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"coupon-system/internal/cache"
+	apperrors "coupon-system/pkg/errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// idempotentClaim sends a claim with an Idempotency-Key as the given caller
+func idempotentClaim(router *gin.Engine, apiKey, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/claim", strings.NewReader(`{}`))
+	req.Header.Set(idempotencyHeader, key)
+	req.Header.Set(apiKeyHeader, apiKey)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+// TestIdempotencyKeysAreScopedToCaller checks one caller's key does not replay another's response
+func TestIdempotencyKeysAreScopedToCaller(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(problemDetails())
+	var calls atomic.Int32
+	router.POST("/claim", idempotency(cache.NewMemory(), time.Minute, time.Minute), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"call": calls.Add(1)})
+	})
+
+	first := idempotentClaim(router, "secret-a", "k1")
+	other := idempotentClaim(router, "secret-b", "k1")
+	retry := idempotentClaim(router, "secret-a", "k1")
+
+	if calls.Load() != 2 {
+		t.Errorf("handler ran %d times, want once per caller", calls.Load())
+	}
+	if other.Header().Get("Idempotent-Replayed") != "" || other.Body.String() == first.Body.String() {
+		t.Errorf("another caller got the first caller's response: %s", other.Body.String())
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" || retry.Body.String() != first.Body.String() {
+		t.Errorf("retry = %s, want the replayed %s", retry.Body.String(), first.Body.String())
+	}
+}
+
+// TestIdempotencyLockExpires checks a request that never finishes holds its key only for the lock TTL
+func TestIdempotencyLockExpires(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(problemDetails())
+	stuck := make(chan struct{})
+	defer close(stuck)
+	var calls atomic.Int32
+	router.POST("/claim", idempotency(cache.NewMemory(), time.Minute, 30*time.Millisecond), func(c *gin.Context) {
+		if calls.Add(1) == 1 {
+			<-stuck
+		}
+		c.Status(http.StatusOK)
+	})
+
+	go idempotentClaim(router, "secret", "k1")
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if rec := idempotentClaim(router, "secret", "k1"); rec.Code != http.StatusConflict {
+		t.Errorf("retry while running = %d, want 409", rec.Code)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if rec := idempotentClaim(router, "secret", "k1"); rec.Code != http.StatusOK || calls.Load() != 2 {
+		t.Errorf("retry after the lock TTL = %d after %d calls, want processed", rec.Code, calls.Load())
+	}
+}
+
+// TestIdempotentDeleteReplaysSuccess checks a retried delete gets its original
+// 204 rather than the 404 of deleting again, and a key cannot be reused for
+// another path
+func TestIdempotentDeleteReplaysSuccess(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(problemDetails())
+	deleted := map[string]bool{}
+	router.DELETE("/claims/:user_id", idempotency(cache.NewMemory(), time.Minute, time.Minute), func(c *gin.Context) {
+		if deleted[c.Param("user_id")] {
+			c.Error(apperrors.ErrClaimNotFound)
+			return
+		}
+		deleted[c.Param("user_id")] = true
+		c.Status(http.StatusNoContent)
+	})
+
+	send := func(path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, path, nil)
+		req.Header.Set(idempotencyHeader, key)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := send("/claims/u1", "k1"); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete = %d, want 204", rec.Code)
+	}
+	if rec := send("/claims/u1", "k1"); rec.Code != http.StatusNoContent || rec.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("retried delete = %d, want the replayed 204", rec.Code)
+	}
+	if rec := send("/claims/u2", "k1"); rec.Code != http.StatusUnprocessableEntity || deleted["u2"] {
+		t.Errorf("key reused for another path = %d, want 422 without deleting", rec.Code)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"coupon-system/internal/jobs"
+	"coupon-system/internal/model"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// createJobHandler handles POST /api/jobs
+func createJobHandler(manager *jobs.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.CreateJobRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(bindError(err))
+			return
+		}
+
+		job, err := manager.Enqueue(c.Request.Context(), &req)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.Header("Location", "/api/jobs/"+job.ID.Hex())
+		c.JSON(http.StatusAccepted, job)
+	}
+}
+
+// getJobHandler handles GET /api/jobs/:id
+func getJobHandler(manager *jobs.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, err := manager.Get(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+// cancelJobHandler handles POST /api/jobs/:id/cancel
+func cancelJobHandler(manager *jobs.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, err := manager.Cancel(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusAccepted, job)
+	}
+}
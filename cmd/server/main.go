@@ -2,12 +2,27 @@ package main
 
 import (
 	"context"
+	"coupon-system/internal/auth"
+	"coupon-system/internal/cache"
+	"coupon-system/internal/events"
+	"coupon-system/internal/faults"
+	"coupon-system/internal/grpcapi"
+	"coupon-system/internal/jobs"
+	"coupon-system/internal/live"
+	"coupon-system/internal/metrics"
 	"coupon-system/internal/model"
+	"coupon-system/internal/outbox"
+	"coupon-system/internal/queue"
+	"coupon-system/internal/ratelimit"
 	"coupon-system/internal/repository"
 	"coupon-system/internal/service"
+	"coupon-system/internal/stock"
+	"coupon-system/internal/webhooks"
 	"coupon-system/pkg/config"
 	"coupon-system/pkg/database"
+	apperrors "coupon-system/pkg/errors"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -22,6 +37,7 @@ func main() {
 	mongoURI := config.GetEnv("MONGO_URI", "mongodb://localhost:27017")
 	dbName := config.GetEnv("MONGO_DB", "coupon_system")
 	port := config.GetEnv("PORT", "8080")
+	grpcPort := config.GetEnv("GRPC_PORT", "9090")
 
 	// Connect to MongoDB
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -39,21 +55,211 @@ func main() {
 
 	log.Println("✅ Connected to MongoDB successfully")
 
+	// Transactions and change streams need a replica set or sharded cluster
+	replicaSet := mongoDB.SupportsTransactions(ctx)
+
 	// Initialize repositories
+	// Every repository's reads are retried on transient errors, and all calls
+	// fail fast through a circuit breaker while MongoDB is unhealthy
+	registry := metrics.NewRegistry()
+	dbGuard := newDatabaseGuard(registry)
 	couponRepo := repository.NewCouponRepository(mongoDB.Database)
+	couponRepo = repository.NewShardedCouponRepository(mongoDB.Database, couponRepo, int32(config.GetEnvInt("STOCK_SHARDS", 0)))
 	claimRepo := repository.NewClaimRepository(mongoDB.Database)
 
+	// Fault injection for failure testing, controlled through /debug/faults
+	var injector *faults.Injector
+	if config.GetEnv("FAULT_INJECTION_ENABLED", "false") == "true" {
+		injector = faults.NewInjector()
+		couponRepo = repository.NewFaultyCouponRepository(couponRepo, injector)
+		claimRepo = repository.NewFaultyClaimRepository(claimRepo, injector)
+		log.Println("⚠️  Fault injection enabled at /debug/faults")
+	}
+
+	couponRepo = repository.NewResilientCouponRepository(couponRepo, dbGuard)
+	claimRepo = repository.NewResilientClaimRepository(claimRepo, dbGuard)
+
+	// Live stock streams read past the lookup cache so clients see changes made
+	// by other instances immediately
+	uncachedCoupons := couponRepo
+
+	// Shared cache for coupon lookups, known claims and idempotency records
+	// Use CACHE_BACKEND=redis when running more than one instance
+	sharedCache := newSharedCache()
+	defer sharedCache.Close()
+
+	// Coupon lookups on the claim and details paths are served from memory
+	couponRepo = repository.NewCachedCouponRepository(couponRepo, repository.CacheOptions{
+		TTL:         config.GetEnvDuration("COUPON_CACHE_TTL", time.Second),
+		NegativeTTL: config.GetEnvDuration("COUPON_CACHE_NEGATIVE_TTL", time.Second),
+		MaxEntries:  config.GetEnvInt("COUPON_CACHE_MAX_ENTRIES", 10000),
+	}, registry)
+
+	waitlistRepo := repository.NewResilientWaitlistRepository(repository.NewWaitlistRepository(mongoDB.Database), dbGuard)
+
+	var opts []service.Option
+
+	// Domain events are delivered in-process; notifications are logged for now
+	bus := events.NewBus()
+	bus.Subscribe(func(e events.Event) {
+		log.Printf("📣 %s: coupon=%s user=%s", e.Type, e.CouponName, e.UserID)
+	})
+
+	// Partner webhooks: events from the outbox become signed HTTP deliveries,
+	// retried with backoff and dead-lettered when out of attempts
+	webhookManager := webhooks.NewManager(repository.NewResilientWebhookRepository(repository.NewWebhookRepository(mongoDB.Database), dbGuard), webhooks.Options{
+		Workers:     config.GetEnvInt("WEBHOOK_WORKERS", 4),
+		Timeout:     config.GetEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		MaxAttempts: config.GetEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		BaseBackoff: config.GetEnvDuration("WEBHOOK_BASE_BACKOFF", 10*time.Second),
+		MaxBackoff:  config.GetEnvDuration("WEBHOOK_MAX_BACKOFF", time.Hour),
+	}, registry)
+	webhookManager.Start()
+
+	// Transactional outbox: events are stored with the change that caused them
+	// and a relay publishes them to the bus and webhooks in order, at least once
+	var relay *outbox.Relay
+	if config.GetEnv("OUTBOX_ENABLED", "true") == "true" {
+		outboxRepo := repository.NewResilientOutboxRepository(repository.NewOutboxRepository(mongoDB.Database), dbGuard)
+		if !replicaSet {
+			log.Println("⚠️  MongoDB is standalone: outbox events are written without transactions and claims' events are recovered by the relay")
+		}
+		opts = append(opts, service.WithOutbox(outboxRepo, repository.NewTransactor(mongoDB.Client, replicaSet)))
+		relay = outbox.NewRelay(outboxRepo, []outbox.Sink{outbox.PublisherSink("bus", bus), webhookManager}, outbox.Options{
+			PollInterval: config.GetEnvDuration("OUTBOX_POLL_INTERVAL", 100*time.Millisecond),
+			BatchSize:    config.GetEnvInt("OUTBOX_BATCH_SIZE", 100),
+			LeaseTTL:     config.GetEnvDuration("OUTBOX_LEASE_TTL", 10*time.Second),
+			MaxBackoff:   config.GetEnvDuration("OUTBOX_MAX_BACKOFF", time.Minute),
+			RecoverAfter: config.GetEnvDuration("OUTBOX_RECOVER_AFTER", 10*time.Second),
+		}, registry)
+	} else {
+		log.Println("⚠️  Outbox disabled: webhooks will not receive events")
+	}
+
+	// Live stock updates: one watcher per coupon fans out to all its stream clients
+	streamHub := live.NewHub(uncachedCoupons.GetCouponByName, live.Options{
+		Coalesce:   config.GetEnvDuration("STREAM_COALESCE", 250*time.Millisecond),
+		Resync:     config.GetEnvDuration("STREAM_RESYNC", 5*time.Second),
+		MaxClients: config.GetEnvInt("STREAM_MAX_CLIENTS", 10000),
+	}, registry)
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	switch source := config.GetEnv("STREAM_SOURCE", "auto"); {
+	case source == "changestream" || (source == "auto" && replicaSet):
+		go live.WatchChangeStream(watchCtx, mongoDB.Database, streamHub)
+		log.Println("📡 Live stock updates fed by MongoDB change streams")
+	default:
+		// Only sees events relayed by this instance; the resync covers the rest
+		bus.Subscribe(func(e events.Event) { streamHub.Notify(e.CouponName) })
+		log.Println("📡 Live stock updates fed by in-process events")
+	}
+
+	// Virtual queue for flash sales: bounds the claim rate per queue-enabled coupon
+	admission := queue.NewManager(queue.Options{
+		AdmitRate: float64(config.GetEnvInt("QUEUE_ADMIT_RATE", 50)),
+		AdmitTTL:  config.GetEnvDuration("QUEUE_ADMIT_TTL", time.Minute),
+		IdleTTL:   config.GetEnvDuration("QUEUE_IDLE_TTL", 30*time.Second),
+		Instance:  config.GetEnv("QUEUE_INSTANCE_ID", ""),
+	})
+	admission.Start()
+	defer admission.Stop()
+
 	// Initialize service (no transaction dependency - uses atomic upsert pattern)
-	svc := service.NewCouponService(couponRepo, claimRepo)
+	opts = append(opts,
+		service.WithEventPublisher(bus),
+		service.WithWaitlist(waitlistRepo),
+		service.WithRaffles(repository.NewResilientRaffleRepository(repository.NewRaffleRepository(mongoDB.Database), dbGuard)),
+		service.WithAdmission(admission),
+		service.WithCache(sharedCache,
+			config.GetEnvDuration("SHARED_COUPON_CACHE_TTL", 5*time.Second),
+			config.GetEnvDuration("CLAIMED_CACHE_TTL", 24*time.Hour)),
+	)
+
+	// Optional stock pre-allocation: claims are served from leased blocks of stock
+	// and written in batches. Expired leases of crashed instances are reclaimed here too
+	var allocator *stock.Allocator
+	if leaseSize := config.GetEnvInt("STOCK_LEASE_SIZE", 0); leaseSize > 0 {
+		allocator = stock.NewAllocator(couponRepo, claimRepo, repository.NewResilientLeaseRepository(repository.NewLeaseRepository(mongoDB.Database), dbGuard), stock.Options{
+			LeaseSize:     int32(leaseSize),
+			LeaseTTL:      config.GetEnvDuration("STOCK_LEASE_TTL", 30*time.Second),
+			FlushInterval: config.GetEnvDuration("CLAIM_FLUSH_INTERVAL", 5*time.Millisecond),
+			MaxBatch:      config.GetEnvInt("CLAIM_BATCH_SIZE", 500),
+			EventPending:  relay != nil,
+		})
+		opts = append(opts, service.WithStockAllocator(allocator))
+		log.Printf("📦 Stock pre-allocation enabled (lease size %d)", leaseSize)
+	}
+
+	svc := service.NewCouponService(couponRepo, claimRepo, opts...)
+	if allocator != nil {
+		// Units coming back from leases go to waiting users first
+		allocator.OnStockReturned(svc.StockReturned)
+		allocator.Start()
+	}
+	// Stock returned outside a request, such as a crashed instance's leases,
+	// reaches waiting users within one sweep
+	sweepCtx, stopSweep := context.WithCancel(context.Background())
+	defer stopSweep()
+	go svc.RunWaitlistSweep(sweepCtx, config.GetEnvDuration("WAITLIST_SWEEP_INTERVAL", 30*time.Second))
+	if relay != nil {
+		// Claims written without a transaction, or by the group commit, carry
+		// their pending event until it is stored
+		relay.AddRecoverer(svc)
+		relay.Start()
+	}
+
+	// Initialize background jobs (resumes jobs interrupted by a crash or restart)
+	jobManager := jobs.NewManager(repository.NewResilientJobRepository(repository.NewJobRepository(mongoDB.Database), dbGuard), jobs.Options{
+		Workers:       config.GetEnvInt("JOB_WORKERS", 4),
+		LeaseDuration: config.GetEnvDuration("JOB_LEASE_DURATION", 30*time.Second),
+	})
+	jobManager.Register(jobs.TypeBulkClaim, jobs.NewBulkClaimHandler(svc))
+	jobManager.Register(jobs.TypeExportClaims, jobs.NewExportClaimsHandler(svc))
+	jobManager.Start()
+
+	// API keys with roles; AUTH_ENABLED=false leaves the API open, e.g. for local load tests
+	var keys *auth.Manager
+	if config.GetEnv("AUTH_ENABLED", "true") == "true" {
+		keys = auth.NewManager(repository.NewResilientAPIKeyRepository(repository.NewAPIKeyRepository(mongoDB.Database), dbGuard), auth.Options{
+			CacheTTL:      config.GetEnvDuration("AUTH_CACHE_TTL", 30*time.Second),
+			RotationGrace: config.GetEnvDuration("AUTH_ROTATION_GRACE", 24*time.Hour),
+			TokenTTL:      config.GetEnvDuration("AUTH_TOKEN_TTL", 5*time.Minute),
+			TokenSecret:   []byte(config.GetEnv("AUTH_TOKEN_SECRET", "")),
+		}, registry)
+		if config.GetEnv("AUTH_TOKEN_SECRET", "") == "" {
+			log.Println("⚠️  AUTH_TOKEN_SECRET not set: stream tokens only work on the instance that issued them")
+		}
+		if secret := config.GetEnv("ADMIN_API_KEY", ""); secret != "" {
+			bootstrapCtx, cancelBootstrap := context.WithTimeout(context.Background(), 10*time.Second)
+			err := keys.Bootstrap(bootstrapCtx, secret)
+			cancelBootstrap()
+			if err != nil {
+				log.Fatalf("Failed to bootstrap admin API key: %v", err)
+			}
+		} else {
+			log.Println("⚠️  ADMIN_API_KEY not set: only existing API keys can call the API")
+		}
+		keys.Start()
+	} else {
+		log.Println("⚠️  Authentication disabled: the API is open to anyone")
+	}
+
+	// Concurrency and claim rate limits, shared by the HTTP and gRPC APIs
+	limits := newAPILimits(registry, sharedCache)
 
 	// Setup Gin router
-	router := setupRouter(svc)
+	router := setupRouter(svc, jobManager, webhookManager, streamHub, keys, registry, sharedCache, limits)
+	if injector != nil {
+		registerFaultRoutes(router, injector, newAuthorizer(keys).allow(adminOnly))
+	}
 
 	// Create HTTP server
 	srv := &http.Server{
 		Addr:    ":" + port,
 		Handler: router,
 	}
+	// Open streams never finish on their own; end them so Shutdown does not wait
+	srv.RegisterOnShutdown(streamHub.Close)
 
 	// Start server in a goroutine
 	go func() {
@@ -63,6 +269,22 @@ func main() {
 		}
 	}()
 
+	// gRPC API for internal services, on its own port
+	grpcServer := grpcapi.NewServer(svc, streamHub, registry, grpcapi.WithAuth(keys),
+		grpcapi.WithClaimLimits(limits.overload, limits.grpcClaimLimit))
+	if config.GetEnv("GRPC_ENABLED", "true") == "true" {
+		lis, err := net.Listen("tcp", ":"+grpcPort)
+		if err != nil {
+			log.Fatalf("Failed to listen for gRPC: %v", err)
+		}
+		go func() {
+			log.Printf("🚀 gRPC server starting on port %s", grpcPort)
+			if err := grpcServer.Serve(lis); err != nil {
+				log.Fatalf("Failed to start gRPC server: %v", err)
+			}
+		}()
+	}
+
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -73,31 +295,184 @@ func main() {
 	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		// Carry on: leased stock, events and audit entries still need to be saved
+		log.Printf("Server forced to shutdown: %v", err)
+	}
+	// WatchCoupon streams have ended with the hub; force the rest at the deadline
+	grpcStopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(grpcStopped)
+	}()
+	select {
+	case <-grpcStopped:
+	case <-ctx.Done():
+		grpcServer.Stop()
+	}
+	// Background work gets its own deadline, so requests that used up the one
+	// above do not cost jobs, events or audit entries
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer stopCancel()
+	if err := jobManager.Stop(stopCtx); err != nil {
+		log.Printf("Error stopping job manager: %v", err)
+	}
+	if allocator != nil {
+		// Commits buffered claims and returns unused leased stock. It gets its
+		// own deadline: stock left out when the HTTP deadline is spent would
+		// stay unsellable until another instance reclaims the lease.
+		allocCtx, allocCancel := context.WithTimeout(context.Background(),
+			config.GetEnvDuration("STOCK_SHUTDOWN_TIMEOUT", 10*time.Second))
+		if err := allocator.Close(allocCtx); err != nil {
+			log.Printf("Error returning leased stock: %v", err)
+		}
+		allocCancel()
+	}
+	if relay != nil {
+		if err := relay.Stop(stopCtx); err != nil {
+			log.Printf("Error stopping outbox relay: %v", err)
+		}
+	}
+	if err := webhookManager.Stop(stopCtx); err != nil {
+		log.Printf("Error stopping webhook deliveries: %v", err)
+	}
+	if keys != nil {
+		// Writes the audit entries still buffered
+		if err := keys.Stop(stopCtx); err != nil {
+			log.Printf("Error flushing API key audit log: %v", err)
+		}
 	}
 
 	log.Println("Server exited")
 }
 
-func setupRouter(svc *service.CouponService) *gin.Engine {
+// newSharedCache builds the cache selected by CACHE_BACKEND (memory or redis)
+func newSharedCache() cache.Cache {
+	if config.GetEnv("CACHE_BACKEND", "memory") != "redis" {
+		return cache.NewMemory()
+	}
+
+	redis := cache.NewRESP(cache.RESPOptions{
+		Addr:     config.GetEnv("REDIS_ADDR", "localhost:6379"),
+		Password: config.GetEnv("REDIS_PASSWORD", ""),
+		DB:       config.GetEnvInt("REDIS_DB", 0),
+		PoolSize: config.GetEnvInt("REDIS_POOL_SIZE", 16),
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := redis.Ping(ctx); err != nil {
+		log.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	log.Println("✅ Connected to Redis successfully")
+	return redis
+}
+
+// setupRouter builds the HTTP router
+// keys is nil when authentication is disabled, which leaves every route open.
+func setupRouter(svc *service.CouponService, jobManager *jobs.Manager, webhookManager *webhooks.Manager, streamHub *live.Hub, keys *auth.Manager, registry *metrics.Registry, sharedCache cache.Cache, limits *apiLimits) *gin.Engine {
 	// Set Gin mode
 	if os.Getenv("GIN_MODE") == "" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
 	router := gin.Default()
+	registerValidators()
+
+	// Client IPs for rate limits and the audit log are read from
+	// X-Forwarded-For only when the request comes through a trusted proxy
+	if err := router.SetTrustedProxies(config.GetEnvList("TRUSTED_PROXIES")); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+
+	// Errors recorded with c.Error are rendered as problem+json
+	router.Use(problemDetails())
+
+	// Adaptive concurrency limit in front of the API
+	overloadLimiter := limits.overload
 
 	// Health check endpoint
+	// Degraded (still 200, the process is alive) while requests are being shed
 	router.GET("/health", func(c *gin.Context) {
+		stats := overloadLimiter.Stats()
+		if stats.Degraded {
+			c.JSON(http.StatusOK, gin.H{"status": "degraded", "overload": stats})
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
 
-	// API routes
-	api := router.Group("/api")
+	// Prometheus metrics
+	router.GET("/metrics", gin.WrapH(registry.Handler()))
+
+	// OpenAPI document and Swagger UI
+	registerDocsRoutes(router)
+
+	// API keys: every API route names the roles it accepts, and requests are audited
+	authz := newAuthorizer(keys)
+
+	// Retried POSTs with the same Idempotency-Key get the original response
+	idem := idempotency(sharedCache, config.GetEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+		config.GetEnvDuration("IDEMPOTENCY_LOCK_TTL", 30*time.Second))
+
+	// Token-bucket rate limits per route, keyed by client IP, API key and user_id
+	limiter := limits.rate
+	claimRoute := limits.claim
+	claimLimit := claimRoute.middleware()
+	bulkClaimLimit := limiter.limit("bulk_claim", loadRouteLimits("bulk_claim", routeLimits{
+		IP:     ratelimit.Limit{Rate: 1, Burst: 10},
+		APIKey: ratelimit.Limit{Rate: 1, Burst: 10},
+	}))
+	queueRoute := limiter.route("queue", loadRouteLimits("queue", routeLimits{
+		IP:   ratelimit.Limit{Rate: 100, Burst: 200},
+		User: ratelimit.Limit{Rate: 1, Burst: 10},
+	}))
+	queueLimit := queueRoute.middleware()
+
+	// Live stock stream and WebSocket channel; long-lived, so they bypass the
+	// concurrency limit below (WebSocket claims still take a slot each)
+	heartbeat := config.GetEnvDuration("STREAM_HEARTBEAT", 15*time.Second)
+	router.GET("/api/coupons/:name/stream", authz.allowToken(anyRole), streamCouponHandler(streamHub, heartbeat))
+	router.GET("/api/ws", authz.allowToken(claimers), claimSocketHandler(socketDeps{
+		svc:        svc,
+		hub:        streamHub,
+		claimLimit: claimRoute,
+		queueLimit: queueRoute,
+		overload:   overloadLimiter,
+		heartbeat:  heartbeat,
+		origins:    config.GetEnvList("WS_ALLOWED_ORIGINS"),
+	}))
+
+	// API routes; each names the key roles it accepts (admins are always allowed)
+	api := router.Group("/api", shedLoad(overloadLimiter, registry))
 	{
-		api.POST("/coupons", createCouponHandler(svc))
-		api.POST("/coupons/claim", claimCouponHandler(svc))
-		api.GET("/coupons/:name", getCouponDetailsHandler(svc))
+		api.GET("/coupons", authz.allow(readers), listCouponsHandler(svc))
+		api.POST("/coupons", authz.allow(adminOnly), idem, createCouponHandler(svc))
+		api.POST("/coupons/claim", authz.allow(claimers), claimLimit, idem, claimCouponHandler(svc))
+		api.POST("/coupons/claim/bulk", authz.allow(supportOnly), bulkClaimLimit, idem, bulkClaimHandler(svc))
+		api.GET("/coupons/:name", authz.allow(readers), getCouponDetailsHandler(svc))
+		api.GET("/coupons/:name/claims", authz.allow(readers), listClaimsHandler(svc))
+		api.DELETE("/coupons/:name/claims/:user_id", authz.allow(supportOnly), idem, cancelClaimHandler(svc))
+		api.POST("/coupons/:name/waitlist", authz.allow(claimers), joinWaitlistHandler(svc))
+		api.GET("/coupons/:name/waitlist/:user_id", authz.allow(anyRole), getWaitlistStatusHandler(svc))
+		api.DELETE("/coupons/:name/waitlist/:user_id", authz.allow(claimers), idem, leaveWaitlistHandler(svc))
+		api.POST("/coupons/:name/raffle/entries", authz.allow(claimers), enterRaffleHandler(svc))
+		api.POST("/coupons/:name/raffle/draw", authz.allow(adminOnly), drawRaffleHandler(svc))
+		api.GET("/coupons/:name/raffle", authz.allow(anyRole), getRaffleDrawHandler(svc))
+		api.GET("/coupons/:name/raffle/verify", authz.allow(anyRole), verifyRaffleDrawHandler(svc))
+		api.POST("/coupons/:name/queue", authz.allow(claimers), queueLimit, joinQueueHandler(svc))
+		api.GET("/queue/:token", authz.allow(anyRole), getQueueTicketHandler(svc))
+		api.POST("/coupons/:name/pause", authz.allow(supportOnly), setCouponActiveHandler(svc, false))
+		api.POST("/coupons/:name/resume", authz.allow(supportOnly), setCouponActiveHandler(svc, true))
+		api.POST("/coupons/:name/restock", authz.allow(adminOnly), idem, restockCouponHandler(svc))
+
+		api.POST("/jobs", authz.allow(supportOnly), idem, createJobHandler(jobManager))
+		api.GET("/jobs/:id", authz.allow(readers), getJobHandler(jobManager))
+		api.POST("/jobs/:id/cancel", authz.allow(supportOnly), cancelJobHandler(jobManager))
+
+		registerWebhookRoutes(api, webhookManager, authz.allow(adminOnly), idem)
+		if keys != nil {
+			registerKeyRoutes(api, keys, authz.allow(adminOnly))
+			api.POST("/stream-tokens", authz.allow(anyRole), streamTokenHandler(keys))
+		}
 	}
 
 	return router
@@ -108,18 +483,13 @@ func createCouponHandler(svc *service.CouponService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req model.CreateCouponRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+			c.Error(bindError(err))
 			return
 		}
 
 		coupon, err := svc.CreateCoupon(c.Request.Context(), &req)
 		if err != nil {
-			switch err {
-			case service.ErrCouponAlreadyExists:
-				c.JSON(http.StatusConflict, gin.H{"error": "coupon already exists"})
-			default:
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create coupon"})
-			}
+			c.Error(err)
 			return
 		}
 
@@ -128,27 +498,19 @@ func createCouponHandler(svc *service.CouponService) gin.HandlerFunc {
 }
 
 // claimCouponHandler handles POST /api/coupons/claim
-// in real use cases, I would put the claim coupon behind a cache layer to prevent duplicate claims
 func claimCouponHandler(svc *service.CouponService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req model.ClaimCouponRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+			c.Error(bindError(err))
 			return
 		}
+		if req.QueueToken == "" {
+			req.QueueToken = c.GetHeader("X-Queue-Token")
+		}
 
-		err := svc.ClaimCoupon(c.Request.Context(), &req)
-		if err != nil {
-			switch err {
-			case service.ErrAlreadyClaimed:
-				c.JSON(http.StatusConflict, gin.H{"error": "coupon already claimed by this user"})
-			case service.ErrNoStock:
-				c.JSON(http.StatusBadRequest, gin.H{"error": "no stock available"})
-			case service.ErrCouponNotFound:
-				c.JSON(http.StatusNotFound, gin.H{"error": "coupon not found"})
-			default:
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to claim coupon"})
-			}
+		if err := svc.ClaimCoupon(c.Request.Context(), &req); err != nil {
+			c.Error(err)
 			return
 		}
 
@@ -156,23 +518,38 @@ func claimCouponHandler(svc *service.CouponService) gin.HandlerFunc {
 	}
 }
 
+// bulkClaimHandler handles POST /api/coupons/claim/bulk
+// Used by customer support to grant a coupon to many users at once
+func bulkClaimHandler(svc *service.CouponService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.BulkClaimRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(bindError(err))
+			return
+		}
+
+		resp, err := svc.BulkClaim(c.Request.Context(), &req)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
 // getCouponDetailsHandler handles GET /api/coupons/:name
 func getCouponDetailsHandler(svc *service.CouponService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		name := c.Param("name")
 		if name == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "coupon name is required"})
+			c.Error(apperrors.ErrInvalidRequest.WithMessage("coupon name is required"))
 			return
 		}
 
 		details, err := svc.GetCouponDetails(c.Request.Context(), name)
 		if err != nil {
-			switch err {
-			case service.ErrCouponNotFound:
-				c.JSON(http.StatusNotFound, gin.H{"error": "coupon not found"})
-			default:
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get coupon details"})
-			}
+			c.Error(err)
 			return
 		}
 
@@ -180,3 +557,74 @@ func getCouponDetailsHandler(svc *service.CouponService) gin.HandlerFunc {
 	}
 }
 
+// listCouponsHandler handles GET /api/coupons
+func listCouponsHandler(svc *service.CouponService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		coupons, err := svc.ListCoupons(c.Request.Context())
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, coupons)
+	}
+}
+
+// listClaimsHandler handles GET /api/coupons/:name/claims
+func listClaimsHandler(svc *service.CouponService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := svc.ListClaims(c.Request.Context(), c.Param("name"))
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, claims)
+	}
+}
+
+// cancelClaimHandler handles DELETE /api/coupons/:name/claims/:user_id
+// The returned stock is granted to the next user on the waitlist, if any
+func cancelClaimHandler(svc *service.CouponService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := svc.CancelClaim(c.Request.Context(), c.Param("name"), c.Param("user_id"))
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// setCouponActiveHandler handles POST /api/coupons/:name/pause and /resume
+func setCouponActiveHandler(svc *service.CouponService, active bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		coupon, err := svc.SetCouponActive(c.Request.Context(), c.Param("name"), active)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, coupon)
+	}
+}
+
+// restockCouponHandler handles POST /api/coupons/:name/restock
+func restockCouponHandler(svc *service.CouponService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.RestockCouponRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(bindError(err))
+			return
+		}
+
+		coupon, err := svc.RestockCoupon(c.Request.Context(), c.Param("name"), req.Amount)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, coupon)
+	}
+}
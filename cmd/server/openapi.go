@@ -0,0 +1,762 @@
+package main
+
+import (
+	"coupon-system/internal/model"
+	"coupon-system/internal/validation"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// routeDoc documents one route of setupRouter
+// Request and Response are zero values of the types the handler binds and
+// writes; their schemas are generated from json and binding tags.
+type routeDoc struct {
+	Summary     string
+	Tag         string
+	Request     interface{}        // JSON body, nil if none
+	Query       []paramDoc         // Query parameters
+	Headers     []paramDoc         // Request headers besides Idempotency-Key
+	Status      int                // Success status
+	Response    interface{}        // Success body, nil if none
+	ContentType string             // Success content type when not JSON
+	Errors      []int              // Error statuses the handler writes
+	Idempotent  bool               // Accepts Idempotency-Key (adds 409 and 422)
+	RateLimited bool               // Behind a rate limit (adds 429)
+	Public      bool               // Needs no API key; other routes add 401 and 403
+	Roles       []model.APIKeyRole // Key roles allowed besides admin, as passed to authz.allow
+}
+
+// paramDoc is a query parameter or header
+type paramDoc struct {
+	Name        string
+	Description string
+	Schema      *schema
+}
+
+// streamTokenParam lets browsers authenticate routes they cannot send headers to
+var streamTokenParam = paramDoc{Name: tokenQuery, Description: "Token from POST /api/stream-tokens, instead of the X-API-Key header", Schema: &schema{Type: "string"}}
+
+// messageResponse is the body of successful actions without a resource to return
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
+// healthResponse is the body of GET /health
+type healthResponse struct {
+	Status   string      `json:"status"` // ok or degraded
+	Overload interface{} `json:"overload,omitempty"`
+}
+
+// replayDeadResponse is the body of POST /api/webhooks/:id/replay
+type replayDeadResponse struct {
+	Replayed int64 `json:"replayed"`
+}
+
+// Standard error sets
+var (
+	readErrors  = []int{http.StatusNotFound, http.StatusInternalServerError, http.StatusServiceUnavailable}
+	writeErrors = []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError, http.StatusServiceUnavailable}
+)
+
+// routeDocs documents every route registered by setupRouter, keyed by "METHOD path"
+// TestOpenAPIMatchesRouter fails when a route is added without an entry here.
+var routeDocs = map[string]routeDoc{
+	"GET /health": {
+		Summary: "Health check; degraded while requests are being shed", Tag: "system",
+		Public: true,
+		Status: http.StatusOK, Response: healthResponse{},
+	},
+	"GET /metrics": {
+		Summary: "Prometheus metrics", Tag: "system",
+		Public: true,
+		Status: http.StatusOK, ContentType: "text/plain",
+	},
+
+	"GET /api/coupons": {
+		Summary: "List coupons", Tag: "coupons",
+		Roles:  readers,
+		Status: http.StatusOK, Response: []model.Coupon{},
+		Errors: []int{http.StatusInternalServerError, http.StatusServiceUnavailable},
+	},
+	"POST /api/coupons": {
+		Summary: "Create a coupon", Tag: "coupons",
+		Roles:   adminOnly,
+		Request: model.CreateCouponRequest{}, Status: http.StatusCreated, Response: model.Coupon{},
+		Errors:     []int{http.StatusBadRequest, http.StatusConflict, http.StatusInternalServerError, http.StatusServiceUnavailable},
+		Idempotent: true,
+	},
+	"GET /api/coupons/:name": {
+		Summary: "Get a coupon and the users who claimed it", Tag: "coupons",
+		Roles:  readers,
+		Status: http.StatusOK, Response: model.CouponDetailsResponse{},
+		Errors: writeErrors,
+	},
+	"POST /api/coupons/:name/pause": {
+		Summary: "Pause a coupon; claims are rejected until it is resumed", Tag: "coupons",
+		Roles:  supportOnly,
+		Status: http.StatusOK, Response: model.Coupon{}, Errors: readErrors,
+	},
+	"POST /api/coupons/:name/resume": {
+		Summary: "Resume a paused coupon", Tag: "coupons",
+		Roles:  supportOnly,
+		Status: http.StatusOK, Response: model.Coupon{}, Errors: readErrors,
+	},
+	"POST /api/coupons/:name/restock": {
+		Summary: "Add stock to a coupon", Tag: "coupons",
+		Roles:   adminOnly,
+		Request: model.RestockCouponRequest{}, Status: http.StatusOK, Response: model.Coupon{},
+		Errors: writeErrors, Idempotent: true,
+	},
+	"GET /api/coupons/:name/stream": {
+		Summary: "Stream stock and status changes as Server-Sent Events", Tag: "coupons",
+		Roles:  anyRole,
+		Query:  []paramDoc{streamTokenParam},
+		Status: http.StatusOK, ContentType: "text/event-stream",
+		Errors: []int{http.StatusNotFound, http.StatusInternalServerError, http.StatusServiceUnavailable},
+	},
+
+	"POST /api/coupons/claim": {
+		Summary: "Claim a coupon for a user", Tag: "claims",
+		Roles:   claimers,
+		Request: model.ClaimCouponRequest{}, Status: http.StatusOK, Response: messageResponse{},
+		Headers: []paramDoc{{Name: "X-Queue-Token", Description: "Admitted queue token, instead of queue_token in the body", Schema: &schema{Type: "string"}}},
+		Errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusMisdirectedRequest,
+			http.StatusConflict, http.StatusInternalServerError, http.StatusServiceUnavailable},
+		Idempotent: true, RateLimited: true,
+	},
+	"POST /api/coupons/claim/bulk": {
+		Summary: "Grant a coupon to many users at once", Tag: "claims",
+		Roles:   supportOnly,
+		Request: model.BulkClaimRequest{}, Status: http.StatusOK, Response: model.BulkClaimResponse{},
+		Errors: writeErrors, Idempotent: true, RateLimited: true,
+	},
+	"GET /api/coupons/:name/claims": {
+		Summary: "List a coupon's claims", Tag: "claims",
+		Roles:  readers,
+		Status: http.StatusOK, Response: []model.Claim{}, Errors: readErrors,
+	},
+	"DELETE /api/coupons/:name/claims/:user_id": {
+		Summary: "Cancel a claim and return its stock", Tag: "claims",
+		Roles:  supportOnly,
+		Status: http.StatusNoContent, Errors: readErrors, Idempotent: true,
+	},
+	"GET /api/ws": {
+		Summary: "WebSocket channel for stock and queue updates and claims", Tag: "claims",
+		Roles:  claimers,
+		Query:  []paramDoc{streamTokenParam},
+		Status: http.StatusSwitchingProtocols,
+	},
+
+	"POST /api/coupons/:name/waitlist": {
+		Summary: "Join a sold-out coupon's waitlist", Tag: "waitlist",
+		Roles:   claimers,
+		Request: model.JoinWaitlistRequest{}, Status: http.StatusCreated, Response: model.WaitlistStatusResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError, http.StatusServiceUnavailable},
+	},
+	"GET /api/coupons/:name/waitlist/:user_id": {
+		Summary: "Get a user's place on a waitlist", Tag: "waitlist",
+		Roles:  anyRole,
+		Status: http.StatusOK, Response: model.WaitlistStatusResponse{}, Errors: readErrors,
+	},
+	"DELETE /api/coupons/:name/waitlist/:user_id": {
+		Summary: "Leave a waitlist", Tag: "waitlist",
+		Roles:  claimers,
+		Status: http.StatusNoContent, Errors: readErrors, Idempotent: true,
+	},
+
+	"POST /api/coupons/:name/raffle/entries": {
+		Summary: "Enter a raffle", Tag: "raffles",
+		Roles:   claimers,
+		Request: model.EnterRaffleRequest{}, Status: http.StatusCreated, Response: model.RaffleEntry{},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError, http.StatusServiceUnavailable},
+	},
+	"POST /api/coupons/:name/raffle/draw": {
+		Summary: "Draw a raffle's winners", Tag: "raffles",
+		Roles:  adminOnly,
+		Status: http.StatusOK, Response: model.RaffleDraw{},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError, http.StatusServiceUnavailable},
+	},
+	"GET /api/coupons/:name/raffle": {
+		Summary: "Get a raffle's draw", Tag: "raffles",
+		Roles:  anyRole,
+		Status: http.StatusOK, Response: model.RaffleDraw{}, Errors: writeErrors,
+	},
+	"GET /api/coupons/:name/raffle/verify": {
+		Summary: "Re-run a raffle draw from its seed", Tag: "raffles",
+		Roles:  anyRole,
+		Status: http.StatusOK, Response: model.RaffleVerification{}, Errors: writeErrors,
+	},
+
+	"POST /api/coupons/:name/queue": {
+		Summary: "Join a coupon's virtual queue", Tag: "queue",
+		Roles:   claimers,
+		Request: model.JoinQueueRequest{}, Status: http.StatusCreated, Response: model.QueueTicket{},
+		Errors: writeErrors, RateLimited: true,
+	},
+	"GET /api/queue/:token": {
+		Summary: "Poll a queue ticket", Tag: "queue",
+		Roles:  anyRole,
+		Status: http.StatusOK, Response: model.QueueTicket{},
+		Errors: []int{http.StatusNotFound, http.StatusMisdirectedRequest, http.StatusInternalServerError, http.StatusServiceUnavailable},
+	},
+
+	"POST /api/jobs": {
+		Summary: "Start a background job", Tag: "jobs",
+		Roles:   supportOnly,
+		Request: model.CreateJobRequest{}, Status: http.StatusAccepted, Response: model.Job{},
+		Errors:     []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusServiceUnavailable},
+		Idempotent: true,
+	},
+	"GET /api/jobs/:id": {
+		Summary: "Get a job's status and progress", Tag: "jobs",
+		Roles:  readers,
+		Status: http.StatusOK, Response: model.Job{}, Errors: readErrors,
+	},
+	"POST /api/jobs/:id/cancel": {
+		Summary: "Cancel a job", Tag: "jobs",
+		Roles:  supportOnly,
+		Status: http.StatusAccepted, Response: model.Job{}, Errors: readErrors,
+	},
+
+	"POST /api/webhooks": {
+		Summary: "Subscribe a URL to domain events; the signing secret is only returned here", Tag: "webhooks",
+		Roles:   adminOnly,
+		Request: model.CreateWebhookRequest{}, Status: http.StatusCreated, Response: webhookCreatedResponse{},
+		Errors:     []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusServiceUnavailable},
+		Idempotent: true,
+	},
+	"GET /api/webhooks": {
+		Summary: "List webhook subscriptions", Tag: "webhooks",
+		Roles:  adminOnly,
+		Status: http.StatusOK, Response: []model.WebhookSubscription{},
+		Errors: []int{http.StatusInternalServerError, http.StatusServiceUnavailable},
+	},
+	"GET /api/webhooks/:id": {
+		Summary: "Get a webhook subscription", Tag: "webhooks",
+		Roles:  adminOnly,
+		Status: http.StatusOK, Response: model.WebhookSubscription{}, Errors: readErrors,
+	},
+	"DELETE /api/webhooks/:id": {
+		Summary: "Delete a webhook subscription", Tag: "webhooks",
+		Roles:  adminOnly,
+		Status: http.StatusNoContent, Errors: readErrors, Idempotent: true,
+	},
+	"GET /api/webhooks/:id/deliveries": {
+		Summary: "List a subscription's deliveries, newest first", Tag: "webhooks",
+		Roles: adminOnly,
+		Query: []paramDoc{
+			{Name: "status", Description: "Only deliveries with this status", Schema: &schema{Type: "string", Enum: []interface{}{
+				model.WebhookDeliveryPending, model.WebhookDeliverySucceeded, model.WebhookDeliveryDead}}},
+			{Name: "limit", Description: "Maximum deliveries returned", Schema: &schema{Type: "integer", Minimum: ptr(1.0), Maximum: ptr(500.0), Default: 50}},
+		},
+		Status: http.StatusOK, Response: []model.WebhookDelivery{}, Errors: writeErrors,
+	},
+	"POST /api/webhooks/:id/deliveries/:delivery_id/replay": {
+		Summary: "Send a dead-lettered delivery again", Tag: "webhooks",
+		Roles:  adminOnly,
+		Status: http.StatusAccepted, Response: model.WebhookDelivery{},
+		Errors: []int{http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError, http.StatusServiceUnavailable},
+	},
+	"POST /api/webhooks/:id/replay": {
+		Summary: "Send every dead-lettered delivery of a subscription again", Tag: "webhooks",
+		Roles:  adminOnly,
+		Status: http.StatusAccepted, Response: replayDeadResponse{}, Errors: readErrors,
+	},
+
+	"POST /api/keys": {
+		Summary: "Issue an API key; the key is only returned here", Tag: "keys",
+		Roles:   adminOnly,
+		Request: model.CreateAPIKeyRequest{}, Status: http.StatusCreated, Response: apiKeyCreatedResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusServiceUnavailable},
+	},
+	"GET /api/keys": {
+		Summary: "List API keys, including revoked ones", Tag: "keys",
+		Roles:  adminOnly,
+		Status: http.StatusOK, Response: []model.APIKey{},
+		Errors: []int{http.StatusInternalServerError, http.StatusServiceUnavailable},
+	},
+	"GET /api/keys/:id": {
+		Summary: "Get an API key", Tag: "keys",
+		Roles:  adminOnly,
+		Status: http.StatusOK, Response: model.APIKey{}, Errors: readErrors,
+	},
+	"POST /api/keys/:id/rotate": {
+		Summary: "Replace an API key; the old key keeps working for a grace period", Tag: "keys",
+		Roles:   adminOnly,
+		Request: model.RotateAPIKeyRequest{}, Status: http.StatusCreated, Response: apiKeyCreatedResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError, http.StatusServiceUnavailable},
+	},
+	"POST /api/stream-tokens": {
+		Summary: "Issue a short-lived token for opening streams and the WebSocket from a browser", Tag: "keys",
+		Roles:  anyRole,
+		Status: http.StatusCreated, Response: model.StreamToken{},
+	},
+	"DELETE /api/keys/:id": {
+		Summary: "Revoke an API key immediately", Tag: "keys",
+		Roles:  adminOnly,
+		Status: http.StatusOK, Response: model.APIKey{}, Errors: readErrors,
+	},
+	"GET /api/audit": {
+		Summary: "List requests made with API keys, newest first", Tag: "keys",
+		Roles: adminOnly,
+		Query: []paramDoc{
+			{Name: "key_id", Description: "Only requests made with this key", Schema: &schema{Type: "string"}},
+			{Name: "limit", Description: "Maximum entries returned", Schema: &schema{Type: "integer", Minimum: ptr(1.0), Maximum: ptr(1000.0), Default: 100}},
+		},
+		Status: http.StatusOK, Response: []model.APIKeyAuditEntry{}, Errors: writeErrors,
+	},
+}
+
+// undocumentedRoute reports routes left out of the document: the document
+// itself and debug routes that only exist in test deployments
+func undocumentedRoute(method, path string) bool {
+	return path == "/openapi.json" || strings.HasPrefix(path, "/docs/") || strings.HasPrefix(path, "/debug/")
+}
+
+// registerDocsRoutes serves the OpenAPI document and Swagger UI
+// The document is built on first request from the routes registered by then.
+func registerDocsRoutes(router *gin.Engine) {
+	var (
+		once sync.Once
+		spec []byte
+	)
+	router.GET("/openapi.json", func(c *gin.Context) {
+		once.Do(func() {
+			spec, _ = json.Marshal(buildOpenAPI(router.Routes()))
+		})
+		c.Data(http.StatusOK, "application/json", spec)
+	})
+
+	files := http.StripPrefix("/docs", http.FileServer(swaggerFiles.HTTP))
+	router.GET("/docs/*file", func(c *gin.Context) {
+		switch c.Param("file") {
+		case "/swagger-initializer.js":
+			// Point the bundled UI at our document instead of the petstore example
+			c.Data(http.StatusOK, "application/javascript", []byte(swaggerInitializer))
+		default:
+			files.ServeHTTP(c.Writer, c.Request) // "/" serves index.html
+		}
+	})
+}
+
+const swaggerInitializer = `window.onload = function () {
+  window.ui = SwaggerUIBundle({
+    url: "/openapi.json",
+    dom_id: "#swagger-ui",
+    deepLinking: true,
+    presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
+    plugins: [SwaggerUIBundle.plugins.DownloadUrl],
+    layout: "StandaloneLayout"
+  });
+};
+`
+
+// OpenAPI 3.0 document, limited to what this API uses
+type openAPIDoc struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       openAPIInfo                      `json:"info"`
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components openAPIComponents                `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*schema         `json:"schemas"`
+	SecuritySchemes map[string]*securityScheme `json:"securitySchemes"`
+}
+
+type securityScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Description string `json:"description,omitempty"`
+}
+
+type operation struct {
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Description string                `json:"description,omitempty"`
+	OperationID string                `json:"operationId"`
+	Parameters  []parameter           `json:"parameters,omitempty"`
+	RequestBody *requestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type response struct {
+	Description string               `json:"description"`
+	Headers     map[string]*header   `json:"headers,omitempty"`
+	Content     map[string]mediaType `json:"content,omitempty"`
+}
+
+type header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *schema `json:"schema"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+// schema is a JSON Schema as used by OpenAPI 3.0
+type schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     bool               `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Items                *schema            `json:"items,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// buildOpenAPI describes the documented routes among those registered
+func buildOpenAPI(routes gin.RoutesInfo) *openAPIDoc {
+	doc := &openAPIDoc{
+		OpenAPI: "3.0.3",
+		Info:    openAPIInfo{Title: "Coupon System API", Version: "1.0"},
+		Paths:   make(map[string]map[string]*operation),
+		Components: openAPIComponents{
+			Schemas: make(map[string]*schema),
+			SecuritySchemes: map[string]*securityScheme{
+				"apiKey": {Type: "apiKey", Name: apiKeyHeader, In: "header",
+					Description: "API key issued by an admin through POST /api/keys"},
+			},
+		},
+	}
+	g := &schemaGen{components: doc.Components.Schemas}
+	errSchema := g.schemaFor(reflect.TypeOf(problem{}))
+
+	for _, r := range routes {
+		rd, ok := routeDocs[r.Method+" "+r.Path]
+		if !ok {
+			continue
+		}
+		path, params := openAPIPath(r.Path)
+		op := &operation{
+			Summary:     rd.Summary,
+			OperationID: operationID(r.Method, r.Path),
+			Parameters:  params,
+			Responses:   make(map[string]*response),
+		}
+		if rd.Tag != "" {
+			op.Tags = []string{rd.Tag}
+		}
+		if !rd.Public {
+			op.Security = []map[string][]string{{"apiKey": {}}}
+			op.Description = "Roles: " + roleList(rd.Roles)
+		}
+		for _, q := range rd.Query {
+			op.Parameters = append(op.Parameters, parameter{Name: q.Name, In: "query", Description: q.Description, Schema: q.Schema})
+		}
+		for _, h := range rd.Headers {
+			op.Parameters = append(op.Parameters, parameter{Name: h.Name, In: "header", Description: h.Description, Schema: h.Schema})
+		}
+		if rd.Idempotent {
+			op.Parameters = append(op.Parameters, parameter{Name: idempotencyHeader, In: "header",
+				Description: "Retries with the same key get the original response", Schema: &schema{Type: "string"}})
+		}
+		if rd.Request != nil {
+			op.RequestBody = &requestBody{Required: true, Content: map[string]mediaType{
+				"application/json": {Schema: g.schemaFor(reflect.TypeOf(rd.Request))},
+			}}
+		}
+
+		success := &response{Description: http.StatusText(rd.Status)}
+		switch {
+		case rd.ContentType != "":
+			success.Content = map[string]mediaType{rd.ContentType: {Schema: &schema{Type: "string"}}}
+		case rd.Response != nil:
+			success.Content = map[string]mediaType{"application/json": {Schema: g.schemaFor(reflect.TypeOf(rd.Response))}}
+		}
+		op.Responses[strconv.Itoa(rd.Status)] = success
+
+		for _, status := range errorStatuses(rd) {
+			resp := &response{
+				Description: http.StatusText(status),
+				Content:     map[string]mediaType{problemContentType: {Schema: errSchema}},
+			}
+			if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+				resp.Headers = map[string]*header{"Retry-After": {Description: "Seconds to wait before retrying", Schema: &schema{Type: "integer"}}}
+			}
+			op.Responses[strconv.Itoa(status)] = resp
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*operation)
+		}
+		doc.Paths[path][strings.ToLower(r.Method)] = op
+	}
+	return doc
+}
+
+// errorStatuses returns a route's error statuses including those added by its middleware
+func errorStatuses(rd routeDoc) []int {
+	set := make(map[int]bool)
+	for _, s := range rd.Errors {
+		set[s] = true
+	}
+	if rd.Idempotent {
+		set[http.StatusConflict] = true
+		set[http.StatusUnprocessableEntity] = true
+	}
+	if rd.RateLimited {
+		set[http.StatusTooManyRequests] = true
+	}
+	if !rd.Public {
+		set[http.StatusUnauthorized] = true
+		set[http.StatusForbidden] = true
+	}
+	statuses := make([]int, 0, len(set))
+	for s := range set {
+		statuses = append(statuses, s)
+	}
+	sort.Ints(statuses)
+	return statuses
+}
+
+// roleList names the key roles that may call a route, admin first
+func roleList(roles []model.APIKeyRole) string {
+	names := []string{string(model.RoleAdmin)}
+	for _, role := range roles {
+		names = append(names, string(role))
+	}
+	return strings.Join(names, ", ")
+}
+
+// openAPIPath converts a gin path to OpenAPI form and returns its path parameters
+func openAPIPath(path string) (string, []parameter) {
+	var params []parameter
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, ":") {
+			name := seg[1:]
+			segments[i] = "{" + name + "}"
+			params = append(params, parameter{Name: name, In: "path", Required: true, Schema: &schema{Type: "string"}})
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// operationID derives a stable operation ID such as postApiCouponsClaim
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == ':' || r == '_' }) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+// schemaGen generates schemas from Go types, collecting named structs as components
+type schemaGen struct {
+	components map[string]*schema
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	objectIDType = reflect.TypeOf(primitive.ObjectID{})
+	rawJSONType  = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaFor returns the schema of t, a $ref for named structs
+func (g *schemaGen) schemaFor(t reflect.Type) *schema {
+	switch t {
+	case timeType:
+		return &schema{Type: "string", Format: "date-time"}
+	case objectIDType:
+		return &schema{Type: "string", Format: "objectid"}
+	case rawJSONType:
+		return &schema{} // Any JSON value
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := g.schemaFor(t.Elem())
+		if s.Ref != "" {
+			return s
+		}
+		s.Nullable = true
+		return s
+	case reflect.String:
+		return &schema{Type: "string"}
+	case reflect.Bool:
+		return &schema{Type: "boolean"}
+	case reflect.Int32, reflect.Int16, reflect.Int8, reflect.Uint16, reflect.Uint8:
+		return &schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &schema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &schema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.Interface:
+		return &schema{}
+	case reflect.Struct:
+		name := t.Name()
+		if name == "" {
+			return g.structSchema(t)
+		}
+		name = strings.ToUpper(name[:1]) + name[1:]
+		if _, ok := g.components[name]; !ok {
+			g.components[name] = &schema{} // Placeholder for recursive types
+			g.components[name] = g.structSchema(t)
+		}
+		return &schema{Ref: "#/components/schemas/" + name}
+	}
+	return &schema{}
+}
+
+// structSchema describes a struct's JSON fields, flattening embedded structs
+func (g *schemaGen) structSchema(t reflect.Type) *schema {
+	s := &schema{Type: "object", Properties: make(map[string]*schema)}
+	g.addFields(s, t)
+	sort.Strings(s.Required)
+	return s
+}
+
+func (g *schemaGen) addFields(s *schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			g.addFields(s, ft)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		fs := g.schemaFor(f.Type)
+		if binding := f.Tag.Get("binding"); binding != "" {
+			if applyBinding(fs, binding) {
+				s.Required = append(s.Required, name)
+			}
+		}
+		s.Properties[name] = fs
+	}
+}
+
+// applyBinding adds a field's validator constraints to its schema and reports whether it is required
+// Constraints after "dive" apply to the items of a slice.
+func applyBinding(s *schema, binding string) bool {
+	required := false
+	target := s
+	for _, rule := range strings.Split(binding, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+			if target == s {
+				required = true
+			}
+		case "dive":
+			if target.Items == nil {
+				return required
+			}
+			target = target.Items
+		case "gt", "gte", "min", "lt", "lte", "max":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			setBound(target, key, n)
+		case "oneof":
+			for _, v := range strings.Fields(value) {
+				target.Enum = append(target.Enum, v)
+			}
+		case "coupon_name":
+			target.Pattern = validation.CouponNamePattern
+			target.MaxLength = ptr(validation.MaxCouponNameLength)
+		case "user_id":
+			target.Pattern = validation.UserIDPattern
+			target.MaxLength = ptr(validation.MaxUserIDLength)
+		}
+	}
+	return required
+}
+
+// setBound applies a validator bound to numbers, strings or arrays as appropriate
+func setBound(s *schema, key string, n float64) {
+	lower := key == "gt" || key == "gte" || key == "min"
+	switch s.Type {
+	case "integer", "number":
+		if lower {
+			s.Minimum = ptr(n)
+			s.ExclusiveMinimum = key == "gt"
+		} else {
+			s.Maximum = ptr(n)
+			s.ExclusiveMaximum = key == "lt"
+		}
+	case "string", "array":
+		// Lengths are whole numbers, so exclusive bounds become inclusive ones
+		length := int(n)
+		switch key {
+		case "gt":
+			length++
+		case "lt":
+			length--
+		}
+		switch {
+		case s.Type == "string" && lower:
+			s.MinLength = ptr(length)
+		case s.Type == "string":
+			s.MaxLength = ptr(length)
+		case lower:
+			s.MinItems = ptr(length)
+		default:
+			s.MaxItems = ptr(length)
+		}
+	}
+}
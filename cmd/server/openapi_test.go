@@ -0,0 +1,334 @@
+package main
+
+import (
+	"coupon-system/internal/auth"
+	"coupon-system/internal/auth/authtest"
+	"coupon-system/internal/cache"
+	"coupon-system/internal/jobs"
+	"coupon-system/internal/live"
+	"coupon-system/internal/metrics"
+	"coupon-system/internal/service"
+	"coupon-system/internal/webhooks"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestRouter builds the real router; its handlers are never called with these dependencies
+func newTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	registry := metrics.NewRegistry()
+	return newAuthRouter(t, auth.NewManager(authtest.NewRepository(), auth.Options{}, registry), registry)
+}
+
+// newAuthRouter builds the real router with the given API keys
+func newAuthRouter(t *testing.T, keys *auth.Manager, registry *metrics.Registry) *gin.Engine {
+	t.Helper()
+	t.Setenv("GIN_MODE", "")
+	return setupRouter(
+		service.NewCouponService(nil, nil),
+		jobs.NewManager(nil, jobs.Options{}),
+		webhooks.NewManager(nil, webhooks.Options{}, registry),
+		live.NewHub(nil, live.Options{}, registry),
+		keys,
+		registry,
+		cache.NewMemory(),
+		newAPILimits(registry, nil),
+	)
+}
+
+// TestOpenAPIMatchesRouter checks every route is documented and every documented route exists
+func TestOpenAPIMatchesRouter(t *testing.T) {
+	router := newTestRouter(t)
+
+	registered := make(map[string]bool)
+	for _, r := range router.Routes() {
+		key := r.Method + " " + r.Path
+		registered[key] = true
+		if !undocumentedRoute(r.Method, r.Path) {
+			if _, ok := routeDocs[key]; !ok {
+				t.Errorf("route %s is not documented in routeDocs", key)
+			}
+		}
+	}
+	for key := range routeDocs {
+		if !registered[key] {
+			t.Errorf("routeDocs documents %s, which is not registered", key)
+		}
+	}
+}
+
+// TestOpenAPIHandlerStatuses checks that every status a handler can write is
+// documented for its route, by reading the handler's source: the statuses it
+// writes itself and those of the AppErrors it names
+func TestOpenAPIHandlerStatuses(t *testing.T) {
+	statuses := handlerStatuses(t)
+	router := newTestRouter(t)
+
+	for _, r := range router.Routes() {
+		rd, ok := routeDocs[r.Method+" "+r.Path]
+		if !ok {
+			continue
+		}
+		handler := handlerName(r.Handler)
+		written, ok := statuses[handler]
+		if !ok || len(written) == 0 {
+			continue // Inline handlers and handlers that write no status themselves
+		}
+
+		documented := map[string]bool{http.StatusText(rd.Status): true}
+		for _, s := range errorStatuses(rd) {
+			documented[http.StatusText(s)] = true
+		}
+		for _, s := range written {
+			if !documented[s] {
+				t.Errorf("%s %s: %s can return %q, which is not documented", r.Method, r.Path, handler, s)
+			}
+		}
+	}
+}
+
+// TestOpenAPIDocument checks the served document, including schemas generated from binding tags
+func TestOpenAPIDocument(t *testing.T) {
+	router := newTestRouter(t)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json = %d", rec.Code)
+	}
+
+	var doc openAPIDoc
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode document: %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" || len(doc.Paths) == 0 {
+		t.Fatalf("document = openapi %q with %d paths", doc.OpenAPI, len(doc.Paths))
+	}
+
+	claim := doc.Paths["/api/coupons/claim"]["post"]
+	if claim == nil {
+		t.Fatal("POST /api/coupons/claim missing")
+	}
+	if ref := claim.RequestBody.Content["application/json"].Schema.Ref; ref != "#/components/schemas/ClaimCouponRequest" {
+		t.Errorf("claim request body = %q", ref)
+	}
+	for _, code := range []string{"200", "400", "403", "404", "409", "422", "429", "500", "503"} {
+		if claim.Responses[code] == nil {
+			t.Errorf("claim has no %s response", code)
+		}
+	}
+
+	cancel := doc.Paths["/api/coupons/{name}/claims/{user_id}"]["delete"]
+	if cancel == nil || len(cancel.Parameters) != 3 || cancel.Parameters[1].Name != "user_id" || cancel.Parameters[1].In != "path" ||
+		cancel.Parameters[2].Name != idempotencyHeader {
+		t.Errorf("cancel claim parameters = %+v", cancel)
+	}
+
+	schemas := doc.Components.Schemas
+	if req := schemas["ClaimCouponRequest"]; req == nil || strings.Join(req.Required, ",") != "coupon_name,user_id" {
+		t.Errorf("ClaimCouponRequest = %+v, want coupon_name and user_id required", req)
+	}
+	create := schemas["CreateCouponRequest"]
+	if amount := create.Properties["amount"]; amount.Minimum == nil || *amount.Minimum != 0 || !amount.ExclusiveMinimum {
+		t.Errorf("amount = %+v, want exclusive minimum 0", amount)
+	}
+	if shards := create.Properties["stock_shards"]; shards.Maximum == nil || *shards.Maximum != 64 {
+		t.Errorf("stock_shards = %+v, want maximum 64", shards)
+	}
+	if mode := create.Properties["distribution_mode"]; len(mode.Enum) != 2 {
+		t.Errorf("distribution_mode = %+v, want enum fcfs, raffle", mode)
+	}
+	if users := schemas["BulkClaimRequest"].Properties["user_ids"]; users.MinItems == nil || *users.MinItems != 1 || *users.MaxItems != 10000 {
+		t.Errorf("user_ids = %+v, want 1 to 10000 items", users)
+	}
+	if _, ok := schemas["WebhookCreatedResponse"].Properties["secret"]; !ok {
+		t.Error("WebhookCreatedResponse has no secret")
+	}
+	if _, ok := schemas["WebhookSubscription"].Properties["secret"]; ok {
+		t.Error("WebhookSubscription exposes its secret")
+	}
+}
+
+// TestSwaggerUI checks the bundled UI is served and points at the document
+func TestSwaggerUI(t *testing.T) {
+	router := newTestRouter(t)
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	if rec := get("/docs/"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "swagger-ui") {
+		t.Errorf("GET /docs/ = %d", rec.Code)
+	}
+	if rec := get("/docs/swagger-ui-bundle.js"); rec.Code != http.StatusOK {
+		t.Errorf("GET /docs/swagger-ui-bundle.js = %d", rec.Code)
+	}
+	if rec := get("/docs/swagger-initializer.js"); !strings.Contains(rec.Body.String(), `"/openapi.json"`) {
+		t.Errorf("initializer does not load /openapi.json: %s", rec.Body.String())
+	}
+}
+
+// handlerName extracts the handler constructor from a gin handler name such
+// as coupon-system/cmd/server.claimCouponHandler.func1
+func handlerName(name string) string {
+	for _, part := range strings.Split(name, ".") {
+		if strings.HasSuffix(part, "Handler") {
+			return part
+		}
+	}
+	return ""
+}
+
+// handlerStatuses returns, for each function in this package, the HTTP
+// statuses it and the package functions it calls can write
+func handlerStatuses(t *testing.T) map[string][]string {
+	t.Helper()
+	errorStatuses := appErrorStatuses(t)
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	direct := make(map[string]map[string]bool)
+	calls := make(map[string][]string)
+	fset := token.NewFileSet()
+	for _, f := range files {
+		if strings.HasSuffix(f, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, f, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil {
+				continue
+			}
+			name := fn.Name.Name
+			direct[name] = make(map[string]bool)
+			ast.Inspect(fn, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.SelectorExpr:
+					if pkg, ok := n.X.(*ast.Ident); ok && pkg.Name == "http" && strings.HasPrefix(n.Sel.Name, "Status") {
+						direct[name][statusText(n.Sel.Name)] = true
+					}
+				case *ast.AssignStmt:
+					// err = service.ErrX before c.Error(err)
+					for _, rhs := range n.Rhs {
+						addErrorStatuses(direct[name], errorStatuses, rhs)
+					}
+				case *ast.CallExpr:
+					if callee, ok := n.Fun.(*ast.Ident); ok {
+						calls[name] = append(calls[name], callee.Name)
+					}
+					if method, ok := n.Fun.(*ast.SelectorExpr); ok && method.Sel.Name == "Error" {
+						// c.Error(apperrors.ErrX...)
+						for _, arg := range n.Args {
+							addErrorStatuses(direct[name], errorStatuses, arg)
+						}
+					}
+				}
+				return true
+			})
+		}
+	}
+
+	result := make(map[string][]string)
+	for name := range direct {
+		set := make(map[string]bool)
+		var visit func(fn string, seen map[string]bool)
+		visit = func(fn string, seen map[string]bool) {
+			if seen[fn] {
+				return
+			}
+			seen[fn] = true
+			for s := range direct[fn] {
+				set[s] = true
+			}
+			for _, callee := range calls[fn] {
+				visit(callee, seen)
+			}
+		}
+		visit(name, make(map[string]bool))
+		for s := range set {
+			result[name] = append(result[name], s)
+		}
+		sort.Strings(result[name])
+	}
+	return result
+}
+
+// addErrorStatuses adds the statuses of the errors from pkg/errors named in expr
+func addErrorStatuses(set map[string]bool, errorStatuses map[string]string, expr ast.Expr) {
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if pkg, ok := sel.X.(*ast.Ident); ok && (pkg.Name == "apperrors" || pkg.Name == "service") {
+				if status, ok := errorStatuses[sel.Sel.Name]; ok {
+					set[status] = true
+				}
+			}
+		}
+		return true
+	})
+}
+
+// appErrorStatuses returns the status text of each error declared in
+// pkg/errors, read from its New(code, http.StatusX, message) call
+func appErrorStatuses(t *testing.T) map[string]string {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), filepath.Join("..", "..", "pkg", "errors", "errors.go"), nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := make(map[string]string)
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			vs := spec.(*ast.ValueSpec)
+			for i, value := range vs.Values {
+				ast.Inspect(value, func(n ast.Node) bool {
+					call, ok := n.(*ast.CallExpr)
+					if !ok || len(call.Args) != 3 {
+						return true
+					}
+					if fn, ok := call.Fun.(*ast.Ident); ok && fn.Name == "New" {
+						if status, ok := call.Args[1].(*ast.SelectorExpr); ok {
+							statuses[vs.Names[i].Name] = statusText(status.Sel.Name)
+						}
+					}
+					return true
+				})
+			}
+		}
+	}
+	if statuses["ErrNoStock"] != http.StatusText(http.StatusBadRequest) {
+		t.Fatalf("could not read the errors in pkg/errors: ErrNoStock = %q", statuses["ErrNoStock"])
+	}
+	return statuses
+}
+
+// statusText maps a net/http constant name such as StatusNotFound to its status text
+func statusText(constant string) string {
+	for code := 100; code < 600; code++ {
+		text := http.StatusText(code)
+		if text != "" && "Status"+strings.NewReplacer(" ", "", "-", "", "'", "").Replace(text) == constant {
+			return text
+		}
+	}
+	return constant
+}
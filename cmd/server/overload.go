@@ -0,0 +1,68 @@
+package main
+
+import (
+	"coupon-system/internal/metrics"
+	"coupon-system/internal/overload"
+	"coupon-system/pkg/config"
+	apperrors "coupon-system/pkg/errors"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newOverloadLimiter configures the adaptive concurrency limit from the environment
+// and exports its state as metrics
+func newOverloadLimiter(registry *metrics.Registry) *overload.Limiter {
+	limiter := overload.NewLimiter(overload.Options{
+		InitialLimit: config.GetEnvInt("OVERLOAD_INITIAL_LIMIT", 100),
+		MinLimit:     config.GetEnvInt("OVERLOAD_MIN_LIMIT", 4),
+		MaxLimit:     config.GetEnvInt("OVERLOAD_MAX_LIMIT", 1000),
+		QueueTimeout: config.GetEnvDuration("OVERLOAD_QUEUE_TIMEOUT", 50*time.Millisecond),
+		MaxQueue:     config.GetEnvInt("OVERLOAD_MAX_QUEUE", 1000),
+	})
+
+	registry.GaugeFunc("overload_concurrency_limit", "Current adaptive concurrency limit", func() float64 {
+		return float64(limiter.Stats().Limit)
+	})
+	registry.GaugeFunc("overload_inflight_requests", "Requests currently being served", func() float64 {
+		return float64(limiter.Stats().Inflight)
+	})
+	registry.GaugeFunc("overload_queued_requests", "Requests waiting for a slot", func() float64 {
+		return float64(limiter.Stats().Queued)
+	})
+	registry.GaugeFunc("overload_latency_baseline_seconds", "Latency the limiter considers healthy", func() float64 {
+		return limiter.Stats().Baseline.Seconds()
+	})
+
+	return limiter
+}
+
+// shedLoad admits requests through the adaptive limiter
+// Requests that cannot get a slot within the queue-time budget get a fast
+// 503 with Retry-After instead of piling up behind a slow database
+func shedLoad(limiter *overload.Limiter, registry *metrics.Registry) gin.HandlerFunc {
+	shed := registry.Counter("overload_shed_requests_total", "Requests rejected with 503 because the server was overloaded")
+
+	return func(c *gin.Context) {
+		done, err := limiter.Acquire(c.Request.Context())
+		if err != nil {
+			if errors.Is(err, overload.ErrOverloaded) {
+				shed.Inc()
+				c.Error(apperrors.ErrOverloaded.WithRetryAfter(limiter.RetryAfter()))
+				c.Abort()
+				return
+			}
+			// The client went away while queued
+			c.Abort()
+			return
+		}
+
+		defer func() {
+			renderProblem(c)
+			done(c.Writer.Status() >= http.StatusInternalServerError)
+		}()
+		c.Next()
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	apperrors "coupon-system/pkg/errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// problemContentType is the media type of error responses (RFC 7807)
+const problemContentType = "application/problem+json"
+
+// problem is the body of every error response
+// An error's details are added as extra members.
+type problem struct {
+	Type     string `json:"type"`     // urn:coupon-system:problem:<code>
+	Title    string `json:"title"`    // Status text
+	Status   int    `json:"status"`   // HTTP status
+	Detail   string `json:"detail"`   // What went wrong
+	Instance string `json:"instance"` // Request path
+	Code     string `json:"code"`     // Stable error code, e.g. no_stock
+}
+
+// problemDetails renders the error a handler or middleware recorded with
+// c.Error once the chain has run
+// Handlers pass service errors on as they are: the status and code come from
+// the AppError in the error's chain, and any other error is a 500.
+func problemDetails() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		renderProblem(c)
+	}
+}
+
+// renderProblem writes the last recorded error unless a response was already written
+// Middleware that looks at the response after c.Next calls it first.
+func renderProblem(c *gin.Context) {
+	if len(c.Errors) == 0 || c.Writer.Written() {
+		return
+	}
+	err := c.Errors.Last().Err
+	appErr, detail := problemDetail(err)
+	if appErr.Status >= http.StatusInternalServerError {
+		log.Printf("%s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+	}
+
+	body := gin.H{}
+	for k, v := range appErr.Details {
+		body[k] = v
+	}
+	body["type"] = "urn:coupon-system:problem:" + appErr.Code
+	body["title"] = http.StatusText(appErr.Status)
+	body["status"] = appErr.Status
+	body["detail"] = detail
+	body["instance"] = c.Request.URL.Path
+	body["code"] = appErr.Code
+
+	if appErr.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(appErr.RetryAfter.Seconds()))))
+	}
+	c.Header("Content-Type", problemContentType)
+	c.JSON(appErr.Status, body)
+}
+
+// problemDetail returns err's AppError and the message to show the client
+// Client errors keep the specifics they were wrapped with, such as which URL
+// is invalid; server errors only show the AppError's own message.
+func problemDetail(err error) (*apperrors.AppError, string) {
+	appErr := apperrors.From(err)
+	if appErr.Status < http.StatusInternalServerError {
+		return appErr, err.Error()
+	}
+	return appErr, appErr.Message
+}
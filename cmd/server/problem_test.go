@@ -0,0 +1,94 @@
+package main
+
+import (
+	"coupon-system/internal/cache"
+	apperrors "coupon-system/pkg/errors"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestProblemDetails checks recorded errors are rendered as problem+json with the AppError's status
+func TestProblemDetails(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(problemDetails())
+	fail := func(err error) gin.HandlerFunc {
+		return func(c *gin.Context) { c.Error(err) }
+	}
+	router.GET("/wrapped", fail(fmt.Errorf("claim coupon: %w", apperrors.ErrAlreadyClaimed)))
+	router.GET("/unknown", fail(errors.New("connection reset by 10.0.0.7")))
+	router.GET("/unavailable", fail(fmt.Errorf("find coupon: %w", apperrors.ErrDatabaseUnavailable)))
+	router.GET("/details", fail(apperrors.ErrInvalidRequest.WithDetails(map[string]interface{}{"invalid_params": []string{"name"}})))
+
+	cases := []struct {
+		path       string
+		status     int
+		code       string
+		detail     string
+		retryAfter string
+	}{
+		{"/wrapped", http.StatusConflict, "already_claimed", "claim coupon: coupon already claimed by this user", ""},
+		{"/unknown", http.StatusInternalServerError, "internal_error", "internal server error", ""},
+		{"/unavailable", http.StatusServiceUnavailable, "database_unavailable", "database unavailable", "1"},
+		{"/details", http.StatusBadRequest, "invalid_request", "invalid request body", ""},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+		var body map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: decode: %v", tc.path, err)
+		}
+		if rec.Code != tc.status || body["status"] != float64(tc.status) || body["code"] != tc.code || body["detail"] != tc.detail {
+			t.Errorf("%s: %d %v, want %d %s %q", tc.path, rec.Code, body, tc.status, tc.code, tc.detail)
+		}
+		if body["type"] != "urn:coupon-system:problem:"+tc.code || body["title"] != http.StatusText(tc.status) || body["instance"] != tc.path {
+			t.Errorf("%s: problem members = %v", tc.path, body)
+		}
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, problemContentType) {
+			t.Errorf("%s: Content-Type = %q", tc.path, ct)
+		}
+		if got := rec.Header().Get("Retry-After"); got != tc.retryAfter {
+			t.Errorf("%s: Retry-After = %q, want %q", tc.path, got, tc.retryAfter)
+		}
+		if tc.path == "/details" {
+			if params, ok := body["invalid_params"].([]interface{}); !ok || len(params) != 1 {
+				t.Errorf("details not rendered: %v", body)
+			}
+		}
+	}
+}
+
+// TestProblemDetailsIdempotentReplay checks an error response is stored and replayed under its Idempotency-Key
+func TestProblemDetailsIdempotentReplay(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(problemDetails())
+	calls := 0
+	router.POST("/claim", idempotency(cache.NewMemory(), time.Minute, time.Minute), func(c *gin.Context) {
+		calls++
+		c.Error(apperrors.ErrNoStock)
+	})
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/claim", strings.NewReader(`{}`))
+		req.Header.Set(idempotencyHeader, "k1")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"code":"no_stock"`) {
+			t.Fatalf("attempt %d: %d %s", i+1, rec.Code, rec.Body.String())
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", calls)
+	}
+}
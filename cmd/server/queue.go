@@ -0,0 +1,56 @@
+package main
+
+import (
+	"coupon-system/internal/model"
+	"coupon-system/internal/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// joinQueueHandler handles POST /api/coupons/:name/queue
+func joinQueueHandler(svc *service.CouponService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.JoinQueueRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(bindError(err))
+			return
+		}
+
+		ticket, err := svc.JoinQueue(c.Request.Context(), c.Param("name"), req.UserID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		setPollHeader(c, ticket)
+		c.JSON(http.StatusCreated, ticket)
+	}
+}
+
+// getQueueTicketHandler handles GET /api/queue/:token
+func getQueueTicketHandler(svc *service.CouponService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ticket, err := svc.GetQueueTicket(c.Param("token"))
+		if errors.Is(err, service.ErrQueueNotEnabled) {
+			err = service.ErrQueueTicketNotFound // Queueing is off, so no ticket exists
+		}
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		setPollHeader(c, ticket)
+		c.JSON(http.StatusOK, ticket)
+	}
+}
+
+// setPollHeader tells waiting clients when to poll again
+func setPollHeader(c *gin.Context, ticket *model.QueueTicket) {
+	if ticket.PollAfterMs > 0 {
+		seconds := (ticket.PollAfterMs + 999) / 1000
+		c.Header("Retry-After", strconv.FormatInt(seconds, 10))
+	}
+}
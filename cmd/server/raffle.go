@@ -0,0 +1,67 @@
+package main
+
+import (
+	"coupon-system/internal/model"
+	"coupon-system/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// enterRaffleHandler handles POST /api/coupons/:name/raffle/entries
+func enterRaffleHandler(svc *service.CouponService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.EnterRaffleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(bindError(err))
+			return
+		}
+
+		entry, err := svc.EnterRaffle(c.Request.Context(), c.Param("name"), req.UserID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, entry)
+	}
+}
+
+// drawRaffleHandler handles POST /api/coupons/:name/raffle/draw
+func drawRaffleHandler(svc *service.CouponService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		draw, err := svc.DrawRaffle(c.Request.Context(), c.Param("name"))
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, draw)
+	}
+}
+
+// getRaffleDrawHandler handles GET /api/coupons/:name/raffle
+func getRaffleDrawHandler(svc *service.CouponService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		draw, err := svc.GetRaffleDraw(c.Request.Context(), c.Param("name"))
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, draw)
+	}
+}
+
+// verifyRaffleDrawHandler handles GET /api/coupons/:name/raffle/verify
+func verifyRaffleDrawHandler(svc *service.CouponService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result, err := svc.VerifyRaffleDraw(c.Request.Context(), c.Param("name"))
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
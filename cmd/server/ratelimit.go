@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"coupon-system/internal/cache"
+	"coupon-system/internal/metrics"
+	"coupon-system/internal/overload"
+	"coupon-system/internal/ratelimit"
+	"coupon-system/pkg/config"
+	apperrors "coupon-system/pkg/errors"
+	"encoding/json"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// routeLimits are the token buckets applied to one route, per key type
+// A zero limit disables limiting by that key
+type routeLimits struct {
+	IP     ratelimit.Limit
+	User   ratelimit.Limit
+	APIKey ratelimit.Limit
+}
+
+// rateLimiter builds per-route rate limiting middleware over one store
+type rateLimiter struct {
+	enabled     bool
+	store       ratelimit.Store
+	registry    *metrics.Registry
+	storeErrors *metrics.Counter
+}
+
+// newRateLimiter configures rate limiting from the environment
+// RATE_LIMIT_STORE selects "memory" (per instance) or "cache" (shared through the cache backend)
+func newRateLimiter(registry *metrics.Registry, sharedCache cache.Cache) *rateLimiter {
+	l := &rateLimiter{
+		enabled:     config.GetEnv("RATE_LIMIT_ENABLED", "true") != "false",
+		registry:    registry,
+		storeErrors: registry.Counter("ratelimit_store_errors_total", "Rate limit checks that failed open because the store was unavailable"),
+	}
+
+	switch store := config.GetEnv("RATE_LIMIT_STORE", "memory"); store {
+	case "memory":
+		memory := ratelimit.NewMemoryStore()
+		registry.GaugeFunc("ratelimit_buckets", "Token buckets tracked in memory", func() float64 {
+			return float64(memory.Len())
+		})
+		l.store = memory
+	case "cache":
+		l.store = ratelimit.NewCacheStore(sharedCache)
+	default:
+		log.Fatalf("Invalid RATE_LIMIT_STORE %q (use memory or cache)", store)
+	}
+
+	return l
+}
+
+// loadRouteLimits reads RATE_LIMIT_<ROUTE>_IP, _USER and _API_KEY, falling back to defaults
+func loadRouteLimits(route string, defaults routeLimits) routeLimits {
+	prefix := "RATE_LIMIT_" + strings.ToUpper(route) + "_"
+	return routeLimits{
+		IP:     envLimit(prefix+"IP", defaults.IP),
+		User:   envLimit(prefix+"USER", defaults.User),
+		APIKey: envLimit(prefix+"API_KEY", defaults.APIKey),
+	}
+}
+
+// envLimit parses a limit from the environment; invalid values stop the server
+func envLimit(key string, defaultValue ratelimit.Limit) ratelimit.Limit {
+	value := config.GetEnv(key, "")
+	if value == "" {
+		return defaultValue
+	}
+	limit, err := ratelimit.ParseLimit(value)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", key, err)
+	}
+	return limit
+}
+
+// rateLimitKeys identifies a caller; empty keys are not limited
+type rateLimitKeys struct {
+	IP     string
+	APIKey string // From callerID: the key's ID, never its secret
+	UserID string
+}
+
+// rateLimitCheck is one token bucket applied to a route
+type rateLimitCheck struct {
+	keyType string
+	limit   ratelimit.Limit
+	key     func(keys rateLimitKeys) string
+	allowed *metrics.Counter
+	limited *metrics.Counter
+}
+
+// apiLimits are the limits claims share over HTTP, the WebSocket channel and gRPC
+type apiLimits struct {
+	overload *overload.Limiter
+	rate     *rateLimiter
+	claim    *routeLimiter
+}
+
+// newAPILimits builds the adaptive concurrency limit and the claim rate limits
+func newAPILimits(registry *metrics.Registry, sharedCache cache.Cache) *apiLimits {
+	rate := newRateLimiter(registry, sharedCache)
+	return &apiLimits{
+		overload: newOverloadLimiter(registry),
+		rate:     rate,
+		claim: rate.route("claim", loadRouteLimits("claim", routeLimits{
+			IP:   ratelimit.Limit{Rate: 100, Burst: 200},
+			User: ratelimit.Limit{Rate: 1, Burst: 10},
+		})),
+	}
+}
+
+// grpcClaimLimit applies the claim route's limits to a gRPC ClaimCoupon call
+func (l *apiLimits) grpcClaimLimit(ctx context.Context, ip, keyID, userID string) (bool, time.Duration) {
+	return l.claim.allow(ctx, rateLimitKeys{IP: ip, APIKey: keyID, UserID: userID})
+}
+
+// routeLimiter enforces one route's limits
+type routeLimiter struct {
+	limiter *rateLimiter
+	route   string
+	checks  []rateLimitCheck
+}
+
+// route builds the limiter for a route's limits
+func (l *rateLimiter) route(route string, limits routeLimits) *routeLimiter {
+	r := &routeLimiter{limiter: l, route: route}
+	if !l.enabled {
+		return r
+	}
+
+	add := func(keyType string, limit ratelimit.Limit, key func(keys rateLimitKeys) string) {
+		if !limit.Enabled() {
+			return
+		}
+		r.checks = append(r.checks, rateLimitCheck{
+			keyType: keyType,
+			limit:   limit,
+			key:     key,
+			allowed: l.registry.Counter("ratelimit_requests_total", "Rate limit decisions by route, key type and result",
+				"route", route, "key", keyType, "result", "allowed"),
+			limited: l.registry.Counter("ratelimit_requests_total", "Rate limit decisions by route, key type and result",
+				"route", route, "key", keyType, "result", "limited"),
+		})
+	}
+	add("ip", limits.IP, func(keys rateLimitKeys) string { return keys.IP })
+	add("api_key", limits.APIKey, func(keys rateLimitKeys) string { return keys.APIKey })
+	add("user", limits.User, func(keys rateLimitKeys) string { return keys.UserID })
+	return r
+}
+
+// allow takes a token for each of the caller's keys
+// Returns false and how long to wait when any limit is exceeded. If the store
+// is unavailable the call is let through.
+func (r *routeLimiter) allow(ctx context.Context, keys rateLimitKeys) (bool, time.Duration) {
+	now := time.Now()
+	for _, chk := range r.checks {
+		key := chk.key(keys)
+		if key == "" {
+			continue // e.g. no API key sent; other keys still apply
+		}
+
+		decision, err := r.limiter.store.Take(ctx, r.route+":"+chk.keyType+":"+key, chk.limit, now)
+		if err != nil {
+			r.limiter.storeErrors.Inc()
+			continue
+		}
+		if !decision.Allowed {
+			chk.limited.Inc()
+			return false, decision.RetryAfter
+		}
+		chk.allowed.Inc()
+	}
+	return true, 0
+}
+
+// limit returns middleware enforcing a route's limits by client IP, API key
+// and user_id (read from the JSON body). Exceeding any of them returns 429
+// with Retry-After. If the store is unavailable requests are let through.
+func (l *rateLimiter) limit(route string, limits routeLimits) gin.HandlerFunc {
+	return l.route(route, limits).middleware()
+}
+
+// middleware enforces the route's limits on HTTP requests
+func (r *routeLimiter) middleware() gin.HandlerFunc {
+	if len(r.checks) == 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	return func(c *gin.Context) {
+		keys := rateLimitKeys{IP: c.ClientIP(), APIKey: callerID(c)}
+		if r.needsUser() {
+			keys.UserID = requestUserID(c)
+		}
+
+		if ok, retryAfter := r.allow(c.Request.Context(), keys); !ok {
+			c.Error(apperrors.ErrRateLimited.WithRetryAfter(retryAfter))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// needsUser reports whether the route limits by user, which means reading the body
+func (r *routeLimiter) needsUser() bool {
+	for _, chk := range r.checks {
+		if chk.keyType == "user" {
+			return true
+		}
+	}
+	return false
+}
+
+// requestUserID reads user_id from a JSON request body without consuming it
+func requestUserID(c *gin.Context) string {
+	if c.Request.Body == nil {
+		return ""
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+
+	var req struct {
+		UserID string `json:"user_id"`
+	}
+	if json.Unmarshal(body, &req) != nil {
+		return ""
+	}
+	return req.UserID
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"coupon-system/internal/metrics"
+	"coupon-system/internal/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestRateLimitIgnoresSpoofedForwardedFor checks X-Forwarded-For picks the
+// IP bucket only when the request comes through a trusted proxy
+func TestRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name        string
+		trusted     []string
+		wantLimited bool
+	}{
+		{"no trusted proxies", nil, true},
+		{"through a trusted proxy", []string{"192.0.2.0/24"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			if err := router.SetTrustedProxies(tt.trusted); err != nil {
+				t.Fatal(err)
+			}
+			router.Use(problemDetails())
+			limiter := newRateLimiter(metrics.NewRegistry(), nil)
+			router.POST("/claim", limiter.limit("test", routeLimits{IP: ratelimit.Limit{Rate: 1, Burst: 1}}), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			var codes []int
+			for _, forwarded := range []string{"203.0.113.1", "203.0.113.2"} {
+				req := httptest.NewRequest(http.MethodPost, "/claim", nil)
+				req.RemoteAddr = "192.0.2.10:4321"
+				req.Header.Set("X-Forwarded-For", forwarded)
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+				codes = append(codes, rec.Code)
+			}
+
+			if limited := codes[1] == http.StatusTooManyRequests; codes[0] != http.StatusOK || limited != tt.wantLimited {
+				t.Errorf("status codes = %v, want the second limited: %v", codes, tt.wantLimited)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"coupon-system/internal/metrics"
+	"coupon-system/internal/resilience"
+	"coupon-system/pkg/config"
+	"time"
+)
+
+// newDatabaseGuard configures retries and the circuit breaker for MongoDB calls
+// DB_RETRY_MAX_ATTEMPTS=1 disables retries and DB_BREAKER_FAILURES=0 disables the breaker
+func newDatabaseGuard(registry *metrics.Registry) *resilience.Guard {
+	return resilience.NewGuard(resilience.RetryPolicy{
+		MaxAttempts: config.GetEnvInt("DB_RETRY_MAX_ATTEMPTS", 3),
+		BaseDelay:   config.GetEnvDuration("DB_RETRY_BASE_DELAY", 50*time.Millisecond),
+		MaxDelay:    config.GetEnvDuration("DB_RETRY_MAX_DELAY", time.Second),
+	}, resilience.BreakerOptions{
+		FailureThreshold: config.GetEnvInt("DB_BREAKER_FAILURES", 5),
+		OpenTimeout:      config.GetEnvDuration("DB_BREAKER_OPEN_TIMEOUT", 5*time.Second),
+	}, registry)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"coupon-system/internal/live"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamCouponHandler handles GET /api/coupons/:name/stream
+// Pushes a "stock" event with the coupon's current state on connect and after
+// every change, and a comment every heartbeat so proxies keep the connection open
+func streamCouponHandler(hub *live.Hub, heartbeat time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sub, err := hub.Subscribe(c.Request.Context(), c.Param("name"))
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		defer sub.Close()
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no") // Stop nginx from buffering the stream
+		c.Status(http.StatusOK)
+
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case <-sub.Done():
+				return
+			case <-sub.Updates():
+				update := sub.Latest()
+				data, err := json.Marshal(update)
+				if err != nil {
+					return
+				}
+				if _, err := fmt.Fprintf(c.Writer, "id: %d\nevent: stock\ndata: %s\n\n", update.Version, data); err != nil {
+					return
+				}
+			case <-ticker.C:
+				if _, err := c.Writer.WriteString(": heartbeat\n\n"); err != nil {
+					return
+				}
+			}
+			c.Writer.Flush()
+		}
+	}
+}
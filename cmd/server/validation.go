@@ -0,0 +1,105 @@
+package main
+
+import (
+	"coupon-system/internal/validation"
+	apperrors "coupon-system/pkg/errors"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// registerValidators adds the coupon_name and user_id binding rules and makes
+// validation errors name fields by their JSON names
+func registerValidators() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	_ = v.RegisterValidation("coupon_name", func(fl validator.FieldLevel) bool {
+		return validation.CouponName(fl.Field().String()) == nil
+	})
+	_ = v.RegisterValidation("user_id", func(fl validator.FieldLevel) bool {
+		return validation.UserID(fl.Field().String()) == nil
+	})
+}
+
+// bindError turns a ShouldBindJSON failure into a validation error listing each
+// invalid field, or ErrInvalidRequest when the body is not JSON at all
+func bindError(err error) error {
+	var invalid validation.Errors
+	var fieldErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &fieldErrs):
+		for _, fe := range fieldErrs {
+			// The namespace starts with the struct's type name: CreateCouponRequest.name
+			_, name, _ := strings.Cut(fe.Namespace(), ".")
+			invalid.Add(name, fieldReason(fe))
+		}
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		invalid.Add(typeErr.Field, "must be "+jsonType(typeErr.Type))
+	default:
+		return apperrors.ErrInvalidRequest
+	}
+	return invalid.Err()
+}
+
+// fieldReason describes why a field failed a binding rule
+func fieldReason(fe validator.FieldError) string {
+	unit := ""
+	switch fe.Kind() {
+	case reflect.String:
+		unit = " characters"
+	case reflect.Slice, reflect.Map:
+		unit = " items"
+	}
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "coupon_name":
+		return validation.CouponName(fmt.Sprint(fe.Value())).Error()
+	case "user_id":
+		return validation.UserID(fmt.Sprint(fe.Value())).Error()
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "gt":
+		return "must be greater than " + fe.Param() + unit
+	case "gte", "min":
+		return "must be at least " + fe.Param() + unit
+	case "lt":
+		return "must be less than " + fe.Param() + unit
+	case "lte", "max":
+		return "must be at most " + fe.Param() + unit
+	}
+	return "is invalid"
+}
+
+// jsonType names the JSON type a Go type is decoded from
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+	return "an object"
+}
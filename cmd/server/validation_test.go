@@ -0,0 +1,91 @@
+package main
+
+import (
+	"coupon-system/internal/model"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestBindErrorListsInvalidFields checks a rejected body reports each invalid field by its JSON name
+func TestBindErrorListsInvalidFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	registerValidators()
+	router := gin.New()
+	router.Use(problemDetails())
+	router.POST("/coupons", func(c *gin.Context) {
+		var req model.CreateCouponRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(bindError(err))
+			return
+		}
+		c.Status(http.StatusCreated)
+	})
+	router.POST("/bulk", func(c *gin.Context) {
+		var req model.BulkClaimRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(bindError(err))
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	cases := []struct {
+		path   string
+		body   string
+		status int
+		code   string
+		params map[string]string // Invalid field -> reason
+	}{
+		{"/coupons", `{"name":"SPRING_SALE","amount":100}`, http.StatusCreated, "", nil},
+		{"/coupons", `{"name":"spring sale!","amount":0,"distribution_mode":"lottery"}`, http.StatusBadRequest, "validation_failed", map[string]string{
+			"name":              "must start with a letter or digit and contain only letters, digits, '_' and '-'",
+			"amount":            "is required",
+			"distribution_mode": "must be one of fcfs, raffle",
+		}},
+		{"/coupons", `{"name":"SPRING_SALE","amount":"100"}`, http.StatusBadRequest, "validation_failed", map[string]string{
+			"amount": "must be an integer",
+		}},
+		{"/bulk", `{"coupon_name":"SPRING_SALE","user_ids":["user_1","bad user"]}`, http.StatusBadRequest, "validation_failed", map[string]string{
+			"user_ids[1]": "may only contain letters, digits and '_', '.', ':', '@', '+', '-'",
+		}},
+		{"/coupons", `{"name":`, http.StatusBadRequest, "invalid_request", nil},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body)))
+		if rec.Code != tc.status {
+			t.Errorf("%s: status = %d, want %d: %s", tc.body, rec.Code, tc.status, rec.Body.String())
+			continue
+		}
+		if tc.code == "" {
+			continue
+		}
+
+		var body struct {
+			Code          string `json:"code"`
+			InvalidParams []struct {
+				Name   string `json:"name"`
+				Reason string `json:"reason"`
+			} `json:"invalid_params"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: decode: %v", tc.body, err)
+		}
+		got := make(map[string]string)
+		for _, p := range body.InvalidParams {
+			got[p.Name] = p.Reason
+		}
+		if len(got) == 0 {
+			got = nil
+		}
+		if body.Code != tc.code || !reflect.DeepEqual(got, tc.params) {
+			t.Errorf("%s: %s %v, want %s %v", tc.body, body.Code, got, tc.code, tc.params)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"coupon-system/internal/model"
+	"coupon-system/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// joinWaitlistHandler handles POST /api/coupons/:name/waitlist
+func joinWaitlistHandler(svc *service.CouponService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.JoinWaitlistRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(bindError(err))
+			return
+		}
+
+		status, err := svc.JoinWaitlist(c.Request.Context(), c.Param("name"), req.UserID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, status)
+	}
+}
+
+// getWaitlistStatusHandler handles GET /api/coupons/:name/waitlist/:user_id
+func getWaitlistStatusHandler(svc *service.CouponService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status, err := svc.GetWaitlistStatus(c.Request.Context(), c.Param("name"), c.Param("user_id"))
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, status)
+	}
+}
+
+// leaveWaitlistHandler handles DELETE /api/coupons/:name/waitlist/:user_id
+func leaveWaitlistHandler(svc *service.CouponService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := svc.LeaveWaitlist(c.Request.Context(), c.Param("name"), c.Param("user_id"))
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"coupon-system/internal/model"
+	"coupon-system/internal/webhooks"
+	apperrors "coupon-system/pkg/errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// webhookCreatedResponse includes the signing secret, which is only shown once
+type webhookCreatedResponse struct {
+	*model.WebhookSubscription
+	Secret string `json:"secret"`
+}
+
+// registerWebhookRoutes adds the webhook subscription endpoints to the API
+func registerWebhookRoutes(api *gin.RouterGroup, manager *webhooks.Manager, allow, idem gin.HandlerFunc) {
+	api.POST("/webhooks", allow, idem, createWebhookHandler(manager))
+	api.GET("/webhooks", allow, listWebhooksHandler(manager))
+	api.GET("/webhooks/:id", allow, getWebhookHandler(manager))
+	api.DELETE("/webhooks/:id", allow, idem, deleteWebhookHandler(manager))
+	api.GET("/webhooks/:id/deliveries", allow, listDeliveriesHandler(manager))
+	api.POST("/webhooks/:id/deliveries/:delivery_id/replay", allow, replayDeliveryHandler(manager))
+	api.POST("/webhooks/:id/replay", allow, replayDeadHandler(manager))
+}
+
+// createWebhookHandler handles POST /api/webhooks
+func createWebhookHandler(manager *webhooks.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.CreateWebhookRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(bindError(err))
+			return
+		}
+
+		sub, err := manager.Subscribe(c.Request.Context(), &req)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.Header("Location", "/api/webhooks/"+sub.ID.Hex())
+		c.JSON(http.StatusCreated, webhookCreatedResponse{WebhookSubscription: sub, Secret: sub.Secret})
+	}
+}
+
+// listWebhooksHandler handles GET /api/webhooks
+func listWebhooksHandler(manager *webhooks.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		subs, err := manager.List(c.Request.Context())
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, subs)
+	}
+}
+
+// getWebhookHandler handles GET /api/webhooks/:id
+func getWebhookHandler(manager *webhooks.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sub, err := manager.Get(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, sub)
+	}
+}
+
+// deleteWebhookHandler handles DELETE /api/webhooks/:id
+func deleteWebhookHandler(manager *webhooks.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := manager.Unsubscribe(c.Request.Context(), c.Param("id")); err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// listDeliveriesHandler handles GET /api/webhooks/:id/deliveries?status=dead&limit=50
+func listDeliveriesHandler(manager *webhooks.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := model.WebhookDeliveryStatus(c.Query("status"))
+		switch status {
+		case "", model.WebhookDeliveryPending, model.WebhookDeliverySucceeded, model.WebhookDeliveryDead:
+		default:
+			c.Error(apperrors.ErrInvalidRequest.WithMessage("status must be pending, succeeded or dead"))
+			return
+		}
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if err != nil || limit < 1 || limit > 500 {
+			c.Error(apperrors.ErrInvalidRequest.WithMessage("limit must be between 1 and 500"))
+			return
+		}
+
+		deliveries, err := manager.Deliveries(c.Request.Context(), c.Param("id"), status, limit)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, deliveries)
+	}
+}
+
+// replayDeliveryHandler handles POST /api/webhooks/:id/deliveries/:delivery_id/replay
+func replayDeliveryHandler(manager *webhooks.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		delivery, err := manager.Replay(c.Request.Context(), c.Param("id"), c.Param("delivery_id"))
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusAccepted, delivery)
+	}
+}
+
+// replayDeadHandler handles POST /api/webhooks/:id/replay (the whole dead-letter queue)
+func replayDeadHandler(manager *webhooks.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		replayed, err := manager.ReplayDead(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{"replayed": replayed})
+	}
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"context"
+	"coupon-system/internal/live"
+	"coupon-system/internal/model"
+	"coupon-system/internal/overload"
+	"coupon-system/internal/service"
+	"coupon-system/internal/validation"
+	apperrors "coupon-system/pkg/errors"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// socketRequest is a message from a WebSocket client
+type socketRequest struct {
+	Type       string `json:"type"`                  // subscribe, join_queue, claim or ping
+	RequestID  string `json:"request_id,omitempty"`  // Echoed in the reply
+	CouponName string `json:"coupon_name,omitempty"` // subscribe
+	UserID     string `json:"user_id,omitempty"`     // subscribe
+	QueueToken string `json:"queue_token,omitempty"` // claim; defaults to the ticket from join_queue
+}
+
+// socketMessage is a message to a WebSocket client
+// Status is the HTTP status the REST API gives the same outcome
+type socketMessage struct {
+	Type         string             `json:"type"` // subscribed, stock, queue, claim_result, pong, heartbeat or error
+	RequestID    string             `json:"request_id,omitempty"`
+	Stock        *live.Update       `json:"stock,omitempty"`
+	Queue        *model.QueueTicket `json:"queue,omitempty"`
+	Status       int                `json:"status,omitempty"`
+	Message      string             `json:"message,omitempty"`
+	Code         string             `json:"code,omitempty"` // Error code, as in the REST API's problem responses
+	Error        string             `json:"error,omitempty"`
+	RetryAfterMs int64              `json:"retry_after_ms,omitempty"`
+}
+
+// socketDeps are what a claim socket needs from the server
+type socketDeps struct {
+	svc        *service.CouponService
+	hub        *live.Hub
+	claimLimit *routeLimiter
+	queueLimit *routeLimiter
+	overload   *overload.Limiter
+	heartbeat  time.Duration
+	origins    []string // Browser origins allowed besides the server's own; "*" allows any
+}
+
+// claimSocketHandler handles GET /api/ws
+// A client subscribes to one coupon as one user, then receives stock and
+// queue position updates and can join the queue and claim on the same
+// connection. Claims go through the same rate limits, overload limiter and
+// ClaimCoupon path as POST /api/coupons/claim.
+func claimSocketHandler(deps socketDeps) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keys := rateLimitKeys{IP: c.ClientIP(), APIKey: callerID(c)}
+		server := websocket.Server{
+			Handshake: checkOrigin(deps.origins),
+			Handler: func(ws *websocket.Conn) {
+				ws.MaxPayloadBytes = 4 << 10
+				s := &claimSocket{socketDeps: deps, ws: ws, keys: keys, changed: make(chan struct{}, 1)}
+				s.serve()
+			},
+		}
+		server.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// checkOrigin refuses browser connections from other sites
+// Clients that send no Origin, such as services, are allowed; browsers always send one.
+func checkOrigin(allowed []string) func(*websocket.Config, *http.Request) error {
+	return func(config *websocket.Config, req *http.Request) error {
+		u, err := websocket.Origin(config, req)
+		if err != nil {
+			return err
+		}
+		if u == nil || u.Host == req.Host {
+			return nil
+		}
+		origin := u.Scheme + "://" + u.Host
+		for _, a := range allowed {
+			if a == "*" || a == origin {
+				return nil
+			}
+		}
+		return errors.New("origin not allowed")
+	}
+}
+
+// claimSocket is one client connection
+type claimSocket struct {
+	socketDeps
+	ws      *websocket.Conn
+	keys    rateLimitKeys
+	changed chan struct{} // Wakes the pusher when the subscription or ticket changes
+
+	mu         sync.Mutex
+	couponName string
+	userID     string
+	sub        *live.Subscription
+	ticket     *model.QueueTicket
+}
+
+// serve reads client messages until the connection closes
+func (s *claimSocket) serve() {
+	ctx, cancel := context.WithCancel(s.ws.Request().Context())
+	defer cancel()
+	defer s.ws.Close()
+	defer s.unsubscribe()
+
+	go s.push(ctx)
+
+	for {
+		var req socketRequest
+		if err := websocket.JSON.Receive(s.ws, &req); err != nil {
+			return
+		}
+
+		switch req.Type {
+		case "subscribe":
+			s.subscribe(ctx, &req)
+		case "join_queue":
+			s.joinQueue(ctx, &req)
+		case "claim":
+			s.claim(ctx, &req)
+		case "ping":
+			s.send(socketMessage{Type: "pong", RequestID: req.RequestID})
+		default:
+			s.sendError("error", req.RequestID, apperrors.ErrInvalidRequest.WithMessage("unknown message type"))
+		}
+	}
+}
+
+// subscribe switches the connection to a coupon and user
+func (s *claimSocket) subscribe(ctx context.Context, req *socketRequest) {
+	var invalid validation.Errors
+	if req.CouponName == "" {
+		invalid.Add("coupon_name", "is required")
+	}
+	invalid.Check("user_id", validation.UserID(req.UserID))
+	if err := invalid.Err(); err != nil {
+		s.sendError("error", req.RequestID, err)
+		return
+	}
+
+	sub, err := s.hub.Subscribe(ctx, req.CouponName)
+	if err != nil {
+		s.sendError("error", req.RequestID, err)
+		return
+	}
+
+	s.mu.Lock()
+	old := s.sub
+	s.couponName, s.userID, s.sub, s.ticket = req.CouponName, req.UserID, sub, nil
+	s.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+
+	s.send(socketMessage{Type: "subscribed", RequestID: req.RequestID, Status: http.StatusOK})
+	s.wake()
+}
+
+// joinQueue puts the user in the coupon's virtual queue
+// Position updates follow until the ticket is admitted, used or expired.
+func (s *claimSocket) joinQueue(ctx context.Context, req *socketRequest) {
+	couponName, userID, ok := s.subscription(req)
+	if !ok {
+		return
+	}
+
+	keys := s.keys
+	keys.UserID = userID
+	if ok, retryAfter := s.queueLimit.allow(ctx, keys); !ok {
+		s.sendError("error", req.RequestID, apperrors.ErrRateLimited.WithRetryAfter(retryAfter))
+		return
+	}
+
+	ticket, err := s.svc.JoinQueue(ctx, couponName, userID)
+	if err != nil {
+		s.sendError("error", req.RequestID, err)
+		return
+	}
+
+	s.mu.Lock()
+	s.ticket = ticket
+	s.mu.Unlock()
+	s.send(socketMessage{Type: "queue", RequestID: req.RequestID, Status: http.StatusCreated, Queue: ticket})
+	s.wake()
+}
+
+// claim claims the subscribed coupon for the subscribed user
+func (s *claimSocket) claim(ctx context.Context, req *socketRequest) {
+	couponName, userID, ok := s.subscription(req)
+	if !ok {
+		return
+	}
+	token := req.QueueToken
+	if token == "" {
+		s.mu.Lock()
+		if s.ticket != nil {
+			token = s.ticket.Token
+		}
+		s.mu.Unlock()
+	}
+
+	keys := s.keys
+	keys.UserID = userID
+	if ok, retryAfter := s.claimLimit.allow(ctx, keys); !ok {
+		s.sendError("claim_result", req.RequestID, apperrors.ErrRateLimited.WithRetryAfter(retryAfter))
+		return
+	}
+
+	done, err := s.overload.Acquire(ctx)
+	if err != nil {
+		s.sendError("claim_result", req.RequestID, apperrors.ErrOverloaded.WithRetryAfter(s.overload.RetryAfter()))
+		return
+	}
+
+	err = s.svc.ClaimCoupon(ctx, &model.ClaimCouponRequest{UserID: userID, CouponName: couponName, QueueToken: token})
+	if err != nil {
+		done(apperrors.From(err).Status >= http.StatusInternalServerError)
+		s.sendError("claim_result", req.RequestID, err)
+		return
+	}
+	done(false)
+
+	s.send(socketMessage{Type: "claim_result", RequestID: req.RequestID, Status: http.StatusOK, Message: "coupon claimed successfully"})
+
+	// Report the spent ticket now rather than at the next poll
+	s.mu.Lock()
+	ticket := s.ticket
+	s.mu.Unlock()
+	if ticket != nil && ticket.Token == token {
+		s.refreshTicket(ticket)
+	}
+}
+
+// subscription returns the subscribed coupon and user, replying with an error if there are none
+func (s *claimSocket) subscription(req *socketRequest) (string, string, bool) {
+	s.mu.Lock()
+	couponName, userID := s.couponName, s.userID
+	s.mu.Unlock()
+	if couponName == "" {
+		s.sendError("error", req.RequestID, apperrors.ErrInvalidRequest.WithMessage("subscribe to a coupon first"))
+		return "", "", false
+	}
+	return couponName, userID, true
+}
+
+// push sends stock updates, queue position changes and heartbeats
+func (s *claimSocket) push(ctx context.Context) {
+	heartbeat := time.NewTicker(s.heartbeat)
+	defer heartbeat.Stop()
+	poll := time.NewTimer(time.Hour)
+	defer poll.Stop()
+
+	var following string // Token of the ticket being polled
+	for {
+		s.mu.Lock()
+		sub, ticket := s.sub, s.ticket
+		s.mu.Unlock()
+
+		var updates, done <-chan struct{}
+		if sub != nil {
+			updates, done = sub.Updates(), sub.Done()
+		}
+
+		// Follow a new ticket until it is used or expires
+		if ticket != nil && ticket.Token != following {
+			following = ticket.Token
+			poll.Reset(0)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-done:
+			// Server shutting down
+			s.ws.Close()
+			return
+		case <-s.changed:
+		case <-updates:
+			s.send(socketMessage{Type: "stock", Stock: sub.Latest()})
+		case <-poll.C:
+			if next := s.refreshTicket(ticket); next > 0 {
+				poll.Reset(next)
+			}
+		case <-heartbeat.C:
+			s.send(socketMessage{Type: "heartbeat"})
+		}
+	}
+}
+
+// refreshTicket sends the ticket's status if it changed and returns when to check again (0 = stop)
+func (s *claimSocket) refreshTicket(ticket *model.QueueTicket) time.Duration {
+	if ticket == nil {
+		return 0
+	}
+	current, err := s.svc.GetQueueTicket(ticket.Token)
+	if err != nil {
+		return 0
+	}
+
+	s.mu.Lock()
+	if s.ticket == nil || s.ticket.Token != ticket.Token {
+		s.mu.Unlock()
+		return 0 // Replaced by a new subscription
+	}
+	changed := current.Status != s.ticket.Status || current.Position != s.ticket.Position
+	s.ticket = current
+	s.mu.Unlock()
+	if changed {
+		s.send(socketMessage{Type: "queue", Queue: current})
+	}
+
+	switch {
+	case current.Status == model.QueueStatusWaiting && current.PollAfterMs > 0:
+		return time.Duration(current.PollAfterMs) * time.Millisecond
+	case current.Status == model.QueueStatusWaiting || current.Status == model.QueueStatusAdmitted:
+		return time.Second
+	default:
+		return 0
+	}
+}
+
+// send writes a message; the connection serializes concurrent writers
+func (s *claimSocket) send(msg socketMessage) {
+	if err := websocket.JSON.Send(s.ws, msg); err != nil {
+		log.Printf("WebSocket: failed to send %s: %v", msg.Type, err)
+		s.ws.Close()
+	}
+}
+
+// sendError reports an error with the status, code and message the REST API gives it
+func (s *claimSocket) sendError(msgType, requestID string, err error) {
+	appErr, detail := problemDetail(err)
+	s.send(socketMessage{Type: msgType, RequestID: requestID, Status: appErr.Status, Code: appErr.Code,
+		Error: detail, RetryAfterMs: appErr.RetryAfter.Milliseconds()})
+}
+
+// wake tells the pusher the subscription or ticket changed
+func (s *claimSocket) wake() {
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+// unsubscribe releases the connection's stock subscription
+func (s *claimSocket) unsubscribe() {
+	s.mu.Lock()
+	sub := s.sub
+	s.sub = nil
+	s.mu.Unlock()
+	if sub != nil {
+		sub.Close()
+	}
+}
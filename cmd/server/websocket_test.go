@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"coupon-system/internal/live"
+	"coupon-system/internal/metrics"
+	"coupon-system/internal/model"
+	"coupon-system/internal/overload"
+	"coupon-system/internal/queue"
+	"coupon-system/internal/ratelimit"
+	"coupon-system/internal/repository/repotest"
+	"coupon-system/internal/service"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// socketServer serves POST /api/coupons/claim and /api/ws over one service and
+// one set of limits, so the same outcome can be compared on both
+func socketServer(t *testing.T, origins []string) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	registerValidators()
+
+	admission := queue.NewManager(queue.Options{AdmitRate: 1000, Tick: 5 * time.Millisecond})
+	admission.Start()
+	t.Cleanup(admission.Stop)
+
+	coupons := repotest.NewCouponRepository()
+	coupon := &model.Coupon{Name: "FLASH", Amount: 10, RemainingAmount: 10, IsActive: true, QueueEnabled: true, CreatedAt: time.Now()}
+	if err := coupons.CreateCoupon(context.Background(), coupon); err != nil {
+		t.Fatal(err)
+	}
+	svc := service.NewCouponService(coupons, repotest.NewClaimRepository(), service.WithAdmission(admission))
+
+	registry := metrics.NewRegistry()
+	hub := live.NewHub(coupons.GetCouponByName, live.Options{}, registry)
+	t.Cleanup(hub.Close)
+
+	limiter := newRateLimiter(registry, nil)
+	claimRoute := limiter.route("claim", routeLimits{User: ratelimit.Limit{Rate: 0.001, Burst: 2}})
+	queueRoute := limiter.route("queue", routeLimits{User: ratelimit.Limit{Rate: 0.001, Burst: 10}})
+
+	router := gin.New()
+	router.Use(problemDetails())
+	router.POST("/api/coupons/claim", claimRoute.middleware(), claimCouponHandler(svc))
+	router.GET("/api/ws", claimSocketHandler(socketDeps{
+		svc:        svc,
+		hub:        hub,
+		claimLimit: claimRoute,
+		queueLimit: queueRoute,
+		overload:   overload.NewLimiter(overload.Options{}),
+		heartbeat:  time.Minute,
+		origins:    origins,
+	}))
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// dialSocket opens /api/ws with the given Origin
+func dialSocket(server *httptest.Server, origin string) (*websocket.Conn, error) {
+	return websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/ws", "", origin)
+}
+
+// reply reads messages until the reply to requestID, skipping pushed updates
+func reply(t *testing.T, ws *websocket.Conn, requestID string) socketMessage {
+	t.Helper()
+	for {
+		msg := receive(t, ws)
+		if msg.RequestID == requestID {
+			return msg
+		}
+	}
+}
+
+// receive reads the next message
+func receive(t *testing.T, ws *websocket.Conn) socketMessage {
+	t.Helper()
+	_ = ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg socketMessage
+	if err := websocket.JSON.Receive(ws, &msg); err != nil {
+		t.Fatalf("receive: %v", err)
+	}
+	return msg
+}
+
+// TestSocketMatchesHTTP runs the queued claim flow over the WebSocket and
+// checks each failure has the status and code POST /api/coupons/claim gives it
+func TestSocketMatchesHTTP(t *testing.T) {
+	server := socketServer(t, nil)
+
+	// The same claims over HTTP: no queue token, then over the limit
+	var httpResults []problem
+	for i := 0; i < 3; i++ {
+		resp, err := http.Post(server.URL+"/api/coupons/claim", "application/json",
+			strings.NewReader(`{"user_id":"http-user","coupon_name":"FLASH"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body problem
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		httpResults = append(httpResults, body)
+	}
+
+	ws, err := dialSocket(server, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	send := func(req socketRequest) {
+		if err := websocket.JSON.Send(ws, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send(socketRequest{Type: "join_queue", RequestID: "j0"})
+	if msg := reply(t, ws, "j0"); msg.Type != "error" || msg.Code != "invalid_request" {
+		t.Errorf("join_queue before subscribe = %+v, want an invalid_request error", msg)
+	}
+
+	send(socketRequest{Type: "subscribe", RequestID: "s1", CouponName: "FLASH", UserID: "ws-user"})
+	if msg := reply(t, ws, "s1"); msg.Type != "subscribed" || msg.Status != http.StatusOK {
+		t.Fatalf("subscribe = %+v, want subscribed", msg)
+	}
+
+	send(socketRequest{Type: "claim", RequestID: "c1"})
+	msg := reply(t, ws, "c1")
+	if msg.Type != "claim_result" || msg.Status != httpResults[0].Status || msg.Code != httpResults[0].Code {
+		t.Errorf("claim without a ticket = %d %s, HTTP gives %d %s", msg.Status, msg.Code, httpResults[0].Status, httpResults[0].Code)
+	}
+
+	send(socketRequest{Type: "join_queue", RequestID: "j1"})
+	msg = reply(t, ws, "j1")
+	if msg.Type != "queue" || msg.Status != http.StatusCreated || msg.Queue == nil {
+		t.Fatalf("join_queue = %+v, want a queue ticket", msg)
+	}
+	for msg.Queue == nil || msg.Queue.Status != model.QueueStatusAdmitted {
+		msg = receive(t, ws)
+	}
+
+	send(socketRequest{Type: "claim", RequestID: "c2"})
+	if msg := reply(t, ws, "c2"); msg.Type != "claim_result" || msg.Status != http.StatusOK {
+		t.Errorf("claim with an admitted ticket = %+v, want 200", msg)
+	}
+
+	send(socketRequest{Type: "claim", RequestID: "c3"})
+	msg = reply(t, ws, "c3")
+	if msg.Type != "claim_result" || msg.Status != httpResults[2].Status || msg.Code != httpResults[2].Code {
+		t.Errorf("claim over the limit = %d %s, HTTP gives %d %s", msg.Status, msg.Code, httpResults[2].Status, httpResults[2].Code)
+	}
+	if msg.Status != http.StatusTooManyRequests || msg.RetryAfterMs <= 0 {
+		t.Errorf("claim over the limit = %+v, want 429 with retry_after_ms", msg)
+	}
+}
+
+// TestSocketChecksOrigin checks browsers can connect only from the server's
+// own origin or an allowed one
+func TestSocketChecksOrigin(t *testing.T) {
+	server := socketServer(t, []string{"https://shop.example.com"})
+
+	for _, tt := range []struct {
+		origin string
+		want   bool
+	}{
+		{server.URL, true},
+		{"https://shop.example.com", true},
+		{"https://evil.example.com", false},
+	} {
+		ws, err := dialSocket(server, tt.origin)
+		if err == nil {
+			ws.Close()
+		}
+		if connected := err == nil; connected != tt.want {
+			t.Errorf("origin %s: connected = %v, want %v (%v)", tt.origin, connected, tt.want, err)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Package authtest provides an in-memory API key repository for tests
+package authtest
+
+import (
+	"context"
+	"coupon-system/internal/model"
+	apperrors "coupon-system/pkg/errors"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Repository is a repository.APIKeyRepository backed by maps
+type Repository struct {
+	mu    sync.Mutex
+	keys  map[primitive.ObjectID]*model.APIKey
+	audit []*model.APIKeyAuditEntry
+}
+
+// NewRepository creates an empty repository
+func NewRepository() *Repository {
+	return &Repository{keys: make(map[primitive.ObjectID]*model.APIKey)}
+}
+
+func (r *Repository) CreateKey(_ context.Context, key *model.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if key.ID.IsZero() {
+		key.ID = primitive.NewObjectID()
+	}
+	stored := *key
+	r.keys[key.ID] = &stored
+	return nil
+}
+
+func (r *Repository) GetKey(_ context.Context, id string) (*model.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.get(id)
+}
+
+func (r *Repository) get(id string) (*model.APIKey, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, apperrors.ErrAPIKeyNotFound
+	}
+	key, ok := r.keys[objectID]
+	if !ok {
+		return nil, apperrors.ErrAPIKeyNotFound
+	}
+	copied := *key
+	return &copied, nil
+}
+
+func (r *Repository) GetKeyByHash(_ context.Context, hash string) (*model.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range r.keys {
+		if key.Hash == hash {
+			copied := *key
+			return &copied, nil
+		}
+	}
+	return nil, apperrors.ErrAPIKeyNotFound
+}
+
+func (r *Repository) ListKeys(_ context.Context) ([]*model.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := make([]*model.APIKey, 0, len(r.keys))
+	for _, key := range r.keys {
+		copied := *key
+		keys = append(keys, &copied)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID.Hex() < keys[j].ID.Hex() })
+	return keys, nil
+}
+
+func (r *Repository) RevokeKey(_ context.Context, id string, at time.Time) (*model.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key, err := r.get(id)
+	if err != nil {
+		return nil, err
+	}
+	stored := r.keys[key.ID]
+	if stored.RevokedAt == nil {
+		stored.RevokedAt = &at
+	}
+	copied := *stored
+	return &copied, nil
+}
+
+func (r *Repository) ReplaceKey(_ context.Context, oldID string, expiresAt time.Time, successor *model.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old, err := r.get(oldID)
+	if err != nil {
+		return err
+	}
+	stored := r.keys[old.ID]
+	if stored.RevokedAt != nil {
+		return apperrors.ErrAPIKeyRevoked
+	}
+	if successor.ID.IsZero() {
+		successor.ID = primitive.NewObjectID()
+	}
+	added := *successor
+	r.keys[successor.ID] = &added
+	if stored.ExpiresAt == nil || expiresAt.Before(*stored.ExpiresAt) {
+		stored.ExpiresAt = &expiresAt
+	}
+	stored.RotatedTo = &successor.ID
+	return nil
+}
+
+func (r *Repository) TouchKeys(_ context.Context, ids []primitive.ObjectID, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, id := range ids {
+		if key, ok := r.keys[id]; ok && (key.LastUsedAt == nil || at.After(*key.LastUsedAt)) {
+			key.LastUsedAt = &at
+		}
+	}
+	return nil
+}
+
+func (r *Repository) RecordUsage(_ context.Context, entries []*model.APIKeyAuditEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.audit = append(r.audit, entries...)
+	return nil
+}
+
+func (r *Repository) ListUsage(_ context.Context, keyID string, limit int) ([]*model.APIKeyAuditEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var entries []*model.APIKeyAuditEntry
+	for i := len(r.audit) - 1; i >= 0 && len(entries) < limit; i-- {
+		entry := r.audit[i]
+		if keyID == "" || (entry.KeyID != nil && entry.KeyID.Hex() == keyID) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
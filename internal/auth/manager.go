@@ -0,0 +1,426 @@
+// Package auth authenticates API keys and keeps an audit log of their use
+//
+// Keys are random secrets shown once, when they are issued or rotated; only
+// their SHA-256 hash is stored. Each key has a role, and routes name the roles
+// they accept. Requests are recorded in an audit log that is written in
+// batches in the background, so auditing adds no database write to a request.
+package auth
+
+import (
+	"context"
+	"coupon-system/internal/metrics"
+	"coupon-system/internal/model"
+	"coupon-system/internal/repository"
+	"coupon-system/internal/validation"
+	apperrors "coupon-system/pkg/errors"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// keyPrefix starts every issued key, so leaked keys are easy to search for
+const keyPrefix = "csk_"
+
+// maxCachedKeys bounds the cache of looked-up keys; unknown keys are cached
+// too, so a client trying random keys cannot grow it without limit
+const maxCachedKeys = 10000
+
+// roles are the valid key roles
+var roles = map[model.APIKeyRole]bool{
+	model.RoleAdmin:    true,
+	model.RoleSupport:  true,
+	model.RoleReadOnly: true,
+	model.RoleClaimer:  true,
+}
+
+// Options configures a Manager
+type Options struct {
+	CacheTTL      time.Duration // How long a looked-up key is trusted; bounds how late other instances see a revocation
+	RotationGrace time.Duration // How long a rotated key keeps working by default
+	AuditBuffer   int           // Audit entries waiting to be written; more are dropped and counted
+	AuditBatch    int           // Entries written per insert
+	AuditInterval time.Duration // How often buffered entries are written
+	TokenTTL      time.Duration // How long a token from IssueToken works
+	TokenSecret   []byte        // Signs tokens; instances must share it (default: random, so tokens only work on the issuing instance)
+}
+
+// Manager issues, authenticates and revokes API keys
+type Manager struct {
+	repo repository.APIKeyRepository
+	opts Options
+
+	mu    sync.Mutex
+	cache map[string]cachedKey // By hash, or "id:" and the ID for tokens
+
+	audit  chan *model.APIKeyAuditEntry
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	registry *metrics.Registry
+	dropped  *metrics.Counter
+}
+
+// cachedKey is a looked-up key; key is nil for an unknown hash
+type cachedKey struct {
+	key   *model.APIKey
+	until time.Time
+}
+
+// NewManager creates an API key manager and registers its metrics
+func NewManager(repo repository.APIKeyRepository, opts Options, registry *metrics.Registry) *Manager {
+	if opts.CacheTTL <= 0 {
+		opts.CacheTTL = 30 * time.Second
+	}
+	if opts.RotationGrace <= 0 {
+		opts.RotationGrace = 24 * time.Hour
+	}
+	if opts.AuditBuffer <= 0 {
+		opts.AuditBuffer = 10000
+	}
+	if opts.AuditBatch <= 0 {
+		opts.AuditBatch = 500
+	}
+	if opts.AuditInterval <= 0 {
+		opts.AuditInterval = time.Second
+	}
+	if opts.TokenTTL <= 0 {
+		opts.TokenTTL = 5 * time.Minute
+	}
+	if len(opts.TokenSecret) == 0 {
+		opts.TokenSecret = make([]byte, 32)
+		if _, err := rand.Read(opts.TokenSecret); err != nil {
+			panic("auth: cannot generate a token secret: " + err.Error())
+		}
+	}
+
+	return &Manager{
+		repo:     repo,
+		opts:     opts,
+		cache:    make(map[string]cachedKey),
+		audit:    make(chan *model.APIKeyAuditEntry, opts.AuditBuffer),
+		registry: registry,
+		dropped:  registry.Counter("auth_audit_dropped_total", "Audit entries dropped because the buffer was full"),
+	}
+}
+
+// Create issues a key and returns it with its secret, which is not returned again
+func (m *Manager) Create(ctx context.Context, req *model.CreateAPIKeyRequest) (*model.APIKey, string, error) {
+	var invalid validation.Errors
+	if req.Name == "" {
+		invalid.Add("name", "is required")
+	}
+	if !roles[req.Role] {
+		invalid.Add("role", "must be one of admin, support, read_only, claimer")
+	}
+	var expiresAt *time.Time
+	if req.ExpiresAt != "" {
+		parsed, err := time.Parse(time.RFC3339, req.ExpiresAt)
+		switch {
+		case err != nil:
+			invalid.Add("expires_at", "must be an RFC3339 timestamp, e.g. 2026-12-31T23:59:59Z")
+		case !parsed.After(time.Now()):
+			invalid.Add("expires_at", "must be in the future")
+		default:
+			expiresAt = &parsed
+		}
+	}
+	if err := invalid.Err(); err != nil {
+		return nil, "", err
+	}
+
+	key, secret, err := newKey(req.Name, req.Role)
+	if err != nil {
+		return nil, "", err
+	}
+	key.ExpiresAt = expiresAt
+	if err := m.repo.CreateKey(ctx, key); err != nil {
+		return nil, "", err
+	}
+	return key, secret, nil
+}
+
+// Bootstrap makes sure secret is an admin key, so a new deployment can issue
+// its first keys
+// A bootstrap key that was revoked stays revoked.
+func (m *Manager) Bootstrap(ctx context.Context, secret string) error {
+	if len(secret) < 16 {
+		return errors.New("the bootstrap admin key must be at least 16 characters")
+	}
+	key, err := m.repo.GetKeyByHash(ctx, hashKey(secret))
+	switch {
+	case err == nil:
+		if !key.Active(time.Now()) {
+			log.Printf("Auth: the bootstrap admin key %s is revoked or expired", key.Prefix)
+		}
+		return nil
+	case !errors.Is(err, apperrors.ErrAPIKeyNotFound):
+		return err
+	}
+
+	return m.repo.CreateKey(ctx, &model.APIKey{
+		Name:      "bootstrap",
+		Role:      model.RoleAdmin,
+		Prefix:    Prefix(secret),
+		Hash:      hashKey(secret),
+		CreatedAt: time.Now(),
+	})
+}
+
+// Get retrieves a key
+func (m *Manager) Get(ctx context.Context, id string) (*model.APIKey, error) {
+	return m.repo.GetKey(ctx, id)
+}
+
+// List returns every key, including revoked ones
+func (m *Manager) List(ctx context.Context) ([]*model.APIKey, error) {
+	return m.repo.ListKeys(ctx)
+}
+
+// Rotate issues a key with the same name and role that replaces the given one
+// The old key keeps working for the grace period (Options.RotationGrace if
+// zero), so clients can switch over without downtime.
+func (m *Manager) Rotate(ctx context.Context, id string, grace time.Duration) (*model.APIKey, string, error) {
+	old, err := m.repo.GetKey(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	if old.RevokedAt != nil {
+		return nil, "", apperrors.ErrAPIKeyRevoked
+	}
+	if grace <= 0 {
+		grace = m.opts.RotationGrace
+	}
+
+	key, secret, err := newKey(old.Name, old.Role)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := m.repo.ReplaceKey(ctx, id, key.CreatedAt.Add(grace), key); err != nil {
+		return nil, "", err
+	}
+	m.forget(old.ID)
+	return key, secret, nil
+}
+
+// Revoke stops a key from working
+// This instance stops accepting it at once; others within Options.CacheTTL.
+func (m *Manager) Revoke(ctx context.Context, id string) (*model.APIKey, error) {
+	key, err := m.repo.RevokeKey(ctx, id, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	m.forget(key.ID)
+	return key, nil
+}
+
+// Authenticate returns the key with the given secret if it is active
+// A revoked or expired key is returned along with ErrUnauthorized, so the
+// attempt can be audited under the key's name.
+func (m *Manager) Authenticate(ctx context.Context, secret string) (*model.APIKey, error) {
+	if secret == "" {
+		return nil, apperrors.ErrUnauthorized.WithMessage("X-API-Key header is required")
+	}
+
+	hash := hashKey(secret)
+	key, err := m.lookup(hash, func() (*model.APIKey, error) { return m.repo.GetKeyByHash(ctx, hash) })
+	if err != nil {
+		return nil, err
+	}
+	return checkKey(key, time.Now())
+}
+
+// lookup returns a cached key or fetches it; nil means there is no such key
+func (m *Manager) lookup(cacheKey string, fetch func() (*model.APIKey, error)) (*model.APIKey, error) {
+	now := time.Now()
+	m.mu.Lock()
+	entry, ok := m.cache[cacheKey]
+	m.mu.Unlock()
+	if ok && now.Before(entry.until) {
+		return entry.key, nil
+	}
+
+	key, err := fetch()
+	if err != nil && !errors.Is(err, apperrors.ErrAPIKeyNotFound) {
+		return nil, err
+	}
+	m.mu.Lock()
+	if len(m.cache) >= maxCachedKeys {
+		m.cache = make(map[string]cachedKey)
+	}
+	m.cache[cacheKey] = cachedKey{key: key, until: now.Add(m.opts.CacheTTL)}
+	m.mu.Unlock()
+	return key, nil
+}
+
+// checkKey returns key if it may be used at now
+// A revoked or expired key is returned along with ErrUnauthorized.
+func checkKey(key *model.APIKey, now time.Time) (*model.APIKey, error) {
+	switch {
+	case key == nil:
+		return nil, apperrors.ErrUnauthorized.WithMessage("API key is not valid")
+	case key.RevokedAt != nil:
+		return key, apperrors.ErrUnauthorized.WithMessage("API key has been revoked")
+	case !key.Active(now):
+		return key, apperrors.ErrUnauthorized.WithMessage("API key has expired")
+	}
+	return key, nil
+}
+
+// forget drops a key from the cache so a change to it applies at once
+func (m *Manager) forget(id primitive.ObjectID) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for hash, entry := range m.cache {
+		if entry.key != nil && entry.key.ID == id {
+			delete(m.cache, hash)
+		}
+	}
+}
+
+// Record queues an audit entry to be written in the background
+// It never blocks: when the buffer is full the entry is dropped and counted.
+func (m *Manager) Record(entry *model.APIKeyAuditEntry) {
+	m.registry.Counter("auth_requests_total", "Authenticated API requests by outcome", "outcome", string(entry.Outcome)).Inc()
+	select {
+	case m.audit <- entry:
+	default:
+		m.dropped.Inc()
+	}
+}
+
+// Audit returns the audit log, newest first, optionally only of one key
+func (m *Manager) Audit(ctx context.Context, keyID string, limit int) ([]*model.APIKeyAuditEntry, error) {
+	if keyID != "" {
+		if _, err := m.repo.GetKey(ctx, keyID); err != nil {
+			return nil, err
+		}
+	}
+	return m.repo.ListUsage(ctx, keyID, limit)
+}
+
+// Start launches the audit writer
+func (m *Manager) Start() {
+	var ctx context.Context
+	ctx, m.cancel = context.WithCancel(context.Background())
+	m.done = make(chan struct{})
+	go m.writeAudit(ctx)
+}
+
+// Stop writes the buffered audit entries and stops the audit writer
+func (m *Manager) Stop(ctx context.Context) error {
+	if m.cancel == nil {
+		return nil
+	}
+	m.cancel()
+	select {
+	case <-m.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// writeAudit writes audit entries in batches until the manager stops, then
+// writes what is left
+func (m *Manager) writeAudit(ctx context.Context) {
+	defer close(m.done)
+
+	ticker := time.NewTicker(m.opts.AuditInterval)
+	defer ticker.Stop()
+
+	batch := make([]*model.APIKeyAuditEntry, 0, m.opts.AuditBatch)
+	for {
+		select {
+		case entry := <-m.audit:
+			batch = append(batch, entry)
+			if len(batch) < m.opts.AuditBatch {
+				continue
+			}
+		case <-ticker.C:
+		case <-ctx.Done():
+			for {
+				select {
+				case entry := <-m.audit:
+					batch = append(batch, entry)
+					if len(batch) >= m.opts.AuditBatch {
+						m.flush(batch)
+						batch = batch[:0]
+					}
+				default:
+					m.flush(batch)
+					return
+				}
+			}
+		}
+		m.flush(batch)
+		batch = batch[:0]
+	}
+}
+
+// flush writes a batch of audit entries and updates the last use of their keys
+func (m *Manager) flush(batch []*model.APIKeyAuditEntry) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := m.repo.RecordUsage(ctx, batch); err != nil {
+		log.Printf("Auth: failed to write %d audit entries: %v", len(batch), err)
+	}
+
+	seen := make(map[primitive.ObjectID]bool)
+	var ids []primitive.ObjectID
+	var last time.Time
+	for _, entry := range batch {
+		if entry.KeyID != nil && entry.Outcome == model.AuditAllowed && !seen[*entry.KeyID] {
+			seen[*entry.KeyID] = true
+			ids = append(ids, *entry.KeyID)
+		}
+		if entry.At.After(last) {
+			last = entry.At
+		}
+	}
+	if err := m.repo.TouchKeys(ctx, ids, last); err != nil {
+		log.Printf("Auth: failed to update key last use: %v", err)
+	}
+}
+
+// newKey generates a key and its secret
+func newKey(name string, role model.APIKeyRole) (*model.APIKey, string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", err
+	}
+	secret := keyPrefix + hex.EncodeToString(buf)
+	return &model.APIKey{
+		ID:        primitive.NewObjectID(),
+		Name:      name,
+		Role:      role,
+		Prefix:    Prefix(secret),
+		Hash:      hashKey(secret),
+		CreatedAt: time.Now(),
+	}, secret, nil
+}
+
+// hashKey returns the stored form of a secret
+// Keys are long random strings, so a fast unsalted hash is enough to make a
+// leaked api_keys collection useless without making every request slow.
+func hashKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Prefix returns the part of a secret that is safe to show, e.g. in the audit log
+func Prefix(secret string) string {
+	if len(secret) > 12 {
+		return secret[:12]
+	}
+	return secret[:len(secret)/2]
+}
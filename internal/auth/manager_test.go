@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"context"
+	"coupon-system/internal/auth/authtest"
+	"coupon-system/internal/metrics"
+	"coupon-system/internal/model"
+	apperrors "coupon-system/pkg/errors"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestManager(t *testing.T) (*Manager, *authtest.Repository) {
+	t.Helper()
+	repo := authtest.NewRepository()
+	return NewManager(repo, Options{CacheTTL: time.Hour, AuditInterval: time.Hour}, metrics.NewRegistry()), repo
+}
+
+func TestCreateAndAuthenticate(t *testing.T) {
+	m, repo := newTestManager(t)
+	ctx := context.Background()
+
+	key, secret, err := m.Create(ctx, &model.CreateAPIKeyRequest{Name: "storefront", Role: model.RoleClaimer})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if !strings.HasPrefix(secret, keyPrefix) || key.Prefix != secret[:12] {
+		t.Errorf("secret %q with prefix %q", secret, key.Prefix)
+	}
+	stored, _ := repo.GetKey(ctx, key.ID.Hex())
+	if stored.Hash == "" || strings.Contains(stored.Hash, secret) {
+		t.Errorf("stored hash = %q", stored.Hash)
+	}
+
+	got, err := m.Authenticate(ctx, secret)
+	if err != nil || got.ID != key.ID {
+		t.Fatalf("Authenticate = %v, %v", got, err)
+	}
+	if !got.Allows(model.RoleClaimer) || got.Allows(model.RoleSupport) {
+		t.Errorf("claimer key allows %v", got.Role)
+	}
+	for _, secret := range []string{"", "csk_unknown"} {
+		if _, err := m.Authenticate(ctx, secret); !errors.Is(err, apperrors.ErrUnauthorized) {
+			t.Errorf("Authenticate(%q) = %v, want ErrUnauthorized", secret, err)
+		}
+	}
+
+	_, _, err = m.Create(ctx, &model.CreateAPIKeyRequest{Name: "x", Role: "owner", ExpiresAt: "2000-01-01T00:00:00Z"})
+	if !errors.Is(err, apperrors.ErrValidation) || !strings.Contains(err.Error(), "role") || !strings.Contains(err.Error(), "expires_at") {
+		t.Errorf("Create with bad role and expiry = %v", err)
+	}
+}
+
+func TestRevokeTakesEffectAtOnce(t *testing.T) {
+	m, _ := newTestManager(t)
+	ctx := context.Background()
+	key, secret, _ := m.Create(ctx, &model.CreateAPIKeyRequest{Name: "support", Role: model.RoleSupport})
+
+	// Cached with a one hour TTL, so only forgetting it makes the revocation visible
+	if _, err := m.Authenticate(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Revoke(ctx, key.ID.Hex()); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	got, err := m.Authenticate(ctx, secret)
+	if !errors.Is(err, apperrors.ErrUnauthorized) || got == nil || got.ID != key.ID {
+		t.Errorf("Authenticate after revoke = %v, %v", got, err)
+	}
+	if _, _, err := m.Rotate(ctx, key.ID.Hex(), 0); !errors.Is(err, apperrors.ErrAPIKeyRevoked) {
+		t.Errorf("Rotate revoked key = %v, want ErrAPIKeyRevoked", err)
+	}
+}
+
+func TestRotateKeepsOldKeyForGracePeriod(t *testing.T) {
+	m, repo := newTestManager(t)
+	ctx := context.Background()
+	old, oldSecret, _ := m.Create(ctx, &model.CreateAPIKeyRequest{Name: "dashboard", Role: model.RoleReadOnly})
+
+	key, secret, err := m.Rotate(ctx, old.ID.Hex(), time.Minute)
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if key.Name != old.Name || key.Role != old.Role || secret == oldSecret {
+		t.Errorf("successor = %+v", key)
+	}
+	if _, err := m.Authenticate(ctx, secret); err != nil {
+		t.Errorf("new key: %v", err)
+	}
+	if _, err := m.Authenticate(ctx, oldSecret); err != nil {
+		t.Errorf("old key within grace period: %v", err)
+	}
+	stored, _ := repo.GetKey(ctx, old.ID.Hex())
+	if stored.RotatedTo == nil || *stored.RotatedTo != key.ID || stored.ExpiresAt == nil || time.Until(*stored.ExpiresAt) > time.Minute {
+		t.Errorf("old key = %+v", stored)
+	}
+
+	// Once the grace period is over the old key is refused
+	_, _, _ = m.Rotate(ctx, key.ID.Hex(), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if _, err := m.Authenticate(ctx, secret); !errors.Is(err, apperrors.ErrUnauthorized) {
+		t.Errorf("rotated key after grace period = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestBootstrap(t *testing.T) {
+	m, repo := newTestManager(t)
+	ctx := context.Background()
+
+	if err := m.Bootstrap(ctx, "short"); err == nil {
+		t.Error("Bootstrap accepted a short key")
+	}
+	secret := "bootstrap-admin-key-0123456789"
+	for i := 0; i < 2; i++ {
+		if err := m.Bootstrap(ctx, secret); err != nil {
+			t.Fatalf("Bootstrap: %v", err)
+		}
+	}
+	keys, _ := repo.ListKeys(ctx)
+	if len(keys) != 1 || keys[0].Role != model.RoleAdmin {
+		t.Fatalf("keys = %+v, want one admin key", keys)
+	}
+	if _, err := m.Authenticate(ctx, secret); err != nil {
+		t.Errorf("bootstrap key: %v", err)
+	}
+}
+
+func TestAuditIsWrittenOnStop(t *testing.T) {
+	m, repo := newTestManager(t)
+	ctx := context.Background()
+	key, _, _ := m.Create(ctx, &model.CreateAPIKeyRequest{Name: "storefront", Role: model.RoleClaimer})
+	m.Start()
+
+	at := time.Now()
+	m.Record(&model.APIKeyAuditEntry{KeyID: &key.ID, Outcome: model.AuditAllowed, Method: "POST", Route: "/api/coupons/claim", Status: 200, At: at})
+	m.Record(&model.APIKeyAuditEntry{Outcome: model.AuditUnauthenticated, Method: "GET", Route: "/api/coupons", Status: 401, At: at})
+	if err := m.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	entries, _ := m.Audit(ctx, "", 10)
+	if len(entries) != 2 {
+		t.Fatalf("audit = %d entries, want 2", len(entries))
+	}
+	entries, _ = m.Audit(ctx, key.ID.Hex(), 10)
+	if len(entries) != 1 || entries[0].Route != "/api/coupons/claim" {
+		t.Errorf("audit of key = %+v", entries)
+	}
+	stored, _ := repo.GetKey(ctx, key.ID.Hex())
+	if stored.LastUsedAt == nil || !stored.LastUsedAt.Equal(at) {
+		t.Errorf("last used = %v, want %v", stored.LastUsedAt, at)
+	}
+}
+
+func TestTokens(t *testing.T) {
+	repo := authtest.NewRepository()
+	secret := []byte("shared-token-secret")
+	m := NewManager(repo, Options{CacheTTL: time.Hour, TokenTTL: time.Minute, TokenSecret: secret}, metrics.NewRegistry())
+	other := NewManager(repo, Options{CacheTTL: time.Hour, TokenSecret: secret}, metrics.NewRegistry())
+	ctx := context.Background()
+	key, _, _ := m.Create(ctx, &model.CreateAPIKeyRequest{Name: "storefront", Role: model.RoleClaimer})
+
+	token, expiresAt := m.IssueToken(key)
+	if d := time.Until(expiresAt); d <= 0 || d > time.Minute {
+		t.Errorf("token expires in %v, want within TokenTTL", d)
+	}
+	if got, err := other.AuthenticateToken(ctx, token); err != nil || got.ID != key.ID {
+		t.Fatalf("AuthenticateToken on another instance = %v, %v", got, err)
+	}
+
+	forged := strings.Replace(token, ".", "9.", 1) // A later expiry
+	unsigned := NewManager(repo, Options{}, metrics.NewRegistry())
+	expired, _ := NewManager(repo, Options{TokenTTL: time.Millisecond, TokenSecret: secret}, metrics.NewRegistry()).IssueToken(key)
+	for name, check := range map[string]func() error{
+		"tampered": func() error { _, err := m.AuthenticateToken(ctx, forged); return err },
+		"api key":  func() error { _, err := m.AuthenticateToken(ctx, "csk_unknown"); return err },
+		"secret":   func() error { _, err := unsigned.AuthenticateToken(ctx, token); return err },
+		"expired":  func() error { _, err := m.AuthenticateToken(ctx, expired); return err },
+	} {
+		if err := check(); !errors.Is(err, apperrors.ErrUnauthorized) {
+			t.Errorf("%s token: err = %v, want ErrUnauthorized", name, err)
+		}
+	}
+
+	if _, err := m.Revoke(ctx, key.ID.Hex()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.AuthenticateToken(ctx, token); !errors.Is(err, apperrors.ErrUnauthorized) {
+		t.Errorf("token of a revoked key: err = %v, want ErrUnauthorized", err)
+	}
+}
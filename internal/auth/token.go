@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"context"
+	"coupon-system/internal/model"
+	apperrors "coupon-system/pkg/errors"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tokenPrefix starts every token from IssueToken
+const tokenPrefix = "cst_"
+
+// IssueToken returns a short-lived token that stands for key
+// It is for clients that cannot send the X-API-Key header, such as a browser's
+// EventSource or WebSocket. The token is signed rather than stored, so every
+// instance sharing Options.TokenSecret accepts it; it stops working when it
+// expires or the key is revoked.
+func (m *Manager) IssueToken(key *model.APIKey) (string, time.Time) {
+	expiresAt := time.Now().Add(m.opts.TokenTTL).Truncate(time.Second)
+	payload := tokenPrefix + key.ID.Hex() + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+	return payload + "." + m.sign(payload), expiresAt
+}
+
+// AuthenticateToken returns the key a token from IssueToken stands for if the
+// token has not expired and the key is active
+func (m *Manager) AuthenticateToken(ctx context.Context, token string) (*model.APIKey, error) {
+	invalid := apperrors.ErrUnauthorized.WithMessage("token is not valid")
+	i := strings.LastIndex(token, ".")
+	if i < 0 || !strings.HasPrefix(token, tokenPrefix) {
+		return nil, invalid
+	}
+	payload, signature := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(m.sign(payload))) {
+		return nil, invalid
+	}
+	id, expires, ok := strings.Cut(strings.TrimPrefix(payload, tokenPrefix), ".")
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if !ok || err != nil {
+		return nil, invalid
+	}
+	now := time.Now()
+	if !now.Before(time.Unix(unix, 0)) {
+		return nil, apperrors.ErrUnauthorized.WithMessage("token has expired")
+	}
+
+	key, err := m.lookup("id:"+id, func() (*model.APIKey, error) { return m.repo.GetKey(ctx, id) })
+	if err != nil {
+		return nil, err
+	}
+	return checkKey(key, now)
+}
+
+// sign returns the signature of a token's payload
+func (m *Manager) sign(payload string) string {
+	mac := hmac.New(sha256.New, m.opts.TokenSecret)
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,31 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Cache is a key/value store with per-key expiry shared by the service layer
+// Implementations must be safe for concurrent use. A zero TTL means no expiry.
+type Cache interface {
+	// Get returns the value for a key; found is false when it is missing or expired
+	Get(ctx context.Context, key string) (value []byte, found bool, err error)
+
+	// Set stores a value, replacing any existing one
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// SetNX stores a value only if the key does not exist
+	// Returns true if the value was stored
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+
+	// Increment atomically adds delta to an integer counter and returns the new value
+	// A missing counter starts at zero and expires after ttl; the expiry of an
+	// existing counter is not changed, so counters can implement fixed windows
+	Increment(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error)
+
+	// Delete removes keys; missing keys are ignored
+	Delete(ctx context.Context, keys ...string) error
+
+	// Close releases the cache's resources
+	Close() error
+}
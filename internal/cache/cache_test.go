@@ -0,0 +1,181 @@
+package cache_test
+
+import (
+	"context"
+	"coupon-system/internal/cache"
+	"coupon-system/internal/cache/resptest"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestCacheBackends runs the same behaviour checks against every backend
+func TestCacheBackends(t *testing.T) {
+	server, err := resptest.NewServer()
+	if err != nil {
+		t.Fatalf("Failed to start RESP server: %v", err)
+	}
+	defer server.Close()
+
+	backends := map[string]cache.Cache{
+		"memory": cache.NewMemory(),
+		"resp":   cache.NewRESP(cache.RESPOptions{Addr: server.Addr(), PoolSize: 4}),
+	}
+
+	for name, c := range backends {
+		t.Run(name, func(t *testing.T) {
+			defer c.Close()
+			ctx := context.Background()
+
+			// Missing keys
+			if _, found, err := c.Get(ctx, "missing"); err != nil || found {
+				t.Fatalf("Get(missing) = found %v, err %v; want not found", found, err)
+			}
+
+			// Set and Get, including binary values
+			value := []byte("a\r\nb\x00c")
+			if err := c.Set(ctx, "key", value, 0); err != nil {
+				t.Fatalf("Set failed: %v", err)
+			}
+			got, found, err := c.Get(ctx, "key")
+			if err != nil || !found || string(got) != string(value) {
+				t.Fatalf("Get(key) = %q, %v, %v; want %q", got, found, err, value)
+			}
+
+			// SetNX only stores missing keys
+			if stored, err := c.SetNX(ctx, "key", []byte("other"), 0); err != nil || stored {
+				t.Fatalf("SetNX(existing) = %v, %v; want false", stored, err)
+			}
+			if stored, err := c.SetNX(ctx, "fresh", []byte("1"), 0); err != nil || !stored {
+				t.Fatalf("SetNX(missing) = %v, %v; want true", stored, err)
+			}
+
+			// Delete, including missing keys
+			if err := c.Delete(ctx, "key", "fresh", "missing"); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+			if _, found, _ := c.Get(ctx, "key"); found {
+				t.Fatal("key still present after Delete")
+			}
+
+			// Expiry
+			if err := c.Set(ctx, "short", []byte("1"), 20*time.Millisecond); err != nil {
+				t.Fatalf("Set with TTL failed: %v", err)
+			}
+			time.Sleep(50 * time.Millisecond)
+			if _, found, _ := c.Get(ctx, "short"); found {
+				t.Fatal("key still present after its TTL")
+			}
+			if stored, _ := c.SetNX(ctx, "short", []byte("2"), time.Minute); !stored {
+				t.Fatal("SetNX should succeed once the previous value expired")
+			}
+
+			// Counters start at zero, keep their first expiry and reject non-integers
+			if n, err := c.Increment(ctx, "counter", 2, 50*time.Millisecond); err != nil || n != 2 {
+				t.Fatalf("Increment(new) = %d, %v; want 2", n, err)
+			}
+			if n, err := c.Increment(ctx, "counter", 3, time.Hour); err != nil || n != 5 {
+				t.Fatalf("Increment(existing) = %d, %v; want 5", n, err)
+			}
+			time.Sleep(80 * time.Millisecond)
+			if n, err := c.Increment(ctx, "counter", 1, time.Minute); err != nil || n != 1 {
+				t.Fatalf("Increment(after expiry) = %d, %v; want 1", n, err)
+			}
+			if err := c.Set(ctx, "text", []byte("abc"), 0); err != nil {
+				t.Fatalf("Set failed: %v", err)
+			}
+			if _, err := c.Increment(ctx, "text", 1, 0); err == nil {
+				t.Fatal("Increment of a non-integer value succeeded")
+			}
+
+			// Exactly one concurrent SetNX wins
+			var (
+				wg   sync.WaitGroup
+				mu   sync.Mutex
+				wins int
+			)
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					stored, err := c.SetNX(ctx, "race", []byte(fmt.Sprint(i)), time.Minute)
+					if err != nil {
+						t.Errorf("SetNX failed: %v", err)
+						return
+					}
+					if stored {
+						mu.Lock()
+						wins++
+						mu.Unlock()
+					}
+				}(i)
+			}
+			wg.Wait()
+			if wins != 1 {
+				t.Fatalf("%d concurrent SetNX calls won; want 1", wins)
+			}
+
+			// Concurrent increments are not lost
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if _, err := c.Increment(ctx, "hits", 1, time.Minute); err != nil {
+						t.Errorf("Increment failed: %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+			if n, _ := c.Increment(ctx, "hits", 0, time.Minute); n != 20 {
+				t.Fatalf("20 concurrent increments counted %d", n)
+			}
+		})
+	}
+}
+
+// TestRESPConnectionReuse checks that connections are pooled rather than dialed per command
+func TestRESPConnectionReuse(t *testing.T) {
+	server, err := resptest.NewServer()
+	if err != nil {
+		t.Fatalf("Failed to start RESP server: %v", err)
+	}
+	defer server.Close()
+
+	c := cache.NewRESP(cache.RESPOptions{Addr: server.Addr(), PoolSize: 2})
+	defer c.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 50; i++ {
+		if err := c.Set(ctx, "key", []byte("value"), time.Minute); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+	if n := server.Connections(); n != 1 {
+		t.Fatalf("Sequential commands opened %d connections; want 1", n)
+	}
+}
+
+// TestRESPServerUnavailable checks that errors surface instead of hanging
+func TestRESPServerUnavailable(t *testing.T) {
+	server, err := resptest.NewServer()
+	if err != nil {
+		t.Fatalf("Failed to start RESP server: %v", err)
+	}
+	addr := server.Addr()
+	server.Close()
+
+	c := cache.NewRESP(cache.RESPOptions{Addr: addr, DialTimeout: 200 * time.Millisecond})
+	defer c.Close()
+
+	err = c.Ping(context.Background())
+	if err == nil {
+		t.Fatal("Ping succeeded against a closed server")
+	}
+
+	var serverErr cache.ServerError
+	if errors.As(err, &serverErr) {
+		t.Fatalf("Connection failure reported as a server error: %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// sweepEvery is the number of writes between sweeps of expired keys
+const sweepEvery = 1024
+
+// memoryCache is an in-process Cache for single-instance deployments and tests
+type memoryCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	writes  int
+}
+
+// memoryEntry is a stored value; a zero expiresAt never expires
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// NewMemory creates an in-process cache
+func NewMemory() Cache {
+	return &memoryCache{entries: make(map[string]memoryEntry)}
+}
+
+// Get returns the value for a key
+func (c *memoryCache) Get(_ context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.lookup(key, time.Now())
+	if !ok {
+		return nil, false, nil
+	}
+	return append([]byte(nil), entry.value...), true, nil
+}
+
+// Set stores a value, replacing any existing one
+func (c *memoryCache) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.store(key, value, ttl, time.Now())
+	return nil
+}
+
+// SetNX stores a value only if the key does not exist
+func (c *memoryCache) SetNX(_ context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if _, ok := c.lookup(key, now); ok {
+		return false, nil
+	}
+	c.store(key, value, ttl, now)
+	return true, nil
+}
+
+// Increment atomically adds delta to an integer counter
+func (c *memoryCache) Increment(_ context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	entry, ok := c.lookup(key, now)
+	if !ok {
+		c.store(key, []byte(strconv.FormatInt(delta, 10)), ttl, now)
+		return delta, nil
+	}
+
+	value, err := strconv.ParseInt(string(entry.value), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cache: value of %s is not an integer", key)
+	}
+	value += delta
+	entry.value = []byte(strconv.FormatInt(value, 10))
+	c.entries[key] = entry
+	return value, nil
+}
+
+// Delete removes keys
+func (c *memoryCache) Delete(_ context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		delete(c.entries, key)
+	}
+	return nil
+}
+
+// Close is a no-op
+func (c *memoryCache) Close() error {
+	return nil
+}
+
+// lookup returns a live entry, dropping it if expired; the caller holds c.mu
+func (c *memoryCache) lookup(key string, now time.Time) (memoryEntry, bool) {
+	entry, ok := c.entries[key]
+	if !ok {
+		return memoryEntry{}, false
+	}
+	if !entry.expiresAt.IsZero() && !now.Before(entry.expiresAt) {
+		delete(c.entries, key)
+		return memoryEntry{}, false
+	}
+	return entry, true
+}
+
+// store writes an entry and periodically sweeps expired ones; the caller holds c.mu
+func (c *memoryCache) store(key string, value []byte, ttl time.Duration, now time.Time) {
+	entry := memoryEntry{value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		entry.expiresAt = now.Add(ttl)
+	}
+	c.entries[key] = entry
+
+	c.writes++
+	if c.writes%sweepEvery == 0 {
+		for k, e := range c.entries {
+			if !e.expiresAt.IsZero() && !now.Before(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+	}
+}
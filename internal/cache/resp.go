@@ -0,0 +1,427 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// RESPOptions configures a cache backed by a Redis-protocol server
+type RESPOptions struct {
+	Addr        string        // host:port
+	Password    string        // Sent with AUTH when set
+	DB          int           // Selected with SELECT when non-zero
+	PoolSize    int           // Idle connections kept open
+	DialTimeout time.Duration // Timeout for establishing a connection
+	IOTimeout   time.Duration // Per-command timeout when the context has no deadline
+}
+
+// ServerError is an error reply from the server
+type ServerError string
+
+func (e ServerError) Error() string {
+	return "resp: " + string(e)
+}
+
+// RESPCache is a Cache speaking RESP2 to Redis or any compatible server
+type RESPCache struct {
+	opts RESPOptions
+	pool chan *respConn
+}
+
+// respConn is one pooled connection
+type respConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+// NewRESP creates a cache for a Redis-protocol server
+// Connections are opened lazily; use Ping to check the server at startup
+func NewRESP(opts RESPOptions) *RESPCache {
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = 16
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = 2 * time.Second
+	}
+	if opts.IOTimeout <= 0 {
+		opts.IOTimeout = time.Second
+	}
+
+	return &RESPCache{
+		opts: opts,
+		pool: make(chan *respConn, opts.PoolSize),
+	}
+}
+
+// Ping checks that the server is reachable
+func (c *RESPCache) Ping(ctx context.Context) error {
+	_, err := c.do(ctx, "PING")
+	return err
+}
+
+// Get returns the value for a key
+func (c *RESPCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := c.do(ctx, "GET", key)
+	if err != nil {
+		return nil, false, err
+	}
+	if reply == nil {
+		return nil, false, nil
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("resp: unexpected GET reply %T", reply)
+	}
+	return value, true, nil
+}
+
+// Set stores a value, replacing any existing one
+func (c *RESPCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := c.do(ctx, setArgs(key, value, ttl, false)...)
+	return err
+}
+
+// SetNX stores a value only if the key does not exist
+func (c *RESPCache) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	reply, err := c.do(ctx, setArgs(key, value, ttl, true)...)
+	if err != nil {
+		return false, err
+	}
+	return reply != nil, nil
+}
+
+// Increment atomically adds delta to an integer counter
+// SET NX creates the counter with its expiry and INCRBY adds to it; both run
+// in one MULTI/EXEC transaction so the counter can never lose its expiry
+func (c *RESPCache) Increment(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	reply, err := c.transaction(ctx,
+		setArgs(key, []byte("0"), ttl, true),
+		[]interface{}{"INCRBY", key, strconv.FormatInt(delta, 10)},
+	)
+	if err != nil {
+		return 0, err
+	}
+	if len(reply) != 2 {
+		return 0, fmt.Errorf("resp: unexpected EXEC reply with %d results", len(reply))
+	}
+	if err, ok := reply[1].(error); ok {
+		return 0, err
+	}
+	value, ok := reply[1].(int64)
+	if !ok {
+		return 0, fmt.Errorf("resp: unexpected INCRBY reply %T", reply[1])
+	}
+	return value, nil
+}
+
+// Delete removes keys
+func (c *RESPCache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	args := make([]interface{}, 0, len(keys)+1)
+	args = append(args, "DEL")
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	_, err := c.do(ctx, args...)
+	return err
+}
+
+// Close closes the idle connections
+func (c *RESPCache) Close() error {
+	for {
+		select {
+		case rc := <-c.pool:
+			rc.conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// setArgs builds a SET command with an optional millisecond expiry and NX
+func setArgs(key string, value []byte, ttl time.Duration, nx bool) []interface{} {
+	args := []interface{}{"SET", key, value}
+	if ttl > 0 {
+		ms := ttl.Milliseconds()
+		if ms == 0 {
+			ms = 1
+		}
+		args = append(args, "PX", strconv.FormatInt(ms, 10))
+	}
+	if nx {
+		args = append(args, "NX")
+	}
+	return args
+}
+
+// do sends one command and reads its reply
+// Connections are reused unless the exchange failed at the network level
+func (c *RESPCache) do(ctx context.Context, args ...interface{}) (interface{}, error) {
+	rc, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := rc.roundTrip(ctx, c.opts.IOTimeout, args)
+	var serverErr ServerError
+	if err != nil && !errors.As(err, &serverErr) {
+		rc.conn.Close()
+		return nil, err
+	}
+
+	c.put(rc)
+	return reply, err
+}
+
+// transaction runs commands in a MULTI/EXEC block, pipelined in one round trip
+// Returns the EXEC results; a failed command's result is its ServerError
+func (c *RESPCache) transaction(ctx context.Context, commands ...[]interface{}) ([]interface{}, error) {
+	rc, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := rc.transaction(ctx, c.opts.IOTimeout, commands)
+	var serverErr ServerError
+	if err != nil && !errors.As(err, &serverErr) {
+		rc.conn.Close()
+		return nil, err
+	}
+
+	c.put(rc)
+	return results, err
+}
+
+// get takes an idle connection or dials a new one
+func (c *RESPCache) get(ctx context.Context) (*respConn, error) {
+	select {
+	case rc := <-c.pool:
+		return rc, nil
+	default:
+	}
+
+	dialer := net.Dialer{Timeout: c.opts.DialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", c.opts.Addr)
+	if err != nil {
+		return nil, err
+	}
+	rc := &respConn{conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+
+	if c.opts.Password != "" {
+		if _, err := rc.roundTrip(ctx, c.opts.IOTimeout, []interface{}{"AUTH", c.opts.Password}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if c.opts.DB != 0 {
+		if _, err := rc.roundTrip(ctx, c.opts.IOTimeout, []interface{}{"SELECT", strconv.Itoa(c.opts.DB)}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return rc, nil
+}
+
+// put returns a connection to the pool, closing it if the pool is full
+func (c *RESPCache) put(rc *respConn) {
+	select {
+	case c.pool <- rc:
+	default:
+		rc.conn.Close()
+	}
+}
+
+// roundTrip writes a command and reads its reply
+func (rc *respConn) roundTrip(ctx context.Context, timeout time.Duration, args []interface{}) (interface{}, error) {
+	if err := rc.setDeadline(ctx, timeout); err != nil {
+		return nil, err
+	}
+	if err := rc.writeCommand(args); err != nil {
+		return nil, err
+	}
+	if err := rc.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	return ReadReply(rc.r)
+}
+
+// transaction writes MULTI, the commands and EXEC in one batch and reads all replies
+func (rc *respConn) transaction(ctx context.Context, timeout time.Duration, commands [][]interface{}) ([]interface{}, error) {
+	if err := rc.setDeadline(ctx, timeout); err != nil {
+		return nil, err
+	}
+
+	batch := append([][]interface{}{{"MULTI"}}, commands...)
+	batch = append(batch, []interface{}{"EXEC"})
+	for _, args := range batch {
+		if err := rc.writeCommand(args); err != nil {
+			return nil, err
+		}
+	}
+	if err := rc.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	// MULTI and every queued command answer +OK / +QUEUED; read them all so the
+	// connection stays in sync even when one was rejected
+	var queueErr error
+	for range batch[:len(batch)-1] {
+		if _, err := ReadReply(rc.r); err != nil {
+			var serverErr ServerError
+			if !errors.As(err, &serverErr) {
+				return nil, err
+			}
+			if queueErr == nil {
+				queueErr = err
+			}
+		}
+	}
+
+	reply, err := readExecReply(rc.r)
+	if err != nil {
+		return nil, err
+	}
+	if queueErr != nil {
+		return nil, queueErr
+	}
+	return reply, nil
+}
+
+// setDeadline applies the context deadline, or the default timeout
+func (rc *respConn) setDeadline(ctx context.Context, timeout time.Duration) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(timeout)
+	}
+	return rc.conn.SetDeadline(deadline)
+}
+
+// writeCommand buffers a command as an array of bulk strings
+func (rc *respConn) writeCommand(args []interface{}) error {
+	fmt.Fprintf(rc.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		var b []byte
+		switch v := arg.(type) {
+		case string:
+			b = []byte(v)
+		case []byte:
+			b = v
+		default:
+			return fmt.Errorf("resp: unsupported argument type %T", arg)
+		}
+		fmt.Fprintf(rc.w, "$%d\r\n", len(b))
+		rc.w.Write(b)
+		rc.w.WriteString("\r\n")
+	}
+	return nil
+}
+
+// readExecReply reads the EXEC array, keeping per-command errors as values
+func readExecReply(r *bufio.Reader) ([]interface{}, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) > 0 && line[0] == '-' {
+		return nil, ServerError(line[1:])
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return nil, fmt.Errorf("resp: unexpected EXEC reply %q", line)
+	}
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil {
+		return nil, fmt.Errorf("resp: invalid array length %q", line[1:])
+	}
+	if n < 0 {
+		return nil, errors.New("resp: transaction aborted")
+	}
+
+	results := make([]interface{}, n)
+	for i := range results {
+		value, err := ReadReply(r)
+		var serverErr ServerError
+		switch {
+		case errors.As(err, &serverErr):
+			results[i] = serverErr
+		case err != nil:
+			return nil, err
+		default:
+			results[i] = value
+		}
+	}
+	return results, nil
+}
+
+// ReadReply reads one RESP2 value
+// Simple strings are returned as string, integers as int64, bulk strings as
+// []byte (nil for a null bulk string), arrays as []interface{} and error
+// replies as a ServerError
+func ReadReply(r *bufio.Reader) (interface{}, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("resp: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return string(line[1:]), nil
+	case '-':
+		return nil, ServerError(line[1:])
+	case ':':
+		return strconv.ParseInt(string(line[1:]), 10, 64)
+	case '$':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return nil, fmt.Errorf("resp: invalid bulk length %q", line[1:])
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return nil, fmt.Errorf("resp: invalid array length %q", line[1:])
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = ReadReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("resp: unexpected reply type %q", line[0])
+}
+
+// readLine reads a CRLF-terminated line without the terminator
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, errors.New("resp: malformed line")
+	}
+	return line[:len(line)-2], nil
+}
@@ -0,0 +1,300 @@
+// Package resptest provides an in-process Redis-protocol server for tests
+// It implements the small command subset the cache uses: PING, AUTH, SELECT,
+// GET, SET (EX, PX, NX, XX), INCRBY, DEL, EXISTS, FLUSHALL and MULTI/EXEC.
+package resptest
+
+import (
+	"bufio"
+	"coupon-system/internal/cache"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is a RESP2 server backed by a map
+type Server struct {
+	listener net.Listener
+
+	mu      sync.Mutex
+	data    map[string]entry
+	conns   map[net.Conn]struct{}
+	closed  bool
+	wg      sync.WaitGroup
+	clients int
+}
+
+// entry is a stored value; a zero expiresAt never expires
+type entry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// NewServer starts a server on a random local port
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		listener: listener,
+		data:     make(map[string]entry),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr returns the host:port the server listens on
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Connections returns the number of connections accepted so far
+func (s *Server) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.clients
+}
+
+// Close stops the server and closes all client connections
+func (s *Server) Close() {
+	s.mu.Lock()
+	s.closed = true
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.listener.Close()
+	s.wg.Wait()
+}
+
+// serve accepts connections until the listener is closed
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.clients++
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+}
+
+// handle runs commands from one connection
+func (s *Server) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+
+	// Commands queued between MULTI and EXEC; nil outside a transaction
+	var queued [][]string
+	for {
+		request, err := cache.ReadReply(r)
+		if err != nil {
+			return
+		}
+		items, ok := request.([]interface{})
+		if !ok || len(items) == 0 {
+			writeError(w, "ERR protocol error: expected an array of bulk strings")
+		} else {
+			args := make([]string, len(items))
+			for i, item := range items {
+				b, _ := item.([]byte)
+				args[i] = string(b)
+			}
+
+			switch command := strings.ToUpper(args[0]); {
+			case command == "MULTI" && queued == nil:
+				queued = [][]string{}
+				w.WriteString("+OK\r\n")
+			case command == "MULTI":
+				writeError(w, "ERR MULTI calls can not be nested")
+			case command == "EXEC" && queued == nil:
+				writeError(w, "ERR EXEC without MULTI")
+			case command == "EXEC":
+				s.exec(w, queued)
+				queued = nil
+			case queued != nil:
+				queued = append(queued, args)
+				w.WriteString("+QUEUED\r\n")
+			default:
+				s.mu.Lock()
+				s.execute(w, args)
+				s.mu.Unlock()
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// exec runs a transaction's queued commands atomically
+func (s *Server) exec(w *bufio.Writer, commands [][]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fmt.Fprintf(w, "*%d\r\n", len(commands))
+	for _, args := range commands {
+		s.execute(w, args)
+	}
+}
+
+// execute runs one command and writes its reply; the caller holds s.mu
+func (s *Server) execute(w *bufio.Writer, args []string) {
+	now := time.Now()
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		w.WriteString("+PONG\r\n")
+	case "AUTH", "SELECT":
+		w.WriteString("+OK\r\n")
+	case "FLUSHALL":
+		s.data = make(map[string]entry)
+		w.WriteString("+OK\r\n")
+	case "GET":
+		if len(args) != 2 {
+			writeError(w, "ERR wrong number of arguments for 'get' command")
+			return
+		}
+		e, ok := s.lookup(args[1], now)
+		if !ok {
+			w.WriteString("$-1\r\n")
+			return
+		}
+		writeBulk(w, e.value)
+	case "SET":
+		s.set(w, args, now)
+	case "INCRBY":
+		if len(args) != 3 {
+			writeError(w, "ERR wrong number of arguments for 'incrby' command")
+			return
+		}
+		delta, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			writeError(w, "ERR value is not an integer or out of range")
+			return
+		}
+		e, _ := s.lookup(args[1], now)
+		value := int64(0)
+		if e.value != nil {
+			if value, err = strconv.ParseInt(string(e.value), 10, 64); err != nil {
+				writeError(w, "ERR value is not an integer or out of range")
+				return
+			}
+		}
+		value += delta
+		e.value = []byte(strconv.FormatInt(value, 10))
+		s.data[args[1]] = e
+		fmt.Fprintf(w, ":%d\r\n", value)
+	case "DEL", "EXISTS":
+		var n int
+		for _, key := range args[1:] {
+			if _, ok := s.lookup(key, now); ok {
+				n++
+				if strings.EqualFold(args[0], "DEL") {
+					delete(s.data, key)
+				}
+			}
+		}
+		fmt.Fprintf(w, ":%d\r\n", n)
+	default:
+		writeError(w, fmt.Sprintf("ERR unknown command '%s'", args[0]))
+	}
+}
+
+// set implements SET key value [EX seconds|PX milliseconds] [NX|XX]
+func (s *Server) set(w *bufio.Writer, args []string, now time.Time) {
+	if len(args) < 3 {
+		writeError(w, "ERR wrong number of arguments for 'set' command")
+		return
+	}
+
+	key := args[1]
+	e := entry{value: []byte(args[2])}
+	var nx, xx bool
+	for i := 3; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "EX", "PX":
+			if i+1 >= len(args) {
+				writeError(w, "ERR syntax error")
+				return
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || n <= 0 {
+				writeError(w, "ERR invalid expire time in 'set' command")
+				return
+			}
+			unit := time.Millisecond
+			if strings.EqualFold(args[i], "EX") {
+				unit = time.Second
+			}
+			e.expiresAt = now.Add(time.Duration(n) * unit)
+			i++
+		default:
+			writeError(w, "ERR syntax error")
+			return
+		}
+	}
+
+	_, exists := s.lookup(key, now)
+	if (nx && exists) || (xx && !exists) {
+		w.WriteString("$-1\r\n")
+		return
+	}
+	s.data[key] = e
+	w.WriteString("+OK\r\n")
+}
+
+// lookup returns a live entry, dropping it if expired; the caller holds s.mu
+func (s *Server) lookup(key string, now time.Time) (entry, bool) {
+	e, ok := s.data[key]
+	if !ok {
+		return entry{}, false
+	}
+	if !e.expiresAt.IsZero() && !now.Before(e.expiresAt) {
+		delete(s.data, key)
+		return entry{}, false
+	}
+	return e, true
+}
+
+// writeBulk writes a bulk string reply
+func writeBulk(w *bufio.Writer, b []byte) {
+	fmt.Fprintf(w, "$%d\r\n", len(b))
+	w.Write(b)
+	w.WriteString("\r\n")
+}
+
+// writeError writes an error reply
+func writeError(w *bufio.Writer, msg string) {
+	w.WriteString("-" + msg + "\r\n")
+}
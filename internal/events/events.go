@@ -0,0 +1,83 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Event types
+const (
+	TypeCouponCreated   = "coupon.created"
+	TypeCouponClaimed   = "coupon.claimed"
+	TypeClaimCancelled  = "claim.cancelled"
+	TypeCouponSoldOut   = "coupon.sold_out"
+	TypeWaitlistGranted = "waitlist.granted"
+)
+
+// Event is a domain event emitted by the coupon service
+// ID is set for events delivered from the outbox; delivery is at least once,
+// so consumers use it to drop duplicates
+type Event struct {
+	ID         string                 `json:"id,omitempty"`
+	Type       string                 `json:"type"`
+	CouponName string                 `json:"coupon_name"`
+	UserID     string                 `json:"user_id,omitempty"`
+	Key        string                 `json:"-"` // Optional outbox dedupe key: only the first event with a key is stored
+	Data       map[string]interface{} `json:"data,omitempty"`
+	OccurredAt time.Time              `json:"occurred_at"`
+}
+
+// Publisher delivers domain events
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// Nop is a Publisher that discards events
+type Nop struct{}
+
+// Publish discards the event
+func (Nop) Publish(context.Context, Event) error { return nil }
+
+// Bus is an in-process Publisher that fans events out to subscribers
+// Subscribers are called synchronously and must not block
+type Bus struct {
+	mu          sync.RWMutex
+	nextID      int
+	subscribers map[int]func(Event)
+}
+
+// NewBus creates a new in-process event bus
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[int]func(Event))}
+}
+
+// Subscribe registers fn for all events and returns a function that removes it
+func (b *Bus) Subscribe(fn func(Event)) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	b.subscribers[id] = fn
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers, id)
+	}
+}
+
+// Publish delivers the event to every subscriber
+func (b *Bus) Publish(_ context.Context, event Event) error {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, fn := range b.subscribers {
+		fn(event)
+	}
+	return nil
+}
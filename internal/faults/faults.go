@@ -0,0 +1,201 @@
+// Package faults injects errors, latency and partial failures into repository
+// calls so failure handling can be exercised in tests and staging
+package faults
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Error kinds with a special meaning; any other message fails with a plain error
+const (
+	ErrorNetwork = "network" // A MongoDB network error (transient, retried by the resilience layer)
+	ErrorTimeout = "timeout" // context.DeadlineExceeded
+)
+
+// Fault describes what to inject into the calls of one method
+type Fault struct {
+	Error       string  `json:"error,omitempty"`       // Fail with this error; empty injects latency only
+	Partial     bool    `json:"partial,omitempty"`     // Apply the call first, then fail (a lost acknowledgement)
+	LatencyMS   int     `json:"latency_ms,omitempty"`  // Delay before the call
+	Probability float64 `json:"probability,omitempty"` // Chance per call; 0 means every call
+	Times       int     `json:"times,omitempty"`       // Remove the fault after this many injections; 0 keeps it
+}
+
+// validate checks a fault's fields
+func (f Fault) validate() error {
+	switch {
+	case f.Probability < 0 || f.Probability > 1:
+		return errors.New("probability must be between 0 and 1")
+	case f.LatencyMS < 0:
+		return errors.New("latency_ms must not be negative")
+	case f.Times < 0:
+		return errors.New("times must not be negative")
+	case f.Partial && f.Error == "":
+		return errors.New("a partial failure needs an error")
+	case f.Error == "" && f.LatencyMS == 0:
+		return errors.New("a fault needs an error or latency")
+	}
+	return nil
+}
+
+// err builds the error the fault injects
+func (f Fault) err(method string) error {
+	switch f.Error {
+	case ErrorNetwork:
+		return mongo.CommandError{
+			Message: "injected network error in " + method,
+			Labels:  []string{"NetworkError"},
+		}
+	case ErrorTimeout:
+		return fmt.Errorf("injected timeout in %s: %w", method, context.DeadlineExceeded)
+	default:
+		return fmt.Errorf("injected fault in %s: %s", method, f.Error)
+	}
+}
+
+// Injector holds the active faults, keyed by method name
+// It is safe for concurrent use and can be changed while calls are running.
+type Injector struct {
+	mu      sync.Mutex
+	methods map[string]bool
+	faults  map[string]*Fault
+	rand    *rand.Rand
+}
+
+// NewInjector creates an injector with no active faults
+func NewInjector() *Injector {
+	return &Injector{
+		methods: make(map[string]bool),
+		faults:  make(map[string]*Fault),
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Register declares methods that faults can be set on
+func (i *Injector) Register(methods ...string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, method := range methods {
+		i.methods[method] = true
+	}
+}
+
+// Methods returns the registered method names, sorted
+func (i *Injector) Methods() []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	methods := make([]string, 0, len(i.methods))
+	for method := range i.methods {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// Set activates a fault on a method, replacing any previous one
+func (i *Injector) Set(method string, f Fault) error {
+	if err := f.validate(); err != nil {
+		return err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if !i.methods[method] {
+		return fmt.Errorf("unknown method %q", method)
+	}
+	i.faults[method] = &f
+	return nil
+}
+
+// Clear removes the fault on a method
+func (i *Injector) Clear(method string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	delete(i.faults, method)
+}
+
+// ClearAll removes every fault
+func (i *Injector) ClearAll() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.faults = make(map[string]*Fault)
+}
+
+// Faults returns the active faults
+func (i *Injector) Faults() map[string]Fault {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	faults := make(map[string]Fault, len(i.faults))
+	for method, f := range i.faults {
+		faults[method] = *f
+	}
+	return faults
+}
+
+// Call runs fn with the method's fault, if one triggers
+// Depending on the fault, fn is delayed, skipped and replaced by an error, or
+// run and followed by an error as if its acknowledgement was lost. Like the
+// MongoDB driver, calls on a cancelled context fail without running fn.
+func (i *Injector) Call(ctx context.Context, method string, fn func(ctx context.Context) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	f, ok := i.trigger(method)
+	if !ok {
+		return fn(ctx)
+	}
+
+	if f.LatencyMS > 0 {
+		timer := time.NewTimer(time.Duration(f.LatencyMS) * time.Millisecond)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	switch {
+	case f.Error == "":
+		return fn(ctx)
+	case f.Partial:
+		if err := fn(ctx); err != nil {
+			return err
+		}
+		return f.err(method)
+	default:
+		return f.err(method)
+	}
+}
+
+// trigger decides whether the method's fault applies to this call
+func (i *Injector) trigger(method string) (Fault, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	f, ok := i.faults[method]
+	if !ok {
+		return Fault{}, false
+	}
+	if f.Probability > 0 && i.rand.Float64() >= f.Probability {
+		return Fault{}, false
+	}
+	if f.Times > 0 {
+		f.Times--
+		if f.Times == 0 {
+			delete(i.faults, method)
+		}
+	}
+	return *f, true
+}
@@ -0,0 +1,77 @@
+package grpcapi
+
+import (
+	"context"
+	couponv1 "coupon-system/api/coupon/v1"
+	"coupon-system/internal/overload"
+	apperrors "coupon-system/pkg/errors"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// RateLimit takes a token for a call by the caller's IP, API key ID and user ID
+// Empty keys are not limited. Returns false and how long to wait when any
+// limit is exceeded.
+type RateLimit func(ctx context.Context, ip, keyID, userID string) (bool, time.Duration)
+
+// WithClaimLimits puts ClaimCoupon behind the same rate limits and adaptive
+// concurrency limit as claims over HTTP
+// Either may be nil.
+func WithClaimLimits(limiter *overload.Limiter, allow RateLimit) Option {
+	return func(i *interceptors) {
+		i.overload = limiter
+		i.claimLimit = allow
+	}
+}
+
+// limit rate limits ClaimCoupon calls and admits them through the overload limiter
+func (i *interceptors) limit(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	claim, ok := req.(*couponv1.ClaimCouponRequest)
+	if !ok {
+		return handler(ctx, req)
+	}
+
+	if i.claimLimit != nil {
+		var ip, keyID string
+		if p, ok := peer.FromContext(ctx); ok {
+			ip = p.Addr.String()
+			if host, _, err := net.SplitHostPort(ip); err == nil {
+				ip = host
+			}
+		}
+		if key := callerKey(ctx); key != nil {
+			keyID = key.ID.Hex()
+		}
+		if ok, retryAfter := i.claimLimit(ctx, ip, keyID, claim.UserId); !ok {
+			return nil, toStatus(apperrors.ErrRateLimited.WithRetryAfter(retryAfter), "")
+		}
+	}
+
+	if i.overload == nil {
+		return handler(ctx, req)
+	}
+	done, err := i.overload.Acquire(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, toStatus(ctx.Err(), "")
+		}
+		return nil, toStatus(apperrors.ErrOverloaded.WithRetryAfter(i.overload.RetryAfter()), "")
+	}
+	resp, err := handler(ctx, req)
+	done(serverFault(status.Code(err)))
+	return resp, err
+}
+
+// serverFault reports whether a code is one HTTP would answer with a 5xx
+func serverFault(code codes.Code) bool {
+	switch code {
+	case codes.Internal, codes.Unknown, codes.Unavailable, codes.DataLoss, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,258 @@
+// Package grpcapi serves the coupon API over gRPC for internal services
+package grpcapi
+
+import (
+	"context"
+	couponv1 "coupon-system/api/coupon/v1"
+	"coupon-system/internal/auth"
+	"coupon-system/internal/live"
+	"coupon-system/internal/metrics"
+	"coupon-system/internal/model"
+	"coupon-system/internal/overload"
+	"coupon-system/internal/validation"
+	"log"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Service is the part of service.CouponService the gRPC API exposes
+type Service interface {
+	CreateCoupon(ctx context.Context, req *model.CreateCouponRequest) (*model.Coupon, error)
+	ClaimCoupon(ctx context.Context, req *model.ClaimCouponRequest) error
+	GetCouponDetails(ctx context.Context, name string) (*model.CouponDetailsResponse, error)
+	ListClaims(ctx context.Context, name string) ([]*model.Claim, error)
+}
+
+// Server implements couponv1.CouponServiceServer on top of the coupon service
+type Server struct {
+	couponv1.UnimplementedCouponServiceServer
+
+	svc Service
+	hub *live.Hub
+}
+
+// NewServer creates a gRPC server with the coupon API registered
+// WatchCoupon streams come from hub, so closing the hub ends them.
+func NewServer(svc Service, hub *live.Hub, registry *metrics.Registry, opts ...Option) *grpc.Server {
+	i := &interceptors{registry: registry}
+	for _, opt := range opts {
+		opt(i)
+	}
+	gs := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(i.unary, i.limit),
+		grpc.ChainStreamInterceptor(i.stream),
+	)
+	couponv1.RegisterCouponServiceServer(gs, &Server{svc: svc, hub: hub})
+	return gs
+}
+
+// CreateCoupon creates a coupon
+func (s *Server) CreateCoupon(ctx context.Context, req *couponv1.CreateCouponRequest) (*couponv1.Coupon, error) {
+	switch {
+	case req.Name == "":
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	case req.Amount <= 0:
+		return nil, status.Error(codes.InvalidArgument, "amount must be greater than 0")
+	case req.StockShards < 0 || req.StockShards > 64:
+		return nil, status.Error(codes.InvalidArgument, "stock_shards must be between 0 and 64")
+	case req.DistributionMode != "" && req.DistributionMode != model.DistributionFCFS && req.DistributionMode != model.DistributionRaffle:
+		return nil, status.Error(codes.InvalidArgument, "distribution_mode must be fcfs or raffle")
+	}
+
+	coupon, err := s.svc.CreateCoupon(ctx, &model.CreateCouponRequest{
+		Name:             req.Name,
+		Amount:           req.Amount,
+		ExpiresAt:        formatTime(req.ExpiresAt),
+		QueueEnabled:     req.QueueEnabled,
+		StockShards:      req.StockShards,
+		DistributionMode: req.DistributionMode,
+		EntryStartsAt:    formatTime(req.EntryStartsAt),
+		EntryEndsAt:      formatTime(req.EntryEndsAt),
+	})
+	if err != nil {
+		return nil, toStatus(err, "failed to create coupon")
+	}
+	return toCoupon(coupon), nil
+}
+
+// ClaimCoupon claims a coupon for a user
+func (s *Server) ClaimCoupon(ctx context.Context, req *couponv1.ClaimCouponRequest) (*couponv1.ClaimCouponResponse, error) {
+	if req.UserId == "" || req.CouponName == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id and coupon_name are required")
+	}
+	if err := validation.UserID(req.UserId); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "user_id "+err.Error())
+	}
+
+	err := s.svc.ClaimCoupon(ctx, &model.ClaimCouponRequest{
+		UserID:     req.UserId,
+		CouponName: req.CouponName,
+		QueueToken: req.QueueToken,
+	})
+	if err != nil {
+		return nil, toStatus(err, "failed to claim coupon")
+	}
+	return &couponv1.ClaimCouponResponse{}, nil
+}
+
+// GetCouponDetails returns a coupon with the users who claimed it
+func (s *Server) GetCouponDetails(ctx context.Context, req *couponv1.GetCouponDetailsRequest) (*couponv1.CouponDetails, error) {
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+
+	details, err := s.svc.GetCouponDetails(ctx, req.Name)
+	if err != nil {
+		return nil, toStatus(err, "failed to get coupon details")
+	}
+	return &couponv1.CouponDetails{
+		Name:            details.Name,
+		Amount:          details.Amount,
+		RemainingAmount: details.RemainingAmount,
+		IsActive:        details.IsActive,
+		ExpiresAt:       toTimestamp(details.ExpiresAt),
+		ClaimedBy:       details.ClaimedBy,
+	}, nil
+}
+
+// ListClaims returns a coupon's claims
+func (s *Server) ListClaims(ctx context.Context, req *couponv1.ListClaimsRequest) (*couponv1.ListClaimsResponse, error) {
+	if req.CouponName == "" {
+		return nil, status.Error(codes.InvalidArgument, "coupon_name is required")
+	}
+
+	claims, err := s.svc.ListClaims(ctx, req.CouponName)
+	if err != nil {
+		return nil, toStatus(err, "failed to list claims")
+	}
+	resp := &couponv1.ListClaimsResponse{Claims: make([]*couponv1.Claim, 0, len(claims))}
+	for _, claim := range claims {
+		resp.Claims = append(resp.Claims, &couponv1.Claim{
+			Id:         claim.ID.Hex(),
+			UserId:     claim.UserID,
+			CouponName: claim.CouponName,
+			CreatedAt:  toTimestamp(claim.CreatedAt),
+		})
+	}
+	return resp, nil
+}
+
+// WatchCoupon streams a coupon's stock and status until the client leaves or the hub closes
+func (s *Server) WatchCoupon(req *couponv1.WatchCouponRequest, stream couponv1.CouponService_WatchCouponServer) error {
+	if req.Name == "" {
+		return status.Error(codes.InvalidArgument, "name is required")
+	}
+
+	ctx := stream.Context()
+	sub, err := s.hub.Subscribe(ctx, req.Name)
+	if err != nil {
+		return toStatus(err, "failed to watch coupon")
+	}
+	defer sub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-sub.Done():
+			return status.Error(codes.Unavailable, "server shutting down")
+		case <-sub.Updates():
+			u := sub.Latest()
+			err := stream.Send(&couponv1.StockUpdate{
+				Name:            u.Name,
+				Amount:          u.Amount,
+				RemainingAmount: u.RemainingAmount,
+				IsActive:        u.IsActive,
+				Status:          u.Status,
+				ExpiresAt:       toTimestamp(u.ExpiresAt),
+				Version:         u.Version,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// toCoupon converts a coupon to its protobuf form
+func toCoupon(c *model.Coupon) *couponv1.Coupon {
+	out := &couponv1.Coupon{
+		Id:               c.ID.Hex(),
+		Name:             c.Name,
+		Amount:           c.Amount,
+		RemainingAmount:  c.RemainingAmount,
+		IsActive:         c.IsActive,
+		CreatedAt:        toTimestamp(c.CreatedAt),
+		ExpiresAt:        toTimestamp(c.ExpiresAt),
+		QueueEnabled:     c.QueueEnabled,
+		StockShards:      c.StockShards,
+		DistributionMode: c.DistributionMode,
+	}
+	if c.EntryStartsAt != nil {
+		out.EntryStartsAt = toTimestamp(*c.EntryStartsAt)
+	}
+	if c.EntryEndsAt != nil {
+		out.EntryEndsAt = toTimestamp(*c.EntryEndsAt)
+	}
+	return out
+}
+
+// toTimestamp converts a time, leaving the zero time unset
+func toTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// formatTime renders a timestamp in the RFC3339 form the service parses ("" if unset)
+func formatTime(ts *timestamppb.Timestamp) string {
+	if ts == nil {
+		return ""
+	}
+	return ts.AsTime().Format(time.RFC3339Nano)
+}
+
+// interceptors count calls, turn handler panics into Internal errors and limit claims
+type interceptors struct {
+	registry   *metrics.Registry
+	keys       *auth.Manager     // Nil when authentication is disabled
+	overload   *overload.Limiter // Nil when claims are not load limited
+	claimLimit RateLimit         // Nil when claims are not rate limited
+}
+
+func (i *interceptors) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	ctx, record, err := i.authorize(ctx, info.FullMethod)
+	defer i.finish(info.FullMethod, &err, record)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (i *interceptors) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	_, record, err := i.authorize(ss.Context(), info.FullMethod)
+	defer i.finish(info.FullMethod, &err, record)
+	if err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// finish recovers from a panic and records the call's outcome
+func (i *interceptors) finish(fullMethod string, err *error, audit func(error)) {
+	if r := recover(); r != nil {
+		log.Printf("gRPC: panic in %s: %v\n%s", fullMethod, r, debug.Stack())
+		*err = status.Error(codes.Internal, "internal error")
+	}
+	audit(*err)
+	method := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	i.registry.Counter("grpc_requests_total", "gRPC calls by method and status code",
+		"method", method, "code", status.Code(*err).String()).Inc()
+}
@@ -0,0 +1,370 @@
+package grpcapi
+
+import (
+	"context"
+	couponv1 "coupon-system/api/coupon/v1"
+	"coupon-system/internal/auth"
+	"coupon-system/internal/auth/authtest"
+	"coupon-system/internal/live"
+	"coupon-system/internal/metrics"
+	"coupon-system/internal/model"
+	"coupon-system/internal/overload"
+	apperrors "coupon-system/pkg/errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeService keeps coupons and claims in memory
+type fakeService struct {
+	mu      sync.Mutex
+	coupons map[string]*model.Coupon
+	claims  map[string][]*model.Claim
+	failing error // Returned by every call when set
+}
+
+func newFakeService() *fakeService {
+	return &fakeService{coupons: make(map[string]*model.Coupon), claims: make(map[string][]*model.Claim)}
+}
+
+func (f *fakeService) CreateCoupon(_ context.Context, req *model.CreateCouponRequest) (*model.Coupon, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failing != nil {
+		return nil, f.failing
+	}
+	if _, ok := f.coupons[req.Name]; ok {
+		return nil, apperrors.ErrCouponAlreadyExists
+	}
+	expiresAt, _ := time.Parse(time.RFC3339, req.ExpiresAt)
+	c := &model.Coupon{ID: primitive.NewObjectID(), Name: req.Name, Amount: req.Amount, RemainingAmount: req.Amount,
+		IsActive: true, ExpiresAt: expiresAt, CreatedAt: time.Now()}
+	f.coupons[req.Name] = c
+	return c, nil
+}
+
+func (f *fakeService) ClaimCoupon(_ context.Context, req *model.ClaimCouponRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failing != nil {
+		return f.failing
+	}
+	c, ok := f.coupons[req.CouponName]
+	if !ok {
+		return apperrors.ErrCouponNotFound
+	}
+	for _, claim := range f.claims[req.CouponName] {
+		if claim.UserID == req.UserID {
+			return apperrors.ErrAlreadyClaimed
+		}
+	}
+	if c.RemainingAmount <= 0 {
+		return apperrors.ErrNoStock
+	}
+	c.RemainingAmount--
+	f.claims[req.CouponName] = append(f.claims[req.CouponName], &model.Claim{
+		ID: primitive.NewObjectID(), UserID: req.UserID, CouponID: c.ID, CouponName: c.Name, CreatedAt: time.Now()})
+	return nil
+}
+
+func (f *fakeService) GetCouponDetails(_ context.Context, name string) (*model.CouponDetailsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.coupons[name]
+	if !ok {
+		return nil, apperrors.ErrCouponNotFound
+	}
+	details := &model.CouponDetailsResponse{Name: c.Name, Amount: c.Amount, RemainingAmount: c.RemainingAmount,
+		IsActive: c.IsActive, ExpiresAt: c.ExpiresAt, ClaimedBy: []string{}}
+	for _, claim := range f.claims[name] {
+		details.ClaimedBy = append(details.ClaimedBy, claim.UserID)
+	}
+	return details, nil
+}
+
+func (f *fakeService) ListClaims(_ context.Context, name string) ([]*model.Claim, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failing != nil {
+		return nil, f.failing
+	}
+	if _, ok := f.coupons[name]; !ok {
+		return nil, apperrors.ErrCouponNotFound
+	}
+	return f.claims[name], nil
+}
+
+func (f *fakeService) fail(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failing = err
+}
+
+func (f *fakeService) load(_ context.Context, name string) (*model.Coupon, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.coupons[name]
+	if !ok {
+		return nil, apperrors.ErrCouponNotFound
+	}
+	copied := *c
+	return &copied, nil
+}
+
+// dial serves the API in memory and returns a client for it
+func dial(t *testing.T, svc *fakeService, opts ...Option) (couponv1.CouponServiceClient, *live.Hub) {
+	t.Helper()
+	registry := metrics.NewRegistry()
+	hub := live.NewHub(svc.load, live.Options{Coalesce: time.Millisecond, Resync: time.Hour}, registry)
+	gs := NewServer(svc, hub, registry, opts...)
+
+	lis := bufconn.Listen(1 << 20)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return couponv1.NewCouponServiceClient(conn), hub
+}
+
+// TestClaimFlow checks the unary calls and their error codes
+func TestClaimFlow(t *testing.T) {
+	client, _ := dial(t, newFakeService())
+	ctx := context.Background()
+
+	coupon, err := client.CreateCoupon(ctx, &couponv1.CreateCouponRequest{Name: "GRPC", Amount: 1})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if coupon.Name != "GRPC" || coupon.RemainingAmount != 1 || coupon.Id == "" {
+		t.Fatalf("created coupon = %+v", coupon)
+	}
+
+	if _, err := client.ClaimCoupon(ctx, &couponv1.ClaimCouponRequest{UserId: "u1", CouponName: "GRPC"}); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		call func() error
+		code codes.Code
+	}{
+		{"duplicate coupon", func() error {
+			_, err := client.CreateCoupon(ctx, &couponv1.CreateCouponRequest{Name: "GRPC", Amount: 1})
+			return err
+		}, codes.AlreadyExists},
+		{"zero amount", func() error {
+			_, err := client.CreateCoupon(ctx, &couponv1.CreateCouponRequest{Name: "OTHER"})
+			return err
+		}, codes.InvalidArgument},
+		{"already claimed", func() error {
+			_, err := client.ClaimCoupon(ctx, &couponv1.ClaimCouponRequest{UserId: "u1", CouponName: "GRPC"})
+			return err
+		}, codes.AlreadyExists},
+		{"no stock", func() error {
+			_, err := client.ClaimCoupon(ctx, &couponv1.ClaimCouponRequest{UserId: "u2", CouponName: "GRPC"})
+			return err
+		}, codes.FailedPrecondition},
+		{"unknown coupon", func() error {
+			_, err := client.GetCouponDetails(ctx, &couponv1.GetCouponDetailsRequest{Name: "MISSING"})
+			return err
+		}, codes.NotFound},
+		{"missing user", func() error {
+			_, err := client.ClaimCoupon(ctx, &couponv1.ClaimCouponRequest{CouponName: "GRPC"})
+			return err
+		}, codes.InvalidArgument},
+	}
+	for _, tc := range cases {
+		if code := status.Code(tc.call()); code != tc.code {
+			t.Errorf("%s: code = %v, want %v", tc.name, code, tc.code)
+		}
+	}
+
+	details, err := client.GetCouponDetails(ctx, &couponv1.GetCouponDetailsRequest{Name: "GRPC"})
+	if err != nil || details.RemainingAmount != 0 || len(details.ClaimedBy) != 1 || details.ClaimedBy[0] != "u1" {
+		t.Fatalf("details = %+v, %v", details, err)
+	}
+	claims, err := client.ListClaims(ctx, &couponv1.ListClaimsRequest{CouponName: "GRPC"})
+	if err != nil || len(claims.Claims) != 1 || claims.Claims[0].UserId != "u1" {
+		t.Fatalf("claims = %+v, %v", claims, err)
+	}
+}
+
+// TestErrorMapping checks wrapped domain errors keep their code and retry delay
+// and unknown errors are not leaked
+func TestErrorMapping(t *testing.T) {
+	svc := newFakeService()
+	client, _ := dial(t, svc)
+	ctx := context.Background()
+	if _, err := client.CreateCoupon(ctx, &couponv1.CreateCouponRequest{Name: "X", Amount: 1}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	svc.fail(fmt.Errorf("list claims: %w", apperrors.ErrDatabaseUnavailable))
+	_, err := client.ListClaims(ctx, &couponv1.ListClaimsRequest{CouponName: "X"})
+	if st := status.Convert(err); st.Code() != codes.Unavailable || st.Message() != "database unavailable" {
+		t.Fatalf("wrapped error: status = %v, want Unavailable", st)
+	}
+
+	if retryDelay(status.Convert(err)) != time.Second {
+		t.Errorf("wrapped error: status = %v, want the Retry-After as RetryInfo", status.Convert(err))
+	}
+
+	// The code follows the HTTP status, including ones without an obvious gRPC equivalent
+	svc.fail(apperrors.ErrQueueWrongInstance)
+	_, err = client.ClaimCoupon(ctx, &couponv1.ClaimCouponRequest{UserId: "u", CouponName: "X"})
+	if st := status.Convert(err); st.Code() != codes.Unavailable || st.Message() != apperrors.ErrQueueWrongInstance.Message {
+		t.Errorf("misdirected claim: status = %v, want Unavailable", st)
+	}
+
+	svc.fail(fmt.Errorf("connection reset by 10.0.0.7"))
+	_, err = client.ClaimCoupon(ctx, &couponv1.ClaimCouponRequest{UserId: "u", CouponName: "X"})
+	if st := status.Convert(err); st.Code() != codes.Internal || st.Message() != "failed to claim coupon" {
+		t.Fatalf("unknown error: status = %v, want Internal without details", st)
+	}
+}
+
+// TestClaimLimits checks ClaimCoupon is rate limited per user and shed when
+// the overload limiter has no slot, with the delay to retry after
+func TestClaimLimits(t *testing.T) {
+	var mu sync.Mutex
+	var limited []string
+	allow := func(_ context.Context, ip, keyID, userID string) (bool, time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		limited = append(limited, userID)
+		return userID != "greedy", 2 * time.Second
+	}
+	limiter := overload.NewLimiter(overload.Options{InitialLimit: 1, MinLimit: 1, MaxLimit: 1, QueueTimeout: time.Millisecond})
+	client, _ := dial(t, newFakeService(), WithClaimLimits(limiter, allow))
+	ctx := context.Background()
+	if _, err := client.CreateCoupon(ctx, &couponv1.CreateCouponRequest{Name: "X", Amount: 5}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	if _, err := client.ClaimCoupon(ctx, &couponv1.ClaimCouponRequest{UserId: "u1", CouponName: "X"}); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	_, err := client.ClaimCoupon(ctx, &couponv1.ClaimCouponRequest{UserId: "greedy", CouponName: "X"})
+	if st := status.Convert(err); st.Code() != codes.ResourceExhausted || retryDelay(st) != 2*time.Second {
+		t.Errorf("rate limited claim: status = %v, retry after %v, want ResourceExhausted after 2s", st, retryDelay(st))
+	}
+	mu.Lock()
+	if len(limited) != 2 {
+		t.Errorf("rate limit checked for %v, want only the two claims", limited)
+	}
+	mu.Unlock()
+
+	// Take the only slot, so the next claim is shed
+	done, err := limiter.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done(false)
+	_, err = client.ClaimCoupon(ctx, &couponv1.ClaimCouponRequest{UserId: "u2", CouponName: "X"})
+	if st := status.Convert(err); st.Code() != codes.Unavailable || retryDelay(st) <= 0 {
+		t.Errorf("claim while overloaded: status = %v, want Unavailable with a retry delay", st)
+	}
+}
+
+// retryDelay returns the RetryInfo delay in a status, or 0
+func retryDelay(st *status.Status) time.Duration {
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.RetryInfo); ok {
+			return info.RetryDelay.AsDuration()
+		}
+	}
+	return 0
+}
+
+// TestWatchCoupon checks the stream sends the current state, then changes, and ends with the hub
+func TestWatchCoupon(t *testing.T) {
+	svc := newFakeService()
+	client, hub := dial(t, svc)
+	ctx := context.Background()
+	if _, err := client.CreateCoupon(ctx, &couponv1.CreateCouponRequest{Name: "LIVE", Amount: 2}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	stream, err := client.WatchCoupon(ctx, &couponv1.WatchCouponRequest{Name: "LIVE"})
+	if err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	first, err := stream.Recv()
+	if err != nil || first.RemainingAmount != 2 || first.Status != live.StatusActive {
+		t.Fatalf("first update = %+v, %v", first, err)
+	}
+
+	if _, err := client.ClaimCoupon(ctx, &couponv1.ClaimCouponRequest{UserId: "u1", CouponName: "LIVE"}); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	hub.Notify("LIVE")
+	next, err := stream.Recv()
+	if err != nil || next.RemainingAmount != 1 || next.Version != first.Version+1 {
+		t.Fatalf("update after claim = %+v, %v", next, err)
+	}
+
+	hub.Close()
+	if _, err := stream.Recv(); status.Code(err) != codes.Unavailable {
+		t.Fatalf("after hub close: err = %v, want Unavailable", err)
+	}
+
+	missing, err := client.WatchCoupon(ctx, &couponv1.WatchCouponRequest{Name: "MISSING"})
+	if err == nil {
+		_, err = missing.Recv()
+	}
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("watch after hub close: err = %v, want Unavailable", err)
+	}
+}
+
+// TestAuth checks calls need an API key in metadata with the method's role
+func TestAuth(t *testing.T) {
+	keys := auth.NewManager(authtest.NewRepository(), auth.Options{}, metrics.NewRegistry())
+	ctx := context.Background()
+	_, claimer, err := keys.Create(ctx, &model.CreateAPIKeyRequest{Name: "storefront", Role: model.RoleClaimer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, admin, _ := keys.Create(ctx, &model.CreateAPIKeyRequest{Name: "ops", Role: model.RoleAdmin})
+	client, _ := dial(t, newFakeService(), WithAuth(keys))
+	withKey := func(secret string) context.Context {
+		return metadata.AppendToOutgoingContext(ctx, apiKeyMetadata, secret)
+	}
+
+	create := &couponv1.CreateCouponRequest{Name: "AUTH", Amount: 1}
+	if _, err := client.CreateCoupon(ctx, create); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("create without a key: err = %v, want Unauthenticated", err)
+	}
+	if _, err := client.CreateCoupon(withKey("csk_unknown"), create); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("create with an unknown key: err = %v, want Unauthenticated", err)
+	}
+	if _, err := client.CreateCoupon(withKey(claimer), create); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("create with a claimer key: err = %v, want PermissionDenied", err)
+	}
+	if _, err := client.CreateCoupon(withKey(admin), create); err != nil {
+		t.Fatalf("create with an admin key: %v", err)
+	}
+	if _, err := client.ClaimCoupon(withKey(claimer), &couponv1.ClaimCouponRequest{UserId: "u1", CouponName: "AUTH"}); err != nil {
+		t.Errorf("claim with a claimer key: %v", err)
+	}
+	if _, err := client.ListClaims(withKey(claimer), &couponv1.ListClaimsRequest{CouponName: "AUTH"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("list claims with a claimer key: err = %v, want PermissionDenied", err)
+	}
+}
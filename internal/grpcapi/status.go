@@ -0,0 +1,107 @@
+package grpcapi
+
+import (
+	"context"
+	"coupon-system/internal/live"
+	apperrors "coupon-system/pkg/errors"
+	"errors"
+	"net/http"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// codeOverrides are errors whose HTTP status alone maps to the wrong gRPC code:
+// 400s and 409s about the coupon's or request's state rather than the request
+// itself, and limits that HTTP reports as 503
+var codeOverrides = map[string]codes.Code{
+	apperrors.ErrNoStock.Code:           codes.FailedPrecondition,
+	apperrors.ErrCouponInactive.Code:    codes.FailedPrecondition,
+	apperrors.ErrStockAvailable.Code:    codes.FailedPrecondition,
+	apperrors.ErrWaitlistDisabled.Code:  codes.FailedPrecondition,
+	apperrors.ErrRaffleOnly.Code:        codes.FailedPrecondition,
+	apperrors.ErrNotRaffle.Code:         codes.FailedPrecondition,
+	apperrors.ErrRaffleEntryClosed.Code: codes.FailedPrecondition,
+	apperrors.ErrQueueNotEnabled.Code:   codes.FailedPrecondition,
+	apperrors.ErrRaffleEntryOpen.Code:   codes.FailedPrecondition,
+	apperrors.ErrDeliveryNotDead.Code:   codes.FailedPrecondition,
+	apperrors.ErrAPIKeyRevoked.Code:     codes.FailedPrecondition,
+	apperrors.ErrRequestInProgress.Code: codes.Aborted,
+	apperrors.ErrJobLeaseLost.Code:      codes.Aborted,
+	live.ErrTooManyClients.Code:         codes.ResourceExhausted,
+}
+
+// toStatus converts a service error to a gRPC status error
+// The code follows the HTTP status the REST API gives the error, and the
+// message is the one its problem response shows. Unknown errors become
+// Internal with the given message, so internals are not leaked.
+func toStatus(err error, internal string) error {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+
+	appErr := apperrors.From(err)
+	code := grpcCode(appErr)
+	message := appErr.Message
+	switch {
+	case code == codes.Internal:
+		message = internal
+	case appErr.Status < http.StatusInternalServerError:
+		// Client errors keep the specifics they were wrapped with, e.g. invalid fields
+		message = err.Error()
+	}
+	return withRetryInfo(status.New(code, message), appErr.RetryAfter)
+}
+
+// grpcCode maps an error's HTTP status to the gRPC code with the same meaning
+func grpcCode(appErr *apperrors.AppError) codes.Code {
+	if code, ok := codeOverrides[appErr.Code]; ok {
+		return code
+	}
+	switch appErr.Status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusMisdirectedRequest:
+		// Another instance can serve it; clients retry Unavailable on a new connection
+		return codes.Unavailable
+	case http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	if appErr.Status < http.StatusInternalServerError {
+		return codes.FailedPrecondition
+	}
+	return codes.Internal
+}
+
+// withRetryInfo tells the client when to retry, like Retry-After over HTTP
+func withRetryInfo(st *status.Status, retryAfter time.Duration) error {
+	if retryAfter <= 0 {
+		return st.Err()
+	}
+	if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
@@ -0,0 +1,126 @@
+package jobs
+
+import (
+	"context"
+	"coupon-system/internal/model"
+	"coupon-system/internal/service"
+	"errors"
+	"time"
+)
+
+// TypeBulkClaim grants a coupon to a list of users of any size
+const TypeBulkClaim = "bulk_claim"
+
+// bulkClaimChunkSize is the number of users granted per BulkClaim call
+const bulkClaimChunkSize = 500
+
+// bulkClaimParams are the parameters of a bulk_claim job
+type bulkClaimParams struct {
+	CouponName string   `json:"coupon_name"`
+	UserIDs    []string `json:"user_ids"`
+}
+
+// chunkStartedKey marks, in the job result, a chunk that has been started but
+// whose counts have not been recorded yet
+const chunkStartedKey = "chunk_started_at"
+
+// NewBulkClaimHandler returns a handler that runs CouponService.BulkClaim in chunks
+// Chunks are idempotent, so a chunk interrupted by a crash is simply re-run on
+// resume. The start of each chunk is recorded before it runs, so on a re-run the
+// claims the job itself made before the crash still count as claimed rather
+// than as already claimed.
+func NewBulkClaimHandler(svc *service.CouponService) Handler {
+	return func(ctx context.Context, job *model.Job, p *Progress) error {
+		var params bulkClaimParams
+		if err := job.DecodeParams(&params); err != nil {
+			return err
+		}
+		if params.CouponName == "" || len(params.UserIDs) == 0 {
+			return errors.New("coupon_name and user_ids are required")
+		}
+
+		total := int64(len(params.UserIDs))
+		result := map[string]interface{}{
+			"coupon_name":     params.CouponName,
+			"claimed":         int64(0),
+			"already_claimed": int64(0),
+			"no_stock":        int64(0),
+		}
+		// Carry over counts from a previous attempt
+		for key := range result {
+			if v, ok := job.Result[key]; ok {
+				result[key] = v
+			}
+		}
+		var interrupted time.Time
+		if v, ok := job.Result[chunkStartedKey].(string); ok {
+			interrupted, _ = time.Parse(time.RFC3339Nano, v)
+			result[chunkStartedKey] = v // Kept until the chunk completes, in case it is interrupted again
+		}
+
+		for done := job.Progress.Done; done < total; {
+			end := done + bulkClaimChunkSize
+			if end > total {
+				end = total
+			}
+			chunk := params.UserIDs[done:end]
+
+			// Claims made by an interrupted run of this chunk are the job's own
+			var own map[string]bool
+			if !interrupted.IsZero() {
+				var err error
+				if own, err = svc.ClaimedSince(ctx, params.CouponName, chunk, interrupted); err != nil {
+					return err
+				}
+				interrupted = time.Time{}
+			} else {
+				result[chunkStartedKey] = time.Now().Truncate(time.Millisecond).Format(time.RFC3339Nano)
+				if err := p.Update(ctx, done, total, result); err != nil {
+					return err
+				}
+			}
+
+			resp, err := svc.BulkClaim(ctx, &model.BulkClaimRequest{
+				CouponName: params.CouponName,
+				UserIDs:    chunk,
+			})
+			if err != nil {
+				return err
+			}
+			claimed, alreadyClaimed := resp.Claimed, resp.AlreadyClaimed
+			for _, r := range resp.Results {
+				if r.Status == model.BulkClaimStatusAlreadyClaimed && own[r.UserID] {
+					claimed++
+					alreadyClaimed--
+				}
+			}
+			result["claimed"] = toInt64(result["claimed"]) + int64(claimed)
+			result["already_claimed"] = toInt64(result["already_claimed"]) + int64(alreadyClaimed)
+			result["no_stock"] = toInt64(result["no_stock"]) + int64(resp.NoStock)
+
+			// The chunk's counts and the new resume point are written together
+			delete(result, chunkStartedKey)
+			done = end
+			if err := p.Update(ctx, done, total, result); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// toInt64 normalizes counts decoded from BSON (int32/int64) or JSON (float64)
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	case int:
+		return int64(n)
+	case float64:
+		return int64(n)
+	}
+	return 0
+}
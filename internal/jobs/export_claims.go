@@ -0,0 +1,72 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"coupon-system/internal/model"
+	"coupon-system/internal/service"
+	"encoding/csv"
+	"errors"
+	"time"
+)
+
+// TypeExportClaims exports a coupon's claims as CSV
+const TypeExportClaims = "export_claims"
+
+// exportProgressInterval is the number of rows written between progress updates
+const exportProgressInterval = 1000
+
+// exportClaimsParams are the parameters of an export_claims job
+type exportClaimsParams struct {
+	CouponName string `json:"coupon_name"`
+}
+
+// NewExportClaimsHandler returns a handler that writes a coupon's claims as CSV
+// (user_id, claimed_at) into the job result
+// Exports only read, so an interrupted export simply starts over on resume. The
+// CSV is stored in the job document, which bounds an export to a few hundred
+// thousand claims.
+func NewExportClaimsHandler(svc *service.CouponService) Handler {
+	return func(ctx context.Context, job *model.Job, p *Progress) error {
+		var params exportClaimsParams
+		if err := job.DecodeParams(&params); err != nil {
+			return err
+		}
+		if params.CouponName == "" {
+			return errors.New("coupon_name is required")
+		}
+
+		claims, err := svc.ListClaims(ctx, params.CouponName)
+		if err != nil {
+			return err
+		}
+
+		total := int64(len(claims))
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		if err := w.Write([]string{"user_id", "claimed_at"}); err != nil {
+			return err
+		}
+		for i, claim := range claims {
+			if err := w.Write([]string{claim.UserID, claim.CreatedAt.UTC().Format(time.RFC3339)}); err != nil {
+				return err
+			}
+			if done := int64(i + 1); done%exportProgressInterval == 0 && done < total {
+				if err := p.Update(ctx, done, total, nil); err != nil {
+					return err
+				}
+			}
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return err
+		}
+
+		return p.Update(ctx, total, total, map[string]interface{}{
+			"coupon_name":  params.CouponName,
+			"rows":         total,
+			"content_type": "text/csv",
+			"csv":          buf.String(),
+		})
+	}
+}
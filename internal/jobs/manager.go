@@ -0,0 +1,318 @@
+package jobs
+
+import (
+	"context"
+	"coupon-system/internal/model"
+	"coupon-system/internal/owner"
+	"coupon-system/internal/repository"
+	apperrors "coupon-system/pkg/errors"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrCancelled is returned by Progress.Update when the job has been cancelled
+// Handlers should stop and return it
+var ErrCancelled = errors.New("job cancelled")
+
+// ErrInterrupted is returned by Progress.Update when the server is shutting down
+// Handlers should stop and return it; the job is resumed from the recorded
+// progress by the next worker that picks it up
+var ErrInterrupted = errors.New("job interrupted by shutdown")
+
+// Handler executes a job. It should resume from job.Progress.Done (a job may be
+// picked up again after a restart) and report progress regularly via p.Update
+type Handler func(ctx context.Context, job *model.Job, p *Progress) error
+
+// Options configures a Manager
+type Options struct {
+	Workers       int           // Number of concurrent jobs per instance
+	LeaseDuration time.Duration // How long a job survives without a heartbeat before another instance takes it over
+	PollInterval  time.Duration // How often idle workers look for new jobs
+}
+
+// Manager runs jobs from the job repository on a pool of workers
+type Manager struct {
+	repo     repository.JobRepository
+	opts     Options
+	owner    string
+	handlers map[string]Handler
+
+	wake   chan struct{}
+	ctx    context.Context // Cancelled when Stop is called
+	cancel context.CancelFunc
+	jobCtx context.Context // Cancelled only once Stop gives up waiting for handlers
+	abort  context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewManager creates a new job manager; register handlers before calling Start
+func NewManager(repo repository.JobRepository, opts Options) *Manager {
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.LeaseDuration <= 0 {
+		opts.LeaseDuration = 30 * time.Second
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+
+	return &Manager{
+		repo:     repo,
+		opts:     opts,
+		owner:    owner.NewID(),
+		handlers: make(map[string]Handler),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Register adds a handler for a job type
+func (m *Manager) Register(jobType string, handler Handler) {
+	m.handlers[jobType] = handler
+}
+
+// Enqueue stores a new job and wakes an idle worker
+func (m *Manager) Enqueue(ctx context.Context, req *model.CreateJobRequest) (*model.Job, error) {
+	if _, ok := m.handlers[req.Type]; !ok {
+		return nil, apperrors.ErrUnknownJobType
+	}
+
+	now := time.Now()
+	job := &model.Job{
+		Type:      req.Type,
+		Status:    model.JobStatusQueued,
+		Params:    req.Params,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := m.repo.CreateJob(ctx, job); err != nil {
+		return nil, err
+	}
+
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+
+	return job, nil
+}
+
+// Get retrieves a job
+func (m *Manager) Get(ctx context.Context, id string) (*model.Job, error) {
+	return m.repo.GetJob(ctx, id)
+}
+
+// Cancel requests cancellation of a job
+// Queued jobs are cancelled immediately; running jobs stop at their next progress update
+func (m *Manager) Cancel(ctx context.Context, id string) (*model.Job, error) {
+	return m.repo.RequestCancel(ctx, id)
+}
+
+// Start launches the worker pool
+// Jobs left running by a crashed instance are resumed once their lease expires
+func (m *Manager) Start() {
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	m.jobCtx, m.abort = context.WithCancel(context.Background())
+
+	for i := 0; i < m.opts.Workers; i++ {
+		m.wg.Add(1)
+		go m.worker()
+	}
+	log.Printf("Job manager started with %d workers (owner %s)", m.opts.Workers, m.owner)
+}
+
+// Stop interrupts running jobs and hands them back to the queue so another
+// instance (or this one after a restart) can resume them straight away
+// Handlers are interrupted at their next progress update, so a step in flight
+// (and its compensation) completes. Only if that takes longer than ctx allows
+// are their contexts cancelled.
+func (m *Manager) Stop(ctx context.Context) error {
+	if m.cancel == nil {
+		return nil
+	}
+	m.cancel()
+	defer m.abort()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		m.abort()
+		return ctx.Err()
+	}
+
+	return m.repo.ReleaseJobs(ctx, m.owner)
+}
+
+// worker claims and runs jobs until the manager stops
+func (m *Manager) worker() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Drain the queue before going back to sleep
+		for m.ctx.Err() == nil {
+			job, err := m.repo.ClaimNextJob(m.ctx, m.owner, time.Now().Add(m.opts.LeaseDuration))
+			if err != nil {
+				if m.ctx.Err() == nil {
+					log.Printf("Failed to claim job: %v", err)
+				}
+				break
+			}
+			if job == nil {
+				break
+			}
+			m.run(job)
+		}
+
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-m.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// run executes a single claimed job and records its outcome
+func (m *Manager) run(job *model.Job) {
+	ctx, cancel := context.WithCancel(m.jobCtx)
+	defer cancel()
+
+	p := &Progress{manager: m, job: job, cancel: cancel}
+
+	// Heartbeat keeps the lease alive and notices cancellation between updates
+	stopHeartbeat := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(m.opts.LeaseDuration / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopHeartbeat:
+				return
+			case <-ticker.C:
+				_ = p.flush(ctx)
+			}
+		}
+	}()
+
+	var err error
+	handler, ok := m.handlers[job.Type]
+	if ok {
+		err = runHandler(ctx, handler, job, p)
+	} else {
+		err = fmt.Errorf("%w: %s", apperrors.ErrUnknownJobType, job.Type)
+	}
+	close(stopHeartbeat)
+
+	// Shutting down: leave the job running so Stop can release it for resumption
+	if errors.Is(err, ErrInterrupted) || m.jobCtx.Err() != nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.leaseLost {
+		log.Printf("Job %s was taken over by another worker", job.ID.Hex())
+		return
+	}
+
+	switch {
+	case p.cancelled || errors.Is(err, ErrCancelled):
+		job.Status = model.JobStatusCancelled
+	case err != nil:
+		job.Status = model.JobStatusFailed
+		job.Error = err.Error()
+	default:
+		job.Status = model.JobStatusSucceeded
+	}
+
+	finishCtx, cancelFinish := context.WithTimeout(context.Background(), m.opts.LeaseDuration)
+	defer cancelFinish()
+	if err := m.repo.FinishJob(finishCtx, job, m.owner); err != nil {
+		log.Printf("Failed to finish job %s: %v", job.ID.Hex(), err)
+	}
+}
+
+// runHandler runs a handler, turning panics into job failures
+func runHandler(ctx context.Context, handler Handler, job *model.Job, p *Progress) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return handler(ctx, job, p)
+}
+
+// Progress persists a running job's progress and extends its lease
+type Progress struct {
+	manager *Manager
+	job     *model.Job
+	cancel  context.CancelFunc
+
+	mu        sync.Mutex
+	cancelled bool
+	leaseLost bool
+}
+
+// Update records progress and a partial result
+// Returns ErrCancelled if the job was cancelled or ErrInterrupted if the server
+// is shutting down, in which case the handler should stop
+func (p *Progress) Update(ctx context.Context, done, total int64, result map[string]interface{}) error {
+	p.mu.Lock()
+	p.job.Progress = model.JobProgress{Done: done, Total: total}
+	if result != nil {
+		// Copied so the handler can keep changing its map while a heartbeat writes this one
+		p.job.Result = make(map[string]interface{}, len(result))
+		for k, v := range result {
+			p.job.Result[k] = v
+		}
+	}
+	p.mu.Unlock()
+
+	if err := p.flush(ctx); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case p.cancelled:
+		return ErrCancelled
+	case p.manager.ctx.Err() != nil:
+		return ErrInterrupted
+	}
+	return nil
+}
+
+// flush writes the current progress, cancelling the handler's context if the
+// job was cancelled or its lease was lost
+func (p *Progress) flush(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	leaseUntil := time.Now().Add(p.manager.opts.LeaseDuration)
+	cancelRequested, err := p.manager.repo.UpdateProgress(ctx, p.job, p.manager.owner, leaseUntil)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrJobLeaseLost) {
+			p.leaseLost = true
+			p.cancel()
+		}
+		return err
+	}
+	if cancelRequested {
+		p.cancelled = true
+		p.cancel()
+	}
+
+	return nil
+}
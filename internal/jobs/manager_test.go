@@ -0,0 +1,245 @@
+package jobs
+
+import (
+	"context"
+	"coupon-system/internal/model"
+	apperrors "coupon-system/pkg/errors"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testJobType = "steps"
+
+// stepHandler runs a job of total steps from its resume point, calling step before each one
+func stepHandler(total int64, step func(ctx context.Context, done int64) error) Handler {
+	return func(ctx context.Context, job *model.Job, p *Progress) error {
+		for done := job.Progress.Done; done < total; done++ {
+			if err := step(ctx, done); err != nil {
+				return err
+			}
+			if err := p.Update(ctx, done+1, total, map[string]interface{}{"done": done + 1}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func newTestManager(repo *memoryJobRepository, lease time.Duration, handler Handler) *Manager {
+	m := NewManager(repo, Options{Workers: 2, LeaseDuration: lease, PollInterval: 5 * time.Millisecond})
+	m.Register(testJobType, handler)
+	return m
+}
+
+func enqueue(t *testing.T, m *Manager) *model.Job {
+	t.Helper()
+	job, err := m.Enqueue(context.Background(), &model.CreateJobRequest{Type: testJobType})
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	return job
+}
+
+// waitForJob polls the job until cond holds
+func waitForJob(t *testing.T, repo *memoryJobRepository, id string, cond func(*model.Job) bool) *model.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := repo.GetJob(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cond(job) {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job did not reach the expected state: %+v", job)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+}
+
+func finished(job *model.Job) bool { return job.IsFinished() }
+
+func TestJobRunsToCompletion(t *testing.T) {
+	repo := newMemoryJobRepository()
+	m := newTestManager(repo, time.Second, stepHandler(3, func(context.Context, int64) error { return nil }))
+	m.Start()
+	defer m.Stop(context.Background())
+
+	job := waitForJob(t, repo, enqueue(t, m).ID.Hex(), finished)
+	if job.Status != model.JobStatusSucceeded || job.Progress.Done != 3 || toInt64(job.Result["done"]) != 3 {
+		t.Errorf("job = %+v", job)
+	}
+
+	if _, err := m.Enqueue(context.Background(), &model.CreateJobRequest{Type: "unknown"}); !errors.Is(err, apperrors.ErrUnknownJobType) {
+		t.Errorf("Enqueue unknown type = %v, want ErrUnknownJobType", err)
+	}
+}
+
+// TestHeartbeatKeepsLease checks a job that runs for several lease durations
+// without reporting progress is not taken over by another instance
+func TestHeartbeatKeepsLease(t *testing.T) {
+	repo := newMemoryJobRepository()
+	var runs int32
+	handler := func(ctx context.Context, job *model.Job, p *Progress) error {
+		atomic.AddInt32(&runs, 1)
+		time.Sleep(200 * time.Millisecond)
+		return nil
+	}
+	lease := 40 * time.Millisecond
+	first := newTestManager(repo, lease, handler)
+	second := newTestManager(repo, lease, handler)
+	first.Start()
+	defer first.Stop(context.Background())
+
+	job := enqueue(t, first)
+	waitForJob(t, repo, job.ID.Hex(), func(j *model.Job) bool { return j.Status == model.JobStatusRunning })
+	second.Start()
+	defer second.Stop(context.Background())
+
+	got := waitForJob(t, repo, job.ID.Hex(), finished)
+	if got.Status != model.JobStatusSucceeded || got.Attempts != 1 || atomic.LoadInt32(&runs) != 1 {
+		t.Errorf("job = %+v after %d runs, want one successful attempt", got, runs)
+	}
+}
+
+func TestCancelStopsRunningJob(t *testing.T) {
+	repo := newMemoryJobRepository()
+	m := newTestManager(repo, time.Second, stepHandler(1<<20, func(context.Context, int64) error {
+		time.Sleep(time.Millisecond)
+		return nil
+	}))
+	m.Start()
+	defer m.Stop(context.Background())
+
+	job := enqueue(t, m)
+	waitForJob(t, repo, job.ID.Hex(), func(j *model.Job) bool { return j.Progress.Done > 0 })
+	if _, err := m.Cancel(context.Background(), job.ID.Hex()); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+
+	got := waitForJob(t, repo, job.ID.Hex(), finished)
+	if got.Status != model.JobStatusCancelled || got.Progress.Done == 0 || got.Progress.Done == 1<<20 {
+		t.Errorf("job = %+v, want cancelled part way", got)
+	}
+}
+
+// TestCrashRecoveryResumesFromProgress checks a job left running by a crashed
+// instance is picked up once its lease expires and resumed where it stopped
+func TestCrashRecoveryResumesFromProgress(t *testing.T) {
+	repo := newMemoryJobRepository()
+	crashed := &model.Job{
+		Type:       testJobType,
+		Status:     model.JobStatusRunning,
+		Owner:      "crashed-instance",
+		LeaseUntil: time.Now().Add(50 * time.Millisecond),
+		Progress:   model.JobProgress{Done: 3, Total: 5},
+		Attempts:   1,
+		CreatedAt:  time.Now(),
+	}
+	_ = repo.CreateJob(context.Background(), crashed)
+
+	var steps []int64
+	m := newTestManager(repo, time.Second, stepHandler(5, func(_ context.Context, done int64) error {
+		steps = append(steps, done)
+		return nil
+	}))
+	m.Start()
+	defer m.Stop(context.Background())
+
+	time.Sleep(20 * time.Millisecond)
+	if job, _ := repo.GetJob(context.Background(), crashed.ID.Hex()); job.Owner != "crashed-instance" {
+		t.Fatalf("job taken over before its lease expired: %+v", job)
+	}
+
+	got := waitForJob(t, repo, crashed.ID.Hex(), finished)
+	if got.Status != model.JobStatusSucceeded || got.Attempts != 2 || len(steps) != 2 || steps[0] != 3 {
+		t.Errorf("job = %+v after steps %v, want steps 3 and 4 in a second attempt", got, steps)
+	}
+}
+
+// TestStopInterruptsAtProgressUpdate checks Stop lets the step in flight finish
+// with a live context and releases the job for resumption
+func TestStopInterruptsAtProgressUpdate(t *testing.T) {
+	repo := newMemoryJobRepository()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var ctxErr error
+	m := newTestManager(repo, time.Second, stepHandler(5, func(ctx context.Context, done int64) error {
+		if done == 1 {
+			close(started)
+			<-release
+			ctxErr = ctx.Err()
+		}
+		return nil
+	}))
+	m.Start()
+	job := enqueue(t, m)
+	<-started
+
+	stopped := make(chan error)
+	go func() { stopped <- m.Stop(context.Background()) }()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	if err := <-stopped; err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	got, _ := repo.GetJob(context.Background(), job.ID.Hex())
+	if ctxErr != nil || got.Status != model.JobStatusQueued || got.Owner != "" || got.Progress.Done != 2 {
+		t.Errorf("job = %+v with step context error %v, want queued after 2 steps", got, ctxErr)
+	}
+}
+
+// TestStopAbortsHandlersAfterDeadline checks a handler that does not reach a
+// progress update in time is cancelled
+func TestStopAbortsHandlersAfterDeadline(t *testing.T) {
+	repo := newMemoryJobRepository()
+	running := make(chan struct{})
+	m := newTestManager(repo, time.Second, func(ctx context.Context, job *model.Job, p *Progress) error {
+		close(running)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	m.Start()
+	job := enqueue(t, m)
+	<-running
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := m.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Stop = %v, want deadline exceeded", err)
+	}
+	m.wg.Wait()
+	if got, _ := repo.GetJob(context.Background(), job.ID.Hex()); got.Status != model.JobStatusRunning {
+		t.Errorf("aborted job = %+v, want left running for lease expiry", got)
+	}
+}
+
+// TestLeaseLostLeavesJobToNewOwner checks a worker that lost its lease stops
+// and does not overwrite the outcome of the instance that took over
+func TestLeaseLostLeavesJobToNewOwner(t *testing.T) {
+	repo := newMemoryJobRepository()
+	var stepErr error
+	stopped := make(chan struct{})
+	m := newTestManager(repo, time.Second, func(ctx context.Context, job *model.Job, p *Progress) error {
+		defer close(stopped)
+		repo.steal(job.ID, "other-instance")
+		stepErr = p.Update(ctx, 1, 2, nil)
+		return stepErr
+	})
+	m.Start()
+	defer m.Stop(context.Background())
+
+	job := enqueue(t, m)
+	<-stopped
+	time.Sleep(20 * time.Millisecond)
+
+	got, _ := repo.GetJob(context.Background(), job.ID.Hex())
+	if !errors.Is(stepErr, apperrors.ErrJobLeaseLost) || got.Status != model.JobStatusRunning || got.Owner != "other-instance" {
+		t.Errorf("job = %+v after update error %v", got, stepErr)
+	}
+}
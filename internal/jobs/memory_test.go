@@ -0,0 +1,155 @@
+package jobs
+
+import (
+	"context"
+	"coupon-system/internal/model"
+	apperrors "coupon-system/pkg/errors"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryJobRepository is an in-memory JobRepository with the same lease rules as MongoDB
+type memoryJobRepository struct {
+	mu   sync.Mutex
+	jobs map[primitive.ObjectID]*model.Job
+}
+
+func newMemoryJobRepository() *memoryJobRepository {
+	return &memoryJobRepository{jobs: make(map[primitive.ObjectID]*model.Job)}
+}
+
+func (r *memoryJobRepository) CreateJob(ctx context.Context, job *model.Job) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if job.ID.IsZero() {
+		job.ID = primitive.NewObjectID()
+	}
+	r.jobs[job.ID] = copyJob(job)
+	return nil
+}
+
+func (r *memoryJobRepository) GetJob(ctx context.Context, id string) (*model.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, apperrors.ErrJobNotFound
+	}
+	job, ok := r.jobs[objectID]
+	if !ok {
+		return nil, apperrors.ErrJobNotFound
+	}
+	return copyJob(job), nil
+}
+
+func (r *memoryJobRepository) ClaimNextJob(ctx context.Context, owner string, leaseUntil time.Time) (*model.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+
+	var runnable []*model.Job
+	for _, job := range r.jobs {
+		if job.Status == model.JobStatusQueued || (job.Status == model.JobStatusRunning && job.LeaseUntil.Before(now)) {
+			runnable = append(runnable, job)
+		}
+	}
+	if len(runnable) == 0 {
+		return nil, nil
+	}
+	sort.Slice(runnable, func(i, j int) bool { return runnable[i].CreatedAt.Before(runnable[j].CreatedAt) })
+
+	job := runnable[0]
+	job.Status = model.JobStatusRunning
+	job.Owner = owner
+	job.LeaseUntil = leaseUntil
+	job.Attempts++
+	if job.StartedAt == nil {
+		job.StartedAt = &now
+	}
+	return copyJob(job), nil
+}
+
+func (r *memoryJobRepository) UpdateProgress(ctx context.Context, job *model.Job, owner string, leaseUntil time.Time) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.jobs[job.ID]
+	if !ok || stored.Owner != owner || stored.Status != model.JobStatusRunning {
+		return false, apperrors.ErrJobLeaseLost
+	}
+	stored.Progress = job.Progress
+	stored.Result = copyResult(job.Result)
+	stored.LeaseUntil = leaseUntil
+	return stored.CancelRequested, nil
+}
+
+func (r *memoryJobRepository) FinishJob(ctx context.Context, job *model.Job, owner string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.jobs[job.ID]
+	if !ok || stored.Owner != owner || stored.Status != model.JobStatusRunning {
+		return apperrors.ErrJobLeaseLost
+	}
+	now := time.Now()
+	stored.Status = job.Status
+	stored.Progress = job.Progress
+	stored.Result = copyResult(job.Result)
+	stored.Error = job.Error
+	stored.FinishedAt = &now
+	return nil
+}
+
+func (r *memoryJobRepository) RequestCancel(ctx context.Context, id string) (*model.Job, error) {
+	r.mu.Lock()
+	objectID, _ := primitive.ObjectIDFromHex(id)
+	if job, ok := r.jobs[objectID]; ok {
+		job.CancelRequested = true
+		if job.Status == model.JobStatusQueued {
+			job.Status = model.JobStatusCancelled
+		}
+	}
+	r.mu.Unlock()
+	return r.GetJob(ctx, id)
+}
+
+func (r *memoryJobRepository) ReleaseJobs(ctx context.Context, owner string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, job := range r.jobs {
+		if job.Owner == owner && job.Status == model.JobStatusRunning {
+			job.Status = model.JobStatusQueued
+			job.Owner = ""
+			job.LeaseUntil = time.Time{}
+		}
+	}
+	return nil
+}
+
+// steal hands a running job to another owner, as if its lease had expired and been taken over
+func (r *memoryJobRepository) steal(id primitive.ObjectID, owner string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobs[id].Owner = owner
+}
+
+func copyJob(job *model.Job) *model.Job {
+	c := *job
+	c.Result = copyResult(job.Result)
+	return &c
+}
+
+func copyResult(result map[string]interface{}) map[string]interface{} {
+	if result == nil {
+		return nil
+	}
+	c := make(map[string]interface{}, len(result))
+	for k, v := range result {
+		c[k] = v
+	}
+	return c
+}
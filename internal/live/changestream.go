@@ -0,0 +1,90 @@
+package live
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// changeEvent is the part of a change stream event the watcher reads
+type changeEvent struct {
+	NS struct {
+		Coll string `bson:"coll"`
+	} `bson:"ns"`
+	DocumentKey struct {
+		ID interface{} `bson:"_id"`
+	} `bson:"documentKey"`
+	FullDocument struct {
+		CouponID primitive.ObjectID `bson:"coupon_id"`
+	} `bson:"fullDocument"`
+}
+
+// WatchChangeStream notifies the hub of changes to coupons and their stock
+// shards, using a single change stream on the database however many coupons
+// are watched. It runs until ctx is cancelled, resuming after errors.
+// Change streams need a replica set or sharded cluster.
+func WatchChangeStream(ctx context.Context, db *mongo.Database, hub *Hub) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"ns.coll":       bson.M{"$in": bson.A{"coupons", "coupon_stock_shards"}},
+			"operationType": bson.M{"$in": bson.A{"insert", "update", "replace"}},
+		}}},
+		// Shard changes are mapped to their coupon; nothing else is needed
+		{{Key: "$project", Value: bson.M{"ns": 1, "documentKey": 1, "fullDocument.coupon_id": 1}}},
+	}
+
+	var resumeToken bson.Raw
+	for ctx.Err() == nil {
+		opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+		if resumeToken != nil {
+			opts.SetResumeAfter(resumeToken)
+		}
+
+		stream, err := db.Watch(ctx, pipeline, opts)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Stream: failed to open change stream: %v", err)
+				sleep(ctx, time.Second)
+			}
+			// The resume point may have left the oplog; changes missed
+			// meanwhile are picked up by the hub's resync
+			resumeToken = nil
+			continue
+		}
+
+		for stream.Next(ctx) {
+			var event changeEvent
+			if err := stream.Decode(&event); err != nil {
+				log.Printf("Stream: failed to decode change event: %v", err)
+				continue
+			}
+			if event.NS.Coll == "coupons" {
+				hub.NotifyID(event.DocumentKey.ID)
+			} else if !event.FullDocument.CouponID.IsZero() {
+				hub.NotifyID(event.FullDocument.CouponID)
+			}
+			resumeToken = stream.ResumeToken()
+		}
+
+		if err := stream.Err(); err != nil && ctx.Err() == nil {
+			log.Printf("Stream: change stream interrupted, resuming: %v", err)
+			sleep(ctx, time.Second)
+		}
+		_ = stream.Close(context.Background())
+	}
+}
+
+// sleep waits for d or until ctx is cancelled
+func sleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
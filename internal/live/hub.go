@@ -0,0 +1,349 @@
+// Package live pushes coupon stock and status changes to connected clients
+package live
+
+import (
+	"context"
+	"coupon-system/internal/metrics"
+	"coupon-system/internal/model"
+	apperrors "coupon-system/pkg/errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// Coupon statuses reported in updates
+const (
+	StatusActive  = "active"
+	StatusPaused  = "paused"
+	StatusSoldOut = "sold_out"
+	StatusExpired = "expired"
+)
+
+// ErrTooManyClients is returned by Subscribe when the client limit is reached
+var ErrTooManyClients = apperrors.ErrTooManyStreamClients
+
+// ErrClosed is returned by Subscribe after the hub has been closed
+var ErrClosed = apperrors.ErrStreamClosed
+
+// Update is the state of a coupon as pushed to clients
+type Update = model.StockUpdate
+
+// NewUpdate builds the update for a coupon
+func NewUpdate(coupon *model.Coupon, now time.Time) *Update {
+	status := StatusActive
+	switch {
+	case !coupon.IsActive:
+		status = StatusPaused
+	case !coupon.ExpiresAt.IsZero() && now.After(coupon.ExpiresAt):
+		status = StatusExpired
+	case coupon.RemainingAmount <= 0:
+		status = StatusSoldOut
+	}
+	return &Update{
+		Name:            coupon.Name,
+		Amount:          coupon.Amount,
+		RemainingAmount: coupon.RemainingAmount,
+		IsActive:        coupon.IsActive,
+		Status:          status,
+		ExpiresAt:       coupon.ExpiresAt,
+	}
+}
+
+// sameState reports whether two updates show clients the same thing
+func sameState(a, b *Update) bool {
+	return a.Amount == b.Amount && a.RemainingAmount == b.RemainingAmount &&
+		a.IsActive == b.IsActive && a.Status == b.Status && a.ExpiresAt.Equal(b.ExpiresAt)
+}
+
+// Loader reads a coupon's current state
+// It should bypass caches; its errors (such as ErrCouponNotFound) are passed
+// back from Subscribe.
+type Loader func(ctx context.Context, name string) (*model.Coupon, error)
+
+// Options configures a Hub
+type Options struct {
+	Coalesce   time.Duration // Minimum time between reloads of one coupon; changes within it are merged
+	Resync     time.Duration // Reload watched coupons this often even without change notifications
+	MaxClients int           // Subscriptions beyond this are refused (0 = unlimited)
+}
+
+// Hub fans coupon updates out to subscribers
+//
+// Each watched coupon has one topic, however many clients watch it. Change
+// notifications only mark a topic dirty; the topic reloads the coupon at most
+// once per Coalesce interval, so a burst of claims costs one read and one
+// broadcast. Subscribers keep only the latest update, so a slow client skips
+// intermediate states instead of holding up the others.
+type Hub struct {
+	load Loader
+	opts Options
+
+	mu      sync.Mutex
+	topics  map[string]*topic
+	names   map[interface{}]string // Coupon ID -> name, for notifications addressed by ID
+	clients int
+	closed  bool
+
+	loads   *metrics.Counter
+	pushes  *metrics.Counter
+	refused *metrics.Counter
+}
+
+// topic is one watched coupon
+type topic struct {
+	name  string
+	subs  map[*Subscription]struct{}
+	last  *Update
+	dirty chan struct{}
+	stop  chan struct{}
+}
+
+// NewHub creates a hub and registers its metrics
+func NewHub(load Loader, opts Options, registry *metrics.Registry) *Hub {
+	if opts.Coalesce <= 0 {
+		opts.Coalesce = 250 * time.Millisecond
+	}
+	if opts.Resync <= 0 {
+		opts.Resync = 5 * time.Second
+	}
+
+	h := &Hub{
+		load:    load,
+		opts:    opts,
+		topics:  make(map[string]*topic),
+		names:   make(map[interface{}]string),
+		loads:   registry.Counter("stream_reloads_total", "Coupon reloads for stream clients"),
+		pushes:  registry.Counter("stream_updates_total", "Coupon updates broadcast to stream clients"),
+		refused: registry.Counter("stream_refused_total", "Stream clients refused at the client limit"),
+	}
+	registry.GaugeFunc("stream_clients", "Connected stream clients", func() float64 {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return float64(h.clients)
+	})
+	registry.GaugeFunc("stream_topics", "Coupons watched by stream clients", func() float64 {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return float64(len(h.topics))
+	})
+	return h
+}
+
+// Subscribe starts watching a coupon
+// The subscription holds the coupon's current state straight away. Close it
+// when the client goes away.
+func (h *Hub) Subscribe(ctx context.Context, name string) (*Subscription, error) {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if h.opts.MaxClients > 0 && h.clients >= h.opts.MaxClients {
+		h.mu.Unlock()
+		h.refused.Inc()
+		return nil, ErrTooManyClients
+	}
+	t, ok := h.topics[name]
+	last := (*Update)(nil)
+	if ok {
+		last = t.last
+	}
+	h.mu.Unlock()
+
+	// First watcher of this coupon: load it before creating the topic, so
+	// unknown coupons are refused and the client starts with real state
+	if last == nil {
+		coupon, err := h.load(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		h.loads.Inc()
+		last = NewUpdate(coupon, time.Now())
+		last.Version = 1
+
+		h.mu.Lock()
+		h.names[coupon.ID] = name
+		h.mu.Unlock()
+	}
+
+	sub := &Subscription{
+		hub:    h,
+		name:   name,
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, ErrClosed
+	}
+	t, ok = h.topics[name]
+	if !ok {
+		t = &topic{
+			name:  name,
+			subs:  make(map[*Subscription]struct{}),
+			last:  last,
+			dirty: make(chan struct{}, 1),
+			stop:  make(chan struct{}),
+		}
+		h.topics[name] = t
+		go h.run(t)
+	}
+	t.subs[sub] = struct{}{}
+	h.clients++
+	sub.latest = t.last
+	sub.notify <- struct{}{}
+	return sub, nil
+}
+
+// Notify marks a coupon as changed; it never blocks
+func (h *Hub) Notify(name string) {
+	h.mu.Lock()
+	t, ok := h.topics[name]
+	h.mu.Unlock()
+	if ok {
+		select {
+		case t.dirty <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// NotifyID marks the coupon with the given ID as changed, if it is watched
+func (h *Hub) NotifyID(id interface{}) {
+	h.mu.Lock()
+	name, ok := h.names[id]
+	h.mu.Unlock()
+	if ok {
+		h.Notify(name)
+	}
+}
+
+// Close ends every subscription and refuses new ones (server shutdown)
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	h.closed = true
+	for name, t := range h.topics {
+		for sub := range t.subs {
+			close(sub.done)
+		}
+		close(t.stop)
+		delete(h.topics, name)
+	}
+	h.clients = 0
+}
+
+// run reloads a topic's coupon when it changes and broadcasts new state
+func (h *Hub) run(t *topic) {
+	resync := time.NewTicker(h.opts.Resync)
+	defer resync.Stop()
+
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-t.dirty:
+		case <-resync.C:
+		}
+
+		h.reload(t)
+
+		// Changes arriving during the pause are merged into the next reload
+		select {
+		case <-t.stop:
+			return
+		case <-time.After(h.opts.Coalesce):
+		}
+	}
+}
+
+// reload reads the coupon and broadcasts it if anything visible changed
+func (h *Hub) reload(t *topic) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	coupon, err := h.load(ctx, t.name)
+	if err != nil {
+		log.Printf("Stream: failed to reload coupon %s: %v", t.name, err)
+		return
+	}
+	h.loads.Inc()
+	update := NewUpdate(coupon, time.Now())
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if sameState(update, t.last) {
+		return
+	}
+	update.Version = t.last.Version + 1
+	t.last = update
+	for sub := range t.subs {
+		sub.set(update)
+	}
+	h.pushes.Inc()
+}
+
+// unsubscribe removes a subscription, stopping its topic if it was the last
+func (h *Hub) unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	t, ok := h.topics[sub.name]
+	if !ok {
+		return
+	}
+	if _, ok := t.subs[sub]; !ok {
+		return
+	}
+	delete(t.subs, sub)
+	h.clients--
+	if len(t.subs) == 0 {
+		close(t.stop)
+		delete(h.topics, sub.name)
+	}
+}
+
+// Subscription is one client watching one coupon
+type Subscription struct {
+	hub    *Hub
+	name   string
+	notify chan struct{}
+	done   chan struct{}
+	once   sync.Once
+
+	mu     sync.Mutex
+	latest *Update
+}
+
+// Updates signals when a new update is ready; read it with Latest
+func (s *Subscription) Updates() <-chan struct{} { return s.notify }
+
+// Done is closed when the hub shuts the subscription down
+func (s *Subscription) Done() <-chan struct{} { return s.done }
+
+// Latest returns the most recent update
+func (s *Subscription) Latest() *Update {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.latest
+}
+
+// Close stops the subscription
+func (s *Subscription) Close() {
+	s.once.Do(func() { s.hub.unsubscribe(s) })
+}
+
+// set replaces the pending update and wakes the client if it is idle
+func (s *Subscription) set(update *Update) {
+	s.mu.Lock()
+	s.latest = update
+	s.mu.Unlock()
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,173 @@
+package live
+
+import (
+	"context"
+	"coupon-system/internal/metrics"
+	"coupon-system/internal/model"
+	apperrors "coupon-system/pkg/errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// store is a coupon source that counts loads
+type store struct {
+	mu      sync.Mutex
+	coupons map[string]*model.Coupon
+	loads   atomic.Int32
+}
+
+func newStore(coupons ...*model.Coupon) *store {
+	s := &store{coupons: make(map[string]*model.Coupon)}
+	for _, c := range coupons {
+		c.ID = primitive.NewObjectID()
+		s.coupons[c.Name] = c
+	}
+	return s
+}
+
+func (s *store) load(_ context.Context, name string) (*model.Coupon, error) {
+	s.loads.Add(1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.coupons[name]
+	if !ok {
+		return nil, apperrors.ErrCouponNotFound
+	}
+	copied := *c
+	return &copied, nil
+}
+
+func (s *store) claim(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.coupons[name].RemainingAmount--
+}
+
+func newTestHub(s *store, opts Options) *Hub {
+	return NewHub(s.load, opts, metrics.NewRegistry())
+}
+
+// next waits for the subscription's next update
+func next(t *testing.T, sub *Subscription) *Update {
+	t.Helper()
+	select {
+	case <-sub.Updates():
+		return sub.Latest()
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for an update")
+		return nil
+	}
+}
+
+// TestHubFanOutAndCoalescing checks that many clients share one watcher and
+// that a burst of changes costs one reload and one update
+func TestHubFanOutAndCoalescing(t *testing.T) {
+	s := newStore(&model.Coupon{Name: "FLASH", Amount: 100, RemainingAmount: 100, IsActive: true})
+	h := newTestHub(s, Options{Coalesce: 50 * time.Millisecond, Resync: time.Hour})
+	defer h.Close()
+
+	const clients = 50
+	subs := make([]*Subscription, clients)
+	for i := range subs {
+		sub, err := h.Subscribe(context.Background(), "FLASH")
+		if err != nil {
+			t.Fatalf("subscribe: %v", err)
+		}
+		defer sub.Close()
+		subs[i] = sub
+		if u := next(t, sub); u.RemainingAmount != 100 || u.Status != StatusActive {
+			t.Fatalf("initial update = %+v, want 100 remaining and active", u)
+		}
+	}
+	if n := s.loads.Load(); n != 1 {
+		t.Fatalf("%d loads for %d clients, want 1", n, clients)
+	}
+
+	for i := 0; i < 30; i++ {
+		s.claim("FLASH")
+		h.Notify("FLASH")
+	}
+	// A first reload may run right away; the rest of the burst waits for the next one
+	for _, sub := range subs {
+		u := next(t, sub)
+		for u.RemainingAmount != 70 {
+			u = next(t, sub)
+		}
+	}
+	if n := s.loads.Load(); n > 3 {
+		t.Fatalf("%d loads for a burst of 30 changes, want at most 3", n)
+	}
+}
+
+// TestHubStatus checks the status of sold-out and paused coupons and that
+// unchanged reloads are not pushed
+func TestHubStatus(t *testing.T) {
+	coupon := &model.Coupon{Name: "LAST", Amount: 1, RemainingAmount: 1, IsActive: true}
+	s := newStore(coupon)
+	h := newTestHub(s, Options{Coalesce: time.Millisecond, Resync: time.Hour})
+	defer h.Close()
+
+	sub, err := h.Subscribe(context.Background(), "LAST")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer sub.Close()
+	first := next(t, sub)
+
+	s.claim("LAST")
+	h.NotifyID(coupon.ID)
+	if u := next(t, sub); u.Status != StatusSoldOut || u.Version != first.Version+1 {
+		t.Fatalf("update = %+v, want sold out at version %d", u, first.Version+1)
+	}
+
+	h.Notify("LAST") // Nothing changed: no update
+	s.mu.Lock()
+	s.coupons["LAST"].IsActive = false
+	s.mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	h.Notify("LAST")
+	if u := next(t, sub); u.Status != StatusPaused || u.Version != first.Version+2 {
+		t.Fatalf("update = %+v, want paused at version %d", u, first.Version+2)
+	}
+}
+
+// TestHubSubscriptionLifecycle checks errors, the client limit and cleanup
+func TestHubSubscriptionLifecycle(t *testing.T) {
+	s := newStore(&model.Coupon{Name: "A", Amount: 1, RemainingAmount: 1, IsActive: true})
+	h := newTestHub(s, Options{MaxClients: 2})
+
+	if _, err := h.Subscribe(context.Background(), "MISSING"); err != apperrors.ErrCouponNotFound {
+		t.Fatalf("subscribe to unknown coupon: err = %v, want ErrCouponNotFound", err)
+	}
+
+	a, _ := h.Subscribe(context.Background(), "A")
+	b, _ := h.Subscribe(context.Background(), "A")
+	if _, err := h.Subscribe(context.Background(), "A"); err != ErrTooManyClients {
+		t.Fatalf("subscribe over the limit: err = %v, want ErrTooManyClients", err)
+	}
+
+	a.Close()
+	a.Close()
+	b.Close()
+	h.mu.Lock()
+	topics, clients := len(h.topics), h.clients
+	h.mu.Unlock()
+	if topics != 0 || clients != 0 {
+		t.Fatalf("%d topics and %d clients after every client left, want none", topics, clients)
+	}
+
+	c, _ := h.Subscribe(context.Background(), "A")
+	h.Close()
+	select {
+	case <-c.Done():
+	default:
+		t.Fatal("subscription still open after the hub closed")
+	}
+	if _, err := h.Subscribe(context.Background(), "A"); err != ErrClosed {
+		t.Fatalf("subscribe after close: err = %v, want ErrClosed", err)
+	}
+}
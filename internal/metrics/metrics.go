@@ -0,0 +1,168 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Registry holds the application's counters and gauges and renders them in
+// the Prometheus text exposition format
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+// family groups the series that share a metric name
+type family struct {
+	name   string
+	help   string
+	kind   string // "counter" or "gauge"
+	series map[string]*series
+}
+
+// series is one labelled time series
+type series struct {
+	labels  string
+	counter *Counter
+	gauge   func() float64
+}
+
+// Counter is a monotonically increasing value
+type Counter struct {
+	value atomic.Uint64
+}
+
+// Inc adds one to the counter
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+// Add adds n to the counter
+func (c *Counter) Add(n uint64) {
+	c.value.Add(n)
+}
+
+// Value returns the current count
+func (c *Counter) Value() uint64 {
+	return c.value.Load()
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// Counter returns the counter for a name and label pairs, creating it on first use
+// Labels are given as alternating names and values: "result", "hit"
+func (r *Registry) Counter(name, help string, labelPairs ...string) *Counter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f := r.family(name, help, "counter")
+	labels := formatLabels(labelPairs)
+	if s, ok := f.series[labels]; ok && s.counter != nil {
+		return s.counter
+	}
+
+	c := &Counter{}
+	f.series[labels] = &series{labels: labels, counter: c}
+	return c
+}
+
+// GaugeFunc registers a gauge whose value is read when metrics are scraped
+// Registering the same name and labels again replaces the function
+func (r *Registry) GaugeFunc(name, help string, fn func() float64, labelPairs ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f := r.family(name, help, "gauge")
+	labels := formatLabels(labelPairs)
+	f.series[labels] = &series{labels: labels, gauge: fn}
+}
+
+// family returns the family for a name, creating it on first use
+func (r *Registry) family(name, help, kind string) *family {
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{name: name, help: help, kind: kind, series: make(map[string]*series)}
+		r.families[name] = f
+	}
+	return f
+}
+
+// WriteTo writes all metrics in the Prometheus text format, sorted by name
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		f := r.families[name]
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			s := f.series[key]
+			if s.counter != nil {
+				fmt.Fprintf(&b, "%s%s %d\n", f.name, s.labels, s.counter.Value())
+			} else {
+				fmt.Fprintf(&b, "%s%s %s\n", f.name, s.labels, formatFloat(s.gauge()))
+			}
+		}
+	}
+	r.mu.Unlock()
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// Handler serves the registry for scraping
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = r.WriteTo(w)
+	})
+}
+
+// formatLabels renders label pairs as {a="1",b="2"} (empty when there are none)
+func formatLabels(pairs []string) string {
+	if len(pairs) == 0 {
+		return ""
+	}
+	if len(pairs)%2 != 0 {
+		panic("metrics: label pairs must be name/value pairs")
+	}
+
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		parts = append(parts, fmt.Sprintf("%s=%q", pairs[i], pairs[i+1]))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// formatFloat renders gauge values the way Prometheus expects
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return fmt.Sprintf("%g", v)
+}
@@ -0,0 +1,96 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKeyRole decides which routes an API key may call
+type APIKeyRole string
+
+// API key roles
+const (
+	RoleAdmin    APIKeyRole = "admin"     // Everything, including coupon setup and key management
+	RoleSupport  APIKeyRole = "support"   // Customer support: reads, claims on behalf of users, bulk claims, cancellations
+	RoleReadOnly APIKeyRole = "read_only" // Dashboards and reporting: reads only
+	RoleClaimer  APIKeyRole = "claimer"   // Storefronts: claims, waitlists, raffles and queues for their users
+)
+
+// APIKey is a credential for the API
+// Only the SHA-256 hash of the key is stored; the key itself is shown once,
+// when it is created or rotated.
+type APIKey struct {
+	ID         primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	Name       string              `bson:"name" json:"name"`
+	Role       APIKeyRole          `bson:"role" json:"role"`
+	Prefix     string              `bson:"prefix" json:"prefix"` // Start of the key, to recognise it in logs and the audit log
+	Hash       string              `bson:"hash" json:"-"`
+	CreatedAt  time.Time           `bson:"created_at" json:"created_at"`
+	ExpiresAt  *time.Time          `bson:"expires_at,omitempty" json:"expires_at,omitempty"`     // Set when the key is created with an expiry or rotated
+	RevokedAt  *time.Time          `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`     // Revoked keys are kept for the audit log
+	RotatedTo  *primitive.ObjectID `bson:"rotated_to,omitempty" json:"rotated_to,omitempty"`     // The key that replaced this one
+	LastUsedAt *time.Time          `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"` // Updated at most once per audit flush
+}
+
+// Active reports whether the key may be used at the given time
+func (k *APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// Allows reports whether the key has one of the given roles; admins are allowed everything
+func (k *APIKey) Allows(roles ...APIKeyRole) bool {
+	if k.Role == RoleAdmin {
+		return true
+	}
+	for _, role := range roles {
+		if k.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyAuditOutcome is the result of a request as far as authentication is concerned
+type APIKeyAuditOutcome string
+
+// API key audit outcomes
+const (
+	AuditAllowed         APIKeyAuditOutcome = "allowed"         // The key was valid and has the route's role
+	AuditUnauthenticated APIKeyAuditOutcome = "unauthenticated" // No key, an unknown key, or a revoked or expired one
+	AuditForbidden       APIKeyAuditOutcome = "forbidden"       // A valid key without the route's role
+)
+
+// APIKeyAuditEntry records one request made with, or without, an API key
+type APIKeyAuditEntry struct {
+	ID        primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	KeyID     *primitive.ObjectID `bson:"key_id,omitempty" json:"key_id,omitempty"` // Unset when the key was missing or unknown
+	KeyName   string              `bson:"key_name,omitempty" json:"key_name,omitempty"`
+	KeyPrefix string              `bson:"key_prefix,omitempty" json:"key_prefix,omitempty"`
+	Role      APIKeyRole          `bson:"role,omitempty" json:"role,omitempty"`
+	Outcome   APIKeyAuditOutcome  `bson:"outcome" json:"outcome"`
+	Method    string              `bson:"method" json:"method"` // HTTP method, or GRPC
+	Route     string              `bson:"route" json:"route"`   // Route pattern, e.g. /api/coupons/:name, or the full gRPC method
+	Status    int                 `bson:"status" json:"status"` // HTTP status, or the gRPC status code
+	ClientIP  string              `bson:"client_ip" json:"client_ip"`
+	At        time.Time           `bson:"at" json:"at"`
+}
+
+// CreateAPIKeyRequest represents the request to issue an API key
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Role      APIKeyRole `json:"role" binding:"required,oneof=admin support read_only claimer"`
+	ExpiresAt string     `json:"expires_at"` // Optional RFC3339 time in the future; keys do not expire by default
+}
+
+// StreamToken stands in for an API key where a header cannot be sent, e.g.
+// from a browser's EventSource or WebSocket, as ?token=
+type StreamToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// RotateAPIKeyRequest represents the request to replace an API key
+type RotateAPIKeyRequest struct {
+	GracePeriod string `json:"grace_period"` // How long the old key keeps working, e.g. 1h (default: AUTH_ROTATION_GRACE)
+}
@@ -10,12 +10,29 @@ import (
 type Coupon struct {
 	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
 	Name            string             `bson:"name" json:"name"`
-	Amount          int32              `bson:"amount" json:"amount"`           // in cents
+	Amount          int32              `bson:"amount" json:"amount"`                     // in cents
 	RemainingAmount int32              `bson:"remaining_amount" json:"remaining_amount"` // in cents
 	CreatedAt       time.Time          `bson:"created_at" json:"created_at"`
 	ExpiresAt       time.Time          `bson:"expired_at" json:"expired_at"`
 	IsActive        bool               `bson:"is_active" json:"is_active"`
 	UpdatedAt       time.Time          `bson:"updated_at" json:"updated_at"`
+
+	// StockShards splits RemainingAmount across this many counter documents so
+	// claims on a hot coupon do not all serialize on one document (0 = single counter)
+	StockShards int32 `bson:"stock_shards,omitempty" json:"stock_shards,omitempty"`
+
+	// QueueEnabled puts claims behind a virtual queue: only admitted queue token holders may claim
+	QueueEnabled bool `bson:"queue_enabled,omitempty" json:"queue_enabled,omitempty"`
+
+	// Raffle distribution (DistributionMode == DistributionRaffle)
+	DistributionMode string     `bson:"distribution_mode,omitempty" json:"distribution_mode,omitempty"`
+	EntryStartsAt    *time.Time `bson:"entry_starts_at,omitempty" json:"entry_starts_at,omitempty"`
+	EntryEndsAt      *time.Time `bson:"entry_ends_at,omitempty" json:"entry_ends_at,omitempty"`
+}
+
+// IsRaffle reports whether the coupon is distributed by raffle instead of first come, first served
+func (c *Coupon) IsRaffle() bool {
+	return c.DistributionMode == DistributionRaffle
 }
 
 // Claim represents a coupon claim by a user
@@ -25,25 +42,94 @@ type Claim struct {
 	CouponID   primitive.ObjectID `bson:"coupon_id" json:"coupon_id"`     // Used for unique index
 	CouponName string             `bson:"coupon_name" json:"coupon_name"` // Denormalized for querying
 	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+
+	// LeaseID is set when the claim was served from a stock lease (pre-allocation mode)
+	LeaseID *primitive.ObjectID `bson:"lease_id,omitempty" json:"-"`
+
+	// EventPending is set while the claim's outbox event may not be stored yet
+	// (no transaction); the outbox relay records the event of claims left marked
+	EventPending bool `bson:"event_pending,omitempty" json:"-"`
+
+	// Waitlisted is set on claims granted from the waitlist, whose events
+	// include waitlist.granted
+	Waitlisted bool `bson:"waitlisted,omitempty" json:"-"`
 }
 
 // ClaimCouponRequest represents the request to claim a coupon
 type ClaimCouponRequest struct {
-	UserID     string `json:"user_id" binding:"required"`
+	UserID     string `json:"user_id" binding:"required,user_id"`
 	CouponName string `json:"coupon_name" binding:"required"`
+	QueueToken string `json:"queue_token,omitempty"` // Required for queue-enabled coupons; may also be sent as X-Queue-Token
 }
 
 // CreateCouponRequest represents the request to create a new coupon
 type CreateCouponRequest struct {
-	Name      string `json:"name" binding:"required"`
+	Name      string `json:"name" binding:"required,coupon_name"`
 	Amount    int32  `json:"amount" binding:"required,gt=0"`
-	ExpiresAt string `json:"expires_at"` // Optional, RFC3339 format
+	ExpiresAt string `json:"expires_at"` // Optional RFC3339 time in the future (default: 30 days from now)
+
+	// Optional virtual queue for flash sales
+	QueueEnabled bool `json:"queue_enabled"`
+
+	// Optional number of stock counter shards for hot coupons (default: STOCK_SHARDS)
+	StockShards int32 `json:"stock_shards" binding:"omitempty,min=0,max=64"`
+
+	// Optional raffle distribution; entry times are RFC3339 and required for raffles
+	DistributionMode string `json:"distribution_mode" binding:"omitempty,oneof=fcfs raffle"`
+	EntryStartsAt    string `json:"entry_starts_at"`
+	EntryEndsAt      string `json:"entry_ends_at"`
+}
+
+// BulkClaimRequest represents a back-office request to grant a coupon to many users
+type BulkClaimRequest struct {
+	CouponName string   `json:"coupon_name" binding:"required"`
+	UserIDs    []string `json:"user_ids" binding:"required,min=1,max=10000,dive,required,user_id"`
+}
+
+// Per-user outcomes of a bulk claim
+const (
+	BulkClaimStatusClaimed        = "claimed"
+	BulkClaimStatusAlreadyClaimed = "already_claimed"
+	BulkClaimStatusNoStock        = "no_stock"
+)
+
+// BulkClaimResult is the outcome of a bulk claim for a single user
+type BulkClaimResult struct {
+	UserID string `json:"user_id"`
+	Status string `json:"status"`
+}
+
+// BulkClaimResponse represents the response for a bulk claim
+type BulkClaimResponse struct {
+	CouponName     string            `json:"coupon_name"`
+	Claimed        int               `json:"claimed"`
+	AlreadyClaimed int               `json:"already_claimed"`
+	NoStock        int               `json:"no_stock"`
+	Results        []BulkClaimResult `json:"results"`
+}
+
+// RestockCouponRequest represents the request to add stock to a coupon
+type RestockCouponRequest struct {
+	Amount int32 `json:"amount" binding:"required,gt=0"`
 }
 
 // CouponDetailsResponse represents the response for coupon details
 type CouponDetailsResponse struct {
-	Name           string   `json:"name"`
-	Amount         int32    `json:"amount"`          // in cents
-	RemainingAmount int32   `json:"remaining_amount"` // in cents
-	ClaimedBy      []string `json:"claimed_by"`
-}
\ No newline at end of file
+	Name            string    `json:"name"`
+	Amount          int32     `json:"amount"`           // in cents
+	RemainingAmount int32     `json:"remaining_amount"` // in cents
+	IsActive        bool      `json:"is_active"`
+	ExpiresAt       time.Time `json:"expires_at"`
+	ClaimedBy       []string  `json:"claimed_by"`
+}
+
+// StockUpdate is the state of a coupon as pushed to stream clients
+type StockUpdate struct {
+	Name            string    `json:"name"`
+	Amount          int32     `json:"amount"`
+	RemainingAmount int32     `json:"remaining_amount"`
+	IsActive        bool      `json:"is_active"`
+	Status          string    `json:"status"`
+	ExpiresAt       time.Time `json:"expired_at"`
+	Version         uint64    `json:"version"` // Increases with every change pushed for the coupon
+}
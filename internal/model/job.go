@@ -0,0 +1,66 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// JobStatus is the lifecycle state of a background job
+type JobStatus string
+
+// Job statuses
+const (
+	JobStatusQueued    JobStatus = "queued"
+	JobStatusRunning   JobStatus = "running"
+	JobStatusSucceeded JobStatus = "succeeded"
+	JobStatusFailed    JobStatus = "failed"
+	JobStatusCancelled JobStatus = "cancelled"
+)
+
+// Job represents a long-running operation executed by the server's worker pool
+type Job struct {
+	ID              primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	Type            string                 `bson:"type" json:"type"`
+	Status          JobStatus              `bson:"status" json:"status"`
+	Params          map[string]interface{} `bson:"params" json:"params,omitempty"`
+	Progress        JobProgress            `bson:"progress" json:"progress"`
+	Result          map[string]interface{} `bson:"result,omitempty" json:"result,omitempty"`
+	Error           string                 `bson:"error,omitempty" json:"error,omitempty"`
+	CancelRequested bool                   `bson:"cancel_requested" json:"cancel_requested"`
+	Attempts        int32                  `bson:"attempts" json:"attempts"`
+	Owner           string                 `bson:"owner,omitempty" json:"-"`       // Worker instance holding the lease
+	LeaseUntil      time.Time              `bson:"lease_until,omitempty" json:"-"` // Expired leases are picked up again after a crash
+	CreatedAt       time.Time              `bson:"created_at" json:"created_at"`
+	StartedAt       *time.Time             `bson:"started_at,omitempty" json:"started_at,omitempty"`
+	FinishedAt      *time.Time             `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+	UpdatedAt       time.Time              `bson:"updated_at" json:"updated_at"`
+}
+
+// JobProgress tracks how far a job has got
+// Done doubles as the resume point when a job is picked up again after a restart
+type JobProgress struct {
+	Done  int64 `bson:"done" json:"done"`
+	Total int64 `bson:"total" json:"total"`
+}
+
+// IsFinished reports whether the job has reached a terminal state
+func (j *Job) IsFinished() bool {
+	return j.Status == JobStatusSucceeded || j.Status == JobStatusFailed || j.Status == JobStatusCancelled
+}
+
+// DecodeParams decodes the job parameters into v
+func (j *Job) DecodeParams(v interface{}) error {
+	data, err := json.Marshal(j.Params)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// CreateJobRequest represents the request to enqueue a job
+type CreateJobRequest struct {
+	Type   string                 `json:"type" binding:"required"`
+	Params map[string]interface{} `json:"params"`
+}
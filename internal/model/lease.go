@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Stock lease statuses
+const (
+	LeaseStatusActive    = "active"    // Held by a live instance
+	LeaseStatusReturned  = "returned"  // Unused stock given back by its owner
+	LeaseStatusReclaimed = "reclaimed" // Expired (owner crashed); unused stock given back by another instance
+)
+
+// StockLease is a block of stock taken from a coupon by one server instance
+// Claims served from the lease carry its ID, so after a crash the unused part
+// can be computed and returned without overselling
+type StockLease struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	CouponID  primitive.ObjectID `bson:"coupon_id" json:"coupon_id"`
+	Owner     string             `bson:"owner" json:"owner"`
+	Size      int32              `bson:"size" json:"size"`
+	Used      int32              `bson:"used" json:"used"` // Claims committed from this lease, updated after each group commit
+	Returned  int32              `bson:"returned" json:"returned"`
+	Status    string             `bson:"status" json:"status"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"` // Extended by the owner's heartbeat
+}
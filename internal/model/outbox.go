@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OutboxEvent is a domain event stored with the state change that caused it
+// The relay publishes unpublished events in _id order and records, per sink,
+// which ones have received it, so a failing sink does not cause duplicates
+// elsewhere
+type OutboxEvent struct {
+	ID         primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	Key        string                 `bson:"key,omitempty" json:"-"` // Optional dedupe key; a second event with the same key is dropped
+	Type       string                 `bson:"type" json:"type"`
+	CouponName string                 `bson:"coupon_name" json:"coupon_name"`
+	UserID     string                 `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	Data       map[string]interface{} `bson:"data,omitempty" json:"data,omitempty"`
+	OccurredAt time.Time              `bson:"occurred_at" json:"occurred_at"`
+
+	// Delivery state, maintained by the relay
+	DeliveredTo   []string   `bson:"delivered_to,omitempty" json:"delivered_to,omitempty"`
+	PublishedAt   *time.Time `bson:"published_at" json:"published_at,omitempty"` // Set once every sink has the event
+	Attempts      int        `bson:"attempts,omitempty" json:"attempts,omitempty"`
+	LastError     string     `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	NextAttemptAt time.Time  `bson:"next_attempt_at,omitempty" json:"next_attempt_at,omitempty"`
+}
@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// Queue ticket statuses
+const (
+	QueueStatusWaiting  = "waiting"  // In line; poll for position
+	QueueStatusAdmitted = "admitted" // May call the claim endpoint with the token until ExpiresAt
+	QueueStatusUsed     = "used"     // Token was spent on a claim
+	QueueStatusExpired  = "expired"  // Abandoned while waiting, or admitted but not used in time
+)
+
+// QueueTicket is a user's place in a coupon's virtual queue
+type QueueTicket struct {
+	Token       string    `json:"token"`
+	CouponName  string    `json:"coupon_name"`
+	UserID      string    `json:"user_id"`
+	Status      string    `json:"status"`
+	Position    int64     `json:"position,omitempty"` // 1-based; only set while waiting
+	ExpiresAt   time.Time `json:"expires_at"`
+	PollAfterMs int64     `json:"poll_after_ms,omitempty"`
+}
+
+// JoinQueueRequest represents the request to join a coupon's virtual queue
+type JoinQueueRequest struct {
+	UserID string `json:"user_id" binding:"required,user_id"`
+}
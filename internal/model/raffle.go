@@ -0,0 +1,61 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Coupon distribution modes
+const (
+	DistributionFCFS   = "fcfs"   // First come, first served through the claim endpoint (default)
+	DistributionRaffle = "raffle" // Users enter during a window; winners are drawn afterwards
+)
+
+// RaffleEntry represents a user's entry into a coupon raffle
+type RaffleEntry struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	CouponID  primitive.ObjectID `bson:"coupon_id" json:"-"`
+	UserID    string             `bson:"user_id" json:"user_id"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// Raffle draw statuses
+const (
+	RaffleDrawStatusDrawn     = "drawn"     // Winners selected, claims not yet created
+	RaffleDrawStatusCompleted = "completed" // Winners converted into claims
+)
+
+// RaffleDraw is the persisted, verifiable record of a raffle draw
+// Anyone with the seed and the entrant list can recompute Winners with raffle.Select
+type RaffleDraw struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	CouponID    primitive.ObjectID `bson:"coupon_id" json:"-"`
+	CouponName  string             `bson:"coupon_name" json:"coupon_name"`
+	Status      string             `bson:"status" json:"status"`
+	Algorithm   string             `bson:"algorithm" json:"algorithm"`
+	Seed        string             `bson:"seed" json:"seed"`
+	EntryCount  int                `bson:"entry_count" json:"entry_count"`
+	EntriesHash string             `bson:"entries_hash" json:"entries_hash"`
+	Stock       int32              `bson:"stock" json:"stock"`
+	Winners     []string           `bson:"winners" json:"winners"`
+	Claimed     int                `bson:"claimed" json:"claimed"`
+	DrawnAt     time.Time          `bson:"drawn_at" json:"drawn_at"`
+	CompletedAt *time.Time         `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+}
+
+// EnterRaffleRequest represents the request to enter a raffle
+type EnterRaffleRequest struct {
+	UserID string `json:"user_id" binding:"required,user_id"`
+}
+
+// RaffleVerification is the result of re-running a draw from its persisted seed
+type RaffleVerification struct {
+	CouponName     string `json:"coupon_name"`
+	Verified       bool   `json:"verified"`
+	EntriesMatch   bool   `json:"entries_match"`
+	WinnersMatch   bool   `json:"winners_match"`
+	EntryCount     int    `json:"entry_count"`
+	ExpectedHash   string `json:"expected_entries_hash"`
+	RecomputedHash string `json:"recomputed_entries_hash"`
+}
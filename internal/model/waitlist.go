@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Waitlist entry statuses
+const (
+	WaitlistStatusWaiting  = "waiting"
+	WaitlistStatusGranting = "granting" // Picked by a worker that is creating the claim
+	WaitlistStatusGranted  = "granted"
+)
+
+// WaitlistEntry represents a user waiting for a sold-out coupon
+// Entries are served in _id order (FIFO)
+type WaitlistEntry struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	CouponID   primitive.ObjectID `bson:"coupon_id" json:"-"`
+	CouponName string             `bson:"coupon_name" json:"coupon_name"`
+	UserID     string             `bson:"user_id" json:"user_id"`
+	Status     string             `bson:"status" json:"status"`
+	Reserved   bool               `bson:"stock_reserved" json:"-"` // A unit of stock has been taken for this grant
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt  time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// JoinWaitlistRequest represents the request to join a coupon's waitlist
+type JoinWaitlistRequest struct {
+	UserID string `json:"user_id" binding:"required,user_id"`
+}
+
+// WaitlistStatusResponse represents a user's place on a waitlist
+type WaitlistStatusResponse struct {
+	CouponName string `json:"coupon_name"`
+	UserID     string `json:"user_id"`
+	Status     string `json:"status"`
+	Position   int64  `json:"position,omitempty"` // 1-based; only set while waiting
+}
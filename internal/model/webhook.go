@@ -0,0 +1,76 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WebhookEventAll subscribes to every event type
+const WebhookEventAll = "*"
+
+// WebhookSubscription is a partner endpoint that receives events over HTTP
+type WebhookSubscription struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	URL        string             `bson:"url" json:"url"`
+	EventTypes []string           `bson:"event_types" json:"event_types"` // Event types delivered, or ["*"] for all
+	Secret     string             `bson:"secret" json:"-"`                // HMAC key; only returned when the subscription is created
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// Matches reports whether the subscription receives events of the given type
+func (s *WebhookSubscription) Matches(eventType string) bool {
+	for _, t := range s.EventTypes {
+		if t == WebhookEventAll || t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDeliveryStatus is the state of one event's delivery to one subscription
+type WebhookDeliveryStatus string
+
+// Webhook delivery statuses
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"   // Waiting for its first or next attempt
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded" // Acknowledged with a 2xx response
+	WebhookDeliveryDead      WebhookDeliveryStatus = "dead"      // Out of attempts; kept in the dead-letter queue until replayed
+)
+
+// WebhookDelivery is one event to be sent to one subscription
+// Deliveries are unique per (subscription, event), so an event published
+// twice by the outbox relay is only sent once.
+type WebhookDelivery struct {
+	ID             primitive.ObjectID    `bson:"_id,omitempty" json:"id"`
+	SubscriptionID primitive.ObjectID    `bson:"subscription_id" json:"subscription_id"`
+	EventID        string                `bson:"event_id" json:"event_id"`
+	EventType      string                `bson:"event_type" json:"event_type"`
+	Payload        json.RawMessage       `bson:"payload" json:"payload"` // Request body, identical on every attempt
+	Status         WebhookDeliveryStatus `bson:"status" json:"status"`
+	Attempts       int                   `bson:"attempts" json:"attempts"`
+	NextAttemptAt  time.Time             `bson:"next_attempt_at" json:"next_attempt_at"`
+	LastError      string                `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	Log            []WebhookAttempt      `bson:"log,omitempty" json:"log,omitempty"` // Most recent attempts, oldest first
+	Owner          string                `bson:"owner,omitempty" json:"-"`           // Worker sending the delivery
+	LeaseUntil     time.Time             `bson:"lease_until,omitempty" json:"-"`     // Deliveries of a crashed worker are picked up again after this
+	CreatedAt      time.Time             `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time             `bson:"updated_at" json:"updated_at"`
+	DeliveredAt    *time.Time            `bson:"delivered_at,omitempty" json:"delivered_at,omitempty"`
+}
+
+// WebhookAttempt records one HTTP attempt of a delivery
+type WebhookAttempt struct {
+	At         time.Time `bson:"at" json:"at"`
+	StatusCode int       `bson:"status_code,omitempty" json:"status_code,omitempty"`
+	Error      string    `bson:"error,omitempty" json:"error,omitempty"`
+	DurationMS int64     `bson:"duration_ms" json:"duration_ms"`
+}
+
+// CreateWebhookRequest represents the request to subscribe an endpoint
+type CreateWebhookRequest struct {
+	URL        string   `json:"url" binding:"required"`
+	EventTypes []string `json:"event_types"` // Empty subscribes to all events
+	Secret     string   `json:"secret"`      // Generated when empty
+}
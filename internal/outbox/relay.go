@@ -0,0 +1,317 @@
+// Package outbox publishes domain events stored in the outbox collection
+package outbox
+
+import (
+	"context"
+	"coupon-system/internal/events"
+	"coupon-system/internal/metrics"
+	"coupon-system/internal/model"
+	"coupon-system/internal/owner"
+	"coupon-system/internal/repository"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Sink receives published events
+// Delivery is at least once: a sink may see an event again after a failure or
+// a relay failover, and should use Event.ID to drop duplicates.
+type Sink interface {
+	// Name identifies the sink in delivery records; it must stay stable
+	Name() string
+
+	// Publish delivers one event; an error makes the relay retry it later
+	Publish(ctx context.Context, event events.Event) error
+}
+
+// publisherSink adapts an events.Publisher to a Sink
+type publisherSink struct {
+	name      string
+	publisher events.Publisher
+}
+
+// PublisherSink delivers events to an events.Publisher, such as the in-process bus
+func PublisherSink(name string, publisher events.Publisher) Sink {
+	return &publisherSink{name: name, publisher: publisher}
+}
+
+func (s *publisherSink) Name() string { return s.name }
+
+func (s *publisherSink) Publish(ctx context.Context, event events.Event) error {
+	return s.publisher.Publish(ctx, event)
+}
+
+// Recoverer stores events whose outbox write may have been lost
+// Without transactions a change can be written without its event. Such
+// changes are marked until their event is stored, and the relay leader asks
+// for the events of changes marked for longer than Options.RecoverAfter.
+type Recoverer interface {
+	// RecoverEvents stores the events of changes marked before the given time
+	// and returns how many it stored
+	RecoverEvents(ctx context.Context, before time.Time) (int, error)
+}
+
+// Options configures the relay
+type Options struct {
+	PollInterval time.Duration // How often to look for new events
+	BatchSize    int           // Events read per poll
+	LeaseTTL     time.Duration // How long the relay lease lasts without renewal
+	MaxBackoff   time.Duration // Cap on the retry delay after failed deliveries
+	RecoverAfter time.Duration // Changes still missing their event after this long are recovered
+}
+
+// Relay publishes outbox events to sinks, in order and at least once
+//
+// One instance at a time holds the relay lease and publishes; the others
+// stand by and take over when the lease expires. Events are published in the
+// order they were recorded. When a sink fails, the relay stops at that event
+// and retries it with exponential backoff, so later events never overtake it.
+// Each sink's delivery is recorded, so a retry only goes to the sinks that
+// have not received the event yet.
+type Relay struct {
+	repo       repository.OutboxRepository
+	sinks      []Sink
+	recoverers []Recoverer
+	opts       Options
+	owner      string
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+
+	published *metrics.Counter
+	recovered *metrics.Counter
+	failures  map[string]*metrics.Counter
+	leader    bool
+	leaderMu  sync.Mutex
+}
+
+// NewRelay creates a relay and registers its metrics
+func NewRelay(repo repository.OutboxRepository, sinks []Sink, opts Options, registry *metrics.Registry) *Relay {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 100 * time.Millisecond
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.LeaseTTL <= 0 {
+		opts.LeaseTTL = 10 * time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = time.Minute
+	}
+	if opts.RecoverAfter <= 0 {
+		opts.RecoverAfter = 10 * time.Second
+	}
+
+	r := &Relay{
+		repo:      repo,
+		sinks:     sinks,
+		opts:      opts,
+		owner:     owner.NewID(),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+		published: registry.Counter("outbox_published_total", "Outbox events delivered to every sink"),
+		recovered: registry.Counter("outbox_recovered_total", "Events stored by the relay for changes that were missing them"),
+		failures:  make(map[string]*metrics.Counter, len(sinks)),
+	}
+	for _, sink := range sinks {
+		r.failures[sink.Name()] = registry.Counter("outbox_delivery_failures_total", "Failed outbox deliveries by sink", "sink", sink.Name())
+	}
+	registry.GaugeFunc("outbox_relay_leader", "1 if this instance is publishing outbox events", func() float64 {
+		if r.isLeader() {
+			return 1
+		}
+		return 0
+	})
+	return r
+}
+
+// AddRecoverer has the relay leader recover missing events from rec
+// It must be called before Start.
+func (r *Relay) AddRecoverer(rec Recoverer) {
+	r.recoverers = append(r.recoverers, rec)
+}
+
+// Start begins relaying in the background
+func (r *Relay) Start() {
+	go r.run()
+}
+
+// Stop stops relaying and releases the lease so another instance can take over
+func (r *Relay) Stop(ctx context.Context) error {
+	r.once.Do(func() { close(r.stop) })
+
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if r.isLeader() {
+		return r.repo.ReleaseRelayLease(ctx, r.owner)
+	}
+	return nil
+}
+
+// run polls for events until stopped
+func (r *Relay) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.opts.PollInterval)
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-r.stop
+		cancel()
+	}()
+
+	var renewed, recovered time.Time
+	for {
+		// Renew the lease well before it expires
+		if time.Since(renewed) >= r.opts.LeaseTTL/3 {
+			leader, err := r.repo.AcquireRelayLease(ctx, r.owner, r.opts.LeaseTTL)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Outbox relay: failed to acquire lease: %v", err)
+			}
+			r.setLeader(leader && err == nil)
+			renewed = time.Now()
+		}
+
+		if r.isLeader() && time.Since(recovered) >= r.opts.RecoverAfter/2 {
+			r.recover(ctx)
+			recovered = time.Now()
+		}
+
+		if r.isLeader() {
+			// Keep draining while full batches come back
+			for {
+				n, err := r.relayBatch(ctx)
+				if err != nil && ctx.Err() == nil {
+					log.Printf("Outbox relay: %v", err)
+				}
+				if err != nil || n < r.opts.BatchSize {
+					break
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// recover stores the events missing for changes older than RecoverAfter
+func (r *Relay) recover(ctx context.Context) {
+	before := time.Now().Add(-r.opts.RecoverAfter)
+	for _, rec := range r.recoverers {
+		n, err := rec.RecoverEvents(ctx, before)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Outbox relay: failed to recover events: %v", err)
+		}
+		if n > 0 {
+			r.recovered.Add(uint64(n))
+			log.Printf("Outbox relay: recovered %d events", n)
+		}
+	}
+}
+
+// relayBatch publishes one batch of events in order
+// Returns how many events were published; it stops early at an event that
+// is waiting for a retry or fails again
+func (r *Relay) relayBatch(ctx context.Context) (int, error) {
+	batch, err := r.repo.Unpublished(ctx, r.opts.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read events: %w", err)
+	}
+
+	for i, event := range batch {
+		if time.Now().Before(event.NextAttemptAt) {
+			return i, nil
+		}
+		if err := r.deliver(ctx, event); err != nil {
+			return i, err
+		}
+	}
+	return len(batch), nil
+}
+
+// deliver sends an event to every sink that has not received it yet
+func (r *Relay) deliver(ctx context.Context, stored *model.OutboxEvent) error {
+	delivered := make(map[string]bool, len(stored.DeliveredTo))
+	for _, name := range stored.DeliveredTo {
+		delivered[name] = true
+	}
+	event := toEvent(stored)
+
+	for _, sink := range r.sinks {
+		if delivered[sink.Name()] {
+			continue
+		}
+
+		if err := sink.Publish(ctx, event); err != nil {
+			r.failures[sink.Name()].Inc()
+			next := time.Now().Add(r.backoff(stored.Attempts + 1))
+			if recErr := r.repo.RecordFailure(ctx, stored.ID, fmt.Sprintf("%s: %v", sink.Name(), err), next); recErr != nil {
+				return fmt.Errorf("failed to record failed delivery of %s: %w", event.ID, recErr)
+			}
+			return fmt.Errorf("sink %s failed for event %s (attempt %d): %w", sink.Name(), event.ID, stored.Attempts+1, err)
+		}
+		if err := r.repo.MarkDelivered(ctx, stored.ID, sink.Name()); err != nil {
+			return fmt.Errorf("failed to record delivery of %s: %w", event.ID, err)
+		}
+	}
+
+	if err := r.repo.MarkPublished(ctx, stored.ID); err != nil {
+		return fmt.Errorf("failed to mark %s published: %w", event.ID, err)
+	}
+	r.published.Inc()
+	return nil
+}
+
+// backoff is the delay before retry number n of an event: 1s, 2s, 4s, ... up to MaxBackoff
+func (r *Relay) backoff(n int) time.Duration {
+	delay := time.Second
+	for i := 1; i < n && delay < r.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > r.opts.MaxBackoff {
+		delay = r.opts.MaxBackoff
+	}
+	return delay
+}
+
+func (r *Relay) isLeader() bool {
+	r.leaderMu.Lock()
+	defer r.leaderMu.Unlock()
+	return r.leader
+}
+
+func (r *Relay) setLeader(leader bool) {
+	r.leaderMu.Lock()
+	defer r.leaderMu.Unlock()
+	if leader != r.leader {
+		if leader {
+			log.Printf("📤 Outbox relay: this instance is publishing events")
+		}
+		r.leader = leader
+	}
+}
+
+// toEvent converts a stored event for delivery
+func toEvent(stored *model.OutboxEvent) events.Event {
+	return events.Event{
+		ID:         stored.ID.Hex(),
+		Type:       stored.Type,
+		CouponName: stored.CouponName,
+		UserID:     stored.UserID,
+		Data:       stored.Data,
+		OccurredAt: stored.OccurredAt,
+	}
+}
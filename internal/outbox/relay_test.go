@@ -0,0 +1,191 @@
+package outbox
+
+import (
+	"context"
+	"coupon-system/internal/events"
+	"coupon-system/internal/metrics"
+	"coupon-system/internal/model"
+	"coupon-system/internal/repository/repotest"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingSink records what it receives and fails while failing is set
+type recordingSink struct {
+	name    string
+	mu      sync.Mutex
+	got     []string
+	failing bool
+}
+
+func (s *recordingSink) Name() string { return s.name }
+
+func (s *recordingSink) Publish(ctx context.Context, event events.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failing {
+		return errors.New("sink down")
+	}
+	s.got = append(s.got, event.UserID)
+	return nil
+}
+
+func (s *recordingSink) setFailing(failing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing = failing
+}
+
+func (s *recordingSink) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.got...)
+}
+
+func appendEvents(t *testing.T, repo *repotest.OutboxRepository, users ...string) {
+	t.Helper()
+	for _, user := range users {
+		if err := repo.Append(context.Background(), &model.OutboxEvent{Type: events.TypeCouponClaimed, CouponName: "OUTBOX", UserID: user}); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+}
+
+// TestRelayOrderAndPerSinkDelivery checks that a failing sink holds back later
+// events and gets them in order once it recovers, without duplicating
+// deliveries to the sink that was healthy
+func TestRelayOrderAndPerSinkDelivery(t *testing.T) {
+	repo := repotest.NewOutboxRepository()
+	healthy := &recordingSink{name: "healthy"}
+	flaky := &recordingSink{name: "flaky"}
+	relay := NewRelay(repo, []Sink{healthy, flaky}, Options{}, metrics.NewRegistry())
+	ctx := context.Background()
+
+	appendEvents(t, repo, "u1", "u2")
+	if n, err := relay.relayBatch(ctx); err != nil || n != 2 {
+		t.Fatalf("relayBatch = %d, %v; want 2, nil", n, err)
+	}
+
+	flaky.setFailing(true)
+	appendEvents(t, repo, "u3", "u4")
+	if n, err := relay.relayBatch(ctx); err == nil || n != 0 {
+		t.Fatalf("relayBatch with a failing sink = %d, %v; want 0 and an error", n, err)
+	}
+
+	// Backing off: the event is not retried before it is due
+	flaky.setFailing(false)
+	if n, err := relay.relayBatch(ctx); err != nil || n != 0 {
+		t.Fatalf("relayBatch while backing off = %d, %v; want 0, nil", n, err)
+	}
+
+	repo.RetryNow()
+	if n, err := relay.relayBatch(ctx); err != nil || n != 2 {
+		t.Fatalf("relayBatch after recovery = %d, %v; want 2, nil", n, err)
+	}
+
+	want := "[u1 u2 u3 u4]"
+	if got := fmt.Sprint(healthy.received()); got != want {
+		t.Errorf("healthy sink got %s, want %s", got, want)
+	}
+	if got := fmt.Sprint(flaky.received()); got != want {
+		t.Errorf("flaky sink got %s, want %s", got, want)
+	}
+	if pending, _ := repo.Unpublished(ctx, 10); len(pending) != 0 {
+		t.Errorf("%d events still unpublished", len(pending))
+	}
+	if e := repo.Events()[2]; e.Attempts != 1 || e.LastError == "" {
+		t.Errorf("failed event attempts = %d, last error = %q; want 1 and an error", e.Attempts, e.LastError)
+	}
+}
+
+// TestRelayLeader checks that only the lease holder publishes and that
+// stopping hands the lease over
+func TestRelayLeader(t *testing.T) {
+	repo := repotest.NewOutboxRepository()
+	first := &recordingSink{name: "bus"}
+	second := &recordingSink{name: "bus"}
+	opts := Options{PollInterval: 5 * time.Millisecond, LeaseTTL: 15 * time.Millisecond}
+	a := NewRelay(repo, []Sink{first}, opts, metrics.NewRegistry())
+	b := NewRelay(repo, []Sink{second}, opts, metrics.NewRegistry())
+
+	a.Start()
+	waitFor(t, func() bool { return a.isLeader() })
+	b.Start()
+
+	appendEvents(t, repo, "u1")
+	waitFor(t, func() bool { return len(first.received()) == 1 })
+
+	if err := a.Stop(context.Background()); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	appendEvents(t, repo, "u2")
+	waitFor(t, func() bool { return len(second.received()) == 1 })
+	if err := b.Stop(context.Background()); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+
+	if got := fmt.Sprint(first.received(), second.received()); got != "[u1] [u2]" {
+		t.Errorf("deliveries = %s, want [u1] [u2]", got)
+	}
+}
+
+// recoveringSource appends the events of its pending changes when asked
+type recoveringSource struct {
+	repo    *repotest.OutboxRepository
+	mu      sync.Mutex
+	pending []string
+	before  time.Time
+}
+
+func (r *recoveringSource) RecoverEvents(ctx context.Context, before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.before = before
+	for _, user := range r.pending {
+		if err := r.repo.Append(ctx, &model.OutboxEvent{Type: events.TypeCouponClaimed, CouponName: "OUTBOX", UserID: user}); err != nil {
+			return 0, err
+		}
+	}
+	n := len(r.pending)
+	r.pending = nil
+	return n, nil
+}
+
+// TestRelayRecoversEvents checks the leader publishes the events recovered
+// for changes that were written without them
+func TestRelayRecoversEvents(t *testing.T) {
+	repo := repotest.NewOutboxRepository()
+	sink := &recordingSink{name: "bus"}
+	source := &recoveringSource{repo: repo, pending: []string{"u1", "u2"}}
+	relay := NewRelay(repo, []Sink{sink}, Options{PollInterval: 5 * time.Millisecond, RecoverAfter: time.Minute}, metrics.NewRegistry())
+	relay.AddRecoverer(source)
+
+	relay.Start()
+	waitFor(t, func() bool { return len(sink.received()) == 2 })
+	if err := relay.Stop(context.Background()); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+
+	source.mu.Lock()
+	defer source.mu.Unlock()
+	if age := time.Since(source.before); age < time.Minute || age > 2*time.Minute {
+		t.Errorf("recovered changes older than %v, want RecoverAfter", age)
+	}
+	if got := fmt.Sprint(sink.received()); got != "[u1 u2]" {
+		t.Errorf("deliveries = %s, want [u1 u2]", got)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,225 @@
+package overload
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrOverloaded is returned when a request cannot get a slot within its queue-time budget
+var ErrOverloaded = errors.New("server overloaded")
+
+// Options configures the adaptive concurrency limiter
+type Options struct {
+	InitialLimit int           // Concurrent requests allowed at start
+	MinLimit     int           // The limit never drops below this
+	MaxLimit     int           // The limit never grows above this
+	Tolerance    float64       // Latency above baseline*Tolerance counts as congestion...
+	LatencySlack time.Duration // ...if it is also more than this above the baseline (ignores jitter on fast requests)
+	QueueTimeout time.Duration // How long a request may wait for a slot before it is shed
+	MaxQueue     int           // Requests waiting beyond this are shed immediately
+	DegradedFor  time.Duration // Health reports degraded for this long after shedding
+	RetryAfter   time.Duration // Sent to shed clients in the Retry-After header
+}
+
+// Limiter is an adaptive concurrency limit (AIMD on observed latency)
+//
+// Every completed request is compared with a slowly moving latency baseline.
+// Fast requests grow the limit by one while at least half of it is in use;
+// slow or failed requests shrink it by 10%, at most once per baseline latency. Requests over the limit wait
+// in FIFO order for QueueTimeout and are then shed with ErrOverloaded, so a
+// slow database turns into fast rejections instead of a growing backlog.
+type Limiter struct {
+	opts Options
+
+	mu           sync.Mutex
+	limit        float64
+	inflight     int
+	waiters      []chan struct{}
+	baseline     time.Duration // Long-term EWMA of request latency
+	lastDecrease time.Time
+	lastShed     time.Time
+	shed         uint64
+}
+
+// Stats is a snapshot of the limiter state
+type Stats struct {
+	Limit    int           `json:"limit"`
+	Inflight int           `json:"inflight"`
+	Queued   int           `json:"queued"`
+	Baseline time.Duration `json:"-"`
+	Shed     uint64        `json:"shed"`
+	Degraded bool          `json:"degraded"`
+}
+
+// NewLimiter creates a new adaptive limiter
+func NewLimiter(opts Options) *Limiter {
+	if opts.MinLimit <= 0 {
+		opts.MinLimit = 4
+	}
+	if opts.MaxLimit <= 0 {
+		opts.MaxLimit = 1000
+	}
+	if opts.InitialLimit <= 0 {
+		opts.InitialLimit = 100
+	}
+	if opts.InitialLimit < opts.MinLimit {
+		opts.InitialLimit = opts.MinLimit
+	}
+	if opts.InitialLimit > opts.MaxLimit {
+		opts.InitialLimit = opts.MaxLimit
+	}
+	if opts.Tolerance <= 1 {
+		opts.Tolerance = 2
+	}
+	if opts.LatencySlack <= 0 {
+		opts.LatencySlack = 10 * time.Millisecond
+	}
+	if opts.QueueTimeout <= 0 {
+		opts.QueueTimeout = 50 * time.Millisecond
+	}
+	if opts.MaxQueue <= 0 {
+		opts.MaxQueue = opts.MaxLimit
+	}
+	if opts.DegradedFor <= 0 {
+		opts.DegradedFor = 10 * time.Second
+	}
+	if opts.RetryAfter <= 0 {
+		opts.RetryAfter = time.Second
+	}
+
+	return &Limiter{
+		opts:  opts,
+		limit: float64(opts.InitialLimit),
+	}
+}
+
+// Acquire waits for a slot, up to the queue-time budget or the context deadline
+// On success the returned function must be called when the request finishes,
+// with whether it failed in a way that suggests overload (timeouts, 5xx)
+func (l *Limiter) Acquire(ctx context.Context) (func(failed bool), error) {
+	l.mu.Lock()
+	if l.inflight < int(l.limit) && len(l.waiters) == 0 {
+		l.inflight++
+		l.mu.Unlock()
+		return l.releaser(time.Now()), nil
+	}
+	if len(l.waiters) >= l.opts.MaxQueue {
+		l.recordShed()
+		l.mu.Unlock()
+		return nil, ErrOverloaded
+	}
+
+	ready := make(chan struct{})
+	l.waiters = append(l.waiters, ready)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.opts.QueueTimeout)
+	defer timer.Stop()
+
+	var cause error = ErrOverloaded
+	select {
+	case <-ready:
+		return l.releaser(time.Now()), nil
+	case <-timer.C:
+	case <-ctx.Done():
+		cause = ctx.Err()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, w := range l.waiters {
+		if w == ready {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			if cause == ErrOverloaded {
+				l.recordShed()
+			}
+			return nil, cause
+		}
+	}
+
+	// Granted a slot just as the budget ran out
+	return l.releaser(time.Now()), nil
+}
+
+// Stats returns the current limiter state
+func (l *Limiter) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return Stats{
+		Limit:    int(l.limit),
+		Inflight: l.inflight,
+		Queued:   len(l.waiters),
+		Baseline: l.baseline,
+		Shed:     l.shed,
+		Degraded: !l.lastShed.IsZero() && time.Since(l.lastShed) < l.opts.DegradedFor,
+	}
+}
+
+// RetryAfter is how long shed clients are asked to wait before retrying
+func (l *Limiter) RetryAfter() time.Duration {
+	return l.opts.RetryAfter
+}
+
+// releaser returns the completion callback for a request that got a slot
+func (l *Limiter) releaser(start time.Time) func(failed bool) {
+	var once sync.Once
+	return func(failed bool) {
+		once.Do(func() {
+			l.release(time.Since(start), failed)
+		})
+	}
+}
+
+// release frees a slot, adapts the limit and hands the slot to the next waiter
+func (l *Limiter) release(latency time.Duration, failed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	used := l.inflight
+	l.inflight--
+
+	if l.baseline == 0 {
+		l.baseline = latency
+	}
+	congested := failed ||
+		(float64(latency) > float64(l.baseline)*l.opts.Tolerance && latency-l.baseline > l.opts.LatencySlack)
+
+	switch {
+	case congested:
+		// Multiplicative decrease, at most once per baseline latency so one
+		// slow burst does not collapse the limit
+		if now.Sub(l.lastDecrease) >= l.baseline {
+			l.limit = math.Max(float64(l.opts.MinLimit), l.limit*0.9)
+			l.lastDecrease = now
+		}
+	case float64(used) >= l.limit/2:
+		// Additive increase while the limit is actually being used
+		l.limit = math.Min(float64(l.opts.MaxLimit), l.limit+1)
+	}
+
+	// Congested samples move the baseline much more slowly, so overload is not
+	// quickly redefined as normal, yet a lasting latency shift is eventually accepted
+	weight := 0.05
+	if congested {
+		weight = 0.005
+	}
+	l.baseline = time.Duration((1-weight)*float64(l.baseline) + weight*float64(latency))
+
+	for len(l.waiters) > 0 && l.inflight < int(l.limit) {
+		next := l.waiters[0]
+		l.waiters = l.waiters[1:]
+		l.inflight++
+		close(next)
+	}
+}
+
+// recordShed counts a shed request; the caller holds l.mu
+func (l *Limiter) recordShed() {
+	l.shed++
+	l.lastShed = time.Now()
+}
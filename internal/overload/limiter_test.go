@@ -0,0 +1,108 @@
+package overload
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// complete runs n requests at once with the given simulated latency
+func complete(t *testing.T, l *Limiter, n int, latency time.Duration, failed bool) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if _, err := l.Acquire(context.Background()); err != nil {
+			t.Fatalf("Acquire %d: %v", i, err)
+		}
+	}
+	for i := 0; i < n; i++ {
+		l.release(latency, failed)
+	}
+}
+
+func TestLimiterIncreasesWhileUsed(t *testing.T) {
+	l := NewLimiter(Options{InitialLimit: 10, MaxLimit: 20})
+
+	// Requests well under the limit say nothing about capacity
+	for i := 0; i < 50; i++ {
+		complete(t, l, 2, time.Millisecond, false)
+	}
+	if got := l.Stats().Limit; got != 10 {
+		t.Fatalf("limit after light load = %d, want 10", got)
+	}
+
+	complete(t, l, 10, time.Millisecond, false)
+	if got := l.Stats().Limit; got <= 10 {
+		t.Errorf("limit after a fast full batch = %d, want above 10", got)
+	}
+	for i := 0; i < 20; i++ {
+		complete(t, l, l.Stats().Limit, time.Millisecond, false)
+	}
+	if got := l.Stats().Limit; got != 20 {
+		t.Errorf("limit = %d, want capped at 20", got)
+	}
+}
+
+func TestLimiterDecreasesUnderLatency(t *testing.T) {
+	l := NewLimiter(Options{InitialLimit: 100, MinLimit: 50, Tolerance: 2, LatencySlack: 5 * time.Millisecond})
+	complete(t, l, 10, 10*time.Millisecond, false)
+	limit := l.Stats().Limit
+
+	// One slow burst shrinks the limit once, not once per request
+	complete(t, l, 10, 200*time.Millisecond, false)
+	if got := l.Stats().Limit; got != int(float64(limit)*0.9) {
+		t.Fatalf("limit after a slow burst = %d, want %d", got, int(float64(limit)*0.9))
+	}
+
+	// Latency within the tolerance, or within the slack, is not congestion
+	limit = l.Stats().Limit
+	complete(t, l, 1, 14*time.Millisecond, false)
+	if got := l.Stats().Limit; got != limit {
+		t.Errorf("limit after a tolerable request = %d, want %d", got, limit)
+	}
+
+	// Sustained congestion stops at the floor
+	for i := 0; i < 50; i++ {
+		l.lastDecrease = time.Time{}
+		complete(t, l, 1, 0, true)
+	}
+	if got := l.Stats().Limit; got != 50 {
+		t.Errorf("limit under sustained failures = %d, want the minimum 50", got)
+	}
+}
+
+func TestLimiterShedsAfterQueueTimeout(t *testing.T) {
+	l := NewLimiter(Options{InitialLimit: 1, MinLimit: 1, MaxQueue: 1, QueueTimeout: 20 * time.Millisecond})
+	done, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One request may queue; the next is shed at once
+	queued := make(chan error)
+	go func() {
+		_, err := l.Acquire(context.Background())
+		queued <- err
+	}()
+	for l.Stats().Queued == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := l.Acquire(context.Background()); !errors.Is(err, ErrOverloaded) {
+		t.Errorf("Acquire beyond the queue = %v, want ErrOverloaded", err)
+	}
+	if err := <-queued; !errors.Is(err, ErrOverloaded) {
+		t.Errorf("queued Acquire = %v, want ErrOverloaded after the timeout", err)
+	}
+	if s := l.Stats(); s.Shed != 2 || !s.Degraded || s.Queued != 0 {
+		t.Errorf("stats = %+v, want 2 shed and degraded", s)
+	}
+
+	// A freed slot goes to the next waiter
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		done(false)
+	}()
+	if _, err := l.Acquire(context.Background()); err != nil {
+		t.Errorf("Acquire as a slot frees = %v", err)
+	}
+}
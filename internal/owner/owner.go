@@ -0,0 +1,18 @@
+// Package owner names server instances in leases
+package owner
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+)
+
+// NewID returns an ID for this instance: host, process ID and a random suffix,
+// so two managers in one process never share a lease
+func NewID() string {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
@@ -0,0 +1,330 @@
+package queue
+
+import (
+	"coupon-system/internal/model"
+	apperrors "coupon-system/pkg/errors"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options configures the admission queue
+type Options struct {
+	AdmitRate float64       // Users admitted per second, per coupon
+	AdmitTTL  time.Duration // How long an admitted token may be used to claim
+	IdleTTL   time.Duration // Waiting tickets that are not polled for this long are dropped
+	Tick      time.Duration // Admission interval
+	Instance  string        // Prefix of the tokens this instance issues (default: host name)
+}
+
+// Manager is an in-process virtual queue per coupon
+// Users join and receive a short-lived token; tokens are admitted in FIFO
+// order at a fixed rate, and only admitted tokens can be spent on a claim, so
+// the database sees at most AdmitRate claims per second per coupon per instance
+// Queues are not shared between instances: tokens start with the issuing
+// instance's ID, and the load balancer must route requests carrying a token to
+// that instance. Other instances refuse them with ErrQueueWrongInstance.
+type Manager struct {
+	opts Options
+
+	mu      sync.Mutex
+	queues  map[string]*couponQueue
+	tickets map[string]*ticket
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// couponQueue is the FIFO line for one coupon
+type couponQueue struct {
+	waiting   []*ticket
+	nextSeq   int64 // Sequence number for the next ticket
+	headSeq   int64 // Sequence number of the oldest ticket not yet admitted
+	allowance float64
+	byUser    map[string]*ticket
+}
+
+// ticket is the internal state behind a model.QueueTicket
+type ticket struct {
+	token      string
+	couponName string
+	userID     string
+	seq        int64
+	status     string
+	reserved   bool // A claim with the admitted token is in progress
+	lastSeen   time.Time
+	expiresAt  time.Time
+}
+
+// NewManager creates a new admission queue manager
+func NewManager(opts Options) *Manager {
+	if opts.AdmitRate <= 0 {
+		opts.AdmitRate = 50
+	}
+	if opts.AdmitTTL <= 0 {
+		opts.AdmitTTL = time.Minute
+	}
+	if opts.IdleTTL <= 0 {
+		opts.IdleTTL = 30 * time.Second
+	}
+	if opts.Tick <= 0 {
+		opts.Tick = 100 * time.Millisecond
+	}
+	if opts.Instance == "" {
+		opts.Instance, _ = os.Hostname()
+	}
+	// Dots separate the instance from the random part of a token
+	opts.Instance = strings.ReplaceAll(opts.Instance, ".", "-")
+
+	return &Manager{
+		opts:    opts,
+		queues:  make(map[string]*couponQueue),
+		tickets: make(map[string]*ticket),
+	}
+}
+
+// Start runs the admission loop
+func (m *Manager) Start() {
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(m.opts.Tick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case now := <-ticker.C:
+				m.admit(now)
+			}
+		}
+	}()
+}
+
+// Stop ends the admission loop
+func (m *Manager) Stop() {
+	if m.stop == nil {
+		return
+	}
+	close(m.stop)
+	<-m.done
+}
+
+// Join puts a user in line for a coupon and returns their ticket
+// Joining again while holding a live ticket returns the same ticket
+func (m *Manager) Join(couponName, userID string) (*model.QueueTicket, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	q := m.queues[couponName]
+	if q == nil {
+		q = &couponQueue{byUser: make(map[string]*ticket)}
+		m.queues[couponName] = q
+	}
+
+	if t := q.byUser[userID]; t != nil && m.live(t, now) {
+		t.lastSeen = now
+		return m.snapshot(q, t), nil
+	}
+
+	token, err := newToken(m.opts.Instance)
+	if err != nil {
+		return nil, err
+	}
+	t := &ticket{
+		token:      token,
+		couponName: couponName,
+		userID:     userID,
+		seq:        q.nextSeq,
+		status:     model.QueueStatusWaiting,
+		lastSeen:   now,
+	}
+	q.nextSeq++
+	q.waiting = append(q.waiting, t)
+	q.byUser[userID] = t
+	m.tickets[token] = t
+
+	return m.snapshot(q, t), nil
+}
+
+// Status returns a ticket's current position; polling keeps a waiting ticket alive
+func (m *Manager) Status(token string) (*model.QueueTicket, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := m.tickets[token]
+	if t == nil {
+		if m.foreign(token) {
+			return nil, apperrors.ErrQueueWrongInstance
+		}
+		return nil, apperrors.ErrQueueTicketNotFound
+	}
+
+	now := time.Now()
+	if m.live(t, now) {
+		t.lastSeen = now
+	} else if t.status != model.QueueStatusUsed {
+		t.status = model.QueueStatusExpired
+	}
+
+	return m.snapshot(m.queues[t.couponName], t), nil
+}
+
+// Reserve holds an admitted token for a claim in progress
+// The claim must end with Commit if it succeeded or Release if the token may
+// be used again. Returns ErrQueueTokenRequired for an empty token,
+// ErrQueueWrongInstance for a token issued by another instance and
+// ErrQueueTokenInvalid for a token that is unknown, not yet admitted, expired,
+// already used or held by another claim, or issued to another user or coupon
+func (m *Manager) Reserve(couponName, userID, token string) error {
+	if token == "" {
+		return apperrors.ErrQueueTokenRequired
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := m.tickets[token]
+	if t == nil && m.foreign(token) {
+		return apperrors.ErrQueueWrongInstance
+	}
+	if t == nil || t.couponName != couponName || t.userID != userID ||
+		t.status != model.QueueStatusAdmitted || !time.Now().Before(t.expiresAt) {
+		return apperrors.ErrQueueTokenInvalid
+	}
+	if t.reserved {
+		return apperrors.ErrQueueTokenInvalid.WithMessage("queue token is already being used by another claim")
+	}
+
+	t.reserved = true
+	return nil
+}
+
+// Commit marks a reserved token as spent
+func (m *Manager) Commit(token string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := m.tickets[token]
+	if t == nil || !t.reserved {
+		return
+	}
+	t.reserved = false
+	t.status = model.QueueStatusUsed
+	if q := m.queues[t.couponName]; q != nil && q.byUser[t.userID] == t {
+		delete(q.byUser, t.userID)
+	}
+}
+
+// Release makes a reserved token usable again until it expires
+func (m *Manager) Release(token string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if t := m.tickets[token]; t != nil {
+		t.reserved = false
+	}
+}
+
+// foreign reports whether a token was issued by another instance
+func (m *Manager) foreign(token string) bool {
+	i := strings.LastIndexByte(token, '.')
+	return i > 0 && token[:i] != m.opts.Instance
+}
+
+// admit lets the next users in each queue through and forgets dead tickets
+func (m *Manager) admit(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	perTick := m.opts.AdmitRate * m.opts.Tick.Seconds()
+	for _, q := range m.queues {
+		// Unused allowance does not pile up into a burst later
+		q.allowance += perTick
+		if limit := perTick + 1; q.allowance > limit {
+			q.allowance = limit
+		}
+
+		for q.allowance >= 1 && len(q.waiting) > 0 {
+			t := q.waiting[0]
+			q.waiting[0] = nil
+			q.waiting = q.waiting[1:]
+			q.headSeq = t.seq + 1
+
+			// Abandoned tickets do not use up admission capacity
+			if now.Sub(t.lastSeen) > m.opts.IdleTTL {
+				t.status = model.QueueStatusExpired
+				continue
+			}
+
+			t.status = model.QueueStatusAdmitted
+			t.expiresAt = now.Add(m.opts.AdmitTTL)
+			q.allowance--
+		}
+	}
+
+	// Keep finished tickets around for a while so clients can read their final status
+	for token, t := range m.tickets {
+		if t.status == model.QueueStatusWaiting || t.reserved {
+			continue
+		}
+		if now.Sub(t.lastSeen) > m.opts.IdleTTL && (t.expiresAt.IsZero() || now.After(t.expiresAt)) {
+			delete(m.tickets, token)
+			if q := m.queues[t.couponName]; q != nil && q.byUser[t.userID] == t {
+				delete(q.byUser, t.userID)
+			}
+		}
+	}
+}
+
+// live reports whether a ticket can still be used or waited on
+func (m *Manager) live(t *ticket, now time.Time) bool {
+	switch t.status {
+	case model.QueueStatusWaiting:
+		return now.Sub(t.lastSeen) <= m.opts.IdleTTL
+	case model.QueueStatusAdmitted:
+		return now.Before(t.expiresAt)
+	}
+	return false
+}
+
+// snapshot converts a ticket into its API representation
+func (m *Manager) snapshot(q *couponQueue, t *ticket) *model.QueueTicket {
+	qt := &model.QueueTicket{
+		Token:      t.token,
+		CouponName: t.couponName,
+		UserID:     t.userID,
+		Status:     t.status,
+		ExpiresAt:  t.expiresAt,
+	}
+	if t.status == model.QueueStatusWaiting {
+		qt.Position = t.seq - q.headSeq + 1
+		qt.ExpiresAt = t.lastSeen.Add(m.opts.IdleTTL)
+
+		// Poll roughly when the user could be admitted, but at least every IdleTTL/3
+		wait := time.Duration(float64(qt.Position) / m.opts.AdmitRate * float64(time.Second))
+		if max := m.opts.IdleTTL / 3; wait > max {
+			wait = max
+		}
+		if wait < m.opts.Tick {
+			wait = m.opts.Tick
+		}
+		qt.PollAfterMs = wait.Milliseconds()
+	}
+	return qt
+}
+
+// newToken returns a random, unguessable queue token prefixed with the instance ID
+func newToken(instance string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return instance + "." + hex.EncodeToString(b), nil
+}
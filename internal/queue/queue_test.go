@@ -0,0 +1,75 @@
+package queue
+
+import (
+	"coupon-system/internal/model"
+	apperrors "coupon-system/pkg/errors"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// admitted joins a user and admits their ticket
+func admitted(t *testing.T, m *Manager, couponName, userID string) string {
+	t.Helper()
+	ticket, err := m.Join(couponName, userID)
+	if err != nil {
+		t.Fatalf("Join: %v", err)
+	}
+	m.admit(time.Now())
+	if status, _ := m.Status(ticket.Token); status.Status != model.QueueStatusAdmitted {
+		t.Fatalf("ticket status = %s, want admitted", status.Status)
+	}
+	return ticket.Token
+}
+
+func TestReserveCommitRelease(t *testing.T) {
+	m := NewManager(Options{AdmitRate: 100, Tick: time.Second, Instance: "node-a"})
+	token := admitted(t, m, "FLASH", "u1")
+
+	if err := m.Reserve("FLASH", "u2", token); !errors.Is(err, apperrors.ErrQueueTokenInvalid) {
+		t.Errorf("Reserve by another user = %v, want ErrQueueTokenInvalid", err)
+	}
+	if err := m.Reserve("FLASH", "u1", token); err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	if err := m.Reserve("FLASH", "u1", token); !errors.Is(err, apperrors.ErrQueueTokenInvalid) {
+		t.Errorf("concurrent Reserve = %v, want ErrQueueTokenInvalid", err)
+	}
+
+	// A failed claim gives the token back
+	m.Release(token)
+	if err := m.Reserve("FLASH", "u1", token); err != nil {
+		t.Fatalf("Reserve after Release: %v", err)
+	}
+
+	m.Commit(token)
+	if err := m.Reserve("FLASH", "u1", token); !errors.Is(err, apperrors.ErrQueueTokenInvalid) {
+		t.Errorf("Reserve after Commit = %v, want ErrQueueTokenInvalid", err)
+	}
+	if status, _ := m.Status(token); status.Status != model.QueueStatusUsed {
+		t.Errorf("status after Commit = %s, want used", status.Status)
+	}
+	if err := m.Reserve("FLASH", "u1", ""); !errors.Is(err, apperrors.ErrQueueTokenRequired) {
+		t.Errorf("Reserve without token = %v, want ErrQueueTokenRequired", err)
+	}
+}
+
+func TestTokensAreBoundToInstance(t *testing.T) {
+	a := NewManager(Options{AdmitRate: 100, Tick: time.Second, Instance: "node-a.example"})
+	b := NewManager(Options{AdmitRate: 100, Tick: time.Second, Instance: "node-b"})
+	token := admitted(t, a, "FLASH", "u1")
+
+	if !strings.HasPrefix(token, "node-a-example.") {
+		t.Errorf("token %q does not name its instance", token)
+	}
+	if _, err := b.Status(token); !errors.Is(err, apperrors.ErrQueueWrongInstance) {
+		t.Errorf("Status on another instance = %v, want ErrQueueWrongInstance", err)
+	}
+	if err := b.Reserve("FLASH", "u1", token); !errors.Is(err, apperrors.ErrQueueWrongInstance) {
+		t.Errorf("Reserve on another instance = %v, want ErrQueueWrongInstance", err)
+	}
+	if _, err := a.Status("node-a-example.unknown"); !errors.Is(err, apperrors.ErrQueueTicketNotFound) {
+		t.Errorf("Status of unknown token = %v, want ErrQueueTicketNotFound", err)
+	}
+}
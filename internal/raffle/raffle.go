@@ -0,0 +1,79 @@
+package raffle
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// Algorithm describes how winners are selected, stored with every draw so it
+// can be re-run independently: each entrant is scored with
+// SHA-256(seed bytes || user_id), entrants are sorted by score (ties broken by
+// user_id) and the lowest scores win
+const Algorithm = "sha256(seed||user_id) ascending"
+
+// NewSeed returns a random 32-byte seed, hex encoded
+func NewSeed() (string, error) {
+	seed := make([]byte, 32)
+	if _, err := rand.Read(seed); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(seed), nil
+}
+
+// Select deterministically picks up to n winners from the entrants using seed
+// The result does not depend on the order of entrants
+func Select(seed string, entrants []string, n int) ([]string, error) {
+	seedBytes, err := hex.DecodeString(seed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid seed: %w", err)
+	}
+
+	type scored struct {
+		userID string
+		score  []byte
+	}
+	candidates := make([]scored, 0, len(entrants))
+	for _, userID := range entrants {
+		h := sha256.New()
+		h.Write(seedBytes)
+		h.Write([]byte(userID))
+		candidates = append(candidates, scored{userID: userID, score: h.Sum(nil)})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if c := bytes.Compare(candidates[i].score, candidates[j].score); c != 0 {
+			return c < 0
+		}
+		return candidates[i].userID < candidates[j].userID
+	})
+
+	if n > len(candidates) {
+		n = len(candidates)
+	}
+	if n < 0 {
+		n = 0
+	}
+	winners := make([]string, 0, n)
+	for _, c := range candidates[:n] {
+		winners = append(winners, c.userID)
+	}
+	return winners, nil
+}
+
+// EntriesDigest commits to the exact set of entrants of a draw
+// It is the hex SHA-256 of the sorted user IDs joined by newlines
+func EntriesDigest(entrants []string) string {
+	sorted := append([]string(nil), entrants...)
+	sort.Strings(sorted)
+
+	h := sha256.New()
+	for _, userID := range sorted {
+		h.Write([]byte(userID))
+		h.Write([]byte("\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,115 @@
+package raffle
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+const testSeed = "00112233445566778899aabbccddeeff00112233445566778899aabbccddeeff"
+
+func entrants(n int) []string {
+	users := make([]string, n)
+	for i := range users {
+		users[i] = fmt.Sprintf("user_%d", i)
+	}
+	return users
+}
+
+func reversed(users []string) []string {
+	r := make([]string, len(users))
+	for i, u := range users {
+		r[len(users)-1-i] = u
+	}
+	return r
+}
+
+func TestSelect(t *testing.T) {
+	tests := []struct {
+		name     string
+		entrants []string
+		n        int
+		want     int
+	}{
+		{"fewer winners than entrants", entrants(100), 10, 10},
+		{"stock covers everyone", entrants(5), 10, 5},
+		{"exactly enough stock", entrants(7), 7, 7},
+		{"no stock", entrants(5), 0, 0},
+		{"negative stock", entrants(5), -1, 0},
+		{"no entrants", nil, 3, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			winners, err := Select(testSeed, tt.entrants, tt.n)
+			if err != nil {
+				t.Fatalf("Select: %v", err)
+			}
+			if len(winners) != tt.want {
+				t.Fatalf("%d winners, want %d", len(winners), tt.want)
+			}
+
+			entered := make(map[string]bool)
+			for _, u := range tt.entrants {
+				entered[u] = true
+			}
+			seen := make(map[string]bool)
+			for _, w := range winners {
+				if !entered[w] || seen[w] {
+					t.Errorf("winner %q is not an entrant or won twice", w)
+				}
+				seen[w] = true
+			}
+
+			// The draw depends only on the seed and the set of entrants
+			again, _ := Select(testSeed, reversed(tt.entrants), tt.n)
+			if !reflect.DeepEqual(winners, again) {
+				t.Errorf("winners depend on entrant order: %v vs %v", winners, again)
+			}
+		})
+	}
+}
+
+func TestSelectSeed(t *testing.T) {
+	users := entrants(50)
+	first, _ := Select(testSeed, users, 5)
+	other, _ := Select("ff"+testSeed[2:], users, 5)
+	if reflect.DeepEqual(first, other) {
+		t.Errorf("different seeds picked the same winners %v", first)
+	}
+
+	// More stock only adds winners; it never changes who won already
+	more, _ := Select(testSeed, users, 10)
+	if !reflect.DeepEqual(first, more[:5]) {
+		t.Errorf("winners of 5 %v are not the first of 10 %v", first, more)
+	}
+
+	if _, err := Select("not-hex", users, 1); err == nil {
+		t.Error("Select accepted a seed that is not hex")
+	}
+}
+
+func TestEntriesDigest(t *testing.T) {
+	base := []string{"alice", "bob", "carol"}
+	digest := EntriesDigest(base)
+
+	tests := []struct {
+		name     string
+		entrants []string
+		same     bool
+	}{
+		{"same entrants", []string{"alice", "bob", "carol"}, true},
+		{"different order", []string{"carol", "alice", "bob"}, true},
+		{"entry changed", []string{"alice", "bob", "carol2"}, false},
+		{"entry added", []string{"alice", "bob", "carol", "dave"}, false},
+		{"entry removed", []string{"alice", "bob"}, false},
+		{"entries merged", []string{"alicebob", "carol"}, false},
+		{"no entrants", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EntriesDigest(tt.entrants); (got == digest) != tt.same {
+				t.Errorf("digest %s vs %s, want same = %v", got, digest, tt.same)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package ratelimit
+
+import (
+	"context"
+	"coupon-system/internal/cache"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit is a token bucket: Rate tokens are added per second up to Burst
+// A zero Limit is disabled and allows everything
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Enabled reports whether the limit restricts anything
+func (l Limit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+// String renders the limit in the form accepted by ParseLimit
+func (l Limit) String() string {
+	if !l.Enabled() {
+		return "off"
+	}
+	return fmt.Sprintf("%g/s:%d", l.Rate, l.Burst)
+}
+
+// ParseLimit parses "N/unit" or "N/unit:burst", e.g. "10/s", "600/m:50"
+// Units are s, m and h; the burst defaults to N. "off", "0" and "" disable the limit
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "off" || s == "0" {
+		return Limit{}, nil
+	}
+
+	spec, burstSpec, hasBurst := strings.Cut(s, ":")
+	countSpec, unitSpec, ok := strings.Cut(spec, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: want N/unit[:burst]", s)
+	}
+
+	count, err := strconv.Atoi(countSpec)
+	if err != nil || count <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: count must be a positive integer", s)
+	}
+
+	var per time.Duration
+	switch unitSpec {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		return Limit{}, fmt.Errorf("invalid rate limit %q: unit must be s, m or h", s)
+	}
+
+	burst := count
+	if hasBurst {
+		if burst, err = strconv.Atoi(burstSpec); err != nil || burst <= 0 {
+			return Limit{}, fmt.Errorf("invalid rate limit %q: burst must be a positive integer", s)
+		}
+	}
+
+	return Limit{Rate: float64(count) / per.Seconds(), Burst: burst}, nil
+}
+
+// Decision is the outcome of taking a token
+type Decision struct {
+	Allowed    bool
+	Remaining  int           // Tokens left after this request
+	RetryAfter time.Duration // When a token will be available again (if not allowed)
+}
+
+// Store keeps limiter state
+type Store interface {
+	// Take consumes one token for a key
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Decision, error)
+}
+
+// MemoryStore keeps exact token buckets in process memory
+// Buckets idle long enough to be full again are dropped
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	takes   int
+}
+
+// bucket is the state of one token bucket
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+// NewMemoryStore creates an in-process limiter store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket)}
+}
+
+// Take consumes one token for a key
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit, now time.Time) (Decision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.takes++
+	if s.takes%1024 == 0 {
+		s.sweep(now)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now, limit: limit}
+		s.buckets[key] = b
+	}
+
+	// Refill for the time since the last request
+	elapsed := now.Sub(b.updated).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed*limit.Rate)
+		b.updated = now
+	}
+	b.limit = limit
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return Decision{Allowed: true, Remaining: int(b.tokens)}, nil
+	}
+
+	wait := time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+	return Decision{Allowed: false, RetryAfter: wait}, nil
+}
+
+// Len returns the number of tracked buckets
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.buckets)
+}
+
+// sweep drops buckets that have refilled completely; the caller holds s.mu
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		refill := time.Duration((float64(b.limit.Burst) - b.tokens) / b.limit.Rate * float64(time.Second))
+		if now.Sub(b.updated) >= refill {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// CacheStore shares limiter state through a cache.Cache so every instance
+// enforces the same budget
+//
+// Shared caches only offer atomic counters, so the bucket is approximated by
+// fixed windows: each window of Burst/Rate allows Burst requests, which gives
+// the same long-run rate as the token bucket. Unlike the bucket, a client can
+// spend a full burst at the end of one window and another at the start of the
+// next, so up to 2*Burst requests get through around a window boundary.
+type CacheStore struct {
+	cache cache.Cache
+}
+
+// NewCacheStore creates a limiter store on top of a shared cache
+func NewCacheStore(c cache.Cache) *CacheStore {
+	return &CacheStore{cache: c}
+}
+
+// Take consumes one token for a key
+func (s *CacheStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Decision, error) {
+	window := time.Duration(float64(limit.Burst) / limit.Rate * float64(time.Second))
+	if window <= 0 {
+		window = time.Millisecond
+	}
+	start := now.Truncate(window)
+	end := start.Add(window)
+
+	count, err := s.cache.Increment(ctx, fmt.Sprintf("ratelimit:%s:%d", key, start.UnixNano()), 1, end.Sub(now))
+	if err != nil {
+		return Decision{}, err
+	}
+
+	if count > int64(limit.Burst) {
+		return Decision{Allowed: false, RetryAfter: end.Sub(now)}, nil
+	}
+	return Decision{Allowed: true, Remaining: limit.Burst - int(count)}, nil
+}
@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"context"
+	"coupon-system/internal/cache"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Limit
+		err  bool
+	}{
+		{in: "10/s", want: Limit{Rate: 10, Burst: 10}},
+		{in: "600/m:50", want: Limit{Rate: 10, Burst: 50}},
+		{in: "3600/h", want: Limit{Rate: 1, Burst: 3600}},
+		{in: "off", want: Limit{}},
+		{in: "", want: Limit{}},
+		{in: "10", err: true},
+		{in: "10/d", err: true},
+		{in: "-1/s", err: true},
+		{in: "10/s:0", err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseLimit(%q) error = %v, want error %v", tt.in, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseLimit(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestStores checks burst, rejection with Retry-After and refill for every store
+func TestStores(t *testing.T) {
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"cache":  NewCacheStore(cache.NewMemory()),
+	}
+	limit := Limit{Rate: 2, Burst: 3}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			// Start on a window boundary so the fixed-window store behaves like the bucket
+			now := time.Now().Truncate(1500 * time.Millisecond).Add(1500 * time.Millisecond)
+
+			for i := 0; i < limit.Burst; i++ {
+				d, err := store.Take(ctx, "user", limit, now)
+				if err != nil || !d.Allowed {
+					t.Fatalf("request %d within burst rejected: %+v, %v", i+1, d, err)
+				}
+			}
+
+			d, err := store.Take(ctx, "user", limit, now)
+			if err != nil || d.Allowed {
+				t.Fatalf("request beyond burst allowed: %+v, %v", d, err)
+			}
+			if d.RetryAfter <= 0 || d.RetryAfter > 1500*time.Millisecond {
+				t.Fatalf("RetryAfter = %v, want within the refill period", d.RetryAfter)
+			}
+
+			// Other keys have their own budget
+			if d, _ := store.Take(ctx, "other", limit, now); !d.Allowed {
+				t.Fatal("independent key was rejected")
+			}
+
+			// Allowed again once RetryAfter has passed
+			if d, _ := store.Take(ctx, "user", limit, now.Add(d.RetryAfter)); !d.Allowed {
+				t.Fatal("request after RetryAfter rejected")
+			}
+		})
+	}
+}
+
+// TestCacheStoreWindowBoundary shows the fixed-window store letting two bursts
+// through around a window boundary, where the token bucket allows one
+func TestCacheStoreWindowBoundary(t *testing.T) {
+	ctx := context.Background()
+	limit := Limit{Rate: 2, Burst: 3}
+	boundary := time.Now().Truncate(1500 * time.Millisecond).Add(1500 * time.Millisecond)
+
+	allowed := func(store Store) int {
+		n := 0
+		for _, at := range []time.Time{boundary.Add(-time.Millisecond), boundary} {
+			for i := 0; i < limit.Burst; i++ {
+				if d, err := store.Take(ctx, "user", limit, at); err == nil && d.Allowed {
+					n++
+				}
+			}
+		}
+		return n
+	}
+
+	if got := allowed(NewMemoryStore()); got != limit.Burst {
+		t.Errorf("memory store allowed %d around the boundary, want %d", got, limit.Burst)
+	}
+	if got := allowed(NewCacheStore(cache.NewMemory())); got != 2*limit.Burst {
+		t.Errorf("cache store allowed %d around the boundary, want %d", got, 2*limit.Burst)
+	}
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"coupon-system/internal/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKeyRepository defines the interface for API keys and their audit log
+type APIKeyRepository interface {
+	// CreateKey stores a new key
+	CreateKey(ctx context.Context, key *model.APIKey) error
+
+	// GetKey retrieves a key by its ID
+	// Returns ErrAPIKeyNotFound if it does not exist
+	GetKey(ctx context.Context, id string) (*model.APIKey, error)
+
+	// GetKeyByHash retrieves a key by the hash of its secret
+	// Returns ErrAPIKeyNotFound if it does not exist
+	GetKeyByHash(ctx context.Context, hash string) (*model.APIKey, error)
+
+	// ListKeys returns every key, including revoked ones, oldest first
+	ListKeys(ctx context.Context) ([]*model.APIKey, error)
+
+	// RevokeKey marks a key revoked at the given time and returns it
+	// A key that is already revoked keeps its original revocation time.
+	// Returns ErrAPIKeyNotFound if it does not exist
+	RevokeKey(ctx context.Context, id string, at time.Time) (*model.APIKey, error)
+
+	// ReplaceKey stores a key's successor and makes the old key expire at the given time
+	// Returns ErrAPIKeyNotFound if the old key does not exist and
+	// ErrAPIKeyRevoked if it was revoked
+	ReplaceKey(ctx context.Context, oldID string, expiresAt time.Time, successor *model.APIKey) error
+
+	// TouchKeys sets the last use time of keys
+	TouchKeys(ctx context.Context, ids []primitive.ObjectID, at time.Time) error
+
+	// RecordUsage appends entries to the audit log
+	RecordUsage(ctx context.Context, entries []*model.APIKeyAuditEntry) error
+
+	// ListUsage returns audit entries, newest first, optionally only those of one key
+	ListUsage(ctx context.Context, keyID string, limit int) ([]*model.APIKeyAuditEntry, error)
+}
@@ -0,0 +1,269 @@
+package repository
+
+import (
+	"context"
+	"coupon-system/internal/metrics"
+	"coupon-system/internal/model"
+	apperrors "coupon-system/pkg/errors"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// CacheOptions configures the coupon lookup cache
+type CacheOptions struct {
+	TTL         time.Duration // How long a coupon is served from memory
+	NegativeTTL time.Duration // How long an unknown name is remembered
+	MaxEntries  int           // Entries beyond this are not cached
+}
+
+// cachedCouponRepository is a read-through cache for GetCouponByName
+//
+// Concurrent misses for the same name are coalesced into one query, and
+// unknown names are cached briefly so random names cannot all reach MongoDB.
+// Pausing, resuming and creating coupons through this repository invalidate
+// the entry; stock changes adjust the cached remaining amount in place, so
+// the hot claim path keeps hitting the cache. Changes made by other instances
+// are visible after at most TTL. Stock is always enforced by MongoDB itself.
+type cachedCouponRepository struct {
+	CouponRepository
+
+	opts  CacheOptions
+	group singleflight.Group
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+	names   map[interface{}]string // Coupon ID -> name of a cached entry, for updates addressed by ID
+	gen     uint64                 // Bumped by invalidations so in-flight loads do not store stale values
+
+	hits          *metrics.Counter
+	misses        *metrics.Counter
+	negativeHits  *metrics.Counter
+	coalesced     *metrics.Counter
+	invalidations *metrics.Counter
+}
+
+// cacheEntry is a cached lookup; coupon is nil for a negative entry
+type cacheEntry struct {
+	coupon    *model.Coupon
+	expiresAt time.Time
+}
+
+// NewCachedCouponRepository wraps a coupon repository with a lookup cache
+// A zero TTL disables caching and returns the repository unchanged
+func NewCachedCouponRepository(inner CouponRepository, opts CacheOptions, registry *metrics.Registry) CouponRepository {
+	if opts.TTL <= 0 {
+		return inner
+	}
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = 10000
+	}
+
+	r := &cachedCouponRepository{
+		CouponRepository: inner,
+		opts:             opts,
+		entries:          make(map[string]*cacheEntry),
+		names:            make(map[interface{}]string),
+		hits:             registry.Counter("coupon_cache_requests_total", "Coupon lookups by cache result", "result", "hit"),
+		misses:           registry.Counter("coupon_cache_requests_total", "Coupon lookups by cache result", "result", "miss"),
+		negativeHits:     registry.Counter("coupon_cache_requests_total", "Coupon lookups by cache result", "result", "negative_hit"),
+		coalesced:        registry.Counter("coupon_cache_coalesced_total", "Cache misses served by another in-flight lookup"),
+		invalidations:    registry.Counter("coupon_cache_invalidations_total", "Cache entries dropped by admin updates"),
+	}
+	registry.GaugeFunc("coupon_cache_entries", "Coupon lookups currently cached", func() float64 {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return float64(len(r.entries))
+	})
+
+	return r
+}
+
+// GetCouponByName serves a coupon from the cache, loading it on a miss
+func (r *cachedCouponRepository) GetCouponByName(ctx context.Context, name string) (*model.Coupon, error) {
+	now := time.Now()
+
+	r.mu.Lock()
+	entry, ok := r.entries[name]
+	if ok && now.Before(entry.expiresAt) {
+		r.mu.Unlock()
+		if entry.coupon == nil {
+			r.negativeHits.Inc()
+			return nil, apperrors.ErrCouponNotFound
+		}
+		r.hits.Inc()
+		return copyCoupon(entry.coupon), nil
+	}
+	gen := r.gen
+	r.mu.Unlock()
+	r.misses.Inc()
+
+	// The load must not fail for every waiter because the first caller went away
+	loadCtx := context.WithoutCancel(ctx)
+	value, err, shared := r.group.Do(name, func() (interface{}, error) {
+		coupon, err := r.CouponRepository.GetCouponByName(loadCtx, name)
+		switch {
+		case err == nil:
+			r.store(name, coupon, r.opts.TTL, gen)
+		case errors.Is(err, apperrors.ErrCouponNotFound) && r.opts.NegativeTTL > 0:
+			r.store(name, nil, r.opts.NegativeTTL, gen)
+		}
+		return coupon, err
+	})
+	if shared {
+		r.coalesced.Inc()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return copyCoupon(value.(*model.Coupon)), nil
+}
+
+// CreateCoupon creates a coupon and forgets a cached "not found" for its name
+func (r *cachedCouponRepository) CreateCoupon(ctx context.Context, coupon *model.Coupon) error {
+	err := r.CouponRepository.CreateCoupon(ctx, coupon)
+	r.invalidate(coupon.Name)
+	return err
+}
+
+// SetActive pauses or resumes a coupon and drops its cache entry
+func (r *cachedCouponRepository) SetActive(ctx context.Context, couponID interface{}, active bool) error {
+	err := r.CouponRepository.SetActive(ctx, couponID, active)
+	r.invalidateID(couponID)
+	return err
+}
+
+// DecrementStock decrements stock and keeps the cached remaining amount in step
+func (r *cachedCouponRepository) DecrementStock(ctx context.Context, couponID interface{}, amount int32) error {
+	if err := r.CouponRepository.DecrementStock(ctx, couponID, amount); err != nil {
+		if errors.Is(err, apperrors.ErrNoStock) {
+			r.adjustStock(couponID, 0, true)
+		}
+		return err
+	}
+	r.adjustStock(couponID, -amount, false)
+	return nil
+}
+
+// ReserveStock reserves stock and keeps the cached remaining amount in step
+func (r *cachedCouponRepository) ReserveStock(ctx context.Context, couponID interface{}, max int32) (int32, error) {
+	reserved, err := r.CouponRepository.ReserveStock(ctx, couponID, max)
+	if err != nil {
+		return reserved, err
+	}
+	r.adjustStock(couponID, -reserved, reserved < max)
+	return reserved, nil
+}
+
+// IncrementStock adds stock and drops the cached coupon
+// Adding stock bumps updated_at, which tells the next sell-out apart from the
+// last one, so the cached copy is not kept in step like on decrements.
+func (r *cachedCouponRepository) IncrementStock(ctx context.Context, couponID interface{}, amount int32) error {
+	err := r.CouponRepository.IncrementStock(ctx, couponID, amount)
+	r.invalidateID(couponID)
+	return err
+}
+
+// store caches a lookup result, unless the cache is full or it was invalidated while loading
+func (r *cachedCouponRepository) store(name string, coupon *model.Coupon, ttl time.Duration, gen uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if gen != r.gen {
+		return
+	}
+
+	now := time.Now()
+	if _, exists := r.entries[name]; !exists && len(r.entries) >= r.opts.MaxEntries {
+		r.evictExpired(now)
+		if len(r.entries) >= r.opts.MaxEntries {
+			return
+		}
+	}
+
+	// The name may now belong to another coupon, or to none
+	r.remove(name)
+	r.entries[name] = &cacheEntry{coupon: copyCoupon(coupon), expiresAt: now.Add(ttl)}
+	if coupon != nil {
+		r.names[coupon.ID] = name
+	}
+}
+
+// remove drops the entry for a name and its ID mapping, so names never
+// outgrows entries; the caller holds r.mu
+func (r *cachedCouponRepository) remove(name string) bool {
+	entry, ok := r.entries[name]
+	if !ok {
+		return false
+	}
+	delete(r.entries, name)
+	if entry.coupon != nil && r.names[entry.coupon.ID] == name {
+		delete(r.names, entry.coupon.ID)
+	}
+	return true
+}
+
+// evictExpired drops expired entries; the caller holds r.mu
+func (r *cachedCouponRepository) evictExpired(now time.Time) {
+	for name, entry := range r.entries {
+		if !now.Before(entry.expiresAt) {
+			r.remove(name)
+		}
+	}
+}
+
+// adjustStock applies a stock change to the cached coupon, if any
+// soldOut means MongoDB reported the stock as exhausted
+func (r *cachedCouponRepository) adjustStock(couponID interface{}, delta int32, soldOut bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.entries[r.names[couponID]]
+	if !ok || entry.coupon == nil {
+		return
+	}
+
+	remaining := entry.coupon.RemainingAmount + delta
+	if soldOut || remaining < 0 {
+		remaining = 0
+	}
+	updated := copyCoupon(entry.coupon)
+	updated.RemainingAmount = remaining
+	entry.coupon = updated
+}
+
+// invalidate drops the entry for a name
+func (r *cachedCouponRepository) invalidate(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.gen++
+	if r.remove(name) {
+		r.invalidations.Inc()
+	}
+}
+
+// invalidateID drops the entry for a coupon ID
+// A load in flight is not indexed by ID yet, so the generation is bumped
+// even when nothing is cached
+func (r *cachedCouponRepository) invalidateID(couponID interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.gen++
+	if name, ok := r.names[couponID]; ok && r.remove(name) {
+		r.invalidations.Inc()
+	}
+}
+
+// copyCoupon returns a shallow copy so callers cannot modify cached values
+func copyCoupon(coupon *model.Coupon) *model.Coupon {
+	if coupon == nil {
+		return nil
+	}
+	c := *coupon
+	return &c
+}
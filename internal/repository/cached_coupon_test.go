@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"coupon-system/internal/metrics"
+	"coupon-system/internal/model"
+	"coupon-system/internal/repository/repotest"
+	apperrors "coupon-system/pkg/errors"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// countingCouponRepository counts lookups and can hold them until released
+type countingCouponRepository struct {
+	*repotest.CouponRepository
+	loads   atomic.Int32
+	started chan struct{}
+	release chan struct{}
+}
+
+func (r *countingCouponRepository) GetCouponByName(ctx context.Context, name string) (*model.Coupon, error) {
+	r.loads.Add(1)
+	coupon, err := r.CouponRepository.GetCouponByName(ctx, name)
+	if r.release != nil {
+		r.started <- struct{}{}
+		<-r.release
+	}
+	return coupon, err
+}
+
+func newCachedTest(t *testing.T, opts CacheOptions) (*cachedCouponRepository, *countingCouponRepository) {
+	t.Helper()
+	inner := &countingCouponRepository{CouponRepository: repotest.NewCouponRepository()}
+	return NewCachedCouponRepository(inner, opts, metrics.NewRegistry()).(*cachedCouponRepository), inner
+}
+
+func TestCacheInvalidationRacingLoad(t *testing.T) {
+	ctx := context.Background()
+	cached, inner := newCachedTest(t, CacheOptions{TTL: time.Minute})
+	coupon := &model.Coupon{Name: "RACE", Amount: 10, RemainingAmount: 10, IsActive: true}
+	if err := cached.CreateCoupon(ctx, coupon); err != nil {
+		t.Fatal(err)
+	}
+
+	// A lookup reads the active coupon, then stalls before storing it
+	inner.started, inner.release = make(chan struct{}, 4), make(chan struct{})
+	loaded := make(chan *model.Coupon)
+	go func() {
+		c, _ := cached.GetCouponByName(ctx, "RACE")
+		loaded <- c
+	}()
+	<-inner.started
+
+	// The pause lands while the stale value is in flight
+	if err := cached.SetActive(ctx, coupon.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	close(inner.release)
+	if c := <-loaded; !c.IsActive {
+		t.Fatalf("in-flight lookup = inactive, want the value it read")
+	}
+
+	got, err := cached.GetCouponByName(ctx, "RACE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.IsActive {
+		t.Error("lookup after the pause served the stale active coupon")
+	}
+	if n := inner.loads.Load(); n != 2 {
+		t.Errorf("%d loads, want the stale load not cached and one reload", n)
+	}
+}
+
+func TestCacheNegativeEntryExpires(t *testing.T) {
+	ctx := context.Background()
+	cached, inner := newCachedTest(t, CacheOptions{TTL: time.Minute, NegativeTTL: 50 * time.Millisecond})
+
+	for i := 0; i < 3; i++ {
+		if _, err := cached.GetCouponByName(ctx, "LATER"); !errors.Is(err, apperrors.ErrCouponNotFound) {
+			t.Fatalf("lookup = %v, want not found", err)
+		}
+	}
+	if n := inner.loads.Load(); n != 1 {
+		t.Errorf("%d loads for repeated unknown lookups, want 1", n)
+	}
+
+	// Created by another instance, so this cache is not invalidated
+	if err := inner.CreateCoupon(ctx, &model.Coupon{Name: "LATER", Amount: 1, RemainingAmount: 1, IsActive: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cached.GetCouponByName(ctx, "LATER"); !errors.Is(err, apperrors.ErrCouponNotFound) {
+		t.Errorf("lookup within the negative TTL = %v, want the cached not found", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, err := cached.GetCouponByName(ctx, "LATER"); err != nil {
+		t.Errorf("lookup after the negative TTL = %v, want the coupon", err)
+	}
+}
+
+// recreatingCouponRepository returns the coupon under a new ID on every
+// lookup, as when a coupon is deleted and created again
+type recreatingCouponRepository struct {
+	CouponRepository
+}
+
+func (recreatingCouponRepository) GetCouponByName(_ context.Context, name string) (*model.Coupon, error) {
+	return &model.Coupon{ID: primitive.NewObjectID(), Name: name}, nil
+}
+
+func TestCacheIDIndexIsBounded(t *testing.T) {
+	ctx := context.Background()
+	cached := NewCachedCouponRepository(recreatingCouponRepository{}, CacheOptions{TTL: time.Nanosecond, MaxEntries: 4}, metrics.NewRegistry()).(*cachedCouponRepository)
+
+	for i := 0; i < 100; i++ {
+		for _, name := range []string{"A", "B", "C", "D", "E", "F"} {
+			if _, err := cached.GetCouponByName(ctx, name); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	cached.mu.Lock()
+	defer cached.mu.Unlock()
+	if len(cached.entries) > 4 || len(cached.names) > len(cached.entries) {
+		t.Errorf("%d entries and %d IDs, want at most 4 of each", len(cached.entries), len(cached.names))
+	}
+}
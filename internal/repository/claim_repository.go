@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"coupon-system/internal/model"
+	"time"
 )
 
 // ClaimRepository defines the interface for claim data operations
@@ -16,14 +17,38 @@ type ClaimRepository interface {
 	// Returns (true, nil) if created, (false, ErrAlreadyClaimed) if already exists
 	CreateClaimIfNotExists(ctx context.Context, claim *model.Claim) (bool, error)
 
-	// DeleteClaim removes a claim record (used for compensating transactions)
+	// CreateClaimsIfNotExist atomically creates many claims in one bulk write
+	// Each claim uses the same upsert as CreateClaimIfNotExists; the returned slice
+	// reports, per input index, whether that claim was newly created
+	CreateClaimsIfNotExist(ctx context.Context, claims []*model.Claim) ([]bool, error)
+
+	// GetClaimedUserIDs returns which of the given users have already claimed a coupon
+	// A non-zero since only counts claims created at or after it
+	GetClaimedUserIDs(ctx context.Context, couponID interface{}, userIDs []string, since time.Time) (map[string]bool, error)
+
+	// DeleteClaim removes a claim record (used for compensating transactions and cancellations)
+	// Returns ErrClaimNotFound if there was no such claim
 	DeleteClaim(ctx context.Context, userID string, couponID interface{}) error
 
+	// DeleteClaimByID removes exactly the claim with this ID, so a request can undo
+	// its own write without touching a claim written by another request
+	// Returns ErrClaimNotFound if there was no such claim
+	DeleteClaimByID(ctx context.Context, claimID interface{}) error
+
 	// GetClaimsByCouponName retrieves all claims for a specific coupon
 	GetClaimsByCouponName(ctx context.Context, couponName string) ([]*model.Claim, error)
 
+	// CountClaimsByLease counts the claims served from a stock lease
+	CountClaimsByLease(ctx context.Context, leaseID interface{}) (int64, error)
+
+	// ListEventPending returns up to limit claims created before the given time
+	// that are still marked EventPending, oldest first
+	ListEventPending(ctx context.Context, before time.Time, limit int) ([]*model.Claim, error)
+
+	// ClearEventPending removes the EventPending mark once the claims' events are stored
+	ClearEventPending(ctx context.Context, claimIDs []interface{}) error
+
 	// HasUserClaimed checks if a user has already claimed a specific coupon
 	// The context can be a mongo.SessionContext when used in transactions
 	HasUserClaimed(ctx context.Context, userID string, couponID interface{}) (bool, error)
 }
-
@@ -18,5 +18,19 @@ type CouponRepository interface {
 	// Returns error if stock is exhausted or coupon not found
 	// The context can be a mongo.SessionContext when used in transactions
 	DecrementStock(ctx context.Context, couponID interface{}, amount int32) error
-}
 
+	// ReserveStock atomically takes up to max units of stock in a single update
+	// Returns the number of units actually reserved (0 when sold out)
+	ReserveStock(ctx context.Context, couponID interface{}, max int32) (int32, error)
+
+	// IncrementStock atomically adds stock back to a coupon (restocks and returned claims)
+	// Returns ErrCouponNotFound if the coupon does not exist
+	IncrementStock(ctx context.Context, couponID interface{}, amount int32) error
+
+	// SetActive pauses or resumes a coupon
+	// Returns ErrCouponNotFound if the coupon does not exist
+	SetActive(ctx context.Context, couponID interface{}, active bool) error
+
+	// ListCoupons retrieves all coupons ordered by creation time
+	ListCoupons(ctx context.Context) ([]*model.Coupon, error)
+}
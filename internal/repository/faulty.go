@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"coupon-system/internal/faults"
+	"coupon-system/internal/model"
+	"time"
+)
+
+// faultyCouponRepository injects faults into CouponRepository calls
+// Faults are keyed by method name, e.g. "DecrementStock".
+type faultyCouponRepository struct {
+	inner  CouponRepository
+	faults *faults.Injector
+}
+
+// NewFaultyCouponRepository wraps a coupon repository with fault injection
+func NewFaultyCouponRepository(inner CouponRepository, injector *faults.Injector) CouponRepository {
+	injector.Register("CreateCoupon", "GetCouponByName", "DecrementStock", "ReserveStock",
+		"IncrementStock", "SetActive", "ListCoupons")
+	return &faultyCouponRepository{inner: inner, faults: injector}
+}
+
+func (r *faultyCouponRepository) CreateCoupon(ctx context.Context, coupon *model.Coupon) error {
+	return r.faults.Call(ctx, "CreateCoupon", func(ctx context.Context) error {
+		return r.inner.CreateCoupon(ctx, coupon)
+	})
+}
+
+func (r *faultyCouponRepository) GetCouponByName(ctx context.Context, name string) (*model.Coupon, error) {
+	var coupon *model.Coupon
+	err := r.faults.Call(ctx, "GetCouponByName", func(ctx context.Context) (err error) {
+		coupon, err = r.inner.GetCouponByName(ctx, name)
+		return err
+	})
+	return coupon, err
+}
+
+func (r *faultyCouponRepository) DecrementStock(ctx context.Context, couponID interface{}, amount int32) error {
+	return r.faults.Call(ctx, "DecrementStock", func(ctx context.Context) error {
+		return r.inner.DecrementStock(ctx, couponID, amount)
+	})
+}
+
+func (r *faultyCouponRepository) ReserveStock(ctx context.Context, couponID interface{}, max int32) (int32, error) {
+	var reserved int32
+	err := r.faults.Call(ctx, "ReserveStock", func(ctx context.Context) (err error) {
+		reserved, err = r.inner.ReserveStock(ctx, couponID, max)
+		return err
+	})
+	return reserved, err
+}
+
+func (r *faultyCouponRepository) IncrementStock(ctx context.Context, couponID interface{}, amount int32) error {
+	return r.faults.Call(ctx, "IncrementStock", func(ctx context.Context) error {
+		return r.inner.IncrementStock(ctx, couponID, amount)
+	})
+}
+
+func (r *faultyCouponRepository) SetActive(ctx context.Context, couponID interface{}, active bool) error {
+	return r.faults.Call(ctx, "SetActive", func(ctx context.Context) error {
+		return r.inner.SetActive(ctx, couponID, active)
+	})
+}
+
+func (r *faultyCouponRepository) ListCoupons(ctx context.Context) ([]*model.Coupon, error) {
+	var coupons []*model.Coupon
+	err := r.faults.Call(ctx, "ListCoupons", func(ctx context.Context) (err error) {
+		coupons, err = r.inner.ListCoupons(ctx)
+		return err
+	})
+	return coupons, err
+}
+
+// faultyClaimRepository injects faults into ClaimRepository calls
+type faultyClaimRepository struct {
+	inner  ClaimRepository
+	faults *faults.Injector
+}
+
+// NewFaultyClaimRepository wraps a claim repository with fault injection
+func NewFaultyClaimRepository(inner ClaimRepository, injector *faults.Injector) ClaimRepository {
+	injector.Register("CreateClaim", "CreateClaimIfNotExists", "CreateClaimsIfNotExist", "GetClaimedUserIDs",
+		"DeleteClaim", "DeleteClaimByID", "GetClaimsByCouponName", "CountClaimsByLease", "HasUserClaimed")
+	return &faultyClaimRepository{inner: inner, faults: injector}
+}
+
+func (r *faultyClaimRepository) CreateClaim(ctx context.Context, claim *model.Claim) error {
+	return r.faults.Call(ctx, "CreateClaim", func(ctx context.Context) error {
+		return r.inner.CreateClaim(ctx, claim)
+	})
+}
+
+func (r *faultyClaimRepository) CreateClaimIfNotExists(ctx context.Context, claim *model.Claim) (bool, error) {
+	var created bool
+	err := r.faults.Call(ctx, "CreateClaimIfNotExists", func(ctx context.Context) (err error) {
+		created, err = r.inner.CreateClaimIfNotExists(ctx, claim)
+		return err
+	})
+	return created, err
+}
+
+func (r *faultyClaimRepository) CreateClaimsIfNotExist(ctx context.Context, claims []*model.Claim) ([]bool, error) {
+	var created []bool
+	err := r.faults.Call(ctx, "CreateClaimsIfNotExist", func(ctx context.Context) (err error) {
+		created, err = r.inner.CreateClaimsIfNotExist(ctx, claims)
+		return err
+	})
+	return created, err
+}
+
+func (r *faultyClaimRepository) GetClaimedUserIDs(ctx context.Context, couponID interface{}, userIDs []string, since time.Time) (map[string]bool, error) {
+	var claimed map[string]bool
+	err := r.faults.Call(ctx, "GetClaimedUserIDs", func(ctx context.Context) (err error) {
+		claimed, err = r.inner.GetClaimedUserIDs(ctx, couponID, userIDs, since)
+		return err
+	})
+	return claimed, err
+}
+
+func (r *faultyClaimRepository) DeleteClaim(ctx context.Context, userID string, couponID interface{}) error {
+	return r.faults.Call(ctx, "DeleteClaim", func(ctx context.Context) error {
+		return r.inner.DeleteClaim(ctx, userID, couponID)
+	})
+}
+
+func (r *faultyClaimRepository) DeleteClaimByID(ctx context.Context, claimID interface{}) error {
+	return r.faults.Call(ctx, "DeleteClaimByID", func(ctx context.Context) error {
+		return r.inner.DeleteClaimByID(ctx, claimID)
+	})
+}
+
+func (r *faultyClaimRepository) GetClaimsByCouponName(ctx context.Context, couponName string) ([]*model.Claim, error) {
+	var claims []*model.Claim
+	err := r.faults.Call(ctx, "GetClaimsByCouponName", func(ctx context.Context) (err error) {
+		claims, err = r.inner.GetClaimsByCouponName(ctx, couponName)
+		return err
+	})
+	return claims, err
+}
+
+func (r *faultyClaimRepository) CountClaimsByLease(ctx context.Context, leaseID interface{}) (int64, error) {
+	var count int64
+	err := r.faults.Call(ctx, "CountClaimsByLease", func(ctx context.Context) (err error) {
+		count, err = r.inner.CountClaimsByLease(ctx, leaseID)
+		return err
+	})
+	return count, err
+}
+
+func (r *faultyClaimRepository) ListEventPending(ctx context.Context, before time.Time, limit int) ([]*model.Claim, error) {
+	var claims []*model.Claim
+	err := r.faults.Call(ctx, "ListEventPending", func(ctx context.Context) (err error) {
+		claims, err = r.inner.ListEventPending(ctx, before, limit)
+		return err
+	})
+	return claims, err
+}
+
+func (r *faultyClaimRepository) ClearEventPending(ctx context.Context, claimIDs []interface{}) error {
+	return r.faults.Call(ctx, "ClearEventPending", func(ctx context.Context) error {
+		return r.inner.ClearEventPending(ctx, claimIDs)
+	})
+}
+
+func (r *faultyClaimRepository) HasUserClaimed(ctx context.Context, userID string, couponID interface{}) (bool, error) {
+	var claimed bool
+	err := r.faults.Call(ctx, "HasUserClaimed", func(ctx context.Context) (err error) {
+		claimed, err = r.inner.HasUserClaimed(ctx, userID, couponID)
+		return err
+	})
+	return claimed, err
+}
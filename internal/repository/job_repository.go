@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"coupon-system/internal/model"
+	"time"
+)
+
+// JobRepository defines the interface for background job persistence
+// Workers hold a lease on running jobs; a job whose lease expires (because its
+// worker crashed) becomes claimable again
+type JobRepository interface {
+	// CreateJob stores a new queued job
+	CreateJob(ctx context.Context, job *model.Job) error
+
+	// GetJob retrieves a job by its ID
+	// Returns ErrJobNotFound if the job does not exist
+	GetJob(ctx context.Context, id string) (*model.Job, error)
+
+	// ClaimNextJob atomically takes the oldest queued job, or a running job whose
+	// lease has expired, and marks it running under the given owner
+	// Returns (nil, nil) if there is nothing to do
+	ClaimNextJob(ctx context.Context, owner string, leaseUntil time.Time) (*model.Job, error)
+
+	// UpdateProgress records progress and extends the lease
+	// Returns whether cancellation was requested, or ErrJobLeaseLost if another
+	// worker has taken over the job
+	UpdateProgress(ctx context.Context, job *model.Job, owner string, leaseUntil time.Time) (bool, error)
+
+	// FinishJob moves a job held by owner to a terminal status
+	FinishJob(ctx context.Context, job *model.Job, owner string) error
+
+	// RequestCancel cancels a queued job immediately or flags a running job for cancellation
+	// Returns ErrJobNotFound if the job does not exist
+	RequestCancel(ctx context.Context, id string) (*model.Job, error)
+
+	// ReleaseJobs puts running jobs held by owner back in the queue (graceful shutdown)
+	ReleaseJobs(ctx context.Context, owner string) error
+}
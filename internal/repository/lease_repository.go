@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"context"
+	"coupon-system/internal/model"
+	"time"
+)
+
+// LeaseRepository defines the interface for stock leases (pre-allocation mode)
+type LeaseRepository interface {
+	// CreateLease records a block of stock taken by an instance
+	CreateLease(ctx context.Context, lease *model.StockLease) error
+
+	// AddUsed records claims committed from a lease
+	AddUsed(ctx context.Context, leaseID interface{}, n int32) error
+
+	// Heartbeat extends the expiry of an owner's active leases
+	Heartbeat(ctx context.Context, owner string, leaseIDs []interface{}, expiresAt time.Time) error
+
+	// FinishLease moves an active lease to returned or reclaimed, recording the units given back
+	// Returns false if the lease was no longer active (someone else finished it)
+	FinishLease(ctx context.Context, leaseID interface{}, status string, returned int32) (bool, error)
+
+	// FindExpired returns active leases that expired before the given time
+	FindExpired(ctx context.Context, before time.Time) ([]*model.StockLease, error)
+}
@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"coupon-system/internal/model"
+	apperrors "coupon-system/pkg/errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongodbAPIKeyRepository implements APIKeyRepository using MongoDB
+type mongodbAPIKeyRepository struct {
+	keys  *mongo.Collection
+	audit *mongo.Collection
+}
+
+// NewAPIKeyRepository creates a new MongoDB-based API key repository
+func NewAPIKeyRepository(db *mongo.Database) APIKeyRepository {
+	return &mongodbAPIKeyRepository{
+		keys:  db.Collection("api_keys"),
+		audit: db.Collection("api_key_audit"),
+	}
+}
+
+// CreateKey stores a new key
+func (r *mongodbAPIKeyRepository) CreateKey(ctx context.Context, key *model.APIKey) error {
+	if key.ID.IsZero() {
+		key.ID = primitive.NewObjectID()
+	}
+
+	_, err := r.keys.InsertOne(ctx, key)
+	return err
+}
+
+// GetKey retrieves a key by its ID
+func (r *mongodbAPIKeyRepository) GetKey(ctx context.Context, id string) (*model.APIKey, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, apperrors.ErrAPIKeyNotFound
+	}
+	return r.findKey(ctx, bson.M{"_id": objectID})
+}
+
+// GetKeyByHash retrieves a key by the hash of its secret
+func (r *mongodbAPIKeyRepository) GetKeyByHash(ctx context.Context, hash string) (*model.APIKey, error) {
+	return r.findKey(ctx, bson.M{"hash": hash})
+}
+
+func (r *mongodbAPIKeyRepository) findKey(ctx context.Context, filter bson.M) (*model.APIKey, error) {
+	var key model.APIKey
+	if err := r.keys.FindOne(ctx, filter).Decode(&key); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperrors.ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// ListKeys returns every key, oldest first
+func (r *mongodbAPIKeyRepository) ListKeys(ctx context.Context) ([]*model.APIKey, error) {
+	cursor, err := r.keys.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	keys := make([]*model.APIKey, 0)
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RevokeKey marks a key revoked and returns it
+func (r *mongodbAPIKeyRepository) RevokeKey(ctx context.Context, id string, at time.Time) (*model.APIKey, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, apperrors.ErrAPIKeyNotFound
+	}
+
+	// $min keeps the first revocation time when the key is revoked twice
+	var key model.APIKey
+	err = r.keys.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": objectID},
+		bson.M{"$min": bson.M{"revoked_at": at}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&key)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperrors.ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// ReplaceKey stores a key's successor and makes the old key expire
+// The successor is stored first and removed again if the old key cannot be
+// updated, so a failed rotation never leaves the old key expiring without a
+// replacement.
+func (r *mongodbAPIKeyRepository) ReplaceKey(ctx context.Context, oldID string, expiresAt time.Time, successor *model.APIKey) error {
+	objectID, err := primitive.ObjectIDFromHex(oldID)
+	if err != nil {
+		return apperrors.ErrAPIKeyNotFound
+	}
+	if err := r.CreateKey(ctx, successor); err != nil {
+		return err
+	}
+
+	// $min never extends a key that already expires sooner
+	result, err := r.keys.UpdateOne(
+		ctx,
+		bson.M{"_id": objectID, "revoked_at": bson.M{"$exists": false}},
+		bson.M{
+			"$min": bson.M{"expires_at": expiresAt},
+			"$set": bson.M{"rotated_to": successor.ID},
+		},
+	)
+	if err == nil && result.MatchedCount == 0 {
+		err = apperrors.ErrAPIKeyNotFound
+		if _, getErr := r.GetKey(ctx, oldID); getErr == nil {
+			err = apperrors.ErrAPIKeyRevoked
+		}
+	}
+	if err != nil {
+		// Compensate: the successor must not outlive a failed rotation
+		_, _ = r.keys.DeleteOne(ctx, bson.M{"_id": successor.ID})
+		return err
+	}
+	return nil
+}
+
+// TouchKeys sets the last use time of keys
+func (r *mongodbAPIKeyRepository) TouchKeys(ctx context.Context, ids []primitive.ObjectID, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := r.keys.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": ids}}, bson.M{"$max": bson.M{"last_used_at": at}})
+	return err
+}
+
+// RecordUsage appends entries to the audit log
+func (r *mongodbAPIKeyRepository) RecordUsage(ctx context.Context, entries []*model.APIKeyAuditEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	docs := make([]interface{}, len(entries))
+	for i, entry := range entries {
+		if entry.ID.IsZero() {
+			entry.ID = primitive.NewObjectID()
+		}
+		docs[i] = entry
+	}
+	_, err := r.audit.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	return err
+}
+
+// ListUsage returns audit entries, newest first
+func (r *mongodbAPIKeyRepository) ListUsage(ctx context.Context, keyID string, limit int) ([]*model.APIKeyAuditEntry, error) {
+	filter := bson.M{}
+	if keyID != "" {
+		objectID, err := primitive.ObjectIDFromHex(keyID)
+		if err != nil {
+			return nil, apperrors.ErrAPIKeyNotFound
+		}
+		filter["key_id"] = objectID
+	}
+
+	cursor, err := r.audit.Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "at", Value: -1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	entries := make([]*model.APIKeyAuditEntry, 0)
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
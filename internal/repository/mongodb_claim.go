@@ -4,6 +4,8 @@ import (
 	"context"
 	"coupon-system/internal/model"
 	apperrors "coupon-system/pkg/errors"
+	"errors"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -44,14 +46,7 @@ func (r *mongodbClaimRepository) CreateClaimIfNotExists(ctx context.Context, cla
 			"user_id":   claim.UserID,
 			"coupon_id": claim.CouponID,
 		},
-		bson.M{
-			"$setOnInsert": bson.M{
-				"user_id":     claim.UserID,
-				"coupon_id":   claim.CouponID,
-				"coupon_name": claim.CouponName,
-				"created_at":  claim.CreatedAt,
-			},
-		},
+		bson.M{"$setOnInsert": claimFields(claim)},
 		options.Update().SetUpsert(true),
 	)
 	if err != nil {
@@ -67,13 +62,127 @@ func (r *mongodbClaimRepository) CreateClaimIfNotExists(ctx context.Context, cla
 	return true, nil
 }
 
-// DeleteClaim removes a claim record (used for compensating transactions)
+// CreateClaimsIfNotExist atomically creates many claims in one unordered bulk write
+func (r *mongodbClaimRepository) CreateClaimsIfNotExist(ctx context.Context, claims []*model.Claim) ([]bool, error) {
+	created := make([]bool, len(claims))
+	if len(claims) == 0 {
+		return created, nil
+	}
+
+	models := make([]mongo.WriteModel, 0, len(claims))
+	for _, claim := range claims {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{
+				"user_id":   claim.UserID,
+				"coupon_id": claim.CouponID,
+			}).
+			SetUpdate(bson.M{"$setOnInsert": claimFields(claim)}).
+			SetUpsert(true))
+	}
+
+	result, err := r.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		// Concurrent upserts for the same user can lose the race on the unique
+		// index; those are already claimed, anything else is a real failure
+		var bulkErr mongo.BulkWriteException
+		if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+			return nil, err
+		}
+		for _, writeErr := range bulkErr.WriteErrors {
+			if !mongo.IsDuplicateKeyError(writeErr) {
+				return nil, err
+			}
+		}
+	}
+
+	if result != nil {
+		for index := range result.UpsertedIDs {
+			created[index] = true
+		}
+	}
+
+	return created, nil
+}
+
+// claimFields are the fields an upsert writes when it creates a claim
+// A preset ID is kept so the caller can later address exactly this claim
+func claimFields(claim *model.Claim) bson.M {
+	fields := bson.M{
+		"user_id":     claim.UserID,
+		"coupon_id":   claim.CouponID,
+		"coupon_name": claim.CouponName,
+		"created_at":  claim.CreatedAt,
+	}
+	if !claim.ID.IsZero() {
+		fields["_id"] = claim.ID
+	}
+	if claim.LeaseID != nil {
+		fields["lease_id"] = claim.LeaseID
+	}
+	if claim.EventPending {
+		fields["event_pending"] = true
+	}
+	return fields
+}
+
+// GetClaimedUserIDs returns which of the given users have already claimed a coupon
+func (r *mongodbClaimRepository) GetClaimedUserIDs(ctx context.Context, couponID interface{}, userIDs []string, since time.Time) (map[string]bool, error) {
+	filter := bson.M{
+		"coupon_id": couponID,
+		"user_id":   bson.M{"$in": userIDs},
+	}
+	if !since.IsZero() {
+		filter["created_at"] = bson.M{"$gte": since}
+	}
+	cursor, err := r.collection.Find(
+		ctx,
+		filter,
+		options.Find().SetProjection(bson.M{"user_id": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	claimed := make(map[string]bool)
+	for cursor.Next(ctx) {
+		var claim model.Claim
+		if err := cursor.Decode(&claim); err != nil {
+			return nil, err
+		}
+		claimed[claim.UserID] = true
+	}
+
+	return claimed, cursor.Err()
+}
+
+// DeleteClaim removes a claim record (used for compensating transactions and cancellations)
 func (r *mongodbClaimRepository) DeleteClaim(ctx context.Context, userID string, couponID interface{}) error {
-	_, err := r.collection.DeleteOne(ctx, bson.M{
+	result, err := r.collection.DeleteOne(ctx, bson.M{
 		"user_id":   userID,
 		"coupon_id": couponID,
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return apperrors.ErrClaimNotFound
+	}
+
+	return nil
+}
+
+// DeleteClaimByID removes exactly the claim with this ID
+func (r *mongodbClaimRepository) DeleteClaimByID(ctx context.Context, claimID interface{}) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": claimID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return apperrors.ErrClaimNotFound
+	}
+
+	return nil
 }
 
 // GetClaimsByCouponName retrieves all claims for a specific coupon
@@ -108,3 +217,35 @@ func (r *mongodbClaimRepository) HasUserClaimed(ctx context.Context, userID stri
 	return false, err
 }
 
+// CountClaimsByLease counts the claims served from a stock lease
+func (r *mongodbClaimRepository) CountClaimsByLease(ctx context.Context, leaseID interface{}) (int64, error) {
+	return r.collection.CountDocuments(ctx, bson.M{"lease_id": leaseID})
+}
+
+// ListEventPending returns claims whose events may not be stored yet, oldest first
+func (r *mongodbClaimRepository) ListEventPending(ctx context.Context, before time.Time, limit int) ([]*model.Claim, error) {
+	cursor, err := r.collection.Find(
+		ctx,
+		bson.M{"event_pending": true, "created_at": bson.M{"$lt": before}},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}).SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	claims := make([]*model.Claim, 0)
+	if err := cursor.All(ctx, &claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// ClearEventPending removes the event_pending mark from claims
+func (r *mongodbClaimRepository) ClearEventPending(ctx context.Context, claimIDs []interface{}) error {
+	if len(claimIDs) == 0 {
+		return nil
+	}
+	_, err := r.collection.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": claimIDs}}, bson.M{"$unset": bson.M{"event_pending": ""}})
+	return err
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"coupon-system/internal/model"
 	apperrors "coupon-system/pkg/errors"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -73,3 +74,85 @@ func (r *mongodbCouponRepository) DecrementStock(ctx context.Context, couponID i
 	return nil
 }
 
+// ReserveStock atomically takes up to max units of stock in a single update
+// Uses an update pipeline so the new value is clamped at zero server-side
+func (r *mongodbCouponRepository) ReserveStock(ctx context.Context, couponID interface{}, max int32) (int32, error) {
+	var before model.Coupon
+	err := r.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"_id":              couponID,
+			"remaining_amount": bson.M{"$gt": 0},
+		},
+		mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{
+				"remaining_amount": bson.M{"$max": bson.A{0, bson.M{"$subtract": bson.A{"$remaining_amount", max}}}},
+			}}},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.Before),
+	).Decode(&before)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	if before.RemainingAmount < max {
+		return before.RemainingAmount, nil
+	}
+	return max, nil
+}
+
+// IncrementStock atomically adds stock back to a coupon
+func (r *mongodbCouponRepository) IncrementStock(ctx context.Context, couponID interface{}, amount int32) error {
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": couponID},
+		bson.M{
+			"$inc": bson.M{"remaining_amount": amount},
+			"$set": bson.M{"updated_at": time.Now()},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return apperrors.ErrCouponNotFound
+	}
+
+	return nil
+}
+
+// SetActive pauses or resumes a coupon
+func (r *mongodbCouponRepository) SetActive(ctx context.Context, couponID interface{}, active bool) error {
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": couponID},
+		bson.M{"$set": bson.M{"is_active": active, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return apperrors.ErrCouponNotFound
+	}
+
+	return nil
+}
+
+// ListCoupons retrieves all coupons ordered by creation time
+func (r *mongodbCouponRepository) ListCoupons(ctx context.Context) ([]*model.Coupon, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	coupons := make([]*model.Coupon, 0)
+	if err := cursor.All(ctx, &coupons); err != nil {
+		return nil, err
+	}
+
+	return coupons, nil
+}
@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"context"
+	"coupon-system/internal/model"
+	apperrors "coupon-system/pkg/errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongodbJobRepository implements JobRepository using MongoDB
+type mongodbJobRepository struct {
+	collection *mongo.Collection
+}
+
+// NewJobRepository creates a new MongoDB-based job repository
+func NewJobRepository(db *mongo.Database) JobRepository {
+	return &mongodbJobRepository{
+		collection: db.Collection("jobs"),
+	}
+}
+
+// CreateJob stores a new queued job
+func (r *mongodbJobRepository) CreateJob(ctx context.Context, job *model.Job) error {
+	if job.ID.IsZero() {
+		job.ID = primitive.NewObjectID()
+	}
+
+	_, err := r.collection.InsertOne(ctx, job)
+	return err
+}
+
+// GetJob retrieves a job by its ID
+func (r *mongodbJobRepository) GetJob(ctx context.Context, id string) (*model.Job, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, apperrors.ErrJobNotFound
+	}
+
+	var job model.Job
+	if err := r.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&job); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperrors.ErrJobNotFound
+		}
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// ClaimNextJob atomically takes the oldest runnable job
+func (r *mongodbJobRepository) ClaimNextJob(ctx context.Context, owner string, leaseUntil time.Time) (*model.Job, error) {
+	now := time.Now()
+
+	var job model.Job
+	err := r.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"$or": bson.A{
+			bson.M{"status": model.JobStatusQueued},
+			bson.M{"status": model.JobStatusRunning, "lease_until": bson.M{"$lt": now}},
+		}},
+		bson.M{
+			"$set": bson.M{
+				"status":      model.JobStatusRunning,
+				"owner":       owner,
+				"lease_until": leaseUntil,
+				"updated_at":  now,
+			},
+			"$inc": bson.M{"attempts": 1},
+		},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "created_at", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&job)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// First run: remember when the job actually started
+	if job.StartedAt == nil {
+		job.StartedAt = &now
+		_, err := r.collection.UpdateOne(ctx, bson.M{"_id": job.ID}, bson.M{"$set": bson.M{"started_at": now}})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &job, nil
+}
+
+// UpdateProgress records progress and extends the lease
+func (r *mongodbJobRepository) UpdateProgress(ctx context.Context, job *model.Job, owner string, leaseUntil time.Time) (bool, error) {
+	var updated model.Job
+	err := r.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": job.ID, "owner": owner, "status": model.JobStatusRunning},
+		bson.M{"$set": bson.M{
+			"progress":    job.Progress,
+			"result":      job.Result,
+			"lease_until": leaseUntil,
+			"updated_at":  time.Now(),
+		}},
+		options.FindOneAndUpdate().
+			SetReturnDocument(options.After).
+			SetProjection(bson.M{"cancel_requested": 1}),
+	).Decode(&updated)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return false, apperrors.ErrJobLeaseLost
+		}
+		return false, err
+	}
+
+	return updated.CancelRequested, nil
+}
+
+// FinishJob moves a job held by owner to a terminal status
+func (r *mongodbJobRepository) FinishJob(ctx context.Context, job *model.Job, owner string) error {
+	now := time.Now()
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": job.ID, "owner": owner, "status": model.JobStatusRunning},
+		bson.M{
+			"$set": bson.M{
+				"status":      job.Status,
+				"progress":    job.Progress,
+				"result":      job.Result,
+				"error":       job.Error,
+				"finished_at": now,
+				"updated_at":  now,
+			},
+			"$unset": bson.M{"owner": "", "lease_until": ""},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return apperrors.ErrJobLeaseLost
+	}
+
+	return nil
+}
+
+// RequestCancel cancels a queued job immediately or flags a running job for cancellation
+func (r *mongodbJobRepository) RequestCancel(ctx context.Context, id string) (*model.Job, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, apperrors.ErrJobNotFound
+	}
+	now := time.Now()
+
+	// Queued jobs have no worker to notice the flag, so cancel them outright
+	_, err = r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": objectID, "status": model.JobStatusQueued},
+		bson.M{"$set": bson.M{
+			"status":           model.JobStatusCancelled,
+			"cancel_requested": true,
+			"finished_at":      now,
+			"updated_at":       now,
+		}},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Running jobs are stopped by their worker at the next progress update
+	_, err = r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": objectID, "status": model.JobStatusRunning},
+		bson.M{"$set": bson.M{"cancel_requested": true, "updated_at": now}},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetJob(ctx, id)
+}
+
+// ReleaseJobs puts running jobs held by owner back in the queue
+func (r *mongodbJobRepository) ReleaseJobs(ctx context.Context, owner string) error {
+	_, err := r.collection.UpdateMany(
+		ctx,
+		bson.M{"owner": owner, "status": model.JobStatusRunning},
+		bson.M{
+			"$set":   bson.M{"status": model.JobStatusQueued, "updated_at": time.Now()},
+			"$unset": bson.M{"owner": "", "lease_until": ""},
+		},
+	)
+	return err
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"coupon-system/internal/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// mongodbLeaseRepository implements LeaseRepository using MongoDB
+type mongodbLeaseRepository struct {
+	collection *mongo.Collection
+}
+
+// NewLeaseRepository creates a new MongoDB-based stock lease repository
+func NewLeaseRepository(db *mongo.Database) LeaseRepository {
+	return &mongodbLeaseRepository{
+		collection: db.Collection("stock_leases"),
+	}
+}
+
+// CreateLease records a block of stock taken by an instance
+func (r *mongodbLeaseRepository) CreateLease(ctx context.Context, lease *model.StockLease) error {
+	if lease.ID.IsZero() {
+		lease.ID = primitive.NewObjectID()
+	}
+
+	_, err := r.collection.InsertOne(ctx, lease)
+	return err
+}
+
+// AddUsed records claims committed from a lease
+func (r *mongodbLeaseRepository) AddUsed(ctx context.Context, leaseID interface{}, n int32) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": leaseID}, bson.M{"$inc": bson.M{"used": n}})
+	return err
+}
+
+// Heartbeat extends the expiry of an owner's active leases
+func (r *mongodbLeaseRepository) Heartbeat(ctx context.Context, owner string, leaseIDs []interface{}, expiresAt time.Time) error {
+	if len(leaseIDs) == 0 {
+		return nil
+	}
+
+	_, err := r.collection.UpdateMany(
+		ctx,
+		bson.M{
+			"_id":    bson.M{"$in": leaseIDs},
+			"owner":  owner,
+			"status": model.LeaseStatusActive,
+		},
+		bson.M{"$set": bson.M{"expires_at": expiresAt}},
+	)
+	return err
+}
+
+// FinishLease moves an active lease to returned or reclaimed
+func (r *mongodbLeaseRepository) FinishLease(ctx context.Context, leaseID interface{}, status string, returned int32) (bool, error) {
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": leaseID, "status": model.LeaseStatusActive},
+		bson.M{"$set": bson.M{"status": status, "returned": returned}},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount == 1, nil
+}
+
+// FindExpired returns active leases that expired before the given time
+func (r *mongodbLeaseRepository) FindExpired(ctx context.Context, before time.Time) ([]*model.StockLease, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{
+		"status":     model.LeaseStatusActive,
+		"expires_at": bson.M{"$lt": before},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	leases := make([]*model.StockLease, 0)
+	if err := cursor.All(ctx, &leases); err != nil {
+		return nil, err
+	}
+
+	return leases, nil
+}
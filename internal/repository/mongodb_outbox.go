@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"coupon-system/internal/model"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// relayLeaseID is the single document holding the relay lease
+const relayLeaseID = "relay"
+
+// mongodbOutboxRepository implements OutboxRepository using MongoDB
+type mongodbOutboxRepository struct {
+	collection *mongo.Collection
+	leases     *mongo.Collection
+}
+
+// NewOutboxRepository creates a new MongoDB-based outbox repository
+func NewOutboxRepository(db *mongo.Database) OutboxRepository {
+	return &mongodbOutboxRepository{
+		collection: db.Collection("outbox"),
+		leases:     db.Collection("outbox_leases"),
+	}
+}
+
+// Append stores events in order
+// IDs are assigned here, so their order is the order events were recorded
+func (r *mongodbOutboxRepository) Append(ctx context.Context, events ...*model.OutboxEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	docs := make([]interface{}, len(events))
+	for i, event := range events {
+		if event.ID.IsZero() {
+			event.ID = primitive.NewObjectID()
+		}
+		docs[i] = event
+	}
+
+	// Unordered, so a duplicate key does not stop the events after it
+	_, err := r.collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	var bulkErr mongo.BulkWriteException
+	if err != nil && errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+		for _, writeErr := range bulkErr.WriteErrors {
+			if !mongo.IsDuplicateKeyError(writeErr) {
+				return err
+			}
+		}
+		return nil
+	}
+	return err
+}
+
+// Unpublished returns up to limit events not yet published, oldest first
+func (r *mongodbOutboxRepository) Unpublished(ctx context.Context, limit int) ([]*model.OutboxEvent, error) {
+	cursor, err := r.collection.Find(
+		ctx,
+		bson.M{"published_at": nil},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	events := make([]*model.OutboxEvent, 0)
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// MarkDelivered records that a sink has received an event
+func (r *mongodbOutboxRepository) MarkDelivered(ctx context.Context, eventID interface{}, sink string) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": eventID}, bson.M{"$addToSet": bson.M{"delivered_to": sink}})
+	return err
+}
+
+// MarkPublished records that every sink has received an event
+func (r *mongodbOutboxRepository) MarkPublished(ctx context.Context, eventID interface{}) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": eventID}, bson.M{"$set": bson.M{"published_at": time.Now()}})
+	return err
+}
+
+// RecordFailure counts a failed delivery and sets when to try again
+func (r *mongodbOutboxRepository) RecordFailure(ctx context.Context, eventID interface{}, message string, nextAttemptAt time.Time) error {
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": eventID},
+		bson.M{
+			"$inc": bson.M{"attempts": 1},
+			"$set": bson.M{"last_error": message, "next_attempt_at": nextAttemptAt},
+		},
+	)
+	return err
+}
+
+// AcquireRelayLease takes the lease if it is free, expired or already ours
+// Two instances racing for a free lease both upsert the same _id; the loser
+// gets a duplicate key error and is not the relay
+func (r *mongodbOutboxRepository) AcquireRelayLease(ctx context.Context, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	_, err := r.leases.UpdateOne(
+		ctx,
+		bson.M{
+			"_id": relayLeaseID,
+			"$or": bson.A{
+				bson.M{"owner": owner},
+				bson.M{"expires_at": bson.M{"$lt": now}},
+			},
+		},
+		bson.M{"$set": bson.M{"owner": owner, "expires_at": now.Add(ttl)}},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ReleaseRelayLease gives up the lease if owner holds it
+func (r *mongodbOutboxRepository) ReleaseRelayLease(ctx context.Context, owner string) error {
+	_, err := r.leases.DeleteOne(ctx, bson.M{"_id": relayLeaseID, "owner": owner})
+	return err
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"coupon-system/internal/model"
+	apperrors "coupon-system/pkg/errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongodbRaffleRepository implements RaffleRepository using MongoDB
+type mongodbRaffleRepository struct {
+	entries *mongo.Collection
+	draws   *mongo.Collection
+}
+
+// NewRaffleRepository creates a new MongoDB-based raffle repository
+func NewRaffleRepository(db *mongo.Database) RaffleRepository {
+	return &mongodbRaffleRepository{
+		entries: db.Collection("raffle_entries"),
+		draws:   db.Collection("raffle_draws"),
+	}
+}
+
+// AddEntry records a user's entry
+func (r *mongodbRaffleRepository) AddEntry(ctx context.Context, entry *model.RaffleEntry) error {
+	_, err := r.entries.InsertOne(ctx, entry)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return apperrors.ErrAlreadyEntered
+		}
+		return err
+	}
+
+	return nil
+}
+
+// ListEntrants returns the user IDs of everyone who entered a raffle
+func (r *mongodbRaffleRepository) ListEntrants(ctx context.Context, couponID interface{}) ([]string, error) {
+	cursor, err := r.entries.Find(
+		ctx,
+		bson.M{"coupon_id": couponID},
+		options.Find().SetProjection(bson.M{"user_id": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	entrants := make([]string, 0)
+	for cursor.Next(ctx) {
+		var entry model.RaffleEntry
+		if err := cursor.Decode(&entry); err != nil {
+			return nil, err
+		}
+		entrants = append(entrants, entry.UserID)
+	}
+
+	return entrants, cursor.Err()
+}
+
+// CreateDraw persists a draw; the unique index on coupon_id allows only one
+func (r *mongodbRaffleRepository) CreateDraw(ctx context.Context, draw *model.RaffleDraw) error {
+	_, err := r.draws.InsertOne(ctx, draw)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return apperrors.ErrRaffleAlreadyDrawn
+		}
+		return err
+	}
+
+	return nil
+}
+
+// GetDraw retrieves the draw for a coupon
+func (r *mongodbRaffleRepository) GetDraw(ctx context.Context, couponID interface{}) (*model.RaffleDraw, error) {
+	var draw model.RaffleDraw
+	if err := r.draws.FindOne(ctx, bson.M{"coupon_id": couponID}).Decode(&draw); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperrors.ErrRaffleNotDrawn
+		}
+		return nil, err
+	}
+
+	return &draw, nil
+}
+
+// CompleteDraw marks a draw as converted into claims
+func (r *mongodbRaffleRepository) CompleteDraw(ctx context.Context, draw *model.RaffleDraw) error {
+	now := time.Now()
+	draw.Status = model.RaffleDrawStatusCompleted
+	draw.CompletedAt = &now
+
+	_, err := r.draws.UpdateOne(
+		ctx,
+		bson.M{"coupon_id": draw.CouponID},
+		bson.M{"$set": bson.M{
+			"status":       draw.Status,
+			"claimed":      draw.Claimed,
+			"completed_at": now,
+		}},
+	)
+	return err
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"coupon-system/internal/model"
+	apperrors "coupon-system/pkg/errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// grantingTimeout is how long an entry may sit in granting before another worker retries it
+const grantingTimeout = time.Minute
+
+// mongodbWaitlistRepository implements WaitlistRepository using MongoDB
+type mongodbWaitlistRepository struct {
+	collection *mongo.Collection
+}
+
+// NewWaitlistRepository creates a new MongoDB-based waitlist repository
+func NewWaitlistRepository(db *mongo.Database) WaitlistRepository {
+	return &mongodbWaitlistRepository{
+		collection: db.Collection("waitlist"),
+	}
+}
+
+// Join adds a user to a coupon's waitlist
+func (r *mongodbWaitlistRepository) Join(ctx context.Context, entry *model.WaitlistEntry) error {
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+
+	_, err := r.collection.InsertOne(ctx, entry)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return apperrors.ErrAlreadyWaitlisted
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Leave removes a waiting user from a coupon's waitlist
+func (r *mongodbWaitlistRepository) Leave(ctx context.Context, couponID interface{}, userID string) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{
+		"coupon_id": couponID,
+		"user_id":   userID,
+		"status":    model.WaitlistStatusWaiting,
+	})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return apperrors.ErrNotWaitlisted
+	}
+
+	return nil
+}
+
+// GetEntry retrieves a user's waitlist entry
+func (r *mongodbWaitlistRepository) GetEntry(ctx context.Context, couponID interface{}, userID string) (*model.WaitlistEntry, error) {
+	var entry model.WaitlistEntry
+	err := r.collection.FindOne(ctx, bson.M{"coupon_id": couponID, "user_id": userID}).Decode(&entry)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperrors.ErrNotWaitlisted
+		}
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// Position returns the 1-based position of a waiting entry
+func (r *mongodbWaitlistRepository) Position(ctx context.Context, entry *model.WaitlistEntry) (int64, error) {
+	ahead, err := r.collection.CountDocuments(ctx, bson.M{
+		"coupon_id": entry.CouponID,
+		"status":    model.WaitlistStatusWaiting,
+		"_id":       bson.M{"$lt": entry.ID},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return ahead + 1, nil
+}
+
+// PopNext atomically marks the oldest waiting entry as granting and returns it
+func (r *mongodbWaitlistRepository) PopNext(ctx context.Context, couponID interface{}) (*model.WaitlistEntry, error) {
+	now := time.Now()
+
+	var entry model.WaitlistEntry
+	err := r.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"coupon_id": couponID,
+			"$or": bson.A{
+				bson.M{"status": model.WaitlistStatusWaiting},
+				bson.M{"status": model.WaitlistStatusGranting, "updated_at": bson.M{"$lt": now.Add(-grantingTimeout)}},
+			},
+		},
+		bson.M{"$set": bson.M{"status": model.WaitlistStatusGranting, "updated_at": now}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "_id", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&entry)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// WaitingCoupons returns the names of coupons with users waiting
+func (r *mongodbWaitlistRepository) WaitingCoupons(ctx context.Context) ([]string, error) {
+	values, err := r.collection.Distinct(ctx, "coupon_name", bson.M{
+		"status": bson.M{"$in": bson.A{model.WaitlistStatusWaiting, model.WaitlistStatusGranting}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(values))
+	for _, v := range values {
+		if name, ok := v.(string); ok {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// SetStatus moves an entry to waiting (put back), granting or granted
+func (r *mongodbWaitlistRepository) SetStatus(ctx context.Context, entry *model.WaitlistEntry, status string) error {
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": entry.ID},
+		bson.M{"$set": bson.M{"status": status, "stock_reserved": entry.Reserved, "updated_at": time.Now()}},
+	)
+	if err == nil {
+		entry.Status = status
+	}
+	return err
+}
@@ -0,0 +1,262 @@
+package repository
+
+import (
+	"context"
+	"coupon-system/internal/model"
+	apperrors "coupon-system/pkg/errors"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// webhookLogSize is how many attempts are kept in a delivery's log
+const webhookLogSize = 20
+
+// mongodbWebhookRepository implements WebhookRepository using MongoDB
+type mongodbWebhookRepository struct {
+	subscriptions *mongo.Collection
+	deliveries    *mongo.Collection
+}
+
+// NewWebhookRepository creates a new MongoDB-based webhook repository
+func NewWebhookRepository(db *mongo.Database) WebhookRepository {
+	return &mongodbWebhookRepository{
+		subscriptions: db.Collection("webhook_subscriptions"),
+		deliveries:    db.Collection("webhook_deliveries"),
+	}
+}
+
+// CreateSubscription stores a new subscription
+func (r *mongodbWebhookRepository) CreateSubscription(ctx context.Context, sub *model.WebhookSubscription) error {
+	if sub.ID.IsZero() {
+		sub.ID = primitive.NewObjectID()
+	}
+
+	_, err := r.subscriptions.InsertOne(ctx, sub)
+	return err
+}
+
+// GetSubscription retrieves a subscription by its ID
+func (r *mongodbWebhookRepository) GetSubscription(ctx context.Context, id string) (*model.WebhookSubscription, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, apperrors.ErrWebhookNotFound
+	}
+
+	var sub model.WebhookSubscription
+	if err := r.subscriptions.FindOne(ctx, bson.M{"_id": objectID}).Decode(&sub); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperrors.ErrWebhookNotFound
+		}
+		return nil, err
+	}
+
+	return &sub, nil
+}
+
+// ListSubscriptions returns every subscription, oldest first
+func (r *mongodbWebhookRepository) ListSubscriptions(ctx context.Context) ([]*model.WebhookSubscription, error) {
+	cursor, err := r.subscriptions.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	subs := make([]*model.WebhookSubscription, 0)
+	if err := cursor.All(ctx, &subs); err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+// DeleteSubscription removes a subscription and its deliveries
+func (r *mongodbWebhookRepository) DeleteSubscription(ctx context.Context, id string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return apperrors.ErrWebhookNotFound
+	}
+
+	result, err := r.subscriptions.DeleteOne(ctx, bson.M{"_id": objectID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return apperrors.ErrWebhookNotFound
+	}
+
+	_, err = r.deliveries.DeleteMany(ctx, bson.M{"subscription_id": objectID})
+	return err
+}
+
+// EnqueueDeliveries stores pending deliveries, dropping duplicates
+func (r *mongodbWebhookRepository) EnqueueDeliveries(ctx context.Context, deliveries ...*model.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	docs := make([]interface{}, len(deliveries))
+	for i, delivery := range deliveries {
+		if delivery.ID.IsZero() {
+			delivery.ID = primitive.NewObjectID()
+		}
+		docs[i] = delivery
+	}
+
+	// Unordered, so a duplicate does not stop the deliveries after it
+	_, err := r.deliveries.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	var bulkErr mongo.BulkWriteException
+	if err != nil && errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+		for _, writeErr := range bulkErr.WriteErrors {
+			if !mongo.IsDuplicateKeyError(writeErr) {
+				return err
+			}
+		}
+		return nil
+	}
+	return err
+}
+
+// ClaimDueDelivery atomically leases the pending delivery that is due soonest
+func (r *mongodbWebhookRepository) ClaimDueDelivery(ctx context.Context, owner string, leaseUntil time.Time) (*model.WebhookDelivery, error) {
+	now := time.Now()
+
+	var delivery model.WebhookDelivery
+	err := r.deliveries.FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"status":          model.WebhookDeliveryPending,
+			"next_attempt_at": bson.M{"$lte": now},
+			"$or": bson.A{
+				bson.M{"lease_until": bson.M{"$exists": false}},
+				bson.M{"lease_until": bson.M{"$lt": now}},
+			},
+		},
+		bson.M{"$set": bson.M{"owner": owner, "lease_until": leaseUntil, "updated_at": now}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&delivery)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+// RecordAttempt stores the outcome of an attempt and releases the lease
+func (r *mongodbWebhookRepository) RecordAttempt(ctx context.Context, delivery *model.WebhookDelivery, owner string, attempt model.WebhookAttempt) error {
+	set := bson.M{
+		"status":          delivery.Status,
+		"attempts":        delivery.Attempts,
+		"next_attempt_at": delivery.NextAttemptAt,
+		"last_error":      delivery.LastError,
+		"updated_at":      time.Now(),
+	}
+	if delivery.DeliveredAt != nil {
+		set["delivered_at"] = delivery.DeliveredAt
+	}
+
+	_, err := r.deliveries.UpdateOne(
+		ctx,
+		bson.M{"_id": delivery.ID, "owner": owner},
+		bson.M{
+			"$set":   set,
+			"$unset": bson.M{"owner": "", "lease_until": ""},
+			"$push":  bson.M{"log": bson.M{"$each": bson.A{attempt}, "$slice": -webhookLogSize}},
+		},
+	)
+	return err
+}
+
+// ListDeliveries returns a subscription's deliveries, newest first
+func (r *mongodbWebhookRepository) ListDeliveries(ctx context.Context, subscriptionID string, status model.WebhookDeliveryStatus, limit int) ([]*model.WebhookDelivery, error) {
+	objectID, err := primitive.ObjectIDFromHex(subscriptionID)
+	if err != nil {
+		return nil, apperrors.ErrWebhookNotFound
+	}
+
+	filter := bson.M{"subscription_id": objectID}
+	if status != "" {
+		filter["status"] = status
+	}
+	cursor, err := r.deliveries.Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	deliveries := make([]*model.WebhookDelivery, 0)
+	if err := cursor.All(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// ReplayDelivery moves a dead delivery back to pending
+func (r *mongodbWebhookRepository) ReplayDelivery(ctx context.Context, subscriptionID, deliveryID string) (*model.WebhookDelivery, error) {
+	subID, err := primitive.ObjectIDFromHex(subscriptionID)
+	if err != nil {
+		return nil, apperrors.ErrDeliveryNotFound
+	}
+	id, err := primitive.ObjectIDFromHex(deliveryID)
+	if err != nil {
+		return nil, apperrors.ErrDeliveryNotFound
+	}
+
+	var delivery model.WebhookDelivery
+	err = r.deliveries.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": id, "subscription_id": subID, "status": model.WebhookDeliveryDead},
+		replayUpdate(),
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&delivery)
+	if err == nil {
+		return &delivery, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	// Tell a missing delivery apart from one that is not dead
+	count, err := r.deliveries.CountDocuments(ctx, bson.M{"_id": id, "subscription_id": subID})
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, apperrors.ErrDeliveryNotFound
+	}
+	return nil, apperrors.ErrDeliveryNotDead
+}
+
+// ReplayDead moves all of a subscription's dead deliveries back to pending
+func (r *mongodbWebhookRepository) ReplayDead(ctx context.Context, subscriptionID string) (int64, error) {
+	subID, err := primitive.ObjectIDFromHex(subscriptionID)
+	if err != nil {
+		return 0, apperrors.ErrWebhookNotFound
+	}
+
+	result, err := r.deliveries.UpdateMany(ctx, bson.M{"subscription_id": subID, "status": model.WebhookDeliveryDead}, replayUpdate())
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// replayUpdate resets a dead delivery so it is sent again straight away
+func replayUpdate() bson.M {
+	now := time.Now()
+	return bson.M{"$set": bson.M{
+		"status":          model.WebhookDeliveryPending,
+		"attempts":        0,
+		"next_attempt_at": now,
+		"updated_at":      now,
+	}}
+}
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+	"coupon-system/internal/model"
+	"time"
+)
+
+// OutboxRepository defines the interface for the domain event outbox
+type OutboxRepository interface {
+	// Append stores events in order; events whose key was already stored are skipped
+	// The context can be a mongo.SessionContext so events commit with the state change
+	Append(ctx context.Context, events ...*model.OutboxEvent) error
+
+	// Unpublished returns up to limit events not yet published, oldest first
+	Unpublished(ctx context.Context, limit int) ([]*model.OutboxEvent, error)
+
+	// MarkDelivered records that a sink has received an event
+	MarkDelivered(ctx context.Context, eventID interface{}, sink string) error
+
+	// MarkPublished records that every sink has received an event
+	MarkPublished(ctx context.Context, eventID interface{}) error
+
+	// RecordFailure counts a failed delivery and sets when to try again
+	RecordFailure(ctx context.Context, eventID interface{}, message string, nextAttemptAt time.Time) error
+
+	// AcquireRelayLease makes owner the only active relay until the lease expires
+	// Returns true if owner holds the lease, including when it renewed its own
+	AcquireRelayLease(ctx context.Context, owner string, ttl time.Duration) (bool, error)
+
+	// ReleaseRelayLease gives up the lease if owner holds it
+	ReleaseRelayLease(ctx context.Context, owner string) error
+}
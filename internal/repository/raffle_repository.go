@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"context"
+	"coupon-system/internal/model"
+)
+
+// RaffleRepository defines the interface for raffle entries and draws
+type RaffleRepository interface {
+	// AddEntry records a user's entry
+	// Returns ErrAlreadyEntered if the user has already entered
+	AddEntry(ctx context.Context, entry *model.RaffleEntry) error
+
+	// ListEntrants returns the user IDs of everyone who entered a raffle
+	ListEntrants(ctx context.Context, couponID interface{}) ([]string, error)
+
+	// CreateDraw persists a draw; there can only be one draw per coupon
+	// Returns ErrRaffleAlreadyDrawn if the coupon was already drawn
+	CreateDraw(ctx context.Context, draw *model.RaffleDraw) error
+
+	// GetDraw retrieves the draw for a coupon
+	// Returns ErrRaffleNotDrawn if there is none
+	GetDraw(ctx context.Context, couponID interface{}) (*model.RaffleDraw, error)
+
+	// CompleteDraw marks a draw as converted into claims
+	CompleteDraw(ctx context.Context, draw *model.RaffleDraw) error
+}
@@ -0,0 +1,151 @@
+package repotest
+
+import (
+	"context"
+	"coupon-system/internal/model"
+	"errors"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OutboxRepository is an in-memory repository.OutboxRepository whose appends
+// can be made to fail
+type OutboxRepository struct {
+	mu      sync.Mutex
+	events  []*model.OutboxEvent
+	keys    map[string]bool
+	owner   string
+	failing bool
+}
+
+func NewOutboxRepository() *OutboxRepository {
+	return &OutboxRepository{keys: make(map[string]bool)}
+}
+
+func (r *OutboxRepository) Append(_ context.Context, evts ...*model.OutboxEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failing {
+		return errors.New("outbox unavailable")
+	}
+	for _, e := range evts {
+		if e.Key != "" && r.keys[e.Key] {
+			continue
+		}
+		r.keys[e.Key] = true
+		if e.ID.IsZero() {
+			e.ID = primitive.NewObjectID()
+		}
+		r.events = append(r.events, e)
+	}
+	return nil
+}
+
+func (r *OutboxRepository) Unpublished(_ context.Context, limit int) ([]*model.OutboxEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*model.OutboxEvent
+	for _, e := range r.events {
+		if e.PublishedAt == nil && len(out) < limit {
+			copied := *e
+			copied.DeliveredTo = append([]string(nil), e.DeliveredTo...)
+			out = append(out, &copied)
+		}
+	}
+	return out, nil
+}
+
+func (r *OutboxRepository) find(id interface{}) *model.OutboxEvent {
+	for _, e := range r.events {
+		if e.ID == id {
+			return e
+		}
+	}
+	return nil
+}
+
+func (r *OutboxRepository) MarkDelivered(_ context.Context, id interface{}, sink string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e := r.find(id)
+	e.DeliveredTo = append(e.DeliveredTo, sink)
+	return nil
+}
+
+func (r *OutboxRepository) MarkPublished(_ context.Context, id interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	r.find(id).PublishedAt = &now
+	return nil
+}
+
+func (r *OutboxRepository) RecordFailure(_ context.Context, id interface{}, message string, next time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e := r.find(id)
+	e.Attempts++
+	e.LastError = message
+	e.NextAttemptAt = next
+	return nil
+}
+
+func (r *OutboxRepository) AcquireRelayLease(_ context.Context, owner string, _ time.Duration) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.owner == "" || r.owner == owner {
+		r.owner = owner
+		return true, nil
+	}
+	return false, nil
+}
+
+func (r *OutboxRepository) ReleaseRelayLease(_ context.Context, owner string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.owner == owner {
+		r.owner = ""
+	}
+	return nil
+}
+
+// SetFailing makes appends fail until it is called with false
+func (r *OutboxRepository) SetFailing(failing bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failing = failing
+}
+
+// Events returns copies of the stored events in append order
+func (r *OutboxRepository) Events() []*model.OutboxEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := make([]*model.OutboxEvent, len(r.events))
+	for i, e := range r.events {
+		copied := *e
+		events[i] = &copied
+	}
+	return events
+}
+
+// Types returns the types of the stored events in append order
+func (r *OutboxRepository) Types() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	types := make([]string, len(r.events))
+	for i, e := range r.events {
+		types[i] = e.Type
+	}
+	return types
+}
+
+// RetryNow makes every event that failed due for another attempt
+func (r *OutboxRepository) RetryNow() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.events {
+		e.NextAttemptAt = time.Time{}
+	}
+}
@@ -0,0 +1,373 @@
+// Package repotest provides in-memory repositories for tests
+// They follow the MongoDB implementations' rules (unique claims per user and
+// coupon, conditional stock updates) without their durability.
+package repotest
+
+import (
+	"context"
+	"coupon-system/internal/model"
+	apperrors "coupon-system/pkg/errors"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CouponRepository is an in-memory repository.CouponRepository
+type CouponRepository struct {
+	mu      sync.Mutex
+	coupons map[primitive.ObjectID]*model.Coupon
+}
+
+func NewCouponRepository() *CouponRepository {
+	return &CouponRepository{coupons: make(map[primitive.ObjectID]*model.Coupon)}
+}
+
+func (r *CouponRepository) CreateCoupon(_ context.Context, coupon *model.Coupon) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.coupons {
+		if c.Name == coupon.Name {
+			return apperrors.ErrCouponAlreadyExists
+		}
+	}
+	if coupon.ID.IsZero() {
+		coupon.ID = primitive.NewObjectID()
+	}
+	stored := *coupon
+	r.coupons[coupon.ID] = &stored
+	return nil
+}
+
+func (r *CouponRepository) GetCouponByName(_ context.Context, name string) (*model.Coupon, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.coupons {
+		if c.Name == name {
+			coupon := *c
+			return &coupon, nil
+		}
+	}
+	return nil, apperrors.ErrCouponNotFound
+}
+
+func (r *CouponRepository) DecrementStock(_ context.Context, couponID interface{}, amount int32) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.coupons[couponID.(primitive.ObjectID)]
+	if !ok {
+		return apperrors.ErrCouponNotFound
+	}
+	if c.RemainingAmount < amount {
+		return apperrors.ErrNoStock
+	}
+	c.RemainingAmount -= amount
+	return nil
+}
+
+func (r *CouponRepository) ReserveStock(_ context.Context, couponID interface{}, max int32) (int32, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.coupons[couponID.(primitive.ObjectID)]
+	if !ok {
+		return 0, apperrors.ErrCouponNotFound
+	}
+	reserved := max
+	if c.RemainingAmount < reserved {
+		reserved = c.RemainingAmount
+	}
+	c.RemainingAmount -= reserved
+	return reserved, nil
+}
+
+func (r *CouponRepository) IncrementStock(_ context.Context, couponID interface{}, amount int32) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.coupons[couponID.(primitive.ObjectID)]
+	if !ok {
+		return apperrors.ErrCouponNotFound
+	}
+	c.RemainingAmount += amount
+	c.UpdatedAt = time.Now()
+	return nil
+}
+
+func (r *CouponRepository) SetActive(_ context.Context, couponID interface{}, active bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.coupons[couponID.(primitive.ObjectID)]
+	if !ok {
+		return apperrors.ErrCouponNotFound
+	}
+	c.IsActive = active
+	c.UpdatedAt = time.Now()
+	return nil
+}
+
+func (r *CouponRepository) ListCoupons(_ context.Context) ([]*model.Coupon, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	coupons := make([]*model.Coupon, 0, len(r.coupons))
+	for _, c := range r.coupons {
+		coupon := *c
+		coupons = append(coupons, &coupon)
+	}
+	return coupons, nil
+}
+
+// Remaining returns a coupon's stock
+func (r *CouponRepository) Remaining(couponID primitive.ObjectID) int32 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.coupons[couponID].RemainingAmount
+}
+
+// ClaimRepository is an in-memory repository.ClaimRepository
+// Claims are unique per (user, coupon), like the MongoDB unique index.
+type ClaimRepository struct {
+	mu     sync.Mutex
+	claims map[string]*model.Claim
+}
+
+func NewClaimRepository() *ClaimRepository {
+	return &ClaimRepository{claims: make(map[string]*model.Claim)}
+}
+
+func claimKey(userID string, couponID interface{}) string {
+	return couponID.(primitive.ObjectID).Hex() + ":" + userID
+}
+
+func (r *ClaimRepository) CreateClaim(_ context.Context, claim *model.Claim) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := claimKey(claim.UserID, claim.CouponID)
+	if _, ok := r.claims[key]; ok {
+		return apperrors.ErrAlreadyClaimed
+	}
+	r.insert(key, claim)
+	return nil
+}
+
+func (r *ClaimRepository) CreateClaimIfNotExists(_ context.Context, claim *model.Claim) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := claimKey(claim.UserID, claim.CouponID)
+	if _, ok := r.claims[key]; ok {
+		return false, apperrors.ErrAlreadyClaimed
+	}
+	r.insert(key, claim)
+	return true, nil
+}
+
+func (r *ClaimRepository) CreateClaimsIfNotExist(_ context.Context, claims []*model.Claim) ([]bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	created := make([]bool, len(claims))
+	for i, claim := range claims {
+		key := claimKey(claim.UserID, claim.CouponID)
+		if _, ok := r.claims[key]; ok {
+			continue
+		}
+		r.insert(key, claim)
+		created[i] = true
+	}
+	return created, nil
+}
+
+// insert stores a copy of a claim, assigning an ID if it has none; the caller holds r.mu
+func (r *ClaimRepository) insert(key string, claim *model.Claim) {
+	stored := *claim
+	if stored.ID.IsZero() {
+		stored.ID = primitive.NewObjectID()
+	}
+	r.claims[key] = &stored
+}
+
+func (r *ClaimRepository) GetClaimedUserIDs(_ context.Context, couponID interface{}, userIDs []string, since time.Time) (map[string]bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	claimed := make(map[string]bool)
+	for _, userID := range userIDs {
+		if claim, ok := r.claims[claimKey(userID, couponID)]; ok && !claim.CreatedAt.Before(since) {
+			claimed[userID] = true
+		}
+	}
+	return claimed, nil
+}
+
+func (r *ClaimRepository) DeleteClaim(_ context.Context, userID string, couponID interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := claimKey(userID, couponID)
+	if _, ok := r.claims[key]; !ok {
+		return apperrors.ErrClaimNotFound
+	}
+	delete(r.claims, key)
+	return nil
+}
+
+func (r *ClaimRepository) DeleteClaimByID(_ context.Context, claimID interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, claim := range r.claims {
+		if claim.ID == claimID.(primitive.ObjectID) {
+			delete(r.claims, key)
+			return nil
+		}
+	}
+	return apperrors.ErrClaimNotFound
+}
+
+func (r *ClaimRepository) GetClaimsByCouponName(_ context.Context, couponName string) ([]*model.Claim, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var claims []*model.Claim
+	for _, claim := range r.claims {
+		if claim.CouponName == couponName {
+			c := *claim
+			claims = append(claims, &c)
+		}
+	}
+	return claims, nil
+}
+
+func (r *ClaimRepository) CountClaimsByLease(_ context.Context, leaseID interface{}) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var count int64
+	for _, claim := range r.claims {
+		if claim.LeaseID != nil && *claim.LeaseID == leaseID.(primitive.ObjectID) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *ClaimRepository) ListEventPending(_ context.Context, before time.Time, limit int) ([]*model.Claim, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var claims []*model.Claim
+	for _, claim := range r.claims {
+		if claim.EventPending && claim.CreatedAt.Before(before) {
+			c := *claim
+			claims = append(claims, &c)
+		}
+	}
+	sort.Slice(claims, func(i, j int) bool { return claims[i].CreatedAt.Before(claims[j].CreatedAt) })
+	if len(claims) > limit {
+		claims = claims[:limit]
+	}
+	return claims, nil
+}
+
+func (r *ClaimRepository) ClearEventPending(_ context.Context, claimIDs []interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, id := range claimIDs {
+		for _, claim := range r.claims {
+			if claim.ID == id.(primitive.ObjectID) {
+				claim.EventPending = false
+			}
+		}
+	}
+	return nil
+}
+
+func (r *ClaimRepository) HasUserClaimed(_ context.Context, userID string, couponID interface{}) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.claims[claimKey(userID, couponID)]
+	return ok, nil
+}
+
+// Count returns the number of claims on a coupon
+func (r *ClaimRepository) Count(couponID primitive.ObjectID) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, claim := range r.claims {
+		if claim.CouponID == couponID {
+			n++
+		}
+	}
+	return n
+}
+
+// LeaseRepository is an in-memory repository.LeaseRepository
+type LeaseRepository struct {
+	mu     sync.Mutex
+	leases map[primitive.ObjectID]*model.StockLease
+}
+
+func NewLeaseRepository() *LeaseRepository {
+	return &LeaseRepository{leases: make(map[primitive.ObjectID]*model.StockLease)}
+}
+
+func (r *LeaseRepository) CreateLease(_ context.Context, lease *model.StockLease) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if lease.ID.IsZero() {
+		lease.ID = primitive.NewObjectID()
+	}
+	stored := *lease
+	r.leases[lease.ID] = &stored
+	return nil
+}
+
+func (r *LeaseRepository) AddUsed(_ context.Context, leaseID interface{}, n int32) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if l, ok := r.leases[leaseID.(primitive.ObjectID)]; ok {
+		l.Used += n
+	}
+	return nil
+}
+
+func (r *LeaseRepository) Heartbeat(_ context.Context, owner string, leaseIDs []interface{}, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, id := range leaseIDs {
+		if l, ok := r.leases[id.(primitive.ObjectID)]; ok && l.Owner == owner && l.Status == model.LeaseStatusActive {
+			l.ExpiresAt = expiresAt
+		}
+	}
+	return nil
+}
+
+func (r *LeaseRepository) FinishLease(_ context.Context, leaseID interface{}, status string, returned int32) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	l, ok := r.leases[leaseID.(primitive.ObjectID)]
+	if !ok || l.Status != model.LeaseStatusActive {
+		return false, nil
+	}
+	l.Status = status
+	l.Returned = returned
+	return true, nil
+}
+
+func (r *LeaseRepository) FindExpired(_ context.Context, before time.Time) ([]*model.StockLease, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	leases := make([]*model.StockLease, 0)
+	for _, l := range r.leases {
+		if l.Status == model.LeaseStatusActive && l.ExpiresAt.Before(before) {
+			lease := *l
+			leases = append(leases, &lease)
+		}
+	}
+	return leases, nil
+}
+
+// Leases returns copies of all leases
+func (r *LeaseRepository) Leases() []*model.StockLease {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	leases := make([]*model.StockLease, 0, len(r.leases))
+	for _, l := range r.leases {
+		lease := *l
+		leases = append(leases, &lease)
+	}
+	return leases
+}
@@ -0,0 +1,676 @@
+package repository
+
+import (
+	"context"
+	"coupon-system/internal/model"
+	"coupon-system/internal/resilience"
+	apperrors "coupon-system/pkg/errors"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// guarded runs one repository call through the guard
+// Transient failures that remain after retrying, and calls rejected by the
+// open breaker, are reported as ErrDatabaseUnavailable; other errors
+// (not found, already claimed, ...) are returned unchanged.
+//
+// Inside a transaction the call is not retried on its own and its errors are
+// returned as they are: the driver retries the whole transaction on errors
+// labelled TransientTransactionError, and Transactor reports what remains.
+func guarded(ctx context.Context, guard *resilience.Guard, op string, idempotent bool, fn func(ctx context.Context) error) error {
+	if mongo.SessionFromContext(ctx) != nil {
+		err := guard.Do(ctx, false, fn)
+		if errors.Is(err, resilience.ErrCircuitOpen) {
+			return apperrors.ErrDatabaseUnavailable
+		}
+		return err
+	}
+
+	err := guard.Do(ctx, idempotent, fn)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, resilience.ErrCircuitOpen):
+		return apperrors.ErrDatabaseUnavailable
+	case resilience.IsUnhealthy(err):
+		log.Printf("Database: %s failed: %v", op, err)
+		return apperrors.ErrDatabaseUnavailable
+	default:
+		return err
+	}
+}
+
+// resilientCouponRepository retries and fails fast around a CouponRepository
+// Lookups and SetActive are retried; creates and stock changes are not, since
+// an attempt that timed out may already have been applied.
+type resilientCouponRepository struct {
+	inner CouponRepository
+	guard *resilience.Guard
+}
+
+// NewResilientCouponRepository wraps a coupon repository with the guard
+func NewResilientCouponRepository(inner CouponRepository, guard *resilience.Guard) CouponRepository {
+	return &resilientCouponRepository{inner: inner, guard: guard}
+}
+
+func (r *resilientCouponRepository) CreateCoupon(ctx context.Context, coupon *model.Coupon) error {
+	return guarded(ctx, r.guard, "CreateCoupon", false, func(ctx context.Context) error {
+		return r.inner.CreateCoupon(ctx, coupon)
+	})
+}
+
+func (r *resilientCouponRepository) GetCouponByName(ctx context.Context, name string) (*model.Coupon, error) {
+	var coupon *model.Coupon
+	err := guarded(ctx, r.guard, "GetCouponByName", true, func(ctx context.Context) (err error) {
+		coupon, err = r.inner.GetCouponByName(ctx, name)
+		return err
+	})
+	return coupon, err
+}
+
+func (r *resilientCouponRepository) DecrementStock(ctx context.Context, couponID interface{}, amount int32) error {
+	return guarded(ctx, r.guard, "DecrementStock", false, func(ctx context.Context) error {
+		return r.inner.DecrementStock(ctx, couponID, amount)
+	})
+}
+
+func (r *resilientCouponRepository) ReserveStock(ctx context.Context, couponID interface{}, max int32) (int32, error) {
+	var reserved int32
+	err := guarded(ctx, r.guard, "ReserveStock", false, func(ctx context.Context) (err error) {
+		reserved, err = r.inner.ReserveStock(ctx, couponID, max)
+		return err
+	})
+	return reserved, err
+}
+
+func (r *resilientCouponRepository) IncrementStock(ctx context.Context, couponID interface{}, amount int32) error {
+	return guarded(ctx, r.guard, "IncrementStock", false, func(ctx context.Context) error {
+		return r.inner.IncrementStock(ctx, couponID, amount)
+	})
+}
+
+func (r *resilientCouponRepository) SetActive(ctx context.Context, couponID interface{}, active bool) error {
+	return guarded(ctx, r.guard, "SetActive", true, func(ctx context.Context) error {
+		return r.inner.SetActive(ctx, couponID, active)
+	})
+}
+
+func (r *resilientCouponRepository) ListCoupons(ctx context.Context) ([]*model.Coupon, error) {
+	var coupons []*model.Coupon
+	err := guarded(ctx, r.guard, "ListCoupons", true, func(ctx context.Context) (err error) {
+		coupons, err = r.inner.ListCoupons(ctx)
+		return err
+	})
+	return coupons, err
+}
+
+// resilientClaimRepository retries and fails fast around a ClaimRepository
+// Reads are retried. Writes are not: a retried upsert or delete whose first
+// attempt was applied would report "already claimed" or "not found".
+type resilientClaimRepository struct {
+	inner ClaimRepository
+	guard *resilience.Guard
+}
+
+// NewResilientClaimRepository wraps a claim repository with the guard
+func NewResilientClaimRepository(inner ClaimRepository, guard *resilience.Guard) ClaimRepository {
+	return &resilientClaimRepository{inner: inner, guard: guard}
+}
+
+func (r *resilientClaimRepository) CreateClaim(ctx context.Context, claim *model.Claim) error {
+	return guarded(ctx, r.guard, "CreateClaim", false, func(ctx context.Context) error {
+		return r.inner.CreateClaim(ctx, claim)
+	})
+}
+
+func (r *resilientClaimRepository) CreateClaimIfNotExists(ctx context.Context, claim *model.Claim) (bool, error) {
+	var created bool
+	err := guarded(ctx, r.guard, "CreateClaimIfNotExists", false, func(ctx context.Context) (err error) {
+		created, err = r.inner.CreateClaimIfNotExists(ctx, claim)
+		return err
+	})
+	return created, err
+}
+
+func (r *resilientClaimRepository) CreateClaimsIfNotExist(ctx context.Context, claims []*model.Claim) ([]bool, error) {
+	var created []bool
+	err := guarded(ctx, r.guard, "CreateClaimsIfNotExist", false, func(ctx context.Context) (err error) {
+		created, err = r.inner.CreateClaimsIfNotExist(ctx, claims)
+		return err
+	})
+	return created, err
+}
+
+func (r *resilientClaimRepository) GetClaimedUserIDs(ctx context.Context, couponID interface{}, userIDs []string, since time.Time) (map[string]bool, error) {
+	var claimed map[string]bool
+	err := guarded(ctx, r.guard, "GetClaimedUserIDs", true, func(ctx context.Context) (err error) {
+		claimed, err = r.inner.GetClaimedUserIDs(ctx, couponID, userIDs, since)
+		return err
+	})
+	return claimed, err
+}
+
+func (r *resilientClaimRepository) DeleteClaim(ctx context.Context, userID string, couponID interface{}) error {
+	return guarded(ctx, r.guard, "DeleteClaim", false, func(ctx context.Context) error {
+		return r.inner.DeleteClaim(ctx, userID, couponID)
+	})
+}
+
+// DeleteClaimByID is retried: deleting by ID twice only reports ErrClaimNotFound,
+// which callers undoing their own write treat as done
+func (r *resilientClaimRepository) DeleteClaimByID(ctx context.Context, claimID interface{}) error {
+	return guarded(ctx, r.guard, "DeleteClaimByID", true, func(ctx context.Context) error {
+		return r.inner.DeleteClaimByID(ctx, claimID)
+	})
+}
+
+func (r *resilientClaimRepository) GetClaimsByCouponName(ctx context.Context, couponName string) ([]*model.Claim, error) {
+	var claims []*model.Claim
+	err := guarded(ctx, r.guard, "GetClaimsByCouponName", true, func(ctx context.Context) (err error) {
+		claims, err = r.inner.GetClaimsByCouponName(ctx, couponName)
+		return err
+	})
+	return claims, err
+}
+
+func (r *resilientClaimRepository) CountClaimsByLease(ctx context.Context, leaseID interface{}) (int64, error) {
+	var count int64
+	err := guarded(ctx, r.guard, "CountClaimsByLease", true, func(ctx context.Context) (err error) {
+		count, err = r.inner.CountClaimsByLease(ctx, leaseID)
+		return err
+	})
+	return count, err
+}
+
+func (r *resilientClaimRepository) ListEventPending(ctx context.Context, before time.Time, limit int) ([]*model.Claim, error) {
+	var claims []*model.Claim
+	err := guarded(ctx, r.guard, "ListEventPending", true, func(ctx context.Context) (err error) {
+		claims, err = r.inner.ListEventPending(ctx, before, limit)
+		return err
+	})
+	return claims, err
+}
+
+// ClearEventPending is retried: removing the mark twice changes nothing
+func (r *resilientClaimRepository) ClearEventPending(ctx context.Context, claimIDs []interface{}) error {
+	return guarded(ctx, r.guard, "ClearEventPending", true, func(ctx context.Context) error {
+		return r.inner.ClearEventPending(ctx, claimIDs)
+	})
+}
+
+func (r *resilientClaimRepository) HasUserClaimed(ctx context.Context, userID string, couponID interface{}) (bool, error) {
+	var claimed bool
+	err := guarded(ctx, r.guard, "HasUserClaimed", true, func(ctx context.Context) (err error) {
+		claimed, err = r.inner.HasUserClaimed(ctx, userID, couponID)
+		return err
+	})
+	return claimed, err
+}
+
+// resilientWaitlistRepository retries and fails fast around a WaitlistRepository
+// Lookups and SetStatus, which sets absolute values, are retried; joins, leaves
+// and pops are not.
+type resilientWaitlistRepository struct {
+	inner WaitlistRepository
+	guard *resilience.Guard
+}
+
+// NewResilientWaitlistRepository wraps a waitlist repository with the guard
+func NewResilientWaitlistRepository(inner WaitlistRepository, guard *resilience.Guard) WaitlistRepository {
+	return &resilientWaitlistRepository{inner: inner, guard: guard}
+}
+
+func (r *resilientWaitlistRepository) Join(ctx context.Context, entry *model.WaitlistEntry) error {
+	return guarded(ctx, r.guard, "Join", false, func(ctx context.Context) error {
+		return r.inner.Join(ctx, entry)
+	})
+}
+
+func (r *resilientWaitlistRepository) Leave(ctx context.Context, couponID interface{}, userID string) error {
+	return guarded(ctx, r.guard, "Leave", false, func(ctx context.Context) error {
+		return r.inner.Leave(ctx, couponID, userID)
+	})
+}
+
+func (r *resilientWaitlistRepository) GetEntry(ctx context.Context, couponID interface{}, userID string) (*model.WaitlistEntry, error) {
+	var entry *model.WaitlistEntry
+	err := guarded(ctx, r.guard, "GetEntry", true, func(ctx context.Context) (err error) {
+		entry, err = r.inner.GetEntry(ctx, couponID, userID)
+		return err
+	})
+	return entry, err
+}
+
+func (r *resilientWaitlistRepository) Position(ctx context.Context, entry *model.WaitlistEntry) (int64, error) {
+	var position int64
+	err := guarded(ctx, r.guard, "Position", true, func(ctx context.Context) (err error) {
+		position, err = r.inner.Position(ctx, entry)
+		return err
+	})
+	return position, err
+}
+
+func (r *resilientWaitlistRepository) PopNext(ctx context.Context, couponID interface{}) (*model.WaitlistEntry, error) {
+	var entry *model.WaitlistEntry
+	err := guarded(ctx, r.guard, "PopNext", false, func(ctx context.Context) (err error) {
+		entry, err = r.inner.PopNext(ctx, couponID)
+		return err
+	})
+	return entry, err
+}
+
+func (r *resilientWaitlistRepository) WaitingCoupons(ctx context.Context) ([]string, error) {
+	var names []string
+	err := guarded(ctx, r.guard, "WaitingCoupons", true, func(ctx context.Context) (err error) {
+		names, err = r.inner.WaitingCoupons(ctx)
+		return err
+	})
+	return names, err
+}
+
+func (r *resilientWaitlistRepository) SetStatus(ctx context.Context, entry *model.WaitlistEntry, status string) error {
+	return guarded(ctx, r.guard, "SetStatus", true, func(ctx context.Context) error {
+		return r.inner.SetStatus(ctx, entry, status)
+	})
+}
+
+// resilientRaffleRepository retries and fails fast around a RaffleRepository
+// Reads are retried; entries and draws are not.
+type resilientRaffleRepository struct {
+	inner RaffleRepository
+	guard *resilience.Guard
+}
+
+// NewResilientRaffleRepository wraps a raffle repository with the guard
+func NewResilientRaffleRepository(inner RaffleRepository, guard *resilience.Guard) RaffleRepository {
+	return &resilientRaffleRepository{inner: inner, guard: guard}
+}
+
+func (r *resilientRaffleRepository) AddEntry(ctx context.Context, entry *model.RaffleEntry) error {
+	return guarded(ctx, r.guard, "AddEntry", false, func(ctx context.Context) error {
+		return r.inner.AddEntry(ctx, entry)
+	})
+}
+
+func (r *resilientRaffleRepository) ListEntrants(ctx context.Context, couponID interface{}) ([]string, error) {
+	var entrants []string
+	err := guarded(ctx, r.guard, "ListEntrants", true, func(ctx context.Context) (err error) {
+		entrants, err = r.inner.ListEntrants(ctx, couponID)
+		return err
+	})
+	return entrants, err
+}
+
+func (r *resilientRaffleRepository) CreateDraw(ctx context.Context, draw *model.RaffleDraw) error {
+	return guarded(ctx, r.guard, "CreateDraw", false, func(ctx context.Context) error {
+		return r.inner.CreateDraw(ctx, draw)
+	})
+}
+
+func (r *resilientRaffleRepository) GetDraw(ctx context.Context, couponID interface{}) (*model.RaffleDraw, error) {
+	var draw *model.RaffleDraw
+	err := guarded(ctx, r.guard, "GetDraw", true, func(ctx context.Context) (err error) {
+		draw, err = r.inner.GetDraw(ctx, couponID)
+		return err
+	})
+	return draw, err
+}
+
+func (r *resilientRaffleRepository) CompleteDraw(ctx context.Context, draw *model.RaffleDraw) error {
+	return guarded(ctx, r.guard, "CompleteDraw", false, func(ctx context.Context) error {
+		return r.inner.CompleteDraw(ctx, draw)
+	})
+}
+
+// resilientJobRepository retries and fails fast around a JobRepository
+// Reads, progress updates by the owner and ReleaseJobs are retried; creating,
+// claiming, finishing and cancelling jobs are not.
+type resilientJobRepository struct {
+	inner JobRepository
+	guard *resilience.Guard
+}
+
+// NewResilientJobRepository wraps a job repository with the guard
+func NewResilientJobRepository(inner JobRepository, guard *resilience.Guard) JobRepository {
+	return &resilientJobRepository{inner: inner, guard: guard}
+}
+
+func (r *resilientJobRepository) CreateJob(ctx context.Context, job *model.Job) error {
+	return guarded(ctx, r.guard, "CreateJob", false, func(ctx context.Context) error {
+		return r.inner.CreateJob(ctx, job)
+	})
+}
+
+func (r *resilientJobRepository) GetJob(ctx context.Context, id string) (*model.Job, error) {
+	var job *model.Job
+	err := guarded(ctx, r.guard, "GetJob", true, func(ctx context.Context) (err error) {
+		job, err = r.inner.GetJob(ctx, id)
+		return err
+	})
+	return job, err
+}
+
+func (r *resilientJobRepository) ClaimNextJob(ctx context.Context, owner string, leaseUntil time.Time) (*model.Job, error) {
+	var job *model.Job
+	err := guarded(ctx, r.guard, "ClaimNextJob", false, func(ctx context.Context) (err error) {
+		job, err = r.inner.ClaimNextJob(ctx, owner, leaseUntil)
+		return err
+	})
+	return job, err
+}
+
+func (r *resilientJobRepository) UpdateProgress(ctx context.Context, job *model.Job, owner string, leaseUntil time.Time) (bool, error) {
+	var owned bool
+	err := guarded(ctx, r.guard, "UpdateProgress", true, func(ctx context.Context) (err error) {
+		owned, err = r.inner.UpdateProgress(ctx, job, owner, leaseUntil)
+		return err
+	})
+	return owned, err
+}
+
+func (r *resilientJobRepository) FinishJob(ctx context.Context, job *model.Job, owner string) error {
+	return guarded(ctx, r.guard, "FinishJob", false, func(ctx context.Context) error {
+		return r.inner.FinishJob(ctx, job, owner)
+	})
+}
+
+func (r *resilientJobRepository) RequestCancel(ctx context.Context, id string) (*model.Job, error) {
+	var job *model.Job
+	err := guarded(ctx, r.guard, "RequestCancel", false, func(ctx context.Context) (err error) {
+		job, err = r.inner.RequestCancel(ctx, id)
+		return err
+	})
+	return job, err
+}
+
+func (r *resilientJobRepository) ReleaseJobs(ctx context.Context, owner string) error {
+	return guarded(ctx, r.guard, "ReleaseJobs", true, func(ctx context.Context) error {
+		return r.inner.ReleaseJobs(ctx, owner)
+	})
+}
+
+// resilientLeaseRepository retries and fails fast around a LeaseRepository
+// Heartbeats and FindExpired are retried; creating, using and finishing leases
+// are not.
+type resilientLeaseRepository struct {
+	inner LeaseRepository
+	guard *resilience.Guard
+}
+
+// NewResilientLeaseRepository wraps a lease repository with the guard
+func NewResilientLeaseRepository(inner LeaseRepository, guard *resilience.Guard) LeaseRepository {
+	return &resilientLeaseRepository{inner: inner, guard: guard}
+}
+
+func (r *resilientLeaseRepository) CreateLease(ctx context.Context, lease *model.StockLease) error {
+	return guarded(ctx, r.guard, "CreateLease", false, func(ctx context.Context) error {
+		return r.inner.CreateLease(ctx, lease)
+	})
+}
+
+func (r *resilientLeaseRepository) AddUsed(ctx context.Context, leaseID interface{}, n int32) error {
+	return guarded(ctx, r.guard, "AddUsed", false, func(ctx context.Context) error {
+		return r.inner.AddUsed(ctx, leaseID, n)
+	})
+}
+
+func (r *resilientLeaseRepository) Heartbeat(ctx context.Context, owner string, leaseIDs []interface{}, expiresAt time.Time) error {
+	return guarded(ctx, r.guard, "Heartbeat", true, func(ctx context.Context) error {
+		return r.inner.Heartbeat(ctx, owner, leaseIDs, expiresAt)
+	})
+}
+
+func (r *resilientLeaseRepository) FinishLease(ctx context.Context, leaseID interface{}, status string, returned int32) (bool, error) {
+	var finished bool
+	err := guarded(ctx, r.guard, "FinishLease", false, func(ctx context.Context) (err error) {
+		finished, err = r.inner.FinishLease(ctx, leaseID, status, returned)
+		return err
+	})
+	return finished, err
+}
+
+func (r *resilientLeaseRepository) FindExpired(ctx context.Context, before time.Time) ([]*model.StockLease, error) {
+	var leases []*model.StockLease
+	err := guarded(ctx, r.guard, "FindExpired", true, func(ctx context.Context) (err error) {
+		leases, err = r.inner.FindExpired(ctx, before)
+		return err
+	})
+	return leases, err
+}
+
+// resilientOutboxRepository retries and fails fast around a OutboxRepository
+// Reads, marking events delivered or published and the relay lease calls are
+// retried; appending events and recording failures are not.
+type resilientOutboxRepository struct {
+	inner OutboxRepository
+	guard *resilience.Guard
+}
+
+// NewResilientOutboxRepository wraps an outbox repository with the guard
+func NewResilientOutboxRepository(inner OutboxRepository, guard *resilience.Guard) OutboxRepository {
+	return &resilientOutboxRepository{inner: inner, guard: guard}
+}
+
+func (r *resilientOutboxRepository) Append(ctx context.Context, events ...*model.OutboxEvent) error {
+	return guarded(ctx, r.guard, "Append", false, func(ctx context.Context) error {
+		return r.inner.Append(ctx, events...)
+	})
+}
+
+func (r *resilientOutboxRepository) Unpublished(ctx context.Context, limit int) ([]*model.OutboxEvent, error) {
+	var events []*model.OutboxEvent
+	err := guarded(ctx, r.guard, "Unpublished", true, func(ctx context.Context) (err error) {
+		events, err = r.inner.Unpublished(ctx, limit)
+		return err
+	})
+	return events, err
+}
+
+func (r *resilientOutboxRepository) MarkDelivered(ctx context.Context, eventID interface{}, sink string) error {
+	return guarded(ctx, r.guard, "MarkDelivered", true, func(ctx context.Context) error {
+		return r.inner.MarkDelivered(ctx, eventID, sink)
+	})
+}
+
+func (r *resilientOutboxRepository) MarkPublished(ctx context.Context, eventID interface{}) error {
+	return guarded(ctx, r.guard, "MarkPublished", true, func(ctx context.Context) error {
+		return r.inner.MarkPublished(ctx, eventID)
+	})
+}
+
+func (r *resilientOutboxRepository) RecordFailure(ctx context.Context, eventID interface{}, message string, nextAttemptAt time.Time) error {
+	return guarded(ctx, r.guard, "RecordFailure", false, func(ctx context.Context) error {
+		return r.inner.RecordFailure(ctx, eventID, message, nextAttemptAt)
+	})
+}
+
+func (r *resilientOutboxRepository) AcquireRelayLease(ctx context.Context, owner string, ttl time.Duration) (bool, error) {
+	var acquired bool
+	err := guarded(ctx, r.guard, "AcquireRelayLease", true, func(ctx context.Context) (err error) {
+		acquired, err = r.inner.AcquireRelayLease(ctx, owner, ttl)
+		return err
+	})
+	return acquired, err
+}
+
+func (r *resilientOutboxRepository) ReleaseRelayLease(ctx context.Context, owner string) error {
+	return guarded(ctx, r.guard, "ReleaseRelayLease", true, func(ctx context.Context) error {
+		return r.inner.ReleaseRelayLease(ctx, owner)
+	})
+}
+
+// resilientWebhookRepository retries and fails fast around a WebhookRepository
+// Reads are retried; subscription changes, deliveries and replays are not.
+type resilientWebhookRepository struct {
+	inner WebhookRepository
+	guard *resilience.Guard
+}
+
+// NewResilientWebhookRepository wraps a webhook repository with the guard
+func NewResilientWebhookRepository(inner WebhookRepository, guard *resilience.Guard) WebhookRepository {
+	return &resilientWebhookRepository{inner: inner, guard: guard}
+}
+
+func (r *resilientWebhookRepository) CreateSubscription(ctx context.Context, sub *model.WebhookSubscription) error {
+	return guarded(ctx, r.guard, "CreateSubscription", false, func(ctx context.Context) error {
+		return r.inner.CreateSubscription(ctx, sub)
+	})
+}
+
+func (r *resilientWebhookRepository) GetSubscription(ctx context.Context, id string) (*model.WebhookSubscription, error) {
+	var sub *model.WebhookSubscription
+	err := guarded(ctx, r.guard, "GetSubscription", true, func(ctx context.Context) (err error) {
+		sub, err = r.inner.GetSubscription(ctx, id)
+		return err
+	})
+	return sub, err
+}
+
+func (r *resilientWebhookRepository) ListSubscriptions(ctx context.Context) ([]*model.WebhookSubscription, error) {
+	var subs []*model.WebhookSubscription
+	err := guarded(ctx, r.guard, "ListSubscriptions", true, func(ctx context.Context) (err error) {
+		subs, err = r.inner.ListSubscriptions(ctx)
+		return err
+	})
+	return subs, err
+}
+
+func (r *resilientWebhookRepository) DeleteSubscription(ctx context.Context, id string) error {
+	return guarded(ctx, r.guard, "DeleteSubscription", false, func(ctx context.Context) error {
+		return r.inner.DeleteSubscription(ctx, id)
+	})
+}
+
+func (r *resilientWebhookRepository) EnqueueDeliveries(ctx context.Context, deliveries ...*model.WebhookDelivery) error {
+	return guarded(ctx, r.guard, "EnqueueDeliveries", false, func(ctx context.Context) error {
+		return r.inner.EnqueueDeliveries(ctx, deliveries...)
+	})
+}
+
+func (r *resilientWebhookRepository) ClaimDueDelivery(ctx context.Context, owner string, leaseUntil time.Time) (*model.WebhookDelivery, error) {
+	var delivery *model.WebhookDelivery
+	err := guarded(ctx, r.guard, "ClaimDueDelivery", false, func(ctx context.Context) (err error) {
+		delivery, err = r.inner.ClaimDueDelivery(ctx, owner, leaseUntil)
+		return err
+	})
+	return delivery, err
+}
+
+func (r *resilientWebhookRepository) RecordAttempt(ctx context.Context, delivery *model.WebhookDelivery, owner string, attempt model.WebhookAttempt) error {
+	return guarded(ctx, r.guard, "RecordAttempt", false, func(ctx context.Context) error {
+		return r.inner.RecordAttempt(ctx, delivery, owner, attempt)
+	})
+}
+
+func (r *resilientWebhookRepository) ListDeliveries(ctx context.Context, subscriptionID string, status model.WebhookDeliveryStatus, limit int) ([]*model.WebhookDelivery, error) {
+	var deliveries []*model.WebhookDelivery
+	err := guarded(ctx, r.guard, "ListDeliveries", true, func(ctx context.Context) (err error) {
+		deliveries, err = r.inner.ListDeliveries(ctx, subscriptionID, status, limit)
+		return err
+	})
+	return deliveries, err
+}
+
+func (r *resilientWebhookRepository) ReplayDelivery(ctx context.Context, subscriptionID, deliveryID string) (*model.WebhookDelivery, error) {
+	var delivery *model.WebhookDelivery
+	err := guarded(ctx, r.guard, "ReplayDelivery", false, func(ctx context.Context) (err error) {
+		delivery, err = r.inner.ReplayDelivery(ctx, subscriptionID, deliveryID)
+		return err
+	})
+	return delivery, err
+}
+
+func (r *resilientWebhookRepository) ReplayDead(ctx context.Context, subscriptionID string) (int64, error) {
+	var replayed int64
+	err := guarded(ctx, r.guard, "ReplayDead", false, func(ctx context.Context) (err error) {
+		replayed, err = r.inner.ReplayDead(ctx, subscriptionID)
+		return err
+	})
+	return replayed, err
+}
+
+// resilientAPIKeyRepository retries and fails fast around a APIKeyRepository
+// Reads and TouchKeys are retried; creating, revoking and replacing keys and
+// writing the audit log are not.
+type resilientAPIKeyRepository struct {
+	inner APIKeyRepository
+	guard *resilience.Guard
+}
+
+// NewResilientAPIKeyRepository wraps an api key repository with the guard
+func NewResilientAPIKeyRepository(inner APIKeyRepository, guard *resilience.Guard) APIKeyRepository {
+	return &resilientAPIKeyRepository{inner: inner, guard: guard}
+}
+
+func (r *resilientAPIKeyRepository) CreateKey(ctx context.Context, key *model.APIKey) error {
+	return guarded(ctx, r.guard, "CreateKey", false, func(ctx context.Context) error {
+		return r.inner.CreateKey(ctx, key)
+	})
+}
+
+func (r *resilientAPIKeyRepository) GetKey(ctx context.Context, id string) (*model.APIKey, error) {
+	var key *model.APIKey
+	err := guarded(ctx, r.guard, "GetKey", true, func(ctx context.Context) (err error) {
+		key, err = r.inner.GetKey(ctx, id)
+		return err
+	})
+	return key, err
+}
+
+func (r *resilientAPIKeyRepository) GetKeyByHash(ctx context.Context, hash string) (*model.APIKey, error) {
+	var key *model.APIKey
+	err := guarded(ctx, r.guard, "GetKeyByHash", true, func(ctx context.Context) (err error) {
+		key, err = r.inner.GetKeyByHash(ctx, hash)
+		return err
+	})
+	return key, err
+}
+
+func (r *resilientAPIKeyRepository) ListKeys(ctx context.Context) ([]*model.APIKey, error) {
+	var keys []*model.APIKey
+	err := guarded(ctx, r.guard, "ListKeys", true, func(ctx context.Context) (err error) {
+		keys, err = r.inner.ListKeys(ctx)
+		return err
+	})
+	return keys, err
+}
+
+func (r *resilientAPIKeyRepository) RevokeKey(ctx context.Context, id string, at time.Time) (*model.APIKey, error) {
+	var key *model.APIKey
+	err := guarded(ctx, r.guard, "RevokeKey", false, func(ctx context.Context) (err error) {
+		key, err = r.inner.RevokeKey(ctx, id, at)
+		return err
+	})
+	return key, err
+}
+
+func (r *resilientAPIKeyRepository) ReplaceKey(ctx context.Context, oldID string, expiresAt time.Time, successor *model.APIKey) error {
+	return guarded(ctx, r.guard, "ReplaceKey", false, func(ctx context.Context) error {
+		return r.inner.ReplaceKey(ctx, oldID, expiresAt, successor)
+	})
+}
+
+func (r *resilientAPIKeyRepository) TouchKeys(ctx context.Context, ids []primitive.ObjectID, at time.Time) error {
+	return guarded(ctx, r.guard, "TouchKeys", true, func(ctx context.Context) error {
+		return r.inner.TouchKeys(ctx, ids, at)
+	})
+}
+
+func (r *resilientAPIKeyRepository) RecordUsage(ctx context.Context, entries []*model.APIKeyAuditEntry) error {
+	return guarded(ctx, r.guard, "RecordUsage", false, func(ctx context.Context) error {
+		return r.inner.RecordUsage(ctx, entries)
+	})
+}
+
+func (r *resilientAPIKeyRepository) ListUsage(ctx context.Context, keyID string, limit int) ([]*model.APIKeyAuditEntry, error) {
+	var entries []*model.APIKeyAuditEntry
+	err := guarded(ctx, r.guard, "ListUsage", true, func(ctx context.Context) (err error) {
+		entries, err = r.inner.ListUsage(ctx, keyID, limit)
+		return err
+	})
+	return entries, err
+}
@@ -0,0 +1,299 @@
+package repository
+
+import (
+	"context"
+	"coupon-system/internal/model"
+	apperrors "coupon-system/pkg/errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// stockShard is one of the counter documents holding part of a coupon's stock
+type stockShard struct {
+	CouponID  primitive.ObjectID `bson:"coupon_id"`
+	Shard     int32              `bson:"shard"`
+	Remaining int32              `bson:"remaining"`
+}
+
+// shardedCouponRepository decorates a CouponRepository with sharded stock counters
+// For coupons with StockShards > 0, remaining stock lives in N documents of the
+// coupon_stock_shards collection instead of coupons.remaining_amount. Claims
+// decrement a random shard and fall back to the others when it is empty, so
+// concurrent claims spread over N documents instead of queueing on one.
+// Coupons without shards are passed straight through.
+type shardedCouponRepository struct {
+	CouponRepository
+	coupons       *mongo.Collection
+	shards        *mongo.Collection
+	defaultShards int32
+
+	shardCounts sync.Map // coupon ID (primitive.ObjectID) -> int32; shard counts never change
+}
+
+// NewShardedCouponRepository wraps a coupon repository with sharded stock counters
+// New coupons that do not ask for a shard count get defaultShards (0 disables sharding)
+func NewShardedCouponRepository(db *mongo.Database, inner CouponRepository, defaultShards int32) CouponRepository {
+	return &shardedCouponRepository{
+		CouponRepository: inner,
+		coupons:          db.Collection("coupons"),
+		shards:           db.Collection("coupon_stock_shards"),
+		defaultShards:    defaultShards,
+	}
+}
+
+// CreateCoupon creates a coupon and, if sharded, spreads its stock over the shards
+func (r *shardedCouponRepository) CreateCoupon(ctx context.Context, coupon *model.Coupon) error {
+	if coupon.StockShards == 0 {
+		coupon.StockShards = r.defaultShards
+	}
+	if coupon.StockShards <= 0 {
+		return r.CouponRepository.CreateCoupon(ctx, coupon)
+	}
+	if coupon.ID.IsZero() {
+		coupon.ID = primitive.NewObjectID()
+	}
+
+	// Shards go in first so the coupon never becomes visible without its stock
+	remaining := coupon.RemainingAmount
+	docs := make([]interface{}, 0, coupon.StockShards)
+	for i, share := range spreadStock(remaining, coupon.StockShards, 0) {
+		docs = append(docs, stockShard{CouponID: coupon.ID, Shard: int32(i), Remaining: share})
+	}
+	if _, err := r.shards.InsertMany(ctx, docs); err != nil {
+		return err
+	}
+
+	// The coupon document keeps no stock of its own
+	coupon.RemainingAmount = 0
+	err := r.CouponRepository.CreateCoupon(ctx, coupon)
+	coupon.RemainingAmount = remaining
+	if err != nil {
+		_, _ = r.shards.DeleteMany(ctx, bson.M{"coupon_id": coupon.ID})
+		return err
+	}
+
+	r.shardCounts.Store(coupon.ID, coupon.StockShards)
+	return nil
+}
+
+// GetCouponByName retrieves a coupon; for sharded coupons RemainingAmount is the sum of the shards
+func (r *shardedCouponRepository) GetCouponByName(ctx context.Context, name string) (*model.Coupon, error) {
+	coupon, err := r.CouponRepository.GetCouponByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.fillRemaining(ctx, coupon); err != nil {
+		return nil, err
+	}
+
+	return coupon, nil
+}
+
+// ListCoupons retrieves all coupons with sharded stock summed up
+func (r *shardedCouponRepository) ListCoupons(ctx context.Context) ([]*model.Coupon, error) {
+	coupons, err := r.CouponRepository.ListCoupons(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, coupon := range coupons {
+		if err := r.fillRemaining(ctx, coupon); err != nil {
+			return nil, err
+		}
+	}
+
+	return coupons, nil
+}
+
+// DecrementStock takes stock from a random shard, falling back to the others in turn
+// A single decrement must fit in one shard, which is always true for claims (amount 1)
+func (r *shardedCouponRepository) DecrementStock(ctx context.Context, couponID interface{}, amount int32) error {
+	n, err := r.shardCount(ctx, couponID)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return r.CouponRepository.DecrementStock(ctx, couponID, amount)
+	}
+
+	start := rand.Int31n(n)
+	for i := int32(0); i < n; i++ {
+		result, err := r.shards.UpdateOne(
+			ctx,
+			bson.M{
+				"coupon_id": couponID,
+				"shard":     (start + i) % n,
+				"remaining": bson.M{"$gte": amount},
+			},
+			bson.M{"$inc": bson.M{"remaining": -amount}},
+		)
+		if err != nil {
+			return err
+		}
+		if result.ModifiedCount == 1 {
+			return nil
+		}
+	}
+
+	return apperrors.ErrNoStock
+}
+
+// ReserveStock takes up to max units, draining shards one at a time
+func (r *shardedCouponRepository) ReserveStock(ctx context.Context, couponID interface{}, max int32) (int32, error) {
+	n, err := r.shardCount(ctx, couponID)
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return r.CouponRepository.ReserveStock(ctx, couponID, max)
+	}
+
+	var reserved int32
+	start := rand.Int31n(n)
+	for i := int32(0); i < n && reserved < max; i++ {
+		want := max - reserved
+
+		var before stockShard
+		err := r.shards.FindOneAndUpdate(
+			ctx,
+			bson.M{
+				"coupon_id": couponID,
+				"shard":     (start + i) % n,
+				"remaining": bson.M{"$gt": 0},
+			},
+			mongo.Pipeline{
+				{{Key: "$set", Value: bson.M{
+					"remaining": bson.M{"$max": bson.A{0, bson.M{"$subtract": bson.A{"$remaining", want}}}},
+				}}},
+			},
+			options.FindOneAndUpdate().SetReturnDocument(options.Before),
+		).Decode(&before)
+		if err == mongo.ErrNoDocuments {
+			continue
+		}
+		if err != nil {
+			return reserved, err
+		}
+
+		if before.Remaining < want {
+			reserved += before.Remaining
+		} else {
+			reserved += want
+		}
+	}
+
+	return reserved, nil
+}
+
+// IncrementStock spreads returned or restocked units evenly over the shards
+// A restock into one shard would leave the others empty, and claims would pile
+// up on that shard again. The remainder goes to shards from a random one on, so
+// single units (cancelled claims) land on a random shard. The shards are updated
+// in one bulk write, which is atomic only inside a transaction.
+// The coupon's updated_at is bumped as for unsharded coupons, so a sell-out
+// after the stock came back is told apart from the previous one.
+func (r *shardedCouponRepository) IncrementStock(ctx context.Context, couponID interface{}, amount int32) error {
+	n, err := r.shardCount(ctx, couponID)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return r.CouponRepository.IncrementStock(ctx, couponID, amount)
+	}
+
+	result, err := r.coupons.UpdateOne(ctx, bson.M{"_id": couponID}, bson.M{"$set": bson.M{"updated_at": time.Now()}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return apperrors.ErrCouponNotFound
+	}
+
+	var updates []mongo.WriteModel
+	for shard, share := range spreadStock(amount, n, rand.Int31n(n)) {
+		if share == 0 {
+			continue
+		}
+		updates = append(updates, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"coupon_id": couponID, "shard": int32(shard)}).
+			SetUpdate(bson.M{"$inc": bson.M{"remaining": share}}))
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	_, err = r.shards.BulkWrite(ctx, updates)
+	return err
+}
+
+// spreadStock splits amount over n shards as evenly as possible
+// The shards from first on (wrapping around) get one unit of the remainder each.
+func spreadStock(amount, n, first int32) []int32 {
+	shares := make([]int32, n)
+	for i := int32(0); i < n; i++ {
+		shares[i] = amount / n
+	}
+	for i := int32(0); i < amount%n; i++ {
+		shares[(first+i)%n]++
+	}
+	return shares
+}
+
+// fillRemaining replaces RemainingAmount of a sharded coupon with the sum of its shards
+func (r *shardedCouponRepository) fillRemaining(ctx context.Context, coupon *model.Coupon) error {
+	if coupon.StockShards <= 0 {
+		return nil
+	}
+	r.shardCounts.Store(coupon.ID, coupon.StockShards)
+
+	cursor, err := r.shards.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"coupon_id": coupon.ID}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "remaining": bson.M{"$sum": "$remaining"}}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var totals []struct {
+		Remaining int32 `bson:"remaining"`
+	}
+	if err := cursor.All(ctx, &totals); err != nil {
+		return err
+	}
+
+	coupon.RemainingAmount = 0
+	if len(totals) > 0 {
+		coupon.RemainingAmount = totals[0].Remaining
+	}
+	return nil
+}
+
+// shardCount returns the number of shards of a coupon (0 if unsharded)
+// Shard counts are fixed at creation, so they are cached after the first lookup
+func (r *shardedCouponRepository) shardCount(ctx context.Context, couponID interface{}) (int32, error) {
+	if n, ok := r.shardCounts.Load(couponID); ok {
+		return n.(int32), nil
+	}
+
+	var coupon model.Coupon
+	err := r.coupons.FindOne(
+		ctx,
+		bson.M{"_id": couponID},
+		options.FindOne().SetProjection(bson.M{"stock_shards": 1}),
+	).Decode(&coupon)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return 0, apperrors.ErrCouponNotFound
+		}
+		return 0, err
+	}
+
+	r.shardCounts.Store(couponID, coupon.StockShards)
+	return coupon.StockShards, nil
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSpreadStock(t *testing.T) {
+	tests := []struct {
+		amount, n, first int32
+		want             []int32
+	}{
+		{amount: 16, n: 4, first: 0, want: []int32{4, 4, 4, 4}},
+		{amount: 10, n: 4, first: 0, want: []int32{3, 3, 2, 2}},
+		{amount: 10, n: 4, first: 3, want: []int32{3, 2, 2, 3}},
+		{amount: 1, n: 4, first: 2, want: []int32{0, 0, 1, 0}},
+		{amount: 0, n: 3, first: 1, want: []int32{0, 0, 0}},
+	}
+	for _, tt := range tests {
+		got := spreadStock(tt.amount, tt.n, tt.first)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("spreadStock(%d, %d, %d) = %v, want %v", tt.amount, tt.n, tt.first, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"coupon-system/internal/resilience"
+	apperrors "coupon-system/pkg/errors"
+	"log"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Transactor runs a group of repository calls atomically
+// fn receives the context to pass to those calls (a mongo.SessionContext
+// inside a transaction) and may be run more than once on transient conflicts.
+type Transactor interface {
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// mongoTransactor runs fn in a MongoDB multi-document transaction
+type mongoTransactor struct {
+	client *mongo.Client
+}
+
+// NewTransactor creates a Transactor for the client
+// Transactions need a replica set or sharded cluster; on a standalone server
+// pass supported=false and fn runs without a transaction.
+func NewTransactor(client *mongo.Client, supported bool) Transactor {
+	if !supported {
+		return NoTransactor{}
+	}
+	return &mongoTransactor{client: client}
+}
+
+// WithTransaction runs fn in a transaction, retrying it on transient errors
+// Transient failures that remain are reported as ErrDatabaseUnavailable, like
+// guarded calls outside a transaction.
+func (t *mongoTransactor) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	session, err := t.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+	if resilience.IsUnhealthy(err) || resilience.IsWriteConflict(err) {
+		log.Printf("Database: transaction failed: %v", err)
+		return apperrors.ErrDatabaseUnavailable
+	}
+	return err
+}
+
+// NoTransactor runs fn directly, without atomicity
+type NoTransactor struct{}
+
+// WithTransaction runs fn once
+func (NoTransactor) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"coupon-system/internal/metrics"
+	"coupon-system/internal/model"
+	"coupon-system/internal/repository/repotest"
+	"coupon-system/internal/resilience"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// failingCoupons fails its first DecrementStock calls with err
+type failingCoupons struct {
+	CouponRepository
+	err      error
+	failures int
+	calls    int
+}
+
+func (r *failingCoupons) DecrementStock(ctx context.Context, couponID interface{}, amount int32) error {
+	r.calls++
+	if r.calls <= r.failures {
+		return r.err
+	}
+	return r.CouponRepository.DecrementStock(ctx, couponID, amount)
+}
+
+// TestTransactionRetriesTransientErrors checks errors labelled
+// TransientTransactionError reach the driver with their label, so the
+// transaction is run again, and write conflicts do not open the breaker
+func TestTransactionRetriesTransientErrors(t *testing.T) {
+	// Sessions and transactions start on the client; nothing here needs a server
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://127.0.0.1:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+
+	transient := []string{"TransientTransactionError"}
+	for _, tt := range []struct {
+		name       string
+		err        error
+		wantClosed bool
+		threshold  int
+	}{
+		{"write conflict", mongo.CommandError{Code: 112, Name: "WriteConflict", Labels: transient}, true, 1},
+		{"step down", mongo.CommandError{Code: 10107, Name: "NotWritablePrimary", Labels: transient}, false, 5},
+	} {
+		coupons := repotest.NewCouponRepository()
+		coupon := &model.Coupon{Name: "FLASH", Amount: 5, RemainingAmount: 5, IsActive: true, CreatedAt: time.Now()}
+		if err := coupons.CreateCoupon(context.Background(), coupon); err != nil {
+			t.Fatal(err)
+		}
+		guard := resilience.NewGuard(resilience.RetryPolicy{MaxAttempts: 3}, resilience.BreakerOptions{FailureThreshold: tt.threshold}, metrics.NewRegistry())
+		repo := NewResilientCouponRepository(&failingCoupons{CouponRepository: coupons, err: tt.err, failures: 2}, guard)
+
+		runs := 0
+		err = NewTransactor(client, true).WithTransaction(context.Background(), func(ctx context.Context) error {
+			runs++
+			return repo.DecrementStock(ctx, coupon.ID, 1)
+		})
+		if err != nil {
+			t.Fatalf("%s: WithTransaction = %v, want it to succeed once the errors clear", tt.name, err)
+		}
+		if runs != 3 {
+			t.Errorf("%s: transaction ran %d times, want 3", tt.name, runs)
+		}
+		if tt.wantClosed && guard.State() != resilience.StateClosed {
+			t.Errorf("%s: breaker is %s, want closed", tt.name, guard.State())
+		}
+	}
+}
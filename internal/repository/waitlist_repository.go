@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"coupon-system/internal/model"
+)
+
+// WaitlistRepository defines the interface for coupon waitlists
+// Entries are served first-in, first-out
+type WaitlistRepository interface {
+	// Join adds a user to a coupon's waitlist
+	// Returns ErrAlreadyWaitlisted if the user is already on it
+	Join(ctx context.Context, entry *model.WaitlistEntry) error
+
+	// Leave removes a waiting user from a coupon's waitlist
+	// Returns ErrNotWaitlisted if the user is not waiting
+	Leave(ctx context.Context, couponID interface{}, userID string) error
+
+	// GetEntry retrieves a user's waitlist entry
+	// Returns ErrNotWaitlisted if the user never joined
+	GetEntry(ctx context.Context, couponID interface{}, userID string) (*model.WaitlistEntry, error)
+
+	// Position returns the 1-based position of a waiting entry
+	Position(ctx context.Context, entry *model.WaitlistEntry) (int64, error)
+
+	// PopNext atomically marks the oldest waiting entry as granting and returns it
+	// Entries stuck in granting (the worker crashed) are retried, along with
+	// any stock already reserved for them
+	// Returns (nil, nil) if nobody is waiting
+	PopNext(ctx context.Context, couponID interface{}) (*model.WaitlistEntry, error)
+
+	// WaitingCoupons returns the names of coupons with users waiting, including
+	// entries whose grant was interrupted
+	WaitingCoupons(ctx context.Context) ([]string, error)
+
+	// SetStatus moves an entry to waiting (put back), granting or granted and
+	// records whether stock is reserved for it
+	SetStatus(ctx context.Context, entry *model.WaitlistEntry, status string) error
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"coupon-system/internal/model"
+	"time"
+)
+
+// WebhookRepository defines the interface for webhook subscriptions and deliveries
+// Workers hold a lease on the delivery they are sending; a delivery whose
+// lease expires (because its worker crashed) is sent again
+type WebhookRepository interface {
+	// CreateSubscription stores a new subscription
+	CreateSubscription(ctx context.Context, sub *model.WebhookSubscription) error
+
+	// GetSubscription retrieves a subscription by its ID
+	// Returns ErrWebhookNotFound if it does not exist
+	GetSubscription(ctx context.Context, id string) (*model.WebhookSubscription, error)
+
+	// ListSubscriptions returns every subscription, oldest first
+	ListSubscriptions(ctx context.Context) ([]*model.WebhookSubscription, error)
+
+	// DeleteSubscription removes a subscription and its deliveries
+	// Returns ErrWebhookNotFound if it does not exist
+	DeleteSubscription(ctx context.Context, id string) error
+
+	// EnqueueDeliveries stores pending deliveries
+	// A delivery of an event already queued for the same subscription is dropped
+	EnqueueDeliveries(ctx context.Context, deliveries ...*model.WebhookDelivery) error
+
+	// ClaimDueDelivery atomically takes the pending delivery that is due
+	// soonest and not held by another worker, leasing it to owner
+	// Returns (nil, nil) if there is nothing to send
+	ClaimDueDelivery(ctx context.Context, owner string, leaseUntil time.Time) (*model.WebhookDelivery, error)
+
+	// RecordAttempt stores the outcome of an attempt by owner and releases the lease
+	// The delivery's Status, Attempts, NextAttemptAt and LastError are saved and
+	// the attempt is appended to its log
+	RecordAttempt(ctx context.Context, delivery *model.WebhookDelivery, owner string, attempt model.WebhookAttempt) error
+
+	// ListDeliveries returns a subscription's deliveries, newest first,
+	// optionally only those with the given status
+	ListDeliveries(ctx context.Context, subscriptionID string, status model.WebhookDeliveryStatus, limit int) ([]*model.WebhookDelivery, error)
+
+	// ReplayDelivery moves a dead delivery back to pending with fresh attempts
+	// Returns ErrDeliveryNotFound if the subscription has no such delivery and
+	// ErrDeliveryNotDead if it is not in the dead-letter queue
+	ReplayDelivery(ctx context.Context, subscriptionID, deliveryID string) (*model.WebhookDelivery, error)
+
+	// ReplayDead moves all of a subscription's dead deliveries back to pending
+	// Returns how many were replayed
+	ReplayDead(ctx context.Context, subscriptionID string) (int64, error)
+}
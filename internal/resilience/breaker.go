@@ -0,0 +1,135 @@
+package resilience
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling the database while the breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// State is the position of a circuit breaker
+type State int
+
+const (
+	StateClosed   State = iota // Calls pass through
+	StateHalfOpen              // One probe call is let through to test recovery
+	StateOpen                  // Calls fail fast
+)
+
+// String returns the state name used in logs and metrics
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half_open"
+	default:
+		return "open"
+	}
+}
+
+// BreakerOptions configures a circuit breaker
+type BreakerOptions struct {
+	FailureThreshold int           // Consecutive failures that open the breaker; 0 disables it
+	OpenTimeout      time.Duration // How long the breaker stays open before probing
+}
+
+// Breaker is a consecutive-failure circuit breaker
+//
+// After FailureThreshold failures in a row it opens and rejects calls with
+// ErrCircuitOpen for OpenTimeout. It then lets a single probe through: a
+// success closes it, a failure opens it for another OpenTimeout.
+type Breaker struct {
+	opts BreakerOptions
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewBreaker creates a closed breaker
+func NewBreaker(opts BreakerOptions) *Breaker {
+	if opts.OpenTimeout <= 0 {
+		opts.OpenTimeout = 5 * time.Second
+	}
+	return &Breaker{opts: opts}
+}
+
+// Allow asks to make a call
+// On success the returned function must be called with whether the call
+// failed in a way that indicates an unhealthy database
+func (b *Breaker) Allow() (func(failed bool), error) {
+	if b.opts.FailureThreshold <= 0 {
+		return func(bool) {}, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.currentState(time.Now()) {
+	case StateOpen:
+		return nil, ErrCircuitOpen
+	case StateHalfOpen:
+		if b.probing {
+			return nil, ErrCircuitOpen
+		}
+		b.probing = true
+		return b.done(true), nil
+	default:
+		return b.done(false), nil
+	}
+}
+
+// State returns the current breaker state
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.currentState(time.Now())
+}
+
+// currentState moves an open breaker to half-open once its timeout passed; the caller holds b.mu
+func (b *Breaker) currentState(now time.Time) State {
+	if b.state == StateOpen && now.Sub(b.openedAt) >= b.opts.OpenTimeout {
+		b.state = StateHalfOpen
+		b.probing = false
+	}
+	return b.state
+}
+
+// done returns the completion callback for an allowed call
+func (b *Breaker) done(probe bool) func(failed bool) {
+	var once sync.Once
+	return func(failed bool) {
+		once.Do(func() {
+			b.record(probe, failed)
+		})
+	}
+}
+
+// record updates the breaker with the outcome of a call
+func (b *Breaker) record(probe, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe {
+		b.probing = false
+	}
+
+	if !failed {
+		b.failures = 0
+		if probe {
+			b.state = StateClosed
+		}
+		return
+	}
+
+	b.failures++
+	if probe || (b.state == StateClosed && b.failures >= b.opts.FailureThreshold) {
+		b.state = StateOpen
+		b.openedAt = time.Now()
+	}
+}
@@ -0,0 +1,76 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+// retryableCodes are server error codes for conditions that clear up on their
+// own, mostly replica set elections and shutdowns (from the driver retry spec)
+var retryableCodes = []int{
+	6,     // HostUnreachable
+	7,     // HostNotFound
+	89,    // NetworkTimeout
+	91,    // ShutdownInProgress
+	134,   // ReadConcernMajorityNotAvailableYet
+	189,   // PrimarySteppedDown
+	262,   // ExceededTimeLimit
+	9001,  // SocketException
+	10107, // NotWritablePrimary
+	11600, // InterruptedAtShutdown
+	11602, // InterruptedDueToReplStateChange
+	13435, // NotPrimaryNoSecondaryOk
+	13436, // NotPrimaryOrSecondary
+}
+
+// IsRetryable reports whether a MongoDB error is transient, so the same
+// operation may succeed if tried again: network errors, server selection
+// failures during elections and the server codes above
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if mongo.IsNetworkError(err) || errors.Is(err, topology.ErrServerSelectionTimeout) {
+		return true
+	}
+	if errors.As(err, new(topology.ServerSelectionError)) {
+		return true
+	}
+
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) {
+		if serverErr.HasErrorLabel("RetryableWriteError") || serverErr.HasErrorLabel("TransientTransactionError") {
+			return true
+		}
+		for _, code := range retryableCodes {
+			if serverErr.HasErrorCode(code) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeConflict is the server code for two transactions writing the same document
+const writeConflict = 112
+
+// IsUnhealthy reports whether an error counts against the circuit breaker:
+// transient errors and timeouts, but not domain errors, bad input, write
+// conflicts between transactions or requests cancelled by the client
+func IsUnhealthy(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || IsWriteConflict(err) {
+		return false
+	}
+	return IsRetryable(err) || mongo.IsTimeout(err)
+}
+
+// IsWriteConflict reports whether a transaction lost a race for a document
+// to a concurrent one. It is normal contention, e.g. claims of one coupon,
+// and the driver runs the transaction again.
+func IsWriteConflict(err error) bool {
+	var serverErr mongo.ServerError
+	return errors.As(err, &serverErr) && serverErr.HasErrorCode(writeConflict)
+}
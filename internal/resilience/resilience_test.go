@@ -0,0 +1,118 @@
+package resilience
+
+import (
+	"context"
+	"coupon-system/internal/metrics"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var errStepDown = mongo.CommandError{Code: 10107, Message: "not primary"}
+
+func newTestGuard(attempts, failures int) *Guard {
+	return NewGuard(RetryPolicy{MaxAttempts: attempts, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond},
+		BreakerOptions{FailureThreshold: failures, OpenTimeout: 20 * time.Millisecond}, metrics.NewRegistry())
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"step down", errStepDown, true},
+		{"network", mongo.CommandError{Labels: []string{"NetworkError"}}, true},
+		{"duplicate key", mongo.CommandError{Code: 11000}, false},
+		{"domain", errors.New("coupon not found"), false},
+		{"cancelled", context.Canceled, false},
+	}
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("%s: IsRetryable = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGuardRetries(t *testing.T) {
+	g := newTestGuard(3, 0)
+	ctx := context.Background()
+
+	calls := 0
+	err := g.Do(ctx, true, func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return errStepDown
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("idempotent: err=%v calls=%d, want success after 3 calls", err, calls)
+	}
+
+	calls = 0
+	err = g.Do(ctx, false, func(context.Context) error {
+		calls++
+		return errStepDown
+	})
+	if !IsRetryable(err) || calls != 1 {
+		t.Fatalf("non-idempotent: err=%v calls=%d, want one call", err, calls)
+	}
+
+	calls = 0
+	domainErr := errors.New("coupon not found")
+	err = g.Do(ctx, true, func(context.Context) error {
+		calls++
+		return domainErr
+	})
+	if err != domainErr || calls != 1 {
+		t.Fatalf("domain error: err=%v calls=%d, want one call", err, calls)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	g := newTestGuard(1, 3)
+	ctx := context.Background()
+	fail := func(context.Context) error { return errStepDown }
+	ok := func(context.Context) error { return nil }
+
+	// Domain errors do not count against the database
+	for i := 0; i < 5; i++ {
+		g.Do(ctx, true, func(context.Context) error { return errors.New("not found") })
+	}
+	if g.State() != StateClosed {
+		t.Fatalf("state after domain errors = %v, want closed", g.State())
+	}
+
+	for i := 0; i < 3; i++ {
+		g.Do(ctx, true, fail)
+	}
+	if g.State() != StateOpen {
+		t.Fatalf("state after 3 failures = %v, want open", g.State())
+	}
+
+	called := false
+	if err := g.Do(ctx, true, func(context.Context) error { called = true; return nil }); err != ErrCircuitOpen || called {
+		t.Fatalf("open breaker: err=%v called=%v, want ErrCircuitOpen without a call", err, called)
+	}
+
+	// A failed probe re-opens the breaker, a successful one closes it
+	time.Sleep(25 * time.Millisecond)
+	if g.State() != StateHalfOpen {
+		t.Fatalf("state after timeout = %v, want half_open", g.State())
+	}
+	g.Do(ctx, true, fail)
+	if g.State() != StateOpen {
+		t.Fatalf("state after failed probe = %v, want open", g.State())
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	if err := g.Do(ctx, true, ok); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if g.State() != StateClosed {
+		t.Fatalf("state after successful probe = %v, want closed", g.State())
+	}
+}
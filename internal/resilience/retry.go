@@ -0,0 +1,103 @@
+package resilience
+
+import (
+	"context"
+	"coupon-system/internal/metrics"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy configures retries of transient failures
+type RetryPolicy struct {
+	MaxAttempts int           // Total attempts including the first; 1 disables retries
+	BaseDelay   time.Duration // Backoff before the first retry...
+	MaxDelay    time.Duration // ...doubling per retry up to this cap
+}
+
+// Backoff returns how long to wait before retry number n (starting at 1)
+// It uses full jitter: a random delay up to the exponential backoff, so
+// clients that failed together do not retry together
+func (p RetryPolicy) Backoff(n int) time.Duration {
+	ceiling := p.BaseDelay
+	for i := 1; i < n && ceiling < p.MaxDelay; i++ {
+		ceiling *= 2
+	}
+	if ceiling > p.MaxDelay {
+		ceiling = p.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// Guard runs database calls through a circuit breaker and a retry policy
+type Guard struct {
+	policy  RetryPolicy
+	breaker *Breaker
+
+	retries  *metrics.Counter
+	failures *metrics.Counter
+	rejected *metrics.Counter
+}
+
+// NewGuard creates a guard and registers its metrics
+func NewGuard(policy RetryPolicy, breaker BreakerOptions, registry *metrics.Registry) *Guard {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 1
+	}
+	if policy.MaxDelay < policy.BaseDelay {
+		policy.MaxDelay = policy.BaseDelay
+	}
+
+	g := &Guard{
+		policy:   policy,
+		breaker:  NewBreaker(breaker),
+		retries:  registry.Counter("db_retries_total", "Database calls retried after a transient error"),
+		failures: registry.Counter("db_transient_failures_total", "Database calls that failed with a transient error or timeout"),
+		rejected: registry.Counter("db_breaker_rejections_total", "Database calls rejected because the circuit breaker was open"),
+	}
+	registry.GaugeFunc("db_breaker_state", "Database circuit breaker state (0 closed, 1 half-open, 2 open)", func() float64 {
+		return float64(g.breaker.State())
+	})
+	return g
+}
+
+// State returns the circuit breaker state
+func (g *Guard) State() State {
+	return g.breaker.State()
+}
+
+// Do runs fn, retrying transient errors when the operation is idempotent
+// Non-idempotent operations (inserts, stock changes) are never retried, since
+// a timed-out attempt may already have been applied. Returns ErrCircuitOpen
+// without calling fn while the breaker is open.
+func (g *Guard) Do(ctx context.Context, idempotent bool, fn func(ctx context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		done, err := g.breaker.Allow()
+		if err != nil {
+			g.rejected.Inc()
+			return err
+		}
+
+		err = fn(ctx)
+		unhealthy := IsUnhealthy(err)
+		done(unhealthy)
+		if unhealthy {
+			g.failures.Inc()
+		}
+
+		if err == nil || !idempotent || !IsRetryable(err) || attempt >= g.policy.MaxAttempts {
+			return err
+		}
+
+		timer := time.NewTimer(g.policy.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		g.retries.Inc()
+	}
+}
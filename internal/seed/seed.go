@@ -0,0 +1,210 @@
+package seed
+
+import (
+	"context"
+	"coupon-system/internal/model"
+	"coupon-system/internal/repository"
+	apperrors "coupon-system/pkg/errors"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed scenarios/*.json
+var scenarioFS embed.FS
+
+// Fixture describes the coupons and claims to load into the database
+type Fixture struct {
+	Coupons []CouponFixture `json:"coupons" yaml:"coupons"`
+	Claims  []ClaimFixture  `json:"claims" yaml:"claims"`
+}
+
+// CouponFixture describes a single coupon
+// Expiry is given either as an absolute RFC3339 time (ExpiresAt) or relative
+// to the time of seeding (ExpiresIn, a Go duration such as "24h" or "-1h")
+type CouponFixture struct {
+	Name            string `json:"name" yaml:"name"`
+	Amount          int32  `json:"amount" yaml:"amount"`
+	RemainingAmount *int32 `json:"remaining_amount" yaml:"remaining_amount"` // Defaults to Amount
+	IsActive        *bool  `json:"is_active" yaml:"is_active"`               // Defaults to true
+	ExpiresAt       string `json:"expires_at" yaml:"expires_at"`
+	ExpiresIn       string `json:"expires_in" yaml:"expires_in"`
+}
+
+// ClaimFixture describes users that have already claimed a coupon
+// Claims do not touch stock; RemainingAmount on the coupon is taken as-is
+type ClaimFixture struct {
+	CouponName string   `json:"coupon_name" yaml:"coupon_name"`
+	UserIDs    []string `json:"user_ids" yaml:"user_ids"`
+}
+
+// Result summarizes what a seeding run changed
+type Result struct {
+	CouponsCreated int
+	CouponsSkipped int
+	ClaimsCreated  int
+	ClaimsSkipped  int
+}
+
+// Scenarios returns the names of the built-in scenarios
+func Scenarios() []string {
+	entries, _ := scenarioFS.ReadDir("scenarios")
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Scenario loads a built-in scenario by name (e.g. "flash_sale", "double_dip", "expired")
+func Scenario(name string) (*Fixture, error) {
+	data, err := scenarioFS.ReadFile("scenarios/" + name + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("unknown scenario %q (available: %s)", name, strings.Join(Scenarios(), ", "))
+	}
+
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario %q: %w", name, err)
+	}
+	return &fixture, nil
+}
+
+// LoadFile loads a fixture from a JSON or YAML file, chosen by extension
+func LoadFile(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var fixture Fixture
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &fixture)
+	case ".json":
+		err = json.Unmarshal(data, &fixture)
+	default:
+		return nil, fmt.Errorf("unsupported fixture format %q (use .json, .yaml or .yml)", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+	}
+	return &fixture, nil
+}
+
+// Seeder loads fixtures through the repository interfaces
+type Seeder struct {
+	couponRepo repository.CouponRepository
+	claimRepo  repository.ClaimRepository
+}
+
+// NewSeeder creates a new seeder
+func NewSeeder(couponRepo repository.CouponRepository, claimRepo repository.ClaimRepository) *Seeder {
+	return &Seeder{
+		couponRepo: couponRepo,
+		claimRepo:  claimRepo,
+	}
+}
+
+// Apply loads a fixture. It is idempotent: coupons and claims that already
+// exist are skipped rather than overwritten, so running it twice is harmless
+func (s *Seeder) Apply(ctx context.Context, fixture *Fixture) (*Result, error) {
+	result := &Result{}
+	now := time.Now()
+
+	for _, cf := range fixture.Coupons {
+		coupon, err := cf.toCoupon(now)
+		if err != nil {
+			return result, err
+		}
+
+		if err := s.couponRepo.CreateCoupon(ctx, coupon); err != nil {
+			if errors.Is(err, apperrors.ErrCouponAlreadyExists) {
+				result.CouponsSkipped++
+				continue
+			}
+			return result, fmt.Errorf("failed to create coupon %s: %w", cf.Name, err)
+		}
+		result.CouponsCreated++
+	}
+
+	for _, cf := range fixture.Claims {
+		coupon, err := s.couponRepo.GetCouponByName(ctx, cf.CouponName)
+		if err != nil {
+			return result, fmt.Errorf("failed to seed claims for %s: %w", cf.CouponName, err)
+		}
+
+		for _, userID := range cf.UserIDs {
+			_, err := s.claimRepo.CreateClaimIfNotExists(ctx, &model.Claim{
+				UserID:     userID,
+				CouponID:   coupon.ID,
+				CouponName: coupon.Name,
+				CreatedAt:  now,
+			})
+			if err != nil {
+				if errors.Is(err, apperrors.ErrAlreadyClaimed) {
+					result.ClaimsSkipped++
+					continue
+				}
+				return result, fmt.Errorf("failed to seed claim %s/%s: %w", cf.CouponName, userID, err)
+			}
+			result.ClaimsCreated++
+		}
+	}
+
+	return result, nil
+}
+
+// toCoupon converts a fixture entry into a coupon model
+func (cf CouponFixture) toCoupon(now time.Time) (*model.Coupon, error) {
+	if cf.Name == "" {
+		return nil, errors.New("coupon fixture is missing a name")
+	}
+
+	expiresAt := now.Add(30 * 24 * time.Hour)
+	switch {
+	case cf.ExpiresAt != "" && cf.ExpiresIn != "":
+		return nil, fmt.Errorf("coupon %s: set either expires_at or expires_in, not both", cf.Name)
+	case cf.ExpiresAt != "":
+		parsed, err := time.Parse(time.RFC3339, cf.ExpiresAt)
+		if err != nil {
+			return nil, fmt.Errorf("coupon %s: invalid expires_at: %w", cf.Name, err)
+		}
+		expiresAt = parsed
+	case cf.ExpiresIn != "":
+		d, err := time.ParseDuration(cf.ExpiresIn)
+		if err != nil {
+			return nil, fmt.Errorf("coupon %s: invalid expires_in: %w", cf.Name, err)
+		}
+		expiresAt = now.Add(d)
+	}
+
+	remaining := cf.Amount
+	if cf.RemainingAmount != nil {
+		remaining = *cf.RemainingAmount
+	}
+	isActive := true
+	if cf.IsActive != nil {
+		isActive = *cf.IsActive
+	}
+
+	return &model.Coupon{
+		Name:            cf.Name,
+		Amount:          cf.Amount,
+		RemainingAmount: remaining,
+		IsActive:        isActive,
+		CreatedAt:       now,
+		ExpiresAt:       expiresAt,
+		UpdatedAt:       now,
+	}, nil
+}
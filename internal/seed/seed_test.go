@@ -0,0 +1,145 @@
+package seed
+
+import (
+	"context"
+	"coupon-system/internal/repository/repotest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestScenarios checks every built-in scenario parses and seeds, and that
+// seeding again skips everything
+func TestScenarios(t *testing.T) {
+	names := Scenarios()
+	if len(names) == 0 {
+		t.Fatal("no built-in scenarios")
+	}
+
+	ctx := context.Background()
+	for _, name := range names {
+		fixture, err := Scenario(name)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		seeder := NewSeeder(repotest.NewCouponRepository(), repotest.NewClaimRepository())
+		first, err := seeder.Apply(ctx, fixture)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		second, err := seeder.Apply(ctx, fixture)
+		if err != nil {
+			t.Errorf("%s: second run: %v", name, err)
+			continue
+		}
+		if second.CouponsCreated != 0 || second.ClaimsCreated != 0 ||
+			second.CouponsSkipped != first.CouponsCreated || second.ClaimsSkipped != first.ClaimsCreated {
+			t.Errorf("%s: second run = %+v after %+v, want everything skipped", name, second, first)
+		}
+	}
+
+	if _, err := Scenario("nope"); err == nil || !strings.Contains(err.Error(), names[0]) {
+		t.Errorf("unknown scenario: err = %v, want it to list the available scenarios", err)
+	}
+}
+
+// TestLoadFile checks fixtures are parsed by extension and bad files are reported
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		file    string
+		content string
+		wantErr string // Substring of the error, "" if the file loads
+	}{
+		{"ok.yaml", "coupons:\n  - name: YAML\n    amount: 5\nclaims:\n  - coupon_name: YAML\n    user_ids: [a, b]\n", ""},
+		{"ok.yml", "coupons:\n  - name: YML\n    amount: 5\n", ""},
+		{"ok.json", `{"coupons": [{"name": "JSON", "amount": 5}], "claims": [{"coupon_name": "JSON", "user_ids": ["a", "b"]}]}`, ""},
+		{"bad.yaml", "coupons:\n  - name: [unclosed\n", "failed to parse"},
+		{"bad.json", `{"coupons": [{"name": "JSON", "amount": "five"}]}`, "failed to parse"},
+		{"fixture.toml", "name = 'x'", "unsupported fixture format"},
+	} {
+		path := filepath.Join(dir, tc.file)
+		if err := os.WriteFile(path, []byte(tc.content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		fixture, err := LoadFile(path)
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("%s: err = %v, want %q", tc.file, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.file, err)
+			continue
+		}
+		if len(fixture.Coupons) != 1 || fixture.Coupons[0].Amount != 5 {
+			t.Errorf("%s: coupons = %+v", tc.file, fixture.Coupons)
+		}
+		if len(fixture.Claims) == 1 && len(fixture.Claims[0].UserIDs) != 2 {
+			t.Errorf("%s: claims = %+v", tc.file, fixture.Claims)
+		}
+	}
+
+	if _, err := LoadFile(filepath.Join(dir, "missing.yaml")); !os.IsNotExist(err) {
+		t.Errorf("missing file: err = %v, want not exist", err)
+	}
+}
+
+// TestApplyFixture checks coupon defaults and expiry, and that bad entries are rejected
+func TestApplyFixture(t *testing.T) {
+	ctx := context.Background()
+	remaining, inactive := int32(2), false
+	coupons, claims := repotest.NewCouponRepository(), repotest.NewClaimRepository()
+	result, err := NewSeeder(coupons, claims).Apply(ctx, &Fixture{
+		Coupons: []CouponFixture{
+			{Name: "DEFAULTS", Amount: 10},
+			{Name: "SET", Amount: 10, RemainingAmount: &remaining, IsActive: &inactive, ExpiresIn: "-1h"},
+			{Name: "FIXED", Amount: 1, ExpiresAt: "2030-01-01T00:00:00Z"},
+		},
+		Claims: []ClaimFixture{{CouponName: "SET", UserIDs: []string{"a", "b", "a"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.CouponsCreated != 3 || result.ClaimsCreated != 2 || result.ClaimsSkipped != 1 {
+		t.Errorf("result = %+v, want 3 coupons, 2 claims and 1 skipped", result)
+	}
+
+	defaults, _ := coupons.GetCouponByName(ctx, "DEFAULTS")
+	if defaults.RemainingAmount != 10 || !defaults.IsActive || time.Until(defaults.ExpiresAt) < 29*24*time.Hour {
+		t.Errorf("DEFAULTS = %+v, want full stock, active, expiring in 30 days", defaults)
+	}
+	set, _ := coupons.GetCouponByName(ctx, "SET")
+	if set.RemainingAmount != 2 || set.IsActive || !set.ExpiresAt.Before(time.Now()) {
+		t.Errorf("SET = %+v, want 2 remaining, paused and expired", set)
+	}
+	if fixed, _ := coupons.GetCouponByName(ctx, "FIXED"); !fixed.ExpiresAt.Equal(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("FIXED expires at %v, want 2030-01-01", fixed.ExpiresAt)
+	}
+	if n := claims.Count(set.ID); n != 2 {
+		t.Errorf("SET has %d claims, want 2", n)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		fixture Fixture
+		wantErr string
+	}{
+		{"missing name", Fixture{Coupons: []CouponFixture{{Amount: 1}}}, "missing a name"},
+		{"both expiries", Fixture{Coupons: []CouponFixture{{Name: "BOTH", ExpiresAt: "2030-01-01T00:00:00Z", ExpiresIn: "1h"}}}, "not both"},
+		{"bad expires_at", Fixture{Coupons: []CouponFixture{{Name: "BAD", ExpiresAt: "tomorrow"}}}, "invalid expires_at"},
+		{"bad expires_in", Fixture{Coupons: []CouponFixture{{Name: "BAD", ExpiresIn: "1 day"}}}, "invalid expires_in"},
+		{"unknown coupon", Fixture{Claims: []ClaimFixture{{CouponName: "NOPE", UserIDs: []string{"a"}}}}, "NOPE"},
+	} {
+		_, err := NewSeeder(repotest.NewCouponRepository(), repotest.NewClaimRepository()).Apply(ctx, &tc.fixture)
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: err = %v, want %q", tc.name, err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"coupon-system/internal/cache"
+	"coupon-system/internal/model"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// WithCache shares coupon lookups and known claims across instances
+// couponTTL bounds how long a paused or changed coupon may be served from the
+// cache by other instances; claimTTL is how long a successful claim is
+// remembered for rejecting repeat attempts without a database round trip.
+// The cache is best effort: when it fails, requests fall back to MongoDB.
+func WithCache(c cache.Cache, couponTTL, claimTTL time.Duration) Option {
+	return func(s *CouponService) {
+		s.cache = c
+		s.couponCacheTTL = couponTTL
+		s.claimCacheTTL = claimTTL
+	}
+}
+
+// couponCacheKey is the cache key for a coupon lookup
+func couponCacheKey(name string) string {
+	return "coupon:" + name
+}
+
+// claimCacheKey is the cache key marking that a user holds a claim
+func claimCacheKey(coupon *model.Coupon, userID string) string {
+	return "claimed:" + coupon.ID.Hex() + ":" + userID
+}
+
+// lookupCoupon gets a coupon through the shared cache
+// Only used where a slightly stale stock count does not matter
+func (s *CouponService) lookupCoupon(ctx context.Context, name string) (*model.Coupon, error) {
+	if s.cache == nil || s.couponCacheTTL <= 0 {
+		return s.couponRepo.GetCouponByName(ctx, name)
+	}
+
+	key := couponCacheKey(name)
+	if data, found, err := s.cache.Get(ctx, key); err == nil && found {
+		var coupon model.Coupon
+		if err := bson.Unmarshal(data, &coupon); err == nil {
+			return &coupon, nil
+		}
+	}
+
+	coupon, err := s.couponRepo.GetCouponByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if data, err := bson.Marshal(coupon); err == nil {
+		if err := s.cache.Set(ctx, key, data, s.couponCacheTTL); err != nil {
+			log.Printf("Cache: failed to store coupon %s: %v", name, err)
+		}
+	}
+	return coupon, nil
+}
+
+// forgetCoupon drops a coupon from the shared cache after an admin change
+func (s *CouponService) forgetCoupon(ctx context.Context, name string) {
+	if s.cache == nil {
+		return
+	}
+	if err := s.cache.Delete(ctx, couponCacheKey(name)); err != nil {
+		log.Printf("Cache: failed to invalidate coupon %s: %v", name, err)
+	}
+}
+
+// knownClaim reports whether the cache says the user already holds a claim
+func (s *CouponService) knownClaim(ctx context.Context, coupon *model.Coupon, userID string) bool {
+	if s.cache == nil || s.claimCacheTTL <= 0 {
+		return false
+	}
+	_, found, err := s.cache.Get(ctx, claimCacheKey(coupon, userID))
+	return err == nil && found
+}
+
+// rememberClaim records that the user holds a claim
+func (s *CouponService) rememberClaim(ctx context.Context, coupon *model.Coupon, userID string) {
+	if s.cache == nil || s.claimCacheTTL <= 0 {
+		return
+	}
+	if err := s.cache.Set(ctx, claimCacheKey(coupon, userID), []byte("1"), s.claimCacheTTL); err != nil {
+		log.Printf("Cache: failed to remember claim %s/%s: %v", coupon.Name, userID, err)
+	}
+}
+
+// forgetClaim removes the marker after a claim is cancelled
+func (s *CouponService) forgetClaim(ctx context.Context, coupon *model.Coupon, userID string) {
+	if s.cache == nil {
+		return
+	}
+	if err := s.cache.Delete(ctx, claimCacheKey(coupon, userID)); err != nil {
+		log.Printf("Cache: failed to forget claim %s/%s: %v", coupon.Name, userID, err)
+	}
+}
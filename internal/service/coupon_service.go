@@ -2,10 +2,18 @@ package service
 
 import (
 	"context"
+	"coupon-system/internal/cache"
+	"coupon-system/internal/events"
 	"coupon-system/internal/model"
 	"coupon-system/internal/repository"
+	"coupon-system/internal/validation"
 	apperrors "coupon-system/pkg/errors"
+	"errors"
+	"log"
+	"sync"
 	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // Re-export errors for backward compatibility with handlers
@@ -14,82 +22,484 @@ var (
 	ErrCouponAlreadyExists = apperrors.ErrCouponAlreadyExists
 	ErrAlreadyClaimed      = apperrors.ErrAlreadyClaimed
 	ErrNoStock             = apperrors.ErrNoStock
+	ErrCouponInactive      = apperrors.ErrCouponInactive
+	ErrClaimNotFound       = apperrors.ErrClaimNotFound
+	ErrStockAvailable      = apperrors.ErrStockAvailable
+	ErrAlreadyWaitlisted   = apperrors.ErrAlreadyWaitlisted
+	ErrNotWaitlisted       = apperrors.ErrNotWaitlisted
+	ErrWaitlistDisabled    = apperrors.ErrWaitlistDisabled
+	ErrRaffleOnly          = apperrors.ErrRaffleOnly
+	ErrNotRaffle           = apperrors.ErrNotRaffle
+	ErrRaffleEntryClosed   = apperrors.ErrRaffleEntryClosed
+	ErrRaffleEntryOpen     = apperrors.ErrRaffleEntryOpen
+	ErrAlreadyEntered      = apperrors.ErrAlreadyEntered
+	ErrRaffleAlreadyDrawn  = apperrors.ErrRaffleAlreadyDrawn
+	ErrRaffleNotDrawn      = apperrors.ErrRaffleNotDrawn
+	ErrQueueNotEnabled     = apperrors.ErrQueueNotEnabled
+	ErrQueueTokenRequired  = apperrors.ErrQueueTokenRequired
+	ErrQueueTokenInvalid   = apperrors.ErrQueueTokenInvalid
+	ErrQueueTicketNotFound = apperrors.ErrQueueTicketNotFound
+	ErrQueueWrongInstance  = apperrors.ErrQueueWrongInstance
+	ErrDatabaseUnavailable = apperrors.ErrDatabaseUnavailable
+)
+
+// Admission controls access to claims for queue-enabled coupons
+type Admission interface {
+	// Join puts a user in line for a coupon
+	Join(couponName, userID string) (*model.QueueTicket, error)
+
+	// Status returns a ticket's current position
+	Status(token string) (*model.QueueTicket, error)
+
+	// Reserve holds an admitted token for a claim in progress
+	Reserve(couponName, userID, token string) error
+
+	// Commit spends a reserved token once its claim has been made
+	Commit(token string)
+
+	// Release returns a reserved token whose claim failed, so it can be used again
+	Release(token string)
+}
+
+// StockAllocator serves claims from stock pre-allocated to this instance
+type StockAllocator interface {
+	// Claim claims a coupon for a user and returns the claim once it is committed
+	Claim(ctx context.Context, coupon *model.Coupon, userID string) (*model.Claim, error)
+}
+
+// Undoing a failed claim is retried this often, waiting undoBackoff longer each time
+const (
+	undoAttempts = 5
+	undoBackoff  = 20 * time.Millisecond
+	undoTimeout  = 5 * time.Second
 )
 
 // CouponService handles business logic for coupons
 type CouponService struct {
-	couponRepo repository.CouponRepository
-	claimRepo  repository.ClaimRepository
+	couponRepo   repository.CouponRepository
+	claimRepo    repository.ClaimRepository
+	waitlistRepo repository.WaitlistRepository
+	raffleRepo   repository.RaffleRepository
+	admission    Admission
+	allocator    StockAllocator
+	events       events.Publisher
+	outbox       repository.OutboxRepository
+	tx           repository.Transactor
+	soldOut      sync.Map // Coupon ID -> key of the last recorded sell-out
+
+	cache          cache.Cache
+	couponCacheTTL time.Duration
+	claimCacheTTL  time.Duration
+}
+
+// Option configures optional CouponService dependencies
+type Option func(*CouponService)
+
+// WithEventPublisher sets where domain events are published (default: discarded)
+func WithEventPublisher(publisher events.Publisher) Option {
+	return func(s *CouponService) {
+		s.events = publisher
+	}
+}
+
+// WithWaitlist enables waitlists for sold-out coupons
+func WithWaitlist(waitlistRepo repository.WaitlistRepository) Option {
+	return func(s *CouponService) {
+		s.waitlistRepo = waitlistRepo
+	}
+}
+
+// WithRaffles enables the raffle distribution mode
+func WithRaffles(raffleRepo repository.RaffleRepository) Option {
+	return func(s *CouponService) {
+		s.raffleRepo = raffleRepo
+	}
+}
+
+// WithAdmission enables virtual queues for coupons created with queue_enabled
+func WithAdmission(admission Admission) Option {
+	return func(s *CouponService) {
+		s.admission = admission
+	}
+}
+
+// WithStockAllocator serves claims from leased blocks of stock with group commit
+func WithStockAllocator(allocator StockAllocator) Option {
+	return func(s *CouponService) {
+		s.allocator = allocator
+	}
 }
 
 // NewCouponService creates a new coupon service
-func NewCouponService(couponRepo repository.CouponRepository, claimRepo repository.ClaimRepository) *CouponService {
-	return &CouponService{
+func NewCouponService(couponRepo repository.CouponRepository, claimRepo repository.ClaimRepository, opts ...Option) *CouponService {
+	s := &CouponService{
 		couponRepo: couponRepo,
 		claimRepo:  claimRepo,
+		events:     events.Nop{},
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
 // ClaimCoupon attempts to claim a coupon for a user
 // Uses atomic upsert pattern to prevent double-dip attacks without requiring transactions
-func (s *CouponService) ClaimCoupon(ctx context.Context, req *model.ClaimCouponRequest) error {
-	// Get coupon (read-only operation)
-	coupon, err := s.couponRepo.GetCouponByName(ctx, req.CouponName)
+func (s *CouponService) ClaimCoupon(ctx context.Context, req *model.ClaimCouponRequest) (err error) {
+	// Get coupon (read-only operation, may be served from the shared cache)
+	coupon, err := s.lookupCoupon(ctx, req.CouponName)
 	if err != nil {
 		return err
 	}
+	if !coupon.IsActive {
+		return ErrCouponInactive
+	}
+	if coupon.IsRaffle() {
+		return ErrRaffleOnly
+	}
+
+	// Fast rejection of repeat attempts, before a queue token is spent
+	if s.knownClaim(ctx, coupon, req.UserID) {
+		return ErrAlreadyClaimed
+	}
+	if coupon.QueueEnabled && s.admission != nil {
+		if err := s.admission.Reserve(coupon.Name, req.UserID, req.QueueToken); err != nil {
+			return err
+		}
+		// The token is spent only once the user holds the coupon; a claim that
+		// failed for another reason may be retried with it
+		defer func() {
+			if err == nil || errors.Is(err, ErrAlreadyClaimed) {
+				s.admission.Commit(req.QueueToken)
+			} else {
+				s.admission.Release(req.QueueToken)
+			}
+		}()
+	}
+
+	// Pre-allocation mode: stock comes from this instance's lease and the claim
+	// is written by the next group commit; double-dip protection is unchanged
+	if s.allocator != nil {
+		claim, err := s.allocator.Claim(ctx, coupon, req.UserID)
+		switch {
+		case err == nil:
+			// The claim is already committed by the group commit
+			s.recordClaimed(ctx, claim)
+			s.rememberClaim(ctx, coupon, req.UserID)
+		case errors.Is(err, ErrAlreadyClaimed):
+			s.rememberClaim(ctx, coupon, req.UserID)
+		case errors.Is(err, ErrNoStock):
+			s.recordSoldOut(ctx, coupon)
+		}
+		return err
+	}
+
+	// The ID is chosen here so a failed attempt can remove exactly its own claim.
+	// Without a transaction the claim carries its pending event, so the event
+	// is not lost if recording it fails after the claim is written.
+	claim := &model.Claim{
+		ID:           primitive.NewObjectID(),
+		UserID:       req.UserID,
+		CouponID:     coupon.ID,
+		CouponName:   req.CouponName,
+		CreatedAt:    time.Now(),
+		EventPending: s.outbox != nil && !s.transactional(),
+	}
+
+	// With a transaction the claim, the stock decrement and the event commit
+	// together, and a failure leaves nothing to undo
+	if s.transactional() {
+		err := s.atomically(ctx, func(ctx context.Context) error {
+			if _, err := s.claimRepo.CreateClaimIfNotExists(ctx, claim); err != nil {
+				return err
+			}
+			if err := s.couponRepo.DecrementStock(ctx, coupon.ID, 1); err != nil {
+				return err
+			}
+			return s.record(ctx, claimedEvent(claim))
+		})
+		switch {
+		case err == nil, errors.Is(err, ErrAlreadyClaimed):
+			s.rememberClaim(ctx, coupon, req.UserID)
+		case errors.Is(err, ErrNoStock):
+			s.recordSoldOut(ctx, coupon)
+		}
+		return err
+	}
 
 	// Step 1: Atomically claim FIRST using upsert pattern
 	// This is idempotent - 10 concurrent requests result in exactly 1 insert
 	// No race window exists because MongoDB's upsert is atomic
-	claim := &model.Claim{
-		UserID:     req.UserID,
-		CouponID:   coupon.ID,
-		CouponName: req.CouponName,
-		CreatedAt:  time.Now(),
-	}
 
 	created, err := s.claimRepo.CreateClaimIfNotExists(ctx, claim)
-	if err != nil {
-		return err // Already claimed or DB error - no stock touched
-	}
-	if !created {
+	if errors.Is(err, ErrAlreadyClaimed) || (err == nil && !created) {
+		s.rememberClaim(ctx, coupon, req.UserID)
 		return ErrAlreadyClaimed
 	}
+	if err != nil {
+		// DB error - no stock touched, but the insert may have been applied
+		// before the error (e.g. a lost acknowledgement)
+		s.undoClaim(ctx, claim)
+		return err
+	}
 
 	// Step 2: Decrement stock (claim is now secured)
 	// If this fails, we need to rollback the claim we just created
 	if err := s.couponRepo.DecrementStock(ctx, coupon.ID, 1); err != nil {
 		// Compensating action: remove the claim we just created
-		_ = s.claimRepo.DeleteClaim(ctx, req.UserID, coupon.ID)
+		s.undoClaim(ctx, claim)
+		if errors.Is(err, ErrNoStock) {
+			s.recordSoldOut(ctx, coupon)
+		}
 		return err
 	}
 
+	// Step 3: Record the event; the claim stands even if this fails
+	s.recordClaimed(ctx, claim)
+
+	s.rememberClaim(ctx, coupon, req.UserID)
 	return nil
 }
 
+// undoClaim removes a claim written by a failed ClaimCoupon attempt
+// It runs even if the request was cancelled, and retries briefly, because a
+// claim left behind would hold a coupon for which no stock was taken.
+// Returns whether the claim is gone.
+func (s *CouponService) undoClaim(ctx context.Context, claim *model.Claim) bool {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), undoTimeout)
+	defer cancel()
+
+	var err error
+	for attempt := 1; attempt <= undoAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(time.Duration(attempt-1) * undoBackoff)
+		}
+		err = s.claimRepo.DeleteClaimByID(ctx, claim.ID)
+		if err == nil || errors.Is(err, ErrClaimNotFound) || ctx.Err() != nil {
+			break
+		}
+	}
+	if err == nil || errors.Is(err, ErrClaimNotFound) {
+		return true
+	}
+	log.Printf("Claim %s for %s/%s could not be undone and holds no stock: %v",
+		claim.ID.Hex(), claim.CouponName, claim.UserID, err)
+	return false
+}
+
+// BulkClaim grants a coupon to many users at once (back-office grants)
+// Stock is reserved once for all eligible users and claims are inserted with a
+// single bulk upsert, so the unique (user_id, coupon_id) index still guarantees
+// that no user ends up with more than one claim. Reserved stock that is not
+// used (users who claimed concurrently) is returned to the coupon.
+func (s *CouponService) BulkClaim(ctx context.Context, req *model.BulkClaimRequest) (*model.BulkClaimResponse, error) {
+	coupon, err := s.couponRepo.GetCouponByName(ctx, req.CouponName)
+	if err != nil {
+		return nil, err
+	}
+	if !coupon.IsActive {
+		return nil, ErrCouponInactive
+	}
+
+	// Deduplicate while keeping the caller's order
+	statuses := make(map[string]string, len(req.UserIDs))
+	userIDs := make([]string, 0, len(req.UserIDs))
+	for _, userID := range req.UserIDs {
+		if _, seen := statuses[userID]; seen {
+			continue
+		}
+		statuses[userID] = ""
+		userIDs = append(userIDs, userID)
+	}
+
+	// Users that already hold a claim need no stock
+	claimed, err := s.claimRepo.GetClaimedUserIDs(ctx, coupon.ID, userIDs, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]string, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if claimed[userID] {
+			statuses[userID] = model.BulkClaimStatusAlreadyClaimed
+			continue
+		}
+		candidates = append(candidates, userID)
+	}
+
+	// Step 1: Reserve stock for all candidates in one atomic update
+	var reserved int32
+	if len(candidates) > 0 {
+		reserved, err = s.couponRepo.ReserveStock(ctx, coupon.ID, int32(len(candidates)))
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, userID := range candidates[reserved:] {
+		statuses[userID] = model.BulkClaimStatusNoStock
+	}
+	if int(reserved) < len(candidates) {
+		s.recordSoldOut(ctx, coupon)
+	}
+
+	// Step 2: Insert claims for the users we reserved stock for
+	granted := candidates[:reserved]
+	now := time.Now()
+	claims := make([]*model.Claim, 0, len(granted))
+	for _, userID := range granted {
+		claims = append(claims, &model.Claim{
+			ID:         primitive.NewObjectID(),
+			UserID:     userID,
+			CouponID:   coupon.ID,
+			CouponName: coupon.Name,
+			CreatedAt:  now,
+		})
+	}
+
+	// Compensating action: remove whatever we may have inserted (but not
+	// claims other requests made meanwhile) and return the reservation
+	undo := func() {
+		undoCtx := context.WithoutCancel(ctx)
+		for _, claim := range claims {
+			_ = s.claimRepo.DeleteClaimByID(undoCtx, claim.ID)
+		}
+		if reserved > 0 {
+			_ = s.couponRepo.IncrementStock(undoCtx, coupon.ID, reserved)
+		}
+	}
+
+	created, err := s.claimRepo.CreateClaimsIfNotExist(ctx, claims)
+	if err != nil {
+		undo()
+		return nil, err
+	}
+
+	claimedEvents := make([]events.Event, 0, len(claims))
+	for i, claim := range claims {
+		if created[i] {
+			claimedEvents = append(claimedEvents, claimedEvent(claim))
+		}
+	}
+	if err := s.record(ctx, claimedEvents...); err != nil {
+		undo()
+		return nil, err
+	}
+
+	// Step 3: Users who claimed between the lookup and the insert keep their
+	// existing claim; give their reserved units back
+	var unused int32
+	for i, userID := range granted {
+		if created[i] {
+			statuses[userID] = model.BulkClaimStatusClaimed
+			continue
+		}
+		statuses[userID] = model.BulkClaimStatusAlreadyClaimed
+		unused++
+	}
+	// The grants are committed whatever happens to the spare units, so a failure
+	// to return them is logged rather than reported as a failed grant
+	if unused > 0 {
+		if err := s.couponRepo.IncrementStock(context.WithoutCancel(ctx), coupon.ID, unused); err != nil {
+			log.Printf("Failed to return %d unused units of %s after a bulk claim: %v", unused, coupon.Name, err)
+		} else {
+			s.promoteWaitlist(ctx, coupon)
+		}
+	}
+
+	resp := &model.BulkClaimResponse{
+		CouponName: coupon.Name,
+		Results:    make([]model.BulkClaimResult, 0, len(userIDs)),
+	}
+	for _, userID := range userIDs {
+		status := statuses[userID]
+		switch status {
+		case model.BulkClaimStatusClaimed:
+			resp.Claimed++
+		case model.BulkClaimStatusAlreadyClaimed:
+			resp.AlreadyClaimed++
+		case model.BulkClaimStatusNoStock:
+			resp.NoStock++
+		}
+		resp.Results = append(resp.Results, model.BulkClaimResult{UserID: userID, Status: status})
+	}
+
+	return resp, nil
+}
+
 // CreateCoupon creates a new coupon
 func (s *CouponService) CreateCoupon(ctx context.Context, req *model.CreateCouponRequest) (*model.Coupon, error) {
-	// Parse expiration date if provided, otherwise default to 30 days
-	expiresAt := time.Now().Add(30 * 24 * time.Hour)
+	now := time.Now()
+	raffle := req.DistributionMode == model.DistributionRaffle
+	if raffle && s.raffleRepo == nil {
+		return nil, ErrNotRaffle
+	}
+
+	var invalid validation.Errors
+	invalid.Check("name", validation.CouponName(req.Name))
+
+	// Expiry defaults to 30 days; one that is given must parse and lie in the future
+	expiresAt := now.Add(30 * 24 * time.Hour)
 	if req.ExpiresAt != "" {
-		parsed, err := time.Parse(time.RFC3339, req.ExpiresAt)
-		if err == nil {
+		if parsed, ok := parseTime(&invalid, "expires_at", req.ExpiresAt); ok {
 			expiresAt = parsed
+			if !expiresAt.After(now) {
+				invalid.Add("expires_at", "must be in the future")
+			}
+		}
+	}
+
+	// Raffles need an entry window: it opens now unless given, and must close before it is drawn
+	startsAt, endsAt := now, time.Time{}
+	if raffle {
+		if req.EntryStartsAt != "" {
+			if parsed, ok := parseTime(&invalid, "entry_starts_at", req.EntryStartsAt); ok {
+				startsAt = parsed
+			}
+		}
+		if req.EntryEndsAt == "" {
+			invalid.Add("entry_ends_at", "is required for raffles")
+		} else if parsed, ok := parseTime(&invalid, "entry_ends_at", req.EntryEndsAt); ok {
+			endsAt = parsed
+			if !endsAt.After(startsAt) {
+				invalid.Add("entry_ends_at", "must be after entry_starts_at")
+			}
+			if !expiresAt.After(endsAt) {
+				invalid.Add("expires_at", "must be after entry_ends_at")
+			}
 		}
 	}
+	if err := invalid.Err(); err != nil {
+		return nil, err
+	}
 
 	coupon := &model.Coupon{
 		Name:            req.Name,
 		Amount:          req.Amount,
 		RemainingAmount: req.Amount,
 		IsActive:        true,
-		CreatedAt:       time.Now(),
+		CreatedAt:       now,
 		ExpiresAt:       expiresAt,
-		UpdatedAt:       time.Now(),
+		UpdatedAt:       now,
+		QueueEnabled:    req.QueueEnabled,
+		StockShards:     req.StockShards,
+	}
+	if raffle {
+		coupon.DistributionMode = model.DistributionRaffle
+		coupon.EntryStartsAt = &startsAt
+		coupon.EntryEndsAt = &endsAt
 	}
 
-	if err := s.couponRepo.CreateCoupon(ctx, coupon); err != nil {
+	err := s.atomically(ctx, func(ctx context.Context) error {
+		if err := s.couponRepo.CreateCoupon(ctx, coupon); err != nil {
+			return err
+		}
+		return s.record(ctx, events.Event{
+			Type:       events.TypeCouponCreated,
+			CouponName: coupon.Name,
+			Data:       map[string]interface{}{"amount": coupon.Amount},
+			OccurredAt: coupon.CreatedAt,
+		})
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -100,7 +510,7 @@ func (s *CouponService) CreateCoupon(ctx context.Context, req *model.CreateCoupo
 func (s *CouponService) GetCouponDetails(ctx context.Context, name string) (*model.CouponDetailsResponse, error) {
 	coupon, err := s.couponRepo.GetCouponByName(ctx, name)
 	if err != nil {
-		return nil, ErrCouponNotFound
+		return nil, err
 	}
 
 	claims, err := s.claimRepo.GetClaimsByCouponName(ctx, name)
@@ -117,7 +527,82 @@ func (s *CouponService) GetCouponDetails(ctx context.Context, name string) (*mod
 		Name:            coupon.Name,
 		Amount:          coupon.Amount,
 		RemainingAmount: coupon.RemainingAmount,
+		IsActive:        coupon.IsActive,
+		ExpiresAt:       coupon.ExpiresAt,
 		ClaimedBy:       claimedBy,
 	}, nil
 }
 
+// ListCoupons retrieves all coupons
+func (s *CouponService) ListCoupons(ctx context.Context) ([]*model.Coupon, error) {
+	return s.couponRepo.ListCoupons(ctx)
+}
+
+// ListClaims retrieves all claims for a coupon
+func (s *CouponService) ListClaims(ctx context.Context, name string) ([]*model.Claim, error) {
+	if _, err := s.couponRepo.GetCouponByName(ctx, name); err != nil {
+		return nil, err
+	}
+
+	claims, err := s.claimRepo.GetClaimsByCouponName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if claims == nil {
+		claims = []*model.Claim{}
+	}
+
+	return claims, nil
+}
+
+// ClaimedSince returns which of the users claimed a coupon at or after since
+func (s *CouponService) ClaimedSince(ctx context.Context, name string, userIDs []string, since time.Time) (map[string]bool, error) {
+	coupon, err := s.couponRepo.GetCouponByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return s.claimRepo.GetClaimedUserIDs(ctx, coupon.ID, userIDs, since)
+}
+
+// SetCouponActive pauses (active=false) or resumes (active=true) a coupon
+// Paused coupons reject claims with ErrCouponInactive
+func (s *CouponService) SetCouponActive(ctx context.Context, name string, active bool) (*model.Coupon, error) {
+	coupon, err := s.couponRepo.GetCouponByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.couponRepo.SetActive(ctx, coupon.ID, active); err != nil {
+		return nil, err
+	}
+	s.forgetCoupon(ctx, name)
+
+	return s.couponRepo.GetCouponByName(ctx, name)
+}
+
+// RestockCoupon adds stock to a coupon
+func (s *CouponService) RestockCoupon(ctx context.Context, name string, amount int32) (*model.Coupon, error) {
+	coupon, err := s.couponRepo.GetCouponByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.couponRepo.IncrementStock(ctx, coupon.ID, amount); err != nil {
+		return nil, err
+	}
+	// The cached copy carries the updated_at that keys the last sell-out
+	s.forgetCoupon(ctx, name)
+	s.promoteWaitlist(ctx, coupon)
+
+	return s.couponRepo.GetCouponByName(ctx, name)
+}
+
+// parseTime parses an RFC3339 request field, recording it as invalid if it does not parse
+func parseTime(invalid *validation.Errors, name, value string) (time.Time, bool) {
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		invalid.Add(name, "must be an RFC3339 timestamp, e.g. 2026-12-31T23:59:59Z")
+		return time.Time{}, false
+	}
+	return parsed, true
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"coupon-system/internal/faults"
+	"coupon-system/internal/model"
+	"coupon-system/internal/queue"
+	"coupon-system/internal/repository/repotest"
+	"coupon-system/internal/validation"
+	apperrors "coupon-system/pkg/errors"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestCreateCouponValidation checks invalid fields are all reported instead of defaulted
+func TestCreateCouponValidation(t *testing.T) {
+	svc := NewCouponService(repotest.NewCouponRepository(), repotest.NewClaimRepository(), WithRaffles(newMemoryRaffleRepository()))
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	now, soon := time.Now().UTC().Format(time.RFC3339), time.Now().Add(time.Minute).UTC().Format(time.RFC3339)
+
+	cases := []struct {
+		name    string
+		req     model.CreateCouponRequest
+		invalid []string // Names of the fields reported invalid, nil if the coupon is created
+	}{
+		{"valid", model.CreateCouponRequest{Name: "SPRING_SALE-1", Amount: 1, ExpiresAt: future}, nil},
+		{"default expiry", model.CreateCouponRequest{Name: "DEFAULT", Amount: 1}, nil},
+		{"bad name", model.CreateCouponRequest{Name: "spring sale!", Amount: 1}, []string{"name"}},
+		{"long name", model.CreateCouponRequest{Name: string(make([]byte, 65)), Amount: 1}, []string{"name"}},
+		{"malformed expiry", model.CreateCouponRequest{Name: "MALFORMED", Amount: 1, ExpiresAt: "next week"}, []string{"expires_at"}},
+		{"past expiry", model.CreateCouponRequest{Name: "PAST", Amount: 1, ExpiresAt: past}, []string{"expires_at"}},
+		{"every field", model.CreateCouponRequest{Name: "", Amount: 1, ExpiresAt: "2026-13-01"}, []string{"name", "expires_at"}},
+		{"inverted raffle window", model.CreateCouponRequest{Name: "RAFFLE", Amount: 1, ExpiresAt: future, DistributionMode: model.DistributionRaffle, EntryStartsAt: soon, EntryEndsAt: now}, []string{"entry_ends_at"}},
+	}
+	for _, tc := range cases {
+		_, err := svc.CreateCoupon(context.Background(), &tc.req)
+		if tc.invalid == nil {
+			if err != nil {
+				t.Errorf("%s: %v", tc.name, err)
+			}
+			continue
+		}
+
+		if !errors.Is(err, apperrors.ErrValidation) {
+			t.Errorf("%s: err = %v, want validation error", tc.name, err)
+			continue
+		}
+		var got []string
+		for _, p := range apperrors.From(err).Details["invalid_params"].([]validation.InvalidParam) {
+			got = append(got, p.Name)
+		}
+		if !reflect.DeepEqual(got, tc.invalid) {
+			t.Errorf("%s: invalid params = %v, want %v", tc.name, got, tc.invalid)
+		}
+	}
+}
+
+// TestBulkClaim checks each user's status, and that stock is only taken for granted users
+func TestBulkClaim(t *testing.T) {
+	ft := newFaultTest(t, 3)
+	ctx := context.Background()
+	if err := ft.svc.ClaimCoupon(ctx, &model.ClaimCouponRequest{UserID: "b", CouponName: ft.coupon.Name}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := ft.svc.BulkClaim(ctx, &model.BulkClaimRequest{CouponName: ft.coupon.Name, UserIDs: []string{"a", "b", "a", "c", "d"}})
+	if err != nil {
+		t.Fatalf("BulkClaim: %v", err)
+	}
+	want := []model.BulkClaimResult{
+		{UserID: "a", Status: model.BulkClaimStatusClaimed},
+		{UserID: "b", Status: model.BulkClaimStatusAlreadyClaimed},
+		{UserID: "c", Status: model.BulkClaimStatusClaimed},
+		{UserID: "d", Status: model.BulkClaimStatusNoStock},
+	}
+	if !reflect.DeepEqual(resp.Results, want) || resp.Claimed != 2 || resp.AlreadyClaimed != 1 || resp.NoStock != 1 {
+		t.Errorf("results = %+v", resp)
+	}
+	if remaining, claims := ft.coupons.Remaining(ft.coupon.ID), ft.claims.Count(ft.coupon.ID); remaining != 0 || claims != 3 {
+		t.Errorf("remaining = %d, claims = %d, want 0 and 3", remaining, claims)
+	}
+}
+
+// TestBulkClaimUndoKeepsConcurrentClaims checks a failed bulk insert only
+// removes its own claims, not one another request made meanwhile
+func TestBulkClaimUndoKeepsConcurrentClaims(t *testing.T) {
+	ft := newFaultTest(t, 10)
+	ctx := context.Background()
+
+	// The insert is applied after a delay, then reported as failed
+	if err := ft.injector.Set("CreateClaimsIfNotExist", faults.Fault{Error: "boom", Partial: true, LatencyMS: 50, Times: 1}); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := ft.svc.BulkClaim(ctx, &model.BulkClaimRequest{CouponName: ft.coupon.Name, UserIDs: []string{"a", "b", "c"}})
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if err := ft.svc.ClaimCoupon(ctx, &model.ClaimCouponRequest{UserID: "b", CouponName: ft.coupon.Name}); err != nil {
+		t.Fatalf("concurrent claim: %v", err)
+	}
+	if err := <-done; err == nil {
+		t.Fatal("BulkClaim succeeded despite the failed insert")
+	}
+
+	if held, _ := ft.claims.HasUserClaimed(ctx, "b", ft.coupon.ID); !held {
+		t.Error("the concurrent claim of b was removed by the bulk undo")
+	}
+	if remaining, claims := ft.coupons.Remaining(ft.coupon.ID), ft.claims.Count(ft.coupon.ID); remaining != 9 || claims != 1 {
+		t.Errorf("remaining = %d, claims = %d, want 9 and 1", remaining, claims)
+	}
+}
+
+// TestClaimedSince checks only the given users' claims made at or after since are reported
+func TestClaimedSince(t *testing.T) {
+	ft := newFaultTest(t, 10)
+	ctx := context.Background()
+	claim := func(userID string) {
+		if err := ft.svc.ClaimCoupon(ctx, &model.ClaimCouponRequest{UserID: userID, CouponName: ft.coupon.Name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	claim("a")
+	time.Sleep(2 * time.Millisecond)
+	since := time.Now()
+	claim("b")
+	claim("c")
+
+	got, err := ft.svc.ClaimedSince(ctx, ft.coupon.Name, []string{"a", "b", "d"}, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]bool{"b": true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ClaimedSince = %v, want %v", got, want)
+	}
+}
+
+// TestQueueTokenIsSpentOnlyBySuccessfulClaims checks a claim that fails leaves
+// its admitted token usable, and a successful one spends it
+func TestQueueTokenIsSpentOnlyBySuccessfulClaims(t *testing.T) {
+	ctx := context.Background()
+	admission := queue.NewManager(queue.Options{AdmitRate: 1000, Tick: 5 * time.Millisecond})
+	admission.Start()
+	defer admission.Stop()
+
+	coupons := repotest.NewCouponRepository()
+	svc := NewCouponService(coupons, repotest.NewClaimRepository(), WithAdmission(admission))
+	coupon := &model.Coupon{Name: "FLASH", Amount: 1, RemainingAmount: 0, IsActive: true, QueueEnabled: true, CreatedAt: time.Now()}
+	_ = coupons.CreateCoupon(ctx, coupon)
+
+	ticket, err := svc.JoinQueue(ctx, "FLASH", "u1")
+	if err != nil {
+		t.Fatalf("JoinQueue: %v", err)
+	}
+	for ticket.Status != model.QueueStatusAdmitted {
+		time.Sleep(5 * time.Millisecond)
+		if ticket, err = svc.GetQueueTicket(ticket.Token); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	claim := &model.ClaimCouponRequest{UserID: "u1", CouponName: "FLASH", QueueToken: ticket.Token}
+	if err := svc.ClaimCoupon(ctx, claim); !errors.Is(err, ErrNoStock) {
+		t.Fatalf("claim while sold out = %v, want ErrNoStock", err)
+	}
+	if _, err := svc.RestockCoupon(ctx, "FLASH", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.ClaimCoupon(ctx, claim); err != nil {
+		t.Fatalf("claim after restock with the same token: %v", err)
+	}
+	if status, _ := svc.GetQueueTicket(ticket.Token); status.Status != model.QueueStatusUsed {
+		t.Errorf("token status = %s, want used", status.Status)
+	}
+}
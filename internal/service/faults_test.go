@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"coupon-system/internal/faults"
+	"coupon-system/internal/metrics"
+	"coupon-system/internal/model"
+	"coupon-system/internal/repository"
+	"coupon-system/internal/repository/repotest"
+	"coupon-system/internal/resilience"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// faultTest is a service over in-memory repositories with fault injection
+type faultTest struct {
+	svc      *CouponService
+	coupons  *repotest.CouponRepository
+	claims   *repotest.ClaimRepository
+	injector *faults.Injector
+	coupon   *model.Coupon
+}
+
+func newFaultTest(t *testing.T, stock int32) *faultTest {
+	t.Helper()
+
+	ft := &faultTest{
+		coupons:  repotest.NewCouponRepository(),
+		claims:   repotest.NewClaimRepository(),
+		injector: faults.NewInjector(),
+	}
+	ft.svc = NewCouponService(
+		repository.NewFaultyCouponRepository(ft.coupons, ft.injector),
+		repository.NewFaultyClaimRepository(ft.claims, ft.injector),
+	)
+
+	ft.coupon = &model.Coupon{Name: "FAULTS", Amount: stock, RemainingAmount: stock, IsActive: true, CreatedAt: time.Now()}
+	if err := ft.coupons.CreateCoupon(context.Background(), ft.coupon); err != nil {
+		t.Fatalf("create coupon: %v", err)
+	}
+	return ft
+}
+
+// claimConcurrently has users claim the coupon at the same time and returns how many succeeded
+func (ft *faultTest) claimConcurrently(users int, timeout time.Duration) int {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < users; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			err := ft.svc.ClaimCoupon(ctx, &model.ClaimCouponRequest{
+				UserID:     fmt.Sprintf("user_%d", i),
+				CouponName: ft.coupon.Name,
+			})
+			if err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	return succeeded
+}
+
+// TestClaimCouponNoOrphanedClaims injects faults into the claim path and checks
+// that every claim left behind has had its stock taken
+func TestClaimCouponNoOrphanedClaims(t *testing.T) {
+	tests := []struct {
+		name    string
+		faults  map[string]faults.Fault
+		timeout time.Duration
+		// Stock taken for a claim that was then undone is lost when the
+		// decrement itself was applied but reported as failed
+		stockMayLeak bool
+	}{
+		{
+			name:   "decrement fails",
+			faults: map[string]faults.Fault{"DecrementStock": {Error: "write conflict"}},
+		},
+		{
+			name:   "decrement fails intermittently",
+			faults: map[string]faults.Fault{"DecrementStock": {Error: faults.ErrorNetwork, Probability: 0.5}},
+		},
+		{
+			name: "decrement and undo fail",
+			faults: map[string]faults.Fault{
+				"DecrementStock":  {Error: faults.ErrorNetwork},
+				"DeleteClaimByID": {Error: faults.ErrorNetwork, Times: undoAttempts - 1},
+			},
+		},
+		{
+			name:   "claim insert applied but not acknowledged",
+			faults: map[string]faults.Fault{"CreateClaimIfNotExists": {Error: faults.ErrorNetwork, Partial: true, Probability: 0.5}},
+		},
+		{
+			name:         "decrement applied but not acknowledged",
+			faults:       map[string]faults.Fault{"DecrementStock": {Error: faults.ErrorTimeout, Partial: true, Probability: 0.5}},
+			stockMayLeak: true,
+		},
+		{
+			name:    "request times out during decrement",
+			faults:  map[string]faults.Fault{"DecrementStock": {LatencyMS: 200, Probability: 0.5}},
+			timeout: 20 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const stock, users = 20, 40
+			ft := newFaultTest(t, stock)
+			for method, f := range tt.faults {
+				if err := ft.injector.Set(method, f); err != nil {
+					t.Fatalf("set fault on %s: %v", method, err)
+				}
+			}
+
+			timeout := tt.timeout
+			if timeout == 0 {
+				timeout = 5 * time.Second
+			}
+			succeeded := ft.claimConcurrently(users, timeout)
+
+			taken := int(stock - ft.coupons.Remaining(ft.coupon.ID))
+			claims := ft.claims.Count(ft.coupon.ID)
+			if claims > taken {
+				t.Fatalf("%d claims but only %d units of stock taken: %d orphaned claims", claims, taken, claims-taken)
+			}
+			if !tt.stockMayLeak && claims != taken {
+				t.Fatalf("%d claims but %d units of stock taken", claims, taken)
+			}
+			if succeeded != claims {
+				t.Fatalf("%d claims succeeded but %d claims are stored", succeeded, claims)
+			}
+		})
+	}
+}
+
+// TestFaultInjectorTimes checks that a fault limited to a number of injections clears itself
+func TestFaultInjectorTimes(t *testing.T) {
+	ft := newFaultTest(t, 10)
+	if err := ft.injector.Set("DecrementStock", faults.Fault{Error: "boom", Times: 2}); err != nil {
+		t.Fatalf("set fault: %v", err)
+	}
+	if err := ft.injector.Set("NoSuchMethod", faults.Fault{Error: "boom"}); err == nil {
+		t.Fatal("expected an error for an unknown method")
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		err := ft.svc.ClaimCoupon(ctx, &model.ClaimCouponRequest{UserID: fmt.Sprintf("user_%d", i), CouponName: ft.coupon.Name})
+		if wantErr := i < 2; (err != nil) != wantErr {
+			t.Fatalf("claim %d: err = %v, want error: %v", i, err, wantErr)
+		}
+	}
+	if faults := ft.injector.Faults(); len(faults) != 0 {
+		t.Fatalf("faults after use = %v, want none", faults)
+	}
+}
+
+// TestCouponDetailsReportsOutages checks a lookup that fails because the
+// database is down is not reported as a missing coupon
+func TestCouponDetailsReportsOutages(t *testing.T) {
+	ft := newFaultTest(t, 1)
+	guard := resilience.NewGuard(resilience.RetryPolicy{MaxAttempts: 1}, resilience.BreakerOptions{}, metrics.NewRegistry())
+	svc := NewCouponService(
+		repository.NewResilientCouponRepository(repository.NewFaultyCouponRepository(ft.coupons, ft.injector), guard),
+		repository.NewResilientClaimRepository(ft.claims, guard),
+	)
+	ctx := context.Background()
+
+	if _, err := svc.GetCouponDetails(ctx, "MISSING"); !errors.Is(err, ErrCouponNotFound) {
+		t.Errorf("details of a missing coupon = %v, want ErrCouponNotFound", err)
+	}
+	if err := ft.injector.Set("GetCouponByName", faults.Fault{Error: faults.ErrorNetwork}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.GetCouponDetails(ctx, ft.coupon.Name); !errors.Is(err, ErrDatabaseUnavailable) {
+		t.Errorf("details with the database down = %v, want ErrDatabaseUnavailable", err)
+	}
+}
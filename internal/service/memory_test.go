@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"coupon-system/internal/model"
+	apperrors "coupon-system/pkg/errors"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryWaitlistRepository is an in-memory WaitlistRepository for tests
+// Entries stuck in granting for longer than grantingTimeout are retried, like in MongoDB.
+type memoryWaitlistRepository struct {
+	mu      sync.Mutex
+	entries []*model.WaitlistEntry // In _id (join) order
+}
+
+const memoryGrantingTimeout = time.Minute
+
+func newMemoryWaitlistRepository() *memoryWaitlistRepository {
+	return &memoryWaitlistRepository{}
+}
+
+func (r *memoryWaitlistRepository) Join(_ context.Context, entry *model.WaitlistEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.entries {
+		if e.CouponID == entry.CouponID && e.UserID == entry.UserID {
+			return apperrors.ErrAlreadyWaitlisted
+		}
+	}
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	stored := *entry
+	r.entries = append(r.entries, &stored)
+	sort.Slice(r.entries, func(i, j int) bool { return r.entries[i].ID.Hex() < r.entries[j].ID.Hex() })
+	return nil
+}
+
+func (r *memoryWaitlistRepository) Leave(_ context.Context, couponID interface{}, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, e := range r.entries {
+		if e.CouponID == couponID.(primitive.ObjectID) && e.UserID == userID && e.Status == model.WaitlistStatusWaiting {
+			r.entries = append(r.entries[:i], r.entries[i+1:]...)
+			return nil
+		}
+	}
+	return apperrors.ErrNotWaitlisted
+}
+
+func (r *memoryWaitlistRepository) GetEntry(_ context.Context, couponID interface{}, userID string) (*model.WaitlistEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.entries {
+		if e.CouponID == couponID.(primitive.ObjectID) && e.UserID == userID {
+			entry := *e
+			return &entry, nil
+		}
+	}
+	return nil, apperrors.ErrNotWaitlisted
+}
+
+func (r *memoryWaitlistRepository) Position(_ context.Context, entry *model.WaitlistEntry) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ahead int64
+	for _, e := range r.entries {
+		if e.CouponID == entry.CouponID && e.Status == model.WaitlistStatusWaiting && e.ID.Hex() < entry.ID.Hex() {
+			ahead++
+		}
+	}
+	return ahead + 1, nil
+}
+
+func (r *memoryWaitlistRepository) PopNext(_ context.Context, couponID interface{}) (*model.WaitlistEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, e := range r.entries {
+		if e.CouponID != couponID.(primitive.ObjectID) {
+			continue
+		}
+		if e.Status == model.WaitlistStatusWaiting || (e.Status == model.WaitlistStatusGranting && e.UpdatedAt.Before(now.Add(-memoryGrantingTimeout))) {
+			e.Status = model.WaitlistStatusGranting
+			e.UpdatedAt = now
+			entry := *e
+			return &entry, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryWaitlistRepository) WaitingCoupons(_ context.Context) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	seen := make(map[string]bool)
+	var names []string
+	for _, e := range r.entries {
+		if (e.Status == model.WaitlistStatusWaiting || e.Status == model.WaitlistStatusGranting) && !seen[e.CouponName] {
+			seen[e.CouponName] = true
+			names = append(names, e.CouponName)
+		}
+	}
+	return names, nil
+}
+
+func (r *memoryWaitlistRepository) SetStatus(_ context.Context, entry *model.WaitlistEntry, status string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.entries {
+		if e.ID == entry.ID {
+			e.Status = status
+			e.Reserved = entry.Reserved
+			e.UpdatedAt = time.Now()
+			entry.Status = status
+		}
+	}
+	return nil
+}
+
+// status returns a user's entry status, or "" if they are not on the waitlist
+func (r *memoryWaitlistRepository) status(userID string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.entries {
+		if e.UserID == userID {
+			return e.Status
+		}
+	}
+	return ""
+}
+
+// memoryRaffleRepository is an in-memory RaffleRepository for tests
+type memoryRaffleRepository struct {
+	mu      sync.Mutex
+	entries []*model.RaffleEntry // In entry order
+	draws   map[primitive.ObjectID]*model.RaffleDraw
+}
+
+func newMemoryRaffleRepository() *memoryRaffleRepository {
+	return &memoryRaffleRepository{draws: make(map[primitive.ObjectID]*model.RaffleDraw)}
+}
+
+func (r *memoryRaffleRepository) AddEntry(_ context.Context, entry *model.RaffleEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.entries {
+		if e.CouponID == entry.CouponID && e.UserID == entry.UserID {
+			return apperrors.ErrAlreadyEntered
+		}
+	}
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	stored := *entry
+	r.entries = append(r.entries, &stored)
+	return nil
+}
+
+func (r *memoryRaffleRepository) ListEntrants(_ context.Context, couponID interface{}) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entrants := make([]string, 0)
+	for _, e := range r.entries {
+		if e.CouponID == couponID.(primitive.ObjectID) {
+			entrants = append(entrants, e.UserID)
+		}
+	}
+	return entrants, nil
+}
+
+func (r *memoryRaffleRepository) CreateDraw(_ context.Context, draw *model.RaffleDraw) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.draws[draw.CouponID]; ok {
+		return apperrors.ErrRaffleAlreadyDrawn
+	}
+	if draw.ID.IsZero() {
+		draw.ID = primitive.NewObjectID()
+	}
+	stored := *draw
+	stored.Winners = append([]string(nil), draw.Winners...)
+	r.draws[draw.CouponID] = &stored
+	return nil
+}
+
+func (r *memoryRaffleRepository) GetDraw(_ context.Context, couponID interface{}) (*model.RaffleDraw, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d, ok := r.draws[couponID.(primitive.ObjectID)]
+	if !ok {
+		return nil, apperrors.ErrRaffleNotDrawn
+	}
+	draw := *d
+	draw.Winners = append([]string(nil), d.Winners...)
+	return &draw, nil
+}
+
+func (r *memoryRaffleRepository) CompleteDraw(_ context.Context, draw *model.RaffleDraw) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	draw.Status = model.RaffleDrawStatusCompleted
+	draw.CompletedAt = &now
+	if d, ok := r.draws[draw.CouponID]; ok {
+		d.Status, d.Claimed, d.CompletedAt = draw.Status, draw.Claimed, &now
+	}
+	return nil
+}
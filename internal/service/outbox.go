@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"coupon-system/internal/events"
+	"coupon-system/internal/model"
+	"coupon-system/internal/repository"
+	"fmt"
+	"log"
+	"time"
+)
+
+// pendingEventBatch is how many claims RecoverEvents handles per call
+const pendingEventBatch = 100
+
+// WithOutbox records domain events in the outbox instead of publishing them
+// directly; a relay publishes them from there
+// Changes and their events are written in one transaction where tx supports
+// it. Without one, claims are written marked EventPending and RecoverEvents
+// records the events of claims still marked, so no claim goes unannounced.
+func WithOutbox(outbox repository.OutboxRepository, tx repository.Transactor) Option {
+	return func(s *CouponService) {
+		s.outbox = outbox
+		s.tx = tx
+	}
+}
+
+// atomically runs fn in a transaction when the outbox is enabled, so the
+// events fn records commit together with its state change
+func (s *CouponService) atomically(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.outbox == nil || s.tx == nil {
+		return fn(ctx)
+	}
+	return s.tx.WithTransaction(ctx, fn)
+}
+
+// transactional reports whether changes and their events commit together
+func (s *CouponService) transactional() bool {
+	if s.outbox == nil || s.tx == nil {
+		return false
+	}
+	_, none := s.tx.(repository.NoTransactor)
+	return !none
+}
+
+// record stores events in the outbox, or publishes them directly without one
+// Events are stored in the order given
+func (s *CouponService) record(ctx context.Context, evts ...events.Event) error {
+	if len(evts) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	if s.outbox == nil {
+		for _, e := range evts {
+			if e.OccurredAt.IsZero() {
+				e.OccurredAt = now
+			}
+			_ = s.events.Publish(ctx, e)
+		}
+		return nil
+	}
+
+	entries := make([]*model.OutboxEvent, len(evts))
+	for i, e := range evts {
+		if e.OccurredAt.IsZero() {
+			e.OccurredAt = now
+		}
+		entries[i] = &model.OutboxEvent{
+			Key:        e.Key,
+			Type:       e.Type,
+			CouponName: e.CouponName,
+			UserID:     e.UserID,
+			Data:       e.Data,
+			OccurredAt: e.OccurredAt,
+		}
+	}
+	return s.outbox.Append(ctx, entries...)
+}
+
+// recordSoldOut records that a coupon ran out of stock, once per sell-out
+// Called when a claim is turned away for lack of stock. The key changes
+// whenever stock is added back (which bumps updated_at), so a coupon that is
+// restocked and sells out again produces a new event. Repeats are filtered in
+// memory before they reach the outbox's unique key.
+func (s *CouponService) recordSoldOut(ctx context.Context, coupon *model.Coupon) {
+	key := fmt.Sprintf("%s:%s:%d", events.TypeCouponSoldOut, coupon.ID.Hex(), coupon.UpdatedAt.UnixNano())
+	if last, ok := s.soldOut.Load(coupon.ID); ok && last == key {
+		return
+	}
+
+	err := s.record(ctx, events.Event{
+		Key:        key,
+		Type:       events.TypeCouponSoldOut,
+		CouponName: coupon.Name,
+		Data:       map[string]interface{}{"amount": coupon.Amount},
+	})
+	if err != nil {
+		log.Printf("Outbox: failed to record sell-out of %s: %v", coupon.Name, err)
+		return
+	}
+	s.soldOut.Store(coupon.ID, key)
+}
+
+// claimedEvent is the event for a new claim
+func claimedEvent(claim *model.Claim) events.Event {
+	e := events.Event{
+		Type:       events.TypeCouponClaimed,
+		CouponName: claim.CouponName,
+		UserID:     claim.UserID,
+		OccurredAt: claim.CreatedAt,
+	}
+	if !claim.ID.IsZero() {
+		// Keyed by the claim, so recording it again after a partial failure is a no-op
+		e.Key = events.TypeCouponClaimed + ":" + claim.ID.Hex()
+		e.Data = map[string]interface{}{"claim_id": claim.ID.Hex()}
+	}
+	return e
+}
+
+// claimEvents are the events of a new claim: coupon.claimed, and
+// waitlist.granted for claims granted from the waitlist
+func claimEvents(claim *model.Claim) []events.Event {
+	evts := []events.Event{claimedEvent(claim)}
+	if claim.Waitlisted {
+		evts = append(evts, events.Event{
+			Key:        events.TypeWaitlistGranted + ":" + claim.ID.Hex(),
+			Type:       events.TypeWaitlistGranted,
+			CouponName: claim.CouponName,
+			UserID:     claim.UserID,
+			OccurredAt: claim.CreatedAt,
+			Data:       map[string]interface{}{"claim_id": claim.ID.Hex()},
+		})
+	}
+	return evts
+}
+
+// recordClaimed records the events of a committed claim
+// If that fails, a claim marked EventPending keeps its mark and RecoverEvents
+// records the events later; once they are stored the mark is removed.
+func (s *CouponService) recordClaimed(ctx context.Context, claim *model.Claim) {
+	if err := s.record(ctx, claimEvents(claim)...); err != nil {
+		if claim.EventPending {
+			log.Printf("Outbox: event of claim %s left for recovery: %v", claim.ID.Hex(), err)
+		} else {
+			log.Printf("Outbox: failed to record claim of %s by %s: %v", claim.CouponName, claim.UserID, err)
+		}
+		return
+	}
+	if claim.EventPending {
+		// Left marked, the event is recorded again and dropped as a duplicate
+		if err := s.claimRepo.ClearEventPending(ctx, []interface{}{claim.ID}); err != nil {
+			log.Printf("Outbox: failed to clear pending event of claim %s: %v", claim.ID.Hex(), err)
+		}
+	}
+}
+
+// RecoverEvents records the events of claims created before the given time
+// that are still marked EventPending; younger claims are probably still being
+// recorded by their own request. Returns how many events were recorded.
+func (s *CouponService) RecoverEvents(ctx context.Context, before time.Time) (int, error) {
+	if s.outbox == nil {
+		return 0, nil
+	}
+
+	claims, err := s.claimRepo.ListEventPending(ctx, before, pendingEventBatch)
+	if err != nil || len(claims) == 0 {
+		return 0, err
+	}
+
+	var evts []events.Event
+	ids := make([]interface{}, len(claims))
+	for i, claim := range claims {
+		evts = append(evts, claimEvents(claim)...)
+		ids[i] = claim.ID
+	}
+	if err := s.record(ctx, evts...); err != nil {
+		return 0, err
+	}
+	return len(claims), s.claimRepo.ClearEventPending(ctx, ids)
+}
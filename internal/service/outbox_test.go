@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"coupon-system/internal/cache"
+	"coupon-system/internal/events"
+	"coupon-system/internal/metrics"
+	"coupon-system/internal/model"
+	"coupon-system/internal/repository"
+	"coupon-system/internal/repository/repotest"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestClaimCouponRecordsEvents checks the events a claim and a sell-out record,
+// and that a claim whose event cannot be stored keeps it pending for recovery
+func TestClaimCouponRecordsEvents(t *testing.T) {
+	ctx := context.Background()
+	coupons := repotest.NewCouponRepository()
+	claims := repotest.NewClaimRepository()
+	outbox := repotest.NewOutboxRepository()
+	svc := NewCouponService(coupons, claims, WithOutbox(outbox, repository.NoTransactor{}))
+
+	coupon := &model.Coupon{Name: "OUTBOX", Amount: 2, RemainingAmount: 2, IsActive: true, CreatedAt: time.Now()}
+	if err := coupons.CreateCoupon(ctx, coupon); err != nil {
+		t.Fatalf("create coupon: %v", err)
+	}
+	claim := func(user string) error {
+		return svc.ClaimCoupon(ctx, &model.ClaimCouponRequest{UserID: user, CouponName: coupon.Name})
+	}
+
+	outbox.SetFailing(true)
+	if err := claim("u1"); err != nil {
+		t.Fatalf("claim with the outbox down: %v", err)
+	}
+	if pending, _ := claims.ListEventPending(ctx, time.Now().Add(time.Second), 10); len(pending) != 1 {
+		t.Fatalf("%d claims pending their event, want 1", len(pending))
+	}
+
+	// The relay recovers the event once the outbox is back, exactly once
+	outbox.SetFailing(false)
+	for i, want := range []int{1, 0} {
+		if n, err := svc.RecoverEvents(ctx, time.Now().Add(time.Second)); n != want || err != nil {
+			t.Fatalf("recovery %d = %d, %v; want %d", i+1, n, err, want)
+		}
+	}
+	if err := claim("u2"); err != nil {
+		t.Fatalf("claim by u2: %v", err)
+	}
+	if pending, _ := claims.ListEventPending(ctx, time.Now().Add(time.Second), 10); len(pending) != 0 {
+		t.Fatalf("%d claims still pending their event, want 0", len(pending))
+	}
+	for _, user := range []string{"u3", "u4"} {
+		if err := claim(user); err != ErrNoStock {
+			t.Fatalf("claim by %s: err = %v, want ErrNoStock", user, err)
+		}
+	}
+
+	want := []string{events.TypeCouponClaimed, events.TypeCouponClaimed, events.TypeCouponSoldOut}
+	got := outbox.Types()
+	if len(got) != len(want) {
+		t.Fatalf("recorded %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("recorded %v, want %v", got, want)
+		}
+	}
+}
+
+// countingTransactor runs fn directly and counts the transactions
+type countingTransactor struct {
+	n int
+}
+
+func (t *countingTransactor) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	t.n++
+	return fn(ctx)
+}
+
+// TestClaimCouponInTransaction checks a claim, its stock and its event are
+// written in one transaction, leaving no event pending
+func TestClaimCouponInTransaction(t *testing.T) {
+	ctx := context.Background()
+	coupons := repotest.NewCouponRepository()
+	claims := repotest.NewClaimRepository()
+	outbox := repotest.NewOutboxRepository()
+	tx := &countingTransactor{}
+	svc := NewCouponService(coupons, claims, WithOutbox(outbox, tx))
+
+	coupon := &model.Coupon{Name: "TX", Amount: 1, RemainingAmount: 1, IsActive: true, CreatedAt: time.Now()}
+	if err := coupons.CreateCoupon(ctx, coupon); err != nil {
+		t.Fatalf("create coupon: %v", err)
+	}
+	if err := svc.ClaimCoupon(ctx, &model.ClaimCouponRequest{UserID: "u1", CouponName: coupon.Name}); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+
+	if tx.n != 1 {
+		t.Errorf("%d transactions, want 1", tx.n)
+	}
+	if got := outbox.Types(); len(got) != 1 || got[0] != events.TypeCouponClaimed {
+		t.Errorf("recorded %v, want one claim event", got)
+	}
+	if pending, _ := claims.ListEventPending(ctx, time.Now().Add(time.Second), 10); len(pending) != 0 {
+		t.Errorf("%d claims pending their event inside a transaction", len(pending))
+	}
+	if err := svc.ClaimCoupon(ctx, &model.ClaimCouponRequest{UserID: "u1", CouponName: coupon.Name}); !errors.Is(err, ErrAlreadyClaimed) {
+		t.Errorf("second claim = %v, want ErrAlreadyClaimed", err)
+	}
+}
+
+// TestWaitlistGrantRecordsEvents checks a grant from the waitlist records its
+// claim and grant events in the claim's transaction, or keeps them pending on
+// the claim for recovery when there is no transaction and the outbox is down
+func TestWaitlistGrantRecordsEvents(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		tx   repository.Transactor
+	}{
+		{"without a transaction", repository.NoTransactor{}},
+		{"in a transaction", &countingTransactor{}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			coupons := repotest.NewCouponRepository()
+			claims := repotest.NewClaimRepository()
+			outbox := repotest.NewOutboxRepository()
+			svc := NewCouponService(coupons, claims, WithOutbox(outbox, tt.tx), WithWaitlist(newMemoryWaitlistRepository()))
+
+			coupon := &model.Coupon{Name: "GRANT", Amount: 1, RemainingAmount: 0, IsActive: true, CreatedAt: time.Now()}
+			if err := coupons.CreateCoupon(ctx, coupon); err != nil {
+				t.Fatalf("create coupon: %v", err)
+			}
+			if _, err := svc.JoinWaitlist(ctx, coupon.Name, "u1"); err != nil {
+				t.Fatalf("join: %v", err)
+			}
+			_ = coupons.IncrementStock(ctx, coupon.ID, 1)
+
+			counting, transactional := tt.tx.(*countingTransactor)
+			if transactional {
+				svc.promoteWaitlist(ctx, coupon)
+				if counting.n != 1 {
+					t.Errorf("%d transactions, want 1", counting.n)
+				}
+			} else {
+				// Granted while the outbox is down, with both events left for the relay
+				outbox.SetFailing(true)
+				svc.promoteWaitlist(ctx, coupon)
+				outbox.SetFailing(false)
+				pending, _ := claims.ListEventPending(ctx, time.Now().Add(time.Second), 10)
+				if len(pending) != 1 || !pending[0].Waitlisted {
+					t.Fatalf("%d claims pending their events, want the grant", len(pending))
+				}
+				if n, err := svc.RecoverEvents(ctx, time.Now().Add(time.Second)); n != 1 || err != nil {
+					t.Fatalf("recovery = %d, %v; want 1", n, err)
+				}
+			}
+
+			if granted, _ := claims.HasUserClaimed(ctx, "u1", coupon.ID); !granted {
+				t.Fatal("u1 was not granted a claim")
+			}
+			if pending, _ := claims.ListEventPending(ctx, time.Now().Add(time.Second), 10); len(pending) != 0 {
+				t.Errorf("%d claims still pending their events", len(pending))
+			}
+			want := []string{events.TypeCouponClaimed, events.TypeWaitlistGranted}
+			if got := outbox.Types(); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+				t.Errorf("recorded %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// TestSellOutAfterRestockIsRecorded checks a coupon that is restocked and sells
+// out again records a second sell-out, even with its lookups cached
+func TestSellOutAfterRestockIsRecorded(t *testing.T) {
+	ctx := context.Background()
+	coupons := repository.NewCachedCouponRepository(repotest.NewCouponRepository(), repository.CacheOptions{TTL: time.Minute}, metrics.NewRegistry())
+	outbox := repotest.NewOutboxRepository()
+	svc := NewCouponService(coupons, repotest.NewClaimRepository(),
+		WithOutbox(outbox, repository.NoTransactor{}), WithCache(cache.NewMemory(), time.Minute, 0))
+
+	coupon := &model.Coupon{Name: "RESTOCKED", Amount: 1, RemainingAmount: 1, IsActive: true, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := coupons.CreateCoupon(ctx, coupon); err != nil {
+		t.Fatalf("create coupon: %v", err)
+	}
+	claimUntilSoldOut := func(users ...string) {
+		t.Helper()
+		for i, user := range users {
+			err := svc.ClaimCoupon(ctx, &model.ClaimCouponRequest{UserID: user, CouponName: coupon.Name})
+			if last := i == len(users)-1; (last && !errors.Is(err, ErrNoStock)) || (!last && err != nil) {
+				t.Fatalf("claim by %s: %v", user, err)
+			}
+		}
+	}
+
+	claimUntilSoldOut("u1", "u2")
+	// MongoDB and the shared cache keep updated_at to the millisecond
+	time.Sleep(2 * time.Millisecond)
+	if _, err := svc.RestockCoupon(ctx, coupon.Name, 1); err != nil {
+		t.Fatalf("restock: %v", err)
+	}
+	claimUntilSoldOut("u3", "u4")
+
+	soldOut := 0
+	for _, typ := range outbox.Types() {
+		if typ == events.TypeCouponSoldOut {
+			soldOut++
+		}
+	}
+	if soldOut != 2 {
+		t.Errorf("%d sell-outs recorded, want 2", soldOut)
+	}
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"coupon-system/internal/model"
+)
+
+// JoinQueue puts a user in a queue-enabled coupon's virtual queue
+func (s *CouponService) JoinQueue(ctx context.Context, name, userID string) (*model.QueueTicket, error) {
+	if s.admission == nil {
+		return nil, ErrQueueNotEnabled
+	}
+
+	coupon, err := s.couponRepo.GetCouponByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !coupon.QueueEnabled {
+		return nil, ErrQueueNotEnabled
+	}
+	if !coupon.IsActive {
+		return nil, ErrCouponInactive
+	}
+
+	return s.admission.Join(coupon.Name, userID)
+}
+
+// GetQueueTicket returns the status of a queue ticket
+// This never touches the database, so it is safe to poll
+func (s *CouponService) GetQueueTicket(token string) (*model.QueueTicket, error) {
+	if s.admission == nil {
+		return nil, ErrQueueNotEnabled
+	}
+
+	return s.admission.Status(token)
+}
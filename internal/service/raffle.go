@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"coupon-system/internal/model"
+	"coupon-system/internal/raffle"
+	"errors"
+	"time"
+)
+
+// EnterRaffle enters a user into a raffle coupon's draw
+// Entries are only accepted while the entry window is open
+func (s *CouponService) EnterRaffle(ctx context.Context, name, userID string) (*model.RaffleEntry, error) {
+	coupon, err := s.raffleCoupon(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !coupon.IsActive {
+		return nil, ErrCouponInactive
+	}
+
+	now := time.Now()
+	if now.Before(*coupon.EntryStartsAt) || !now.Before(*coupon.EntryEndsAt) {
+		return nil, ErrRaffleEntryClosed
+	}
+
+	entry := &model.RaffleEntry{
+		CouponID:  coupon.ID,
+		UserID:    userID,
+		CreatedAt: now,
+	}
+	if err := s.raffleRepo.AddEntry(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// DrawRaffle selects the winners of a raffle and converts them into claims
+// The seed, entrant digest and winners are persisted before any claim is
+// created, so the draw can be verified afterwards and a draw interrupted half
+// way is finished (never re-drawn) when called again
+func (s *CouponService) DrawRaffle(ctx context.Context, name string) (*model.RaffleDraw, error) {
+	coupon, err := s.raffleCoupon(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	draw, err := s.raffleRepo.GetDraw(ctx, coupon.ID)
+	switch {
+	case err == nil && draw.Status == model.RaffleDrawStatusCompleted:
+		return nil, ErrRaffleAlreadyDrawn
+	case err == nil:
+		// Resume an interrupted draw with the winners already recorded
+	case errors.Is(err, ErrRaffleNotDrawn):
+		if time.Now().Before(*coupon.EntryEndsAt) {
+			return nil, ErrRaffleEntryOpen
+		}
+		if draw, err = s.newDraw(ctx, coupon); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	// Convert winners into claims through the bulk claim path, which reserves
+	// stock once and keeps the one-claim-per-user guarantee
+	if len(draw.Winners) > 0 {
+		resp, err := s.BulkClaim(ctx, &model.BulkClaimRequest{CouponName: coupon.Name, UserIDs: draw.Winners})
+		if err != nil {
+			return nil, err
+		}
+		// Winners already holding a claim were granted on a previous attempt
+		draw.Claimed = resp.Claimed + resp.AlreadyClaimed
+	}
+
+	if err := s.raffleRepo.CompleteDraw(ctx, draw); err != nil {
+		return nil, err
+	}
+
+	return draw, nil
+}
+
+// GetRaffleDraw retrieves the persisted draw of a raffle
+func (s *CouponService) GetRaffleDraw(ctx context.Context, name string) (*model.RaffleDraw, error) {
+	coupon, err := s.raffleCoupon(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.raffleRepo.GetDraw(ctx, coupon.ID)
+}
+
+// VerifyRaffleDraw re-runs a draw from its persisted seed against the stored
+// entries and checks that both the entrant set and the winners match
+func (s *CouponService) VerifyRaffleDraw(ctx context.Context, name string) (*model.RaffleVerification, error) {
+	coupon, err := s.raffleCoupon(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	draw, err := s.raffleRepo.GetDraw(ctx, coupon.ID)
+	if err != nil {
+		return nil, err
+	}
+	entrants, err := s.raffleRepo.ListEntrants(ctx, coupon.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	winners, err := raffle.Select(draw.Seed, entrants, int(draw.Stock))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &model.RaffleVerification{
+		CouponName:     coupon.Name,
+		EntryCount:     len(entrants),
+		ExpectedHash:   draw.EntriesHash,
+		RecomputedHash: raffle.EntriesDigest(entrants),
+		WinnersMatch:   equalStrings(winners, draw.Winners),
+	}
+	result.EntriesMatch = result.ExpectedHash == result.RecomputedHash
+	result.Verified = result.EntriesMatch && result.WinnersMatch
+
+	return result, nil
+}
+
+// newDraw selects winners with a fresh seed and persists the draw
+func (s *CouponService) newDraw(ctx context.Context, coupon *model.Coupon) (*model.RaffleDraw, error) {
+	entrants, err := s.raffleRepo.ListEntrants(ctx, coupon.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	seed, err := raffle.NewSeed()
+	if err != nil {
+		return nil, err
+	}
+	winners, err := raffle.Select(seed, entrants, int(coupon.RemainingAmount))
+	if err != nil {
+		return nil, err
+	}
+
+	draw := &model.RaffleDraw{
+		CouponID:    coupon.ID,
+		CouponName:  coupon.Name,
+		Status:      model.RaffleDrawStatusDrawn,
+		Algorithm:   raffle.Algorithm,
+		Seed:        seed,
+		EntryCount:  len(entrants),
+		EntriesHash: raffle.EntriesDigest(entrants),
+		Stock:       coupon.RemainingAmount,
+		Winners:     winners,
+		DrawnAt:     time.Now(),
+	}
+	if err := s.raffleRepo.CreateDraw(ctx, draw); err != nil {
+		return nil, err
+	}
+
+	return draw, nil
+}
+
+// raffleCoupon loads a coupon and checks that it is a raffle
+func (s *CouponService) raffleCoupon(ctx context.Context, name string) (*model.Coupon, error) {
+	if s.raffleRepo == nil {
+		return nil, ErrNotRaffle
+	}
+
+	coupon, err := s.couponRepo.GetCouponByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !coupon.IsRaffle() {
+		return nil, ErrNotRaffle
+	}
+
+	return coupon, nil
+}
+
+// equalStrings reports whether two slices hold the same strings in the same order
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,262 @@
+package service
+
+import (
+	"context"
+	"coupon-system/internal/faults"
+	"coupon-system/internal/model"
+	"coupon-system/internal/repository"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// raffleTest is a raffle coupon over in-memory repositories
+type raffleTest struct {
+	*faultTest
+	raffles *hookedRaffleRepository
+}
+
+// hookedRaffleRepository lets tests observe draws being stored and fail completions
+type hookedRaffleRepository struct {
+	*memoryRaffleRepository
+	onCreateDraw    func()
+	completeFailure error
+}
+
+func (r *hookedRaffleRepository) CreateDraw(ctx context.Context, draw *model.RaffleDraw) error {
+	if r.onCreateDraw != nil {
+		r.onCreateDraw()
+	}
+	return r.memoryRaffleRepository.CreateDraw(ctx, draw)
+}
+
+func (r *hookedRaffleRepository) CompleteDraw(ctx context.Context, draw *model.RaffleDraw) error {
+	if err := r.completeFailure; err != nil {
+		r.completeFailure = nil
+		return err
+	}
+	return r.memoryRaffleRepository.CompleteDraw(ctx, draw)
+}
+
+// newRaffleTest creates a raffle coupon whose entry window spans the given offsets from now
+func newRaffleTest(t *testing.T, stock int32, opens, closes time.Duration) *raffleTest {
+	t.Helper()
+	ft := newFaultTest(t, stock)
+	rt := &raffleTest{faultTest: ft, raffles: &hookedRaffleRepository{memoryRaffleRepository: newMemoryRaffleRepository()}}
+	ft.svc = NewCouponService(
+		repository.NewFaultyCouponRepository(ft.coupons, ft.injector),
+		repository.NewFaultyClaimRepository(ft.claims, ft.injector),
+		WithRaffles(rt.raffles),
+	)
+
+	now := time.Now()
+	startsAt, endsAt := now.Add(opens), now.Add(closes)
+	ft.coupon = &model.Coupon{
+		Name: "RAFFLE", Amount: stock, RemainingAmount: stock, IsActive: true, CreatedAt: now, ExpiresAt: now.Add(time.Hour),
+		DistributionMode: model.DistributionRaffle, EntryStartsAt: &startsAt, EntryEndsAt: &endsAt,
+	}
+	if err := ft.coupons.CreateCoupon(context.Background(), ft.coupon); err != nil {
+		t.Fatalf("create coupon: %v", err)
+	}
+	return rt
+}
+
+// enter adds entries directly, bypassing the entry window
+func (rt *raffleTest) enter(t *testing.T, users int) {
+	t.Helper()
+	for i := 0; i < users; i++ {
+		entry := &model.RaffleEntry{CouponID: rt.coupon.ID, UserID: fmt.Sprintf("user_%d", i), CreatedAt: time.Now()}
+		if err := rt.raffles.AddEntry(context.Background(), entry); err != nil {
+			t.Fatalf("add entry: %v", err)
+		}
+	}
+}
+
+// TestEnterRaffleWindow checks entries are only accepted while the window is open
+func TestEnterRaffleWindow(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name          string
+		opens, closes time.Duration
+		want          error
+	}{
+		{"not yet open", time.Minute, time.Hour, ErrRaffleEntryClosed},
+		{"open", -time.Minute, time.Hour, nil},
+		{"closed", -time.Hour, -time.Minute, ErrRaffleEntryClosed},
+	} {
+		rt := newRaffleTest(t, 1, tc.opens, tc.closes)
+		if _, err := rt.svc.EnterRaffle(ctx, rt.coupon.Name, "alice"); !errors.Is(err, tc.want) {
+			t.Errorf("%s: EnterRaffle = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+
+	rt := newRaffleTest(t, 1, -time.Minute, time.Hour)
+	if _, err := rt.svc.EnterRaffle(ctx, rt.coupon.Name, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rt.svc.EnterRaffle(ctx, rt.coupon.Name, "alice"); !errors.Is(err, ErrAlreadyEntered) {
+		t.Errorf("second entry = %v, want ErrAlreadyEntered", err)
+	}
+	if _, err := rt.svc.EnterRaffle(ctx, "FAULTS", "alice"); !errors.Is(err, ErrNotRaffle) {
+		t.Errorf("entry into a first come, first served coupon = %v, want ErrNotRaffle", err)
+	}
+	if err := rt.coupons.SetActive(ctx, rt.coupon.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rt.svc.EnterRaffle(ctx, rt.coupon.Name, "bob"); !errors.Is(err, ErrCouponInactive) {
+		t.Errorf("entry into a paused raffle = %v, want ErrCouponInactive", err)
+	}
+}
+
+// TestDrawRafflePersistsDrawFirst checks the draw is stored before any winner is
+// granted, so a draw whose grant fails is finished with the same winners
+func TestDrawRafflePersistsDrawFirst(t *testing.T) {
+	ctx := context.Background()
+	rt := newRaffleTest(t, 3, -time.Hour, time.Minute)
+	if _, err := rt.svc.DrawRaffle(ctx, rt.coupon.Name); !errors.Is(err, ErrRaffleEntryOpen) {
+		t.Errorf("draw while entries are open = %v, want ErrRaffleEntryOpen", err)
+	}
+
+	rt = newRaffleTest(t, 3, -time.Hour, -time.Minute)
+	rt.enter(t, 5)
+	claimsAtDraw := -1
+	rt.raffles.onCreateDraw = func() { claimsAtDraw = rt.claims.Count(rt.coupon.ID) }
+	if err := rt.injector.Set("CreateClaimsIfNotExist", faults.Fault{Error: "boom", Times: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rt.svc.DrawRaffle(ctx, rt.coupon.Name); err == nil {
+		t.Fatal("DrawRaffle succeeded despite the failed grant")
+	}
+	if claimsAtDraw != 0 {
+		t.Errorf("%d claims existed when the draw was stored, want 0", claimsAtDraw)
+	}
+	stored, err := rt.svc.GetRaffleDraw(ctx, rt.coupon.Name)
+	if err != nil {
+		t.Fatalf("the draw was not stored before granting: %v", err)
+	}
+	if stored.Status != model.RaffleDrawStatusDrawn || len(stored.Winners) != 3 {
+		t.Fatalf("stored draw = %+v, want 3 winners not yet granted", stored)
+	}
+
+	draw, err := rt.svc.DrawRaffle(ctx, rt.coupon.Name)
+	if err != nil {
+		t.Fatalf("resumed DrawRaffle: %v", err)
+	}
+	if draw.Seed != stored.Seed || !reflect.DeepEqual(draw.Winners, stored.Winners) {
+		t.Errorf("resumed draw picked %v with seed %s, want the stored %v with seed %s", draw.Winners, draw.Seed, stored.Winners, stored.Seed)
+	}
+	if draw.Status != model.RaffleDrawStatusCompleted || draw.Claimed != 3 {
+		t.Errorf("draw status = %s, claimed = %d, want completed and 3", draw.Status, draw.Claimed)
+	}
+	if remaining, claims := rt.coupons.Remaining(rt.coupon.ID), rt.claims.Count(rt.coupon.ID); remaining != 0 || claims != 3 {
+		t.Errorf("remaining = %d, claims = %d, want 0 and 3", remaining, claims)
+	}
+	if _, err := rt.svc.DrawRaffle(ctx, rt.coupon.Name); !errors.Is(err, ErrRaffleAlreadyDrawn) {
+		t.Errorf("third DrawRaffle = %v, want ErrRaffleAlreadyDrawn", err)
+	}
+}
+
+// TestResumedDrawGrantsOnce checks a draw interrupted after some or all of its
+// winners were granted is finished without re-drawing or granting anyone twice
+func TestResumedDrawGrantsOnce(t *testing.T) {
+	ctx := context.Background()
+
+	// Interrupted after every winner was granted, before the draw was completed
+	rt := newRaffleTest(t, 3, -time.Hour, -time.Minute)
+	rt.enter(t, 5)
+	rt.raffles.completeFailure = errors.New("boom")
+	if _, err := rt.svc.DrawRaffle(ctx, rt.coupon.Name); err == nil {
+		t.Fatal("DrawRaffle succeeded despite the failed completion")
+	}
+	stored, _ := rt.svc.GetRaffleDraw(ctx, rt.coupon.Name)
+	draw, err := rt.svc.DrawRaffle(ctx, rt.coupon.Name)
+	if err != nil {
+		t.Fatalf("resumed DrawRaffle: %v", err)
+	}
+	if !reflect.DeepEqual(draw.Winners, stored.Winners) || draw.Claimed != 3 {
+		t.Errorf("resumed draw = %v with %d claimed, want %v with 3", draw.Winners, draw.Claimed, stored.Winners)
+	}
+	if remaining, claims := rt.coupons.Remaining(rt.coupon.ID), rt.claims.Count(rt.coupon.ID); remaining != 0 || claims != 3 {
+		t.Errorf("after completion failure: remaining = %d, claims = %d, want 0 and 3", remaining, claims)
+	}
+
+	// Interrupted part way through the grant: the draw is stored, the grant
+	// fails, then one winner is granted as a crashed attempt would have left it
+	rt = newRaffleTest(t, 3, -time.Hour, -time.Minute)
+	rt.enter(t, 5)
+	if err := rt.injector.Set("CreateClaimsIfNotExist", faults.Fault{Error: "boom", Times: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rt.svc.DrawRaffle(ctx, rt.coupon.Name); err == nil {
+		t.Fatal("DrawRaffle succeeded despite the failed grant")
+	}
+	stored, _ = rt.svc.GetRaffleDraw(ctx, rt.coupon.Name)
+	if err := rt.coupons.DecrementStock(ctx, rt.coupon.ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.claims.CreateClaim(ctx, &model.Claim{UserID: stored.Winners[0], CouponID: rt.coupon.ID, CouponName: rt.coupon.Name, CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	draw, err = rt.svc.DrawRaffle(ctx, rt.coupon.Name)
+	if err != nil {
+		t.Fatalf("resumed DrawRaffle: %v", err)
+	}
+	if !reflect.DeepEqual(draw.Winners, stored.Winners) || draw.Claimed != 3 {
+		t.Errorf("resumed draw = %v with %d claimed, want %v with 3", draw.Winners, draw.Claimed, stored.Winners)
+	}
+	if remaining, claims := rt.coupons.Remaining(rt.coupon.ID), rt.claims.Count(rt.coupon.ID); remaining != 0 || claims != 3 {
+		t.Errorf("after partial grant: remaining = %d, claims = %d, want 0 and 3", remaining, claims)
+	}
+}
+
+// TestVerifyRaffleDraw checks a draw verifies against its entries, and that
+// changed entries or winners are reported
+func TestVerifyRaffleDraw(t *testing.T) {
+	ctx := context.Background()
+	rt := newRaffleTest(t, 2, -time.Hour, -time.Minute)
+	rt.enter(t, 5)
+	if _, err := rt.svc.VerifyRaffleDraw(ctx, rt.coupon.Name); !errors.Is(err, ErrRaffleNotDrawn) {
+		t.Errorf("VerifyRaffleDraw before the draw = %v, want ErrRaffleNotDrawn", err)
+	}
+	if _, err := rt.svc.DrawRaffle(ctx, rt.coupon.Name); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := rt.svc.VerifyRaffleDraw(ctx, rt.coupon.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Verified || result.EntryCount != 5 {
+		t.Errorf("verification = %+v, want verified with 5 entries", result)
+	}
+
+	// Swapping a winner for a loser is caught
+	rt.raffles.mu.Lock()
+	draw := rt.raffles.draws[rt.coupon.ID]
+	winners := map[string]bool{}
+	for _, w := range draw.Winners {
+		winners[w] = true
+	}
+	for _, e := range rt.raffles.entries {
+		if !winners[e.UserID] {
+			draw.Winners[0] = e.UserID
+			break
+		}
+	}
+	rt.raffles.mu.Unlock()
+	if result, err = rt.svc.VerifyRaffleDraw(ctx, rt.coupon.Name); err != nil || result.Verified || result.WinnersMatch || !result.EntriesMatch {
+		t.Errorf("verification with a swapped winner = %+v, %v; want winners not to match", result, err)
+	}
+
+	// So is an entry added after the draw
+	late := &model.RaffleEntry{CouponID: rt.coupon.ID, UserID: "late", CreatedAt: time.Now()}
+	if err := rt.raffles.AddEntry(ctx, late); err != nil {
+		t.Fatal(err)
+	}
+	if result, err = rt.svc.VerifyRaffleDraw(ctx, rt.coupon.Name); err != nil || result.Verified || result.EntriesMatch || result.EntryCount != 6 {
+		t.Errorf("verification with a late entry = %+v, %v; want entries not to match", result, err)
+	}
+}
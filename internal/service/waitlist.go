@@ -0,0 +1,304 @@
+package service
+
+import (
+	"context"
+	"coupon-system/internal/events"
+	"coupon-system/internal/model"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// JoinWaitlist puts a user on a sold-out coupon's waitlist
+// Users are granted claims in the order they joined whenever stock is returned
+func (s *CouponService) JoinWaitlist(ctx context.Context, name, userID string) (*model.WaitlistStatusResponse, error) {
+	if s.waitlistRepo == nil {
+		return nil, ErrWaitlistDisabled
+	}
+
+	coupon, err := s.couponRepo.GetCouponByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !coupon.IsActive {
+		return nil, ErrCouponInactive
+	}
+	if coupon.IsRaffle() {
+		return nil, ErrRaffleOnly
+	}
+	if coupon.RemainingAmount > 0 {
+		return nil, ErrStockAvailable
+	}
+
+	claimed, err := s.claimRepo.HasUserClaimed(ctx, userID, coupon.ID)
+	if err != nil {
+		return nil, err
+	}
+	if claimed {
+		return nil, ErrAlreadyClaimed
+	}
+
+	now := time.Now()
+	entry := &model.WaitlistEntry{
+		CouponID:   coupon.ID,
+		CouponName: coupon.Name,
+		UserID:     userID,
+		Status:     model.WaitlistStatusWaiting,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := s.waitlistRepo.Join(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	// Stock may have come back between the check above and the join
+	s.promoteWaitlist(ctx, coupon)
+
+	return s.waitlistStatus(ctx, coupon, userID)
+}
+
+// GetWaitlistStatus returns a user's status and position on a coupon's waitlist
+func (s *CouponService) GetWaitlistStatus(ctx context.Context, name, userID string) (*model.WaitlistStatusResponse, error) {
+	if s.waitlistRepo == nil {
+		return nil, ErrWaitlistDisabled
+	}
+
+	coupon, err := s.couponRepo.GetCouponByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.waitlistStatus(ctx, coupon, userID)
+}
+
+// LeaveWaitlist removes a waiting user from a coupon's waitlist
+func (s *CouponService) LeaveWaitlist(ctx context.Context, name, userID string) error {
+	if s.waitlistRepo == nil {
+		return ErrWaitlistDisabled
+	}
+
+	coupon, err := s.couponRepo.GetCouponByName(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	return s.waitlistRepo.Leave(ctx, coupon.ID, userID)
+}
+
+// CancelClaim removes a user's claim and returns its stock to the coupon
+// The returned unit goes to the next user on the waitlist, if any
+func (s *CouponService) CancelClaim(ctx context.Context, name, userID string) error {
+	coupon, err := s.couponRepo.GetCouponByName(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	err = s.atomically(ctx, func(ctx context.Context) error {
+		if err := s.claimRepo.DeleteClaim(ctx, userID, coupon.ID); err != nil {
+			return err
+		}
+		if err := s.couponRepo.IncrementStock(ctx, coupon.ID, 1); err != nil {
+			return err
+		}
+		return s.record(ctx, events.Event{
+			Type:       events.TypeClaimCancelled,
+			CouponName: coupon.Name,
+			UserID:     userID,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	s.forgetClaim(ctx, coupon, userID)
+	s.forgetCoupon(ctx, name)
+
+	s.promoteWaitlist(ctx, coupon)
+	return nil
+}
+
+// waitlistStatus builds the status response for a user's waitlist entry
+func (s *CouponService) waitlistStatus(ctx context.Context, coupon *model.Coupon, userID string) (*model.WaitlistStatusResponse, error) {
+	entry, err := s.waitlistRepo.GetEntry(ctx, coupon.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &model.WaitlistStatusResponse{
+		CouponName: coupon.Name,
+		UserID:     userID,
+		Status:     entry.Status,
+	}
+	if entry.Status == model.WaitlistStatusWaiting {
+		if resp.Position, err = s.waitlistRepo.Position(ctx, entry); err != nil {
+			return nil, err
+		}
+	}
+
+	return resp, nil
+}
+
+// promoteWaitlist hands returned stock to waiting users, oldest first
+// Called after every operation that returns stock. Failures are logged rather
+// than returned: the caller's own operation has already succeeded, and any
+// stock left over is picked up by the next promotion.
+// The entry is taken before the stock, and the unit taken for it is recorded on
+// the entry, so a grant retried after a crash reuses that unit instead of
+// taking another one.
+func (s *CouponService) promoteWaitlist(ctx context.Context, coupon *model.Coupon) {
+	if s.waitlistRepo == nil || !coupon.IsActive {
+		return
+	}
+
+	for {
+		// Step 1: Take the next waiting user (or one whose grant was interrupted)
+		entry, err := s.waitlistRepo.PopNext(ctx, coupon.ID)
+		if err != nil {
+			log.Printf("Waitlist promotion for %s: failed to pop next entry: %v", coupon.Name, err)
+			return
+		}
+		if entry == nil {
+			return
+		}
+		retry := entry.Reserved
+
+		// Step 2: Take a unit of stock for them, unless an earlier attempt did;
+		// put them back if it is gone
+		if !entry.Reserved {
+			if err := s.couponRepo.DecrementStock(ctx, coupon.ID, 1); err != nil {
+				if !errors.Is(err, ErrNoStock) {
+					log.Printf("Waitlist promotion for %s: failed to reserve stock: %v", coupon.Name, err)
+				}
+				s.putBack(ctx, coupon, entry)
+				return
+			}
+			entry.Reserved = true
+			if err := s.waitlistRepo.SetStatus(ctx, entry, model.WaitlistStatusGranting); err != nil {
+				log.Printf("Waitlist promotion for %s: failed to record reserved stock for %s: %v", coupon.Name, entry.UserID, err)
+			}
+		}
+
+		// Step 3: Grant the claim with the same upsert as ClaimCoupon, together
+		// with its events where a transaction allows; without one the claim
+		// carries its pending events, as in ClaimCoupon
+		claim := &model.Claim{
+			ID:           primitive.NewObjectID(),
+			UserID:       entry.UserID,
+			CouponID:     coupon.ID,
+			CouponName:   coupon.Name,
+			CreatedAt:    time.Now(),
+			EventPending: s.outbox != nil && !s.transactional(),
+			Waitlisted:   true,
+		}
+		err = s.atomically(ctx, func(ctx context.Context) error {
+			if _, err := s.claimRepo.CreateClaimIfNotExists(ctx, claim); err != nil {
+				return err
+			}
+			if !s.transactional() {
+				return nil
+			}
+			return s.record(ctx, claimEvents(claim)...)
+		})
+		switch {
+		case errors.Is(err, ErrAlreadyClaimed):
+			_ = s.waitlistRepo.SetStatus(ctx, entry, model.WaitlistStatusGranted)
+			// A retried grant may have made this claim before the crash, in
+			// which case it holds the unit; otherwise the user got the coupon
+			// some other way and the unit goes to the next user. Telling the two
+			// apart is not possible, so a retry keeps the unit rather than risk
+			// handing it out twice.
+			if retry {
+				continue
+			}
+			if err := s.couponRepo.IncrementStock(ctx, coupon.ID, 1); err != nil {
+				log.Printf("Waitlist promotion for %s: failed to return stock: %v", coupon.Name, err)
+				return
+			}
+			continue
+		case err != nil:
+			log.Printf("Waitlist promotion for %s: failed to grant claim to %s: %v", coupon.Name, entry.UserID, err)
+			s.putBack(ctx, coupon, entry)
+			return
+		}
+
+		if err := s.waitlistRepo.SetStatus(ctx, entry, model.WaitlistStatusGranted); err != nil {
+			log.Printf("Waitlist promotion for %s: failed to mark %s as granted: %v", coupon.Name, entry.UserID, err)
+		}
+
+		// Notify the user through the event system; events that cannot be
+		// stored now are recovered from the claim's mark
+		if !s.transactional() {
+			s.recordClaimed(ctx, claim)
+		}
+	}
+}
+
+// PromoteWaitlists hands stock to the waitlists of coupons that have some
+// Stock is promoted by the operation that returns it; this sweep covers the
+// rest, such as leases reclaimed from a crashed instance and promotions that
+// failed part way.
+func (s *CouponService) PromoteWaitlists(ctx context.Context) {
+	s.promoteWaiting(ctx, func(coupon *model.Coupon) bool { return coupon.RemainingAmount > 0 })
+}
+
+// StockReturned hands units the stock allocator returned to a coupon to its waitlist
+func (s *CouponService) StockReturned(ctx context.Context, couponID primitive.ObjectID) {
+	s.promoteWaiting(ctx, func(coupon *model.Coupon) bool { return coupon.ID == couponID })
+}
+
+// RunWaitlistSweep calls PromoteWaitlists every interval until ctx is done
+func (s *CouponService) RunWaitlistSweep(ctx context.Context, interval time.Duration) {
+	if s.waitlistRepo == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.PromoteWaitlists(ctx)
+		}
+	}
+}
+
+// promoteWaiting promotes the waitlists of the coupons with users waiting that want selects
+func (s *CouponService) promoteWaiting(ctx context.Context, want func(coupon *model.Coupon) bool) {
+	if s.waitlistRepo == nil {
+		return
+	}
+
+	names, err := s.waitlistRepo.WaitingCoupons(ctx)
+	if err != nil {
+		log.Printf("Waitlist promotion: failed to list coupons with waiting users: %v", err)
+		return
+	}
+	for _, name := range names {
+		coupon, err := s.couponRepo.GetCouponByName(ctx, name)
+		if err != nil {
+			log.Printf("Waitlist promotion for %s: failed to look up coupon: %v", name, err)
+			continue
+		}
+		if want(coupon) {
+			s.promoteWaitlist(ctx, coupon)
+		}
+	}
+}
+
+// putBack returns an entry to the waitlist along with any stock reserved for it
+func (s *CouponService) putBack(ctx context.Context, coupon *model.Coupon, entry *model.WaitlistEntry) {
+	if entry.Reserved {
+		if err := s.couponRepo.IncrementStock(context.WithoutCancel(ctx), coupon.ID, 1); err != nil {
+			// Left reserved, so the retry of the stuck entry reuses the unit
+			log.Printf("Waitlist promotion for %s: failed to return stock: %v", coupon.Name, err)
+			return
+		}
+		entry.Reserved = false
+	}
+	if err := s.waitlistRepo.SetStatus(context.WithoutCancel(ctx), entry, model.WaitlistStatusWaiting); err != nil {
+		log.Printf("Waitlist promotion for %s: failed to put %s back on the waitlist: %v", coupon.Name, entry.UserID, err)
+	}
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"coupon-system/internal/faults"
+	"coupon-system/internal/model"
+	"coupon-system/internal/repository"
+	"coupon-system/internal/repository/repotest"
+	"coupon-system/internal/stock"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitlistTest is a sold-out coupon with a waitlist over in-memory repositories
+type waitlistTest struct {
+	*faultTest
+	waitlist *memoryWaitlistRepository
+}
+
+func newWaitlistTest(t *testing.T, stock int32) *waitlistTest {
+	t.Helper()
+	ft := newFaultTest(t, stock)
+	wt := &waitlistTest{faultTest: ft, waitlist: newMemoryWaitlistRepository()}
+	ft.svc = NewCouponService(
+		repository.NewFaultyCouponRepository(ft.coupons, ft.injector),
+		repository.NewFaultyClaimRepository(ft.claims, ft.injector),
+		WithWaitlist(wt.waitlist),
+	)
+	return wt
+}
+
+func (wt *waitlistTest) claim(t *testing.T, userID string) {
+	t.Helper()
+	if err := wt.svc.ClaimCoupon(context.Background(), &model.ClaimCouponRequest{UserID: userID, CouponName: wt.coupon.Name}); err != nil {
+		t.Fatalf("claim for %s: %v", userID, err)
+	}
+}
+
+func (wt *waitlistTest) join(t *testing.T, userIDs ...string) {
+	t.Helper()
+	for _, userID := range userIDs {
+		if _, err := wt.svc.JoinWaitlist(context.Background(), wt.coupon.Name, userID); err != nil {
+			t.Fatalf("join for %s: %v", userID, err)
+		}
+	}
+}
+
+func (wt *waitlistTest) hasClaim(userID string) bool {
+	claimed, _ := wt.claims.HasUserClaimed(context.Background(), userID, wt.coupon.ID)
+	return claimed
+}
+
+func TestJoinWaitlist(t *testing.T) {
+	wt := newWaitlistTest(t, 1)
+	ctx := context.Background()
+
+	if _, err := wt.svc.JoinWaitlist(ctx, wt.coupon.Name, "b"); !errors.Is(err, ErrStockAvailable) {
+		t.Errorf("join with stock left = %v, want ErrStockAvailable", err)
+	}
+	wt.claim(t, "a")
+	wt.join(t, "b", "c")
+
+	tests := []struct {
+		user    string
+		wantErr error
+	}{
+		{"a", ErrAlreadyClaimed},
+		{"b", ErrAlreadyWaitlisted},
+	}
+	for _, tt := range tests {
+		if _, err := wt.svc.JoinWaitlist(ctx, wt.coupon.Name, tt.user); !errors.Is(err, tt.wantErr) {
+			t.Errorf("join for %s = %v, want %v", tt.user, err, tt.wantErr)
+		}
+	}
+
+	status, err := wt.svc.GetWaitlistStatus(ctx, wt.coupon.Name, "c")
+	if err != nil || status.Status != model.WaitlistStatusWaiting || status.Position != 2 {
+		t.Errorf("status of c = %+v, %v, want waiting at 2", status, err)
+	}
+
+	if err := wt.svc.LeaveWaitlist(ctx, wt.coupon.Name, "b"); err != nil {
+		t.Fatalf("leave: %v", err)
+	}
+	if err := wt.svc.LeaveWaitlist(ctx, wt.coupon.Name, "b"); !errors.Is(err, ErrNotWaitlisted) {
+		t.Errorf("leave twice = %v, want ErrNotWaitlisted", err)
+	}
+	if status, _ := wt.svc.GetWaitlistStatus(ctx, wt.coupon.Name, "c"); status.Position != 1 {
+		t.Errorf("position of c after b left = %d, want 1", status.Position)
+	}
+}
+
+// TestCancelClaimPromotesWaitlist checks returned and restocked units go to
+// waiting users in the order they joined
+func TestCancelClaimPromotesWaitlist(t *testing.T) {
+	wt := newWaitlistTest(t, 1)
+	ctx := context.Background()
+	wt.claim(t, "a")
+	wt.join(t, "b", "c", "d")
+
+	if err := wt.svc.CancelClaim(ctx, wt.coupon.Name, "a"); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if err := wt.svc.CancelClaim(ctx, wt.coupon.Name, "a"); !errors.Is(err, ErrClaimNotFound) {
+		t.Errorf("cancel twice = %v, want ErrClaimNotFound", err)
+	}
+	if wt.hasClaim("a") || !wt.hasClaim("b") || wt.waitlist.status("b") != model.WaitlistStatusGranted {
+		t.Errorf("after cancel: a claimed %v, b claimed %v (%s)", wt.hasClaim("a"), wt.hasClaim("b"), wt.waitlist.status("b"))
+	}
+
+	if _, err := wt.svc.RestockCoupon(ctx, wt.coupon.Name, 1); err != nil {
+		t.Fatalf("restock: %v", err)
+	}
+	if !wt.hasClaim("c") || wt.hasClaim("d") || wt.coupons.Remaining(wt.coupon.ID) != 0 {
+		t.Errorf("after restock: c claimed %v, d claimed %v, remaining %d", wt.hasClaim("c"), wt.hasClaim("d"), wt.coupons.Remaining(wt.coupon.ID))
+	}
+}
+
+// TestPromotionFailureKeepsPlaceAndStock checks a grant that fails puts the
+// user back at the head of the waitlist with the unit returned
+func TestPromotionFailureKeepsPlaceAndStock(t *testing.T) {
+	wt := newWaitlistTest(t, 1)
+	ctx := context.Background()
+	wt.claim(t, "a")
+	wt.join(t, "b", "c")
+
+	if err := wt.injector.Set("CreateClaimIfNotExists", faults.Fault{Error: "boom", Times: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.svc.CancelClaim(ctx, wt.coupon.Name, "a"); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if wt.hasClaim("b") || wt.waitlist.status("b") != model.WaitlistStatusWaiting || wt.coupons.Remaining(wt.coupon.ID) != 1 {
+		t.Fatalf("after failed grant: b claimed %v (%s), remaining %d", wt.hasClaim("b"), wt.waitlist.status("b"), wt.coupons.Remaining(wt.coupon.ID))
+	}
+
+	wt.svc.promoteWaitlist(ctx, wt.coupon)
+	if !wt.hasClaim("b") || wt.hasClaim("c") || wt.coupons.Remaining(wt.coupon.ID) != 0 {
+		t.Errorf("after retry: b claimed %v, c claimed %v, remaining %d", wt.hasClaim("b"), wt.hasClaim("c"), wt.coupons.Remaining(wt.coupon.ID))
+	}
+}
+
+// TestStuckGrantIsRetried checks an entry left in granting by a crash is
+// retried once it times out, reusing the unit reserved for it if there was one
+func TestStuckGrantIsRetried(t *testing.T) {
+	tests := []struct {
+		name          string
+		reserved      bool
+		stock         int32
+		wantRemaining int32
+	}{
+		{"crashed after reserving stock", true, 0, 0},
+		{"crashed before reserving stock", false, 1, 0},
+		{"crashed before reserving stock, sold out", false, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wt := newWaitlistTest(t, 1)
+			ctx := context.Background()
+			wt.claim(t, "a")
+			wt.join(t, "b")
+
+			// b was popped by an instance that crashed a while ago
+			entry, _ := wt.waitlist.PopNext(ctx, wt.coupon.ID)
+			entry.Reserved = tt.reserved
+			_ = wt.waitlist.SetStatus(ctx, entry, model.WaitlistStatusGranting)
+			wt.waitlist.entries[0].UpdatedAt = time.Now().Add(-2 * memoryGrantingTimeout)
+			if tt.stock > 0 {
+				_ = wt.coupons.IncrementStock(ctx, wt.coupon.ID, tt.stock)
+			}
+
+			wt.svc.promoteWaitlist(ctx, wt.coupon)
+			wantClaim := tt.reserved || tt.stock > 0
+			if wt.hasClaim("b") != wantClaim || wt.coupons.Remaining(wt.coupon.ID) != tt.wantRemaining {
+				t.Errorf("b claimed %v (%s), remaining %d; want claimed %v, remaining %d",
+					wt.hasClaim("b"), wt.waitlist.status("b"), wt.coupons.Remaining(wt.coupon.ID), wantClaim, tt.wantRemaining)
+			}
+		})
+	}
+}
+
+// TestReturnedLeaseStockPromotesWaitlist checks units returned from a stock
+// lease go to waiting users, and the sweep hands out stock that came back
+// without a promotion, such as a crashed instance's reclaimed lease
+func TestReturnedLeaseStockPromotesWaitlist(t *testing.T) {
+	wt := newWaitlistTest(t, 3)
+	ctx := context.Background()
+	allocator := stock.NewAllocator(wt.coupons, wt.claims, repotest.NewLeaseRepository(), stock.Options{LeaseSize: 3})
+	wt.svc = NewCouponService(wt.coupons, wt.claims, WithWaitlist(wt.waitlist), WithStockAllocator(allocator))
+	allocator.OnStockReturned(wt.svc.StockReturned)
+	allocator.Start()
+
+	// The lease holds all the stock, so the coupon looks sold out
+	wt.claim(t, "a")
+	wt.join(t, "b", "c", "d")
+
+	if err := allocator.Close(ctx); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if !wt.hasClaim("b") || !wt.hasClaim("c") || wt.hasClaim("d") || wt.coupons.Remaining(wt.coupon.ID) != 0 {
+		t.Errorf("after the lease was returned: b claimed %v, c claimed %v, d claimed %v, remaining %d",
+			wt.hasClaim("b"), wt.hasClaim("c"), wt.hasClaim("d"), wt.coupons.Remaining(wt.coupon.ID))
+	}
+
+	_ = wt.coupons.IncrementStock(ctx, wt.coupon.ID, 1)
+	wt.svc.PromoteWaitlists(ctx)
+	if !wt.hasClaim("d") || wt.coupons.Remaining(wt.coupon.ID) != 0 {
+		t.Errorf("after the sweep: d claimed %v, remaining %d", wt.hasClaim("d"), wt.coupons.Remaining(wt.coupon.ID))
+	}
+}
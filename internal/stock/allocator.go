@@ -0,0 +1,604 @@
+package stock
+
+import (
+	"context"
+	"coupon-system/internal/model"
+	"coupon-system/internal/owner"
+	"coupon-system/internal/repository"
+	apperrors "coupon-system/pkg/errors"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrClosed is returned for claims made after the allocator was closed
+var ErrClosed = errors.New("stock allocator is closed")
+
+// errLeaseExpired is returned when a claim's lease expired before the group commit
+// The lease may already have been reclaimed by another instance, so the claim is not written
+var errLeaseExpired = errors.New("stock lease expired before the claim was committed")
+
+// Options configures stock pre-allocation
+type Options struct {
+	LeaseSize     int32         // Units taken from a coupon per lease
+	LeaseTTL      time.Duration // Leases not renewed for this long are reclaimed by any instance
+	FlushInterval time.Duration // Maximum time a claim waits for the next group commit
+	MaxBatch      int           // Claims written per group commit
+	EventPending  bool          // Mark claims EventPending, so an outbox relay records their events if the caller cannot
+}
+
+// Allocator serves claims from blocks of stock leased by this instance
+//
+// Instead of decrementing the coupon document on every claim, the allocator
+// reserves LeaseSize units at a time and hands them out from a local counter.
+// Claims are buffered and written with one bulk upsert per FlushInterval; the
+// unique (user_id, coupon_id) index still rejects double claims, and their
+// units go back to the local counter.
+//
+// Stock is never oversold:
+//   - units leave the coupon document before they are served (ReserveStock)
+//   - every claim records the lease it was served from
+//   - on shutdown the unused units of each lease are returned
+//   - a lease that stops being renewed (crash) is reclaimed by any instance,
+//     which returns size - max(used, claims with that lease_id)
+//   - an instance stops serving a lease before it can be reclaimed, even if
+//     it is still alive but cannot reach the database to renew it
+//
+// A crash between reserving stock and recording the lease can lose units
+// (undersell) but never creates them.
+type Allocator struct {
+	opts  Options
+	owner string
+
+	couponRepo repository.CouponRepository
+	claimRepo  repository.ClaimRepository
+	leaseRepo  repository.LeaseRepository
+
+	returned func(ctx context.Context, couponID primitive.ObjectID)
+
+	// mu is held for reading while a claim is being handed to the batcher and
+	// for writing when closing, so no claim can slip in after the final flush
+	mu     sync.RWMutex
+	closed bool
+
+	couponsMu sync.Mutex
+	coupons   map[primitive.ObjectID]*couponStock
+
+	pending chan *claimRequest
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// couponStock holds this instance's leases for one coupon
+type couponStock struct {
+	mu     sync.Mutex
+	leases []*lease
+}
+
+// lease is the local view of a model.StockLease
+// All fields are guarded by the owning couponStock's mutex
+type lease struct {
+	id        primitive.ObjectID
+	couponID  primitive.ObjectID
+	remaining int32     // Units not yet handed out
+	inflight  int32     // Units handed out but not yet committed
+	deadline  time.Time // Not served after this, even if renewal is only late
+}
+
+// claimRequest is a claim waiting for the next group commit
+type claimRequest struct {
+	claim *model.Claim
+	stock *couponStock
+	lease *lease
+	done  chan error
+}
+
+// NewAllocator creates a new stock allocator
+func NewAllocator(couponRepo repository.CouponRepository, claimRepo repository.ClaimRepository, leaseRepo repository.LeaseRepository, opts Options) *Allocator {
+	if opts.LeaseSize <= 0 {
+		opts.LeaseSize = 100
+	}
+	if opts.LeaseTTL <= 0 {
+		opts.LeaseTTL = 30 * time.Second
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = 5 * time.Millisecond
+	}
+	if opts.MaxBatch <= 0 {
+		opts.MaxBatch = 500
+	}
+
+	return &Allocator{
+		opts:       opts,
+		owner:      owner.NewID(),
+		couponRepo: couponRepo,
+		claimRepo:  claimRepo,
+		leaseRepo:  leaseRepo,
+		coupons:    make(map[primitive.ObjectID]*couponStock),
+		pending:    make(chan *claimRequest, opts.MaxBatch),
+		stop:       make(chan struct{}),
+	}
+}
+
+// OnStockReturned has fn called after unused leased units go back to a
+// coupon, so they can be handed to its waitlist
+// It must be called before Start.
+func (a *Allocator) OnStockReturned(fn func(ctx context.Context, couponID primitive.ObjectID)) {
+	a.returned = fn
+}
+
+// Start runs the group commit loop and the lease renewal and reclaim loop
+func (a *Allocator) Start() {
+	a.wg.Add(2)
+	go a.runBatcher()
+	go a.runMaintenance()
+}
+
+// Close stops serving claims, commits the claims already buffered and
+// returns the unused units of every lease to their coupons
+func (a *Allocator) Close(ctx context.Context) error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	a.mu.Unlock()
+
+	close(a.stop)
+	done := make(chan struct{})
+	go func() {
+		a.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		// Leases that are not returned expire and are reclaimed by another instance
+		return ctx.Err()
+	}
+
+	a.couponsMu.Lock()
+	defer a.couponsMu.Unlock()
+
+	var firstErr error
+	now := time.Now()
+	for _, cs := range a.coupons {
+		cs.mu.Lock()
+		for _, l := range cs.leases {
+			if !now.Before(l.deadline) {
+				continue // May already be reclaimed; leave it to the reclaim loop
+			}
+			if err := a.returnLease(ctx, l, model.LeaseStatusReturned, l.remaining); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		cs.leases = nil
+		cs.mu.Unlock()
+	}
+
+	return firstErr
+}
+
+// Claim claims a coupon for a user from this instance's leased stock
+// It blocks until the claim has been committed by a group commit and returns it
+// Returns ErrNoStock when the coupon has no stock left to lease and
+// ErrAlreadyClaimed if the user already holds a claim
+func (a *Allocator) Claim(ctx context.Context, coupon *model.Coupon, userID string) (*model.Claim, error) {
+	req, err := a.enqueue(ctx, coupon, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case err := <-req.done:
+		if err != nil {
+			return nil, err
+		}
+		return req.claim, nil
+	case <-ctx.Done():
+		// The claim may still be committed by the group commit
+		return nil, ctx.Err()
+	}
+}
+
+// enqueue takes a unit of stock and hands the claim to the batcher
+func (a *Allocator) enqueue(ctx context.Context, coupon *model.Coupon, userID string) (*claimRequest, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return nil, ErrClosed
+	}
+
+	cs := a.couponStock(coupon.ID)
+	l, err := a.take(ctx, cs, coupon.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	leaseID := l.id
+	req := &claimRequest{
+		claim: &model.Claim{
+			ID:           primitive.NewObjectID(),
+			UserID:       userID,
+			CouponID:     coupon.ID,
+			CouponName:   coupon.Name,
+			CreatedAt:    time.Now(),
+			LeaseID:      &leaseID,
+			EventPending: a.opts.EventPending,
+		},
+		stock: cs,
+		lease: l,
+		done:  make(chan error, 1),
+	}
+
+	select {
+	case a.pending <- req:
+		return req, nil
+	case <-ctx.Done():
+		cs.release(l)
+		return nil, ctx.Err()
+	}
+}
+
+// couponStock returns the local lease state for a coupon
+func (a *Allocator) couponStock(couponID primitive.ObjectID) *couponStock {
+	a.couponsMu.Lock()
+	defer a.couponsMu.Unlock()
+
+	cs := a.coupons[couponID]
+	if cs == nil {
+		cs = &couponStock{}
+		a.coupons[couponID] = cs
+	}
+	return cs
+}
+
+// take hands out one unit, leasing a new block when the current leases are used up
+func (a *Allocator) take(ctx context.Context, cs *couponStock, couponID primitive.ObjectID) (*lease, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	now := time.Now()
+	for _, l := range cs.leases {
+		if l.remaining > 0 && now.Before(l.deadline) {
+			l.remaining--
+			l.inflight++
+			return l, nil
+		}
+	}
+
+	// Acquire while holding the coupon's lock so concurrent claims share one new lease
+	l, err := a.acquire(ctx, couponID)
+	if err != nil {
+		return nil, err
+	}
+	cs.leases = append(cs.leases, l)
+
+	l.remaining--
+	l.inflight++
+	return l, nil
+}
+
+// acquire leases a block of stock from the coupon document
+func (a *Allocator) acquire(ctx context.Context, couponID primitive.ObjectID) (*lease, error) {
+	start := time.Now()
+
+	reserved, err := a.couponRepo.ReserveStock(ctx, couponID, a.opts.LeaseSize)
+	if err != nil {
+		return nil, err
+	}
+	if reserved == 0 {
+		return nil, apperrors.ErrNoStock
+	}
+
+	record := &model.StockLease{
+		ID:        primitive.NewObjectID(),
+		CouponID:  couponID,
+		Owner:     a.owner,
+		Size:      reserved,
+		Status:    model.LeaseStatusActive,
+		CreatedAt: start,
+		ExpiresAt: start.Add(a.opts.LeaseTTL),
+	}
+	if err := a.leaseRepo.CreateLease(ctx, record); err != nil {
+		// Nothing was served from the reservation, give it back
+		if incErr := a.couponRepo.IncrementStock(context.Background(), couponID, reserved); incErr != nil {
+			log.Printf("stock: failed to return %d units of coupon %s: %v", reserved, couponID.Hex(), incErr)
+		} else {
+			a.stockReturned(context.Background(), couponID)
+		}
+		return nil, fmt.Errorf("failed to record stock lease: %w", err)
+	}
+
+	return &lease{
+		id:        record.ID,
+		couponID:  couponID,
+		remaining: reserved,
+		deadline:  record.ExpiresAt,
+	}, nil
+}
+
+// release puts an unused unit back on its lease
+func (cs *couponStock) release(l *lease) {
+	cs.mu.Lock()
+	l.remaining++
+	l.inflight--
+	cs.mu.Unlock()
+}
+
+// settle marks a handed-out unit as no longer in flight (committed or abandoned)
+func (cs *couponStock) settle(l *lease) {
+	cs.mu.Lock()
+	l.inflight--
+	cs.mu.Unlock()
+}
+
+// runBatcher writes buffered claims every FlushInterval or MaxBatch claims
+func (a *Allocator) runBatcher() {
+	defer a.wg.Done()
+
+	ticker := time.NewTicker(a.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*claimRequest, 0, a.opts.MaxBatch)
+	for {
+		select {
+		case req := <-a.pending:
+			batch = append(batch, req)
+			if len(batch) >= a.opts.MaxBatch {
+				a.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				a.flush(batch)
+				batch = batch[:0]
+			}
+		case <-a.stop:
+			// Close holds off new claims, so draining the channel is final
+			for {
+				select {
+				case req := <-a.pending:
+					batch = append(batch, req)
+				default:
+					if len(batch) > 0 {
+						a.flush(batch)
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush commits a batch of claims with one bulk upsert
+func (a *Allocator) flush(batch []*claimRequest) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Never write claims for a lease that may already have been reclaimed
+	now := time.Now()
+	live := make([]*claimRequest, 0, len(batch))
+	for _, req := range batch {
+		req.stock.mu.Lock()
+		expired := !now.Before(req.lease.deadline)
+		if expired {
+			req.lease.inflight--
+		}
+		req.stock.mu.Unlock()
+
+		if expired {
+			req.done <- errLeaseExpired
+			continue
+		}
+		live = append(live, req)
+	}
+	if len(live) == 0 {
+		return
+	}
+
+	claims := make([]*model.Claim, len(live))
+	for i, req := range live {
+		claims[i] = req.claim
+	}
+
+	created, err := a.claimRepo.CreateClaimsIfNotExist(ctx, claims)
+	if err != nil {
+		a.failBatch(ctx, live, err)
+		return
+	}
+
+	used := make(map[primitive.ObjectID]int32)
+	for i, req := range live {
+		if created[i] {
+			req.stock.settle(req.lease)
+			used[req.lease.id]++
+			req.done <- nil
+			continue
+		}
+		req.stock.release(req.lease)
+		req.done <- apperrors.ErrAlreadyClaimed
+	}
+
+	// Best effort: reclaiming also counts the lease's claims directly
+	for leaseID, n := range used {
+		if err := a.leaseRepo.AddUsed(ctx, leaseID, n); err != nil {
+			log.Printf("stock: failed to record %d claims on lease %s: %v", n, leaseID.Hex(), err)
+		}
+	}
+}
+
+// failBatch handles a group commit that failed part way
+// Units are only put back for users that verifiably have no claim; the rest
+// are kept out of circulation (undersell rather than risk overselling)
+func (a *Allocator) failBatch(ctx context.Context, batch []*claimRequest, cause error) {
+	byCoupon := make(map[primitive.ObjectID][]string)
+	for _, req := range batch {
+		byCoupon[req.claim.CouponID] = append(byCoupon[req.claim.CouponID], req.claim.UserID)
+	}
+
+	claimed := make(map[primitive.ObjectID]map[string]bool, len(byCoupon))
+	for couponID, userIDs := range byCoupon {
+		users, err := a.claimRepo.GetClaimedUserIDs(ctx, couponID, userIDs, time.Time{})
+		if err != nil {
+			users = nil // Unknown: keep every unit out of circulation
+		}
+		claimed[couponID] = users
+	}
+
+	for _, req := range batch {
+		users := claimed[req.claim.CouponID]
+		if users != nil && !users[req.claim.UserID] {
+			req.stock.release(req.lease)
+		} else {
+			req.stock.settle(req.lease)
+		}
+		req.done <- cause
+	}
+}
+
+// runMaintenance renews this instance's leases and reclaims expired leases
+func (a *Allocator) runMaintenance() {
+	defer a.wg.Done()
+
+	ticker := time.NewTicker(a.opts.LeaseTTL / 3)
+	defer ticker.Stop()
+
+	a.reclaimExpired()
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+			a.renew()
+			a.reclaimExpired()
+		}
+	}
+}
+
+// renew extends live leases and retires the ones that are used up or expired
+func (a *Allocator) renew() {
+	ctx, cancel := context.WithTimeout(context.Background(), a.opts.LeaseTTL/3)
+	defer cancel()
+
+	a.couponsMu.Lock()
+	stocks := make([]*couponStock, 0, len(a.coupons))
+	for _, cs := range a.coupons {
+		stocks = append(stocks, cs)
+	}
+	a.couponsMu.Unlock()
+
+	start := time.Now()
+	var ids []interface{}
+	var exhausted []*lease
+	for _, cs := range stocks {
+		cs.mu.Lock()
+		kept := cs.leases[:0]
+		for _, l := range cs.leases {
+			switch {
+			case l.inflight > 0:
+				kept = append(kept, l)
+				ids = append(ids, l.id)
+			case !start.Before(l.deadline):
+				// Left to the reclaim loop, which counts what was actually used
+			case l.remaining == 0:
+				exhausted = append(exhausted, l)
+			default:
+				kept = append(kept, l)
+				ids = append(ids, l.id)
+			}
+		}
+		cs.leases = kept
+		cs.mu.Unlock()
+	}
+
+	for _, l := range exhausted {
+		if err := a.returnLease(ctx, l, model.LeaseStatusReturned, 0); err != nil {
+			log.Printf("stock: failed to close lease %s: %v", l.id.Hex(), err)
+		}
+	}
+
+	if err := a.leaseRepo.Heartbeat(ctx, a.owner, ids, start.Add(a.opts.LeaseTTL)); err != nil {
+		log.Printf("stock: failed to renew %d leases: %v", len(ids), err)
+		return
+	}
+
+	// The local deadline counts from before the renewal was sent
+	for _, cs := range stocks {
+		cs.mu.Lock()
+		for _, l := range cs.leases {
+			if start.Before(l.deadline) {
+				l.deadline = start.Add(a.opts.LeaseTTL)
+			}
+		}
+		cs.mu.Unlock()
+	}
+}
+
+// reclaimExpired returns the unused stock of leases whose owner stopped renewing them
+// Leases are only reclaimed half a TTL after expiry, which covers clock skew
+// and commits that were already in progress when the owner's deadline passed
+func (a *Allocator) reclaimExpired() {
+	ctx, cancel := context.WithTimeout(context.Background(), a.opts.LeaseTTL/3)
+	defer cancel()
+
+	expired, err := a.leaseRepo.FindExpired(ctx, time.Now().Add(-a.opts.LeaseTTL/2))
+	if err != nil {
+		log.Printf("stock: failed to find expired leases: %v", err)
+		return
+	}
+
+	for _, record := range expired {
+		count, err := a.claimRepo.CountClaimsByLease(ctx, record.ID)
+		if err != nil {
+			log.Printf("stock: failed to count claims on lease %s: %v", record.ID.Hex(), err)
+			continue
+		}
+
+		// The used counter survives cancelled claims, the claim count survives
+		// a crash before the counter was updated; the larger one is safe
+		used := record.Used
+		if int32(count) > used {
+			used = int32(count)
+		}
+		unused := record.Size - used
+		if unused < 0 {
+			unused = 0
+		}
+
+		l := &lease{id: record.ID, couponID: record.CouponID}
+		if err := a.returnLease(ctx, l, model.LeaseStatusReclaimed, unused); err != nil {
+			log.Printf("stock: failed to reclaim lease %s: %v", record.ID.Hex(), err)
+		}
+	}
+}
+
+// returnLease closes a lease and, if this call closed it, adds the unused units back
+// Closing first means a lease is never returned twice; a crash in between loses
+// the units rather than duplicating them
+func (a *Allocator) returnLease(ctx context.Context, l *lease, status string, unused int32) error {
+	finished, err := a.leaseRepo.FinishLease(ctx, l.id, status, unused)
+	if err != nil {
+		return err
+	}
+	if !finished || unused == 0 {
+		return nil
+	}
+	if err := a.couponRepo.IncrementStock(ctx, l.couponID, unused); err != nil {
+		return err
+	}
+	a.stockReturned(ctx, l.couponID)
+	return nil
+}
+
+// stockReturned tells the OnStockReturned callback, if any, about returned units
+func (a *Allocator) stockReturned(ctx context.Context, couponID primitive.ObjectID) {
+	if a.returned != nil {
+		a.returned(ctx, couponID)
+	}
+}
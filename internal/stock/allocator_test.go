@@ -0,0 +1,253 @@
+package stock
+
+import (
+	"context"
+	"coupon-system/internal/faults"
+	"coupon-system/internal/model"
+	"coupon-system/internal/repository"
+	"coupon-system/internal/repository/repotest"
+	apperrors "coupon-system/pkg/errors"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// allocatorTest is an allocator over in-memory repositories, with faults injectable into claim writes
+type allocatorTest struct {
+	coupons  *repotest.CouponRepository
+	claims   *repotest.ClaimRepository
+	leases   *repotest.LeaseRepository
+	injector *faults.Injector
+	coupon   *model.Coupon
+}
+
+func newAllocatorTest(t *testing.T, stock int32) *allocatorTest {
+	t.Helper()
+	at := &allocatorTest{
+		coupons:  repotest.NewCouponRepository(),
+		claims:   repotest.NewClaimRepository(),
+		leases:   repotest.NewLeaseRepository(),
+		injector: faults.NewInjector(),
+	}
+	at.coupon = &model.Coupon{Name: "LEASED", Amount: stock, RemainingAmount: stock, IsActive: true, CreatedAt: time.Now()}
+	if err := at.coupons.CreateCoupon(context.Background(), at.coupon); err != nil {
+		t.Fatal(err)
+	}
+	return at
+}
+
+// allocator starts an allocator sharing the test's repositories, as another instance would
+func (at *allocatorTest) allocator(opts Options) *Allocator {
+	a := NewAllocator(at.coupons, repository.NewFaultyClaimRepository(at.claims, at.injector), at.leases, opts)
+	a.Start()
+	return a
+}
+
+// claimAll has users claim concurrently and counts the outcomes by error
+func (at *allocatorTest) claimAll(a *Allocator, users []string) map[error]int {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	outcomes := make(map[error]int)
+	for _, userID := range users {
+		wg.Add(1)
+		go func(userID string) {
+			defer wg.Done()
+			_, err := a.Claim(context.Background(), at.coupon, userID)
+			switch {
+			case err == nil, errors.Is(err, apperrors.ErrNoStock), errors.Is(err, apperrors.ErrAlreadyClaimed):
+			default:
+				err = errFailed
+			}
+			mu.Lock()
+			outcomes[err]++
+			mu.Unlock()
+		}(userID)
+	}
+	wg.Wait()
+	return outcomes
+}
+
+// errFailed groups claims that failed for any other reason
+var errFailed = errors.New("failed")
+
+// checkNoOversell checks every claim is backed by a unit taken from the coupon
+func (at *allocatorTest) checkNoOversell(t *testing.T) {
+	t.Helper()
+	claims := int32(at.claims.Count(at.coupon.ID))
+	remaining := at.coupons.Remaining(at.coupon.ID)
+	if claims+remaining > at.coupon.Amount {
+		t.Errorf("%d claims + %d remaining > amount %d", claims, remaining, at.coupon.Amount)
+	}
+}
+
+func users(prefix string, n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("%s_%d", prefix, i)
+	}
+	return ids
+}
+
+func TestConcurrentClaimsAcrossLeases(t *testing.T) {
+	at := newAllocatorTest(t, 50)
+	a := at.allocator(Options{LeaseSize: 8, LeaseTTL: time.Minute})
+
+	// Twice the stock, in blocks smaller than the number of waiting users
+	outcomes := at.claimAll(a, users("u", 100))
+	if outcomes[nil] != 50 || outcomes[apperrors.ErrNoStock] != 50 {
+		t.Errorf("outcomes = %v, want 50 claims and 50 out of stock", outcomes)
+	}
+	if err := a.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if got := at.claims.Count(at.coupon.ID); got != 50 || at.coupons.Remaining(at.coupon.ID) != 0 {
+		t.Errorf("%d claims, %d remaining; want 50 and 0", got, at.coupons.Remaining(at.coupon.ID))
+	}
+	if leases := at.leases.Leases(); len(leases) < 7 {
+		t.Errorf("%d leases for 50 units in blocks of 8", len(leases))
+	}
+}
+
+// TestBatchWriteFailure checks a failed group commit fails its claims and
+// only puts units back for users that verifiably got no claim
+func TestBatchWriteFailure(t *testing.T) {
+	tests := []struct {
+		name          string
+		partial       bool
+		wantRemaining int32
+	}{
+		// Nothing was written, so every unit goes back and the retry succeeds
+		{"nothing written", false, 10},
+		// The write was applied but not acknowledged: the users hold their claims
+		{"lost acknowledgement", true, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at := newAllocatorTest(t, 20)
+			a := at.allocator(Options{LeaseSize: 20, LeaseTTL: time.Minute, FlushInterval: 20 * time.Millisecond})
+			_ = at.injector.Set("CreateClaimsIfNotExist", faults.Fault{Error: "boom", Partial: tt.partial, Times: 1})
+
+			first := at.claimAll(a, users("u", 10))
+			if first[errFailed] != 10 {
+				t.Fatalf("outcomes under a failed write = %v, want 10 failures", first)
+			}
+			retry := at.claimAll(a, users("u", 10))
+			if tt.partial && retry[apperrors.ErrAlreadyClaimed] != 10 || !tt.partial && retry[nil] != 10 {
+				t.Errorf("retry outcomes = %v", retry)
+			}
+
+			if err := a.Close(context.Background()); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+			if got := at.claims.Count(at.coupon.ID); got != 10 || at.coupons.Remaining(at.coupon.ID) != tt.wantRemaining {
+				t.Errorf("%d claims, %d remaining; want 10 and %d", got, at.coupons.Remaining(at.coupon.ID), tt.wantRemaining)
+			}
+		})
+	}
+}
+
+// TestExpiredLeaseIsReclaimedByAnotherInstance checks the unused units of a
+// crashed instance's lease return to the coupon through another instance
+func TestExpiredLeaseIsReclaimedByAnotherInstance(t *testing.T) {
+	at := newAllocatorTest(t, 100)
+	ttl := 60 * time.Millisecond
+	crashed := at.allocator(Options{LeaseSize: 10, LeaseTTL: ttl})
+	if outcomes := at.claimAll(crashed, users("u", 3)); outcomes[nil] != 3 {
+		t.Fatalf("outcomes = %v", outcomes)
+	}
+
+	// Stop the instance's loops without returning its lease, as a crash would
+	crashed.mu.Lock()
+	crashed.closed = true
+	crashed.mu.Unlock()
+	close(crashed.stop)
+	crashed.wg.Wait()
+	if got := at.coupons.Remaining(at.coupon.ID); got != 90 {
+		t.Fatalf("remaining with the lease out = %d, want 90", got)
+	}
+
+	other := at.allocator(Options{LeaseSize: 10, LeaseTTL: ttl})
+	defer other.Close(context.Background())
+
+	deadline := time.Now().Add(2 * time.Second)
+	for at.coupons.Remaining(at.coupon.ID) != 97 {
+		if time.Now().After(deadline) {
+			t.Fatalf("remaining = %d, want 97 after the lease is reclaimed", at.coupons.Remaining(at.coupon.ID))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	for _, l := range at.leases.Leases() {
+		if l.Status != model.LeaseStatusReclaimed || l.Returned != 7 {
+			t.Errorf("lease = %+v, want reclaimed with 7 returned", l)
+		}
+	}
+}
+
+func TestCloseReturnsUnusedStock(t *testing.T) {
+	at := newAllocatorTest(t, 100)
+	a := at.allocator(Options{LeaseSize: 30, LeaseTTL: time.Minute})
+	if outcomes := at.claimAll(a, users("u", 5)); outcomes[nil] != 5 {
+		t.Fatalf("outcomes = %v", outcomes)
+	}
+
+	if err := a.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if got := at.coupons.Remaining(at.coupon.ID); got != 95 {
+		t.Errorf("remaining after Close = %d, want 95", got)
+	}
+	for _, l := range at.leases.Leases() {
+		if l.Status != model.LeaseStatusReturned || l.Returned != 25 {
+			t.Errorf("lease = %+v, want returned with 25 units", l)
+		}
+	}
+	if _, err := a.Claim(context.Background(), at.coupon, "late"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Claim after Close = %v, want ErrClosed", err)
+	}
+	if err := a.Close(context.Background()); err != nil {
+		t.Errorf("second Close = %v", err)
+	}
+}
+
+// TestClaimsNeverExceedAmount runs random workloads with random write
+// failures over two instances and checks stock is never oversold
+func TestClaimsNeverExceedAmount(t *testing.T) {
+	for seed := int64(1); seed <= 20; seed++ {
+		t.Run(fmt.Sprintf("seed %d", seed), func(t *testing.T) {
+			rng := rand.New(rand.NewSource(seed))
+			stock := int32(1 + rng.Intn(60))
+			at := newAllocatorTest(t, stock)
+			opts := Options{LeaseSize: int32(1 + rng.Intn(20)), LeaseTTL: time.Minute, MaxBatch: 1 + rng.Intn(16)}
+			if rng.Intn(2) == 0 {
+				_ = at.injector.Set("CreateClaimsIfNotExist", faults.Fault{
+					Error: "boom", Partial: rng.Intn(2) == 0, Probability: 0.3,
+				})
+			}
+
+			a, b := at.allocator(opts), at.allocator(opts)
+			population := users("u", int(stock)*2)
+			var wg sync.WaitGroup
+			for _, alloc := range []*Allocator{a, b} {
+				// Users may pick the same instance or both at once
+				picks := make([]string, len(population))
+				for i := range picks {
+					picks[i] = population[rng.Intn(len(population))]
+				}
+				wg.Add(1)
+				go func(alloc *Allocator) {
+					defer wg.Done()
+					at.claimAll(alloc, picks)
+				}(alloc)
+			}
+			wg.Wait()
+			_ = a.Close(context.Background())
+			_ = b.Close(context.Background())
+
+			at.checkNoOversell(t)
+		})
+	}
+}
@@ -0,0 +1,86 @@
+// Package validation checks request fields and reports every invalid one at once
+package validation
+
+import (
+	apperrors "coupon-system/pkg/errors"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Limits and formats of identifiers; the patterns are also published in the OpenAPI document
+const (
+	MaxCouponNameLength = 64
+	MaxUserIDLength     = 128
+
+	CouponNamePattern = `^[A-Za-z0-9][A-Za-z0-9_-]*$`
+	UserIDPattern     = `^[A-Za-z0-9_.:@+-]+$`
+)
+
+var (
+	couponNameRe = regexp.MustCompile(CouponNamePattern)
+	userIDRe     = regexp.MustCompile(UserIDPattern)
+)
+
+// CouponName checks a new coupon's name
+func CouponName(name string) error {
+	switch {
+	case name == "":
+		return errors.New("is required")
+	case len(name) > MaxCouponNameLength:
+		return fmt.Errorf("must be at most %d characters", MaxCouponNameLength)
+	case !couponNameRe.MatchString(name):
+		return errors.New("must start with a letter or digit and contain only letters, digits, '_' and '-'")
+	}
+	return nil
+}
+
+// UserID checks a user ID
+func UserID(id string) error {
+	switch {
+	case id == "":
+		return errors.New("is required")
+	case len(id) > MaxUserIDLength:
+		return fmt.Errorf("must be at most %d characters", MaxUserIDLength)
+	case !userIDRe.MatchString(id):
+		return errors.New("may only contain letters, digits and '_', '.', ':', '@', '+', '-'")
+	}
+	return nil
+}
+
+// InvalidParam is a field that failed validation and why
+type InvalidParam struct {
+	Name   string `json:"name"`   // JSON name of the field, e.g. user_ids[2]
+	Reason string `json:"reason"` // e.g. "is required"
+}
+
+// Errors collects invalid fields
+type Errors []InvalidParam
+
+// Add records an invalid field
+func (e *Errors) Add(name, reason string) {
+	*e = append(*e, InvalidParam{Name: name, Reason: reason})
+}
+
+// Check records the field as invalid when err is not nil
+func (e *Errors) Check(name string, err error) {
+	if err != nil {
+		e.Add(name, err.Error())
+	}
+}
+
+// Err returns nil if no field is invalid, or else ErrValidation listing every
+// invalid field under the invalid_params problem member
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	reasons := make([]string, len(e))
+	for i, p := range e {
+		reasons[i] = p.Name + " " + p.Reason
+	}
+	return apperrors.ErrValidation.
+		WithMessage(apperrors.ErrValidation.Message + ": " + strings.Join(reasons, "; ")).
+		WithDetails(map[string]interface{}{"invalid_params": []InvalidParam(e)})
+}
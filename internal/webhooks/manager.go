@@ -0,0 +1,405 @@
+// Package webhooks delivers domain events to partner endpoints over HTTP
+//
+// Subscriptions name a URL, the event types they want and a secret. Events
+// reach the manager from the outbox relay (it is a sink) and are stored as one
+// delivery per matching subscription; workers then send each delivery as a
+// signed POST, retrying with exponential backoff. Deliveries that run out of
+// attempts go to a dead-letter queue, from which they can be replayed.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"coupon-system/internal/events"
+	"coupon-system/internal/metrics"
+	"coupon-system/internal/model"
+	"coupon-system/internal/owner"
+	"coupon-system/internal/repository"
+	"coupon-system/internal/validation"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// eventTypes are the event types a subscription can ask for
+var eventTypes = map[string]bool{
+	model.WebhookEventAll:      true,
+	events.TypeCouponCreated:   true,
+	events.TypeCouponClaimed:   true,
+	events.TypeClaimCancelled:  true,
+	events.TypeCouponSoldOut:   true,
+	events.TypeWaitlistGranted: true,
+}
+
+// Options configures a Manager
+type Options struct {
+	Workers      int           // Deliveries sent concurrently per instance
+	PollInterval time.Duration // How often idle workers look for due deliveries
+	Timeout      time.Duration // Per-attempt HTTP timeout
+	MaxAttempts  int           // Attempts before a delivery is dead-lettered
+	BaseBackoff  time.Duration // Delay before the first retry; doubles with each attempt
+	MaxBackoff   time.Duration // Cap on the retry delay
+	Client       *http.Client  // HTTP client for deliveries; defaults to one with Timeout
+}
+
+// Manager manages subscriptions and sends deliveries
+type Manager struct {
+	repo   repository.WebhookRepository
+	opts   Options
+	owner  string
+	client *http.Client
+
+	wake   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	succeeded *metrics.Counter
+	failed    *metrics.Counter
+	dead      *metrics.Counter
+}
+
+// NewManager creates a webhook manager and registers its metrics
+func NewManager(repo repository.WebhookRepository, opts Options, registry *metrics.Registry) *Manager {
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 8
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = 10 * time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = time.Hour
+	}
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{Timeout: opts.Timeout}
+	}
+
+	const name, help = "webhook_attempts_total", "Webhook delivery attempts by result"
+	return &Manager{
+		repo:      repo,
+		opts:      opts,
+		owner:     owner.NewID(),
+		client:    client,
+		wake:      make(chan struct{}, 1),
+		succeeded: registry.Counter(name, help, "result", "succeeded"),
+		failed:    registry.Counter(name, help, "result", "failed"),
+		dead:      registry.Counter(name, help, "result", "dead"),
+	}
+}
+
+// Subscribe validates and stores a new subscription
+// The returned subscription carries its secret; it is not returned again.
+func (m *Manager) Subscribe(ctx context.Context, req *model.CreateWebhookRequest) (*model.WebhookSubscription, error) {
+	var invalid validation.Errors
+	target, err := url.Parse(req.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		invalid.Add("url", "must be an absolute http or https URL")
+	}
+
+	types := req.EventTypes
+	if len(types) == 0 {
+		types = []string{model.WebhookEventAll}
+	}
+	for i, t := range types {
+		if !eventTypes[t] {
+			invalid.Add(fmt.Sprintf("event_types[%d]", i), fmt.Sprintf("unknown event type %q", t))
+		}
+	}
+	if err := invalid.Err(); err != nil {
+		return nil, err
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		buf := make([]byte, 24)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		secret = "whsec_" + hex.EncodeToString(buf)
+	}
+
+	sub := &model.WebhookSubscription{
+		URL:        target.String(),
+		EventTypes: types,
+		Secret:     secret,
+		CreatedAt:  time.Now(),
+	}
+	if err := m.repo.CreateSubscription(ctx, sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Get retrieves a subscription
+func (m *Manager) Get(ctx context.Context, id string) (*model.WebhookSubscription, error) {
+	return m.repo.GetSubscription(ctx, id)
+}
+
+// List returns every subscription
+func (m *Manager) List(ctx context.Context) ([]*model.WebhookSubscription, error) {
+	return m.repo.ListSubscriptions(ctx)
+}
+
+// Unsubscribe removes a subscription and its pending and dead deliveries
+func (m *Manager) Unsubscribe(ctx context.Context, id string) error {
+	return m.repo.DeleteSubscription(ctx, id)
+}
+
+// Deliveries returns a subscription's delivery log, newest first
+// status filters by delivery status when set; dead deliveries form the
+// subscription's dead-letter queue
+func (m *Manager) Deliveries(ctx context.Context, subscriptionID string, status model.WebhookDeliveryStatus, limit int) ([]*model.WebhookDelivery, error) {
+	if _, err := m.repo.GetSubscription(ctx, subscriptionID); err != nil {
+		return nil, err
+	}
+	return m.repo.ListDeliveries(ctx, subscriptionID, status, limit)
+}
+
+// Replay sends a dead delivery again with a fresh set of attempts
+func (m *Manager) Replay(ctx context.Context, subscriptionID, deliveryID string) (*model.WebhookDelivery, error) {
+	delivery, err := m.repo.ReplayDelivery(ctx, subscriptionID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	m.notify()
+	return delivery, nil
+}
+
+// ReplayDead sends all of a subscription's dead deliveries again
+func (m *Manager) ReplayDead(ctx context.Context, subscriptionID string) (int64, error) {
+	if _, err := m.repo.GetSubscription(ctx, subscriptionID); err != nil {
+		return 0, err
+	}
+	n, err := m.repo.ReplayDead(ctx, subscriptionID)
+	if err != nil {
+		return 0, err
+	}
+	m.notify()
+	return n, nil
+}
+
+// Name identifies the manager as an outbox sink
+func (m *Manager) Name() string { return "webhooks" }
+
+// Publish queues an event for every subscription that wants it
+// It only stores deliveries; an error makes the outbox relay retry the event,
+// and deliveries queued by an earlier try are not duplicated.
+func (m *Manager) Publish(ctx context.Context, event events.Event) error {
+	subs, err := m.repo.ListSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	if event.ID == "" {
+		event.ID = primitive.NewObjectID().Hex()
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var deliveries []*model.WebhookDelivery
+	for _, sub := range subs {
+		if !sub.Matches(event.Type) {
+			continue
+		}
+		deliveries = append(deliveries, &model.WebhookDelivery{
+			SubscriptionID: sub.ID,
+			EventID:        event.ID,
+			EventType:      event.Type,
+			Payload:        payload,
+			Status:         model.WebhookDeliveryPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		})
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	if err := m.repo.EnqueueDeliveries(ctx, deliveries...); err != nil {
+		return err
+	}
+	m.notify()
+	return nil
+}
+
+// Start launches the delivery workers
+// Deliveries left in flight by a crashed instance are sent again once their lease expires
+func (m *Manager) Start() {
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+
+	for i := 0; i < m.opts.Workers; i++ {
+		m.wg.Add(1)
+		go m.worker()
+	}
+}
+
+// Stop stops the workers, letting attempts in flight finish
+func (m *Manager) Stop(ctx context.Context) error {
+	if m.cancel == nil {
+		return nil
+	}
+	m.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// notify wakes an idle worker
+func (m *Manager) notify() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// worker sends due deliveries until the manager stops
+func (m *Manager) worker() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Drain due deliveries before going back to sleep
+		for m.ctx.Err() == nil {
+			// Lease for longer than an attempt can take, so a slow attempt is not sent twice
+			delivery, err := m.repo.ClaimDueDelivery(m.ctx, m.owner, time.Now().Add(2*m.opts.Timeout))
+			if err != nil {
+				if m.ctx.Err() == nil {
+					log.Printf("Webhooks: failed to claim delivery: %v", err)
+				}
+				break
+			}
+			if delivery == nil {
+				break
+			}
+			m.deliver(delivery)
+		}
+
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-m.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliver makes one attempt at a delivery and records the outcome
+// Attempts are not interrupted by Stop; the lease covers them if the process dies
+func (m *Manager) deliver(delivery *model.WebhookDelivery) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.opts.Timeout)
+	defer cancel()
+
+	attempt := model.WebhookAttempt{At: time.Now()}
+	statusCode, err := m.send(ctx, delivery)
+	attempt.DurationMS = time.Since(attempt.At).Milliseconds()
+	attempt.StatusCode = statusCode
+
+	delivery.Attempts++
+	switch {
+	case err == nil:
+		now := time.Now()
+		delivery.Status = model.WebhookDeliverySucceeded
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+		m.succeeded.Inc()
+	case delivery.Attempts >= m.opts.MaxAttempts:
+		attempt.Error = err.Error()
+		delivery.Status = model.WebhookDeliveryDead
+		delivery.LastError = err.Error()
+		m.dead.Inc()
+		log.Printf("Webhooks: delivery %s of event %s dead-lettered after %d attempts: %v",
+			delivery.ID.Hex(), delivery.EventID, delivery.Attempts, err)
+	default:
+		attempt.Error = err.Error()
+		delivery.NextAttemptAt = time.Now().Add(m.backoff(delivery.Attempts))
+		delivery.LastError = err.Error()
+		m.failed.Inc()
+	}
+
+	recordCtx, recordCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer recordCancel()
+	if err := m.repo.RecordAttempt(recordCtx, delivery, m.owner, attempt); err != nil {
+		log.Printf("Webhooks: failed to record attempt for delivery %s: %v", delivery.ID.Hex(), err)
+	}
+}
+
+// send POSTs a signed delivery; any response other than 2xx is an error
+func (m *Manager) send(ctx context.Context, delivery *model.WebhookDelivery) (int, error) {
+	sub, err := m.repo.GetSubscription(ctx, delivery.SubscriptionID.Hex())
+	if err != nil {
+		return 0, fmt.Errorf("subscription unavailable: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "coupon-system-webhooks/1.0")
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(sub.Secret, timestamp, delivery.Payload))
+	req.Header.Set(HeaderEventID, delivery.EventID)
+	req.Header.Set(HeaderEventType, delivery.EventType)
+	req.Header.Set(HeaderDeliveryID, delivery.ID.Hex())
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Drain a little of the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff is the delay after attempt n fails: BaseBackoff, doubling up to MaxBackoff
+func (m *Manager) backoff(n int) time.Duration {
+	delay := m.opts.BaseBackoff
+	for i := 1; i < n && delay < m.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > m.opts.MaxBackoff {
+		delay = m.opts.MaxBackoff
+	}
+	return delay
+}
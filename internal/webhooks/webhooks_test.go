@@ -0,0 +1,403 @@
+package webhooks
+
+import (
+	"context"
+	"coupon-system/internal/events"
+	"coupon-system/internal/metrics"
+	"coupon-system/internal/model"
+	"coupon-system/internal/validation"
+	apperrors "coupon-system/pkg/errors"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryRepository is an in-memory WebhookRepository
+type memoryRepository struct {
+	mu         sync.Mutex
+	subs       []*model.WebhookSubscription
+	deliveries []*model.WebhookDelivery
+}
+
+func (r *memoryRepository) CreateSubscription(_ context.Context, sub *model.WebhookSubscription) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sub.ID = primitive.NewObjectID()
+	copied := *sub
+	r.subs = append(r.subs, &copied)
+	return nil
+}
+
+func (r *memoryRepository) GetSubscription(_ context.Context, id string) (*model.WebhookSubscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, sub := range r.subs {
+		if sub.ID.Hex() == id {
+			copied := *sub
+			return &copied, nil
+		}
+	}
+	return nil, apperrors.ErrWebhookNotFound
+}
+
+func (r *memoryRepository) ListSubscriptions(context.Context) ([]*model.WebhookSubscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	subs := make([]*model.WebhookSubscription, len(r.subs))
+	for i, sub := range r.subs {
+		copied := *sub
+		subs[i] = &copied
+	}
+	return subs, nil
+}
+
+func (r *memoryRepository) DeleteSubscription(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, sub := range r.subs {
+		if sub.ID.Hex() == id {
+			r.subs = append(r.subs[:i], r.subs[i+1:]...)
+			return nil
+		}
+	}
+	return apperrors.ErrWebhookNotFound
+}
+
+func (r *memoryRepository) EnqueueDeliveries(_ context.Context, deliveries ...*model.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+next:
+	for _, d := range deliveries {
+		for _, existing := range r.deliveries {
+			if existing.SubscriptionID == d.SubscriptionID && existing.EventID == d.EventID {
+				continue next
+			}
+		}
+		d.ID = primitive.NewObjectID()
+		copied := *d
+		r.deliveries = append(r.deliveries, &copied)
+	}
+	return nil
+}
+
+func (r *memoryRepository) ClaimDueDelivery(_ context.Context, owner string, leaseUntil time.Time) (*model.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	var due *model.WebhookDelivery
+	for _, d := range r.deliveries {
+		if d.Status != model.WebhookDeliveryPending || d.NextAttemptAt.After(now) || d.LeaseUntil.After(now) {
+			continue
+		}
+		if due == nil || d.NextAttemptAt.Before(due.NextAttemptAt) {
+			due = d
+		}
+	}
+	if due == nil {
+		return nil, nil
+	}
+	due.Owner, due.LeaseUntil = owner, leaseUntil
+	copied := *due
+	return &copied, nil
+}
+
+func (r *memoryRepository) RecordAttempt(_ context.Context, delivery *model.WebhookDelivery, owner string, attempt model.WebhookAttempt) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range r.deliveries {
+		if d.ID == delivery.ID && d.Owner == owner {
+			d.Status, d.Attempts, d.NextAttemptAt = delivery.Status, delivery.Attempts, delivery.NextAttemptAt
+			d.LastError, d.DeliveredAt = delivery.LastError, delivery.DeliveredAt
+			d.Owner, d.LeaseUntil = "", time.Time{}
+			d.Log = append(d.Log, attempt)
+		}
+	}
+	return nil
+}
+
+func (r *memoryRepository) ListDeliveries(_ context.Context, subscriptionID string, status model.WebhookDeliveryStatus, limit int) ([]*model.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*model.WebhookDelivery
+	for i := len(r.deliveries) - 1; i >= 0 && len(out) < limit; i-- {
+		d := r.deliveries[i]
+		if d.SubscriptionID.Hex() == subscriptionID && (status == "" || d.Status == status) {
+			copied := *d
+			copied.Log = append([]model.WebhookAttempt(nil), d.Log...)
+			out = append(out, &copied)
+		}
+	}
+	return out, nil
+}
+
+func (r *memoryRepository) ReplayDelivery(_ context.Context, subscriptionID, deliveryID string) (*model.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range r.deliveries {
+		if d.ID.Hex() == deliveryID && d.SubscriptionID.Hex() == subscriptionID {
+			if d.Status != model.WebhookDeliveryDead {
+				return nil, apperrors.ErrDeliveryNotDead
+			}
+			d.Status, d.Attempts, d.NextAttemptAt = model.WebhookDeliveryPending, 0, time.Now()
+			copied := *d
+			return &copied, nil
+		}
+	}
+	return nil, apperrors.ErrDeliveryNotFound
+}
+
+func (r *memoryRepository) ReplayDead(_ context.Context, subscriptionID string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var n int64
+	for _, d := range r.deliveries {
+		if d.SubscriptionID.Hex() == subscriptionID && d.Status == model.WebhookDeliveryDead {
+			d.Status, d.Attempts, d.NextAttemptAt = model.WebhookDeliveryPending, 0, time.Now()
+			n++
+		}
+	}
+	return n, nil
+}
+
+// receiver is a local webhook endpoint that verifies signatures
+type receiver struct {
+	*httptest.Server
+	secret  string
+	failing atomic.Int32 // Respond 500 to this many more requests
+
+	mu       sync.Mutex
+	received []string // Event IDs of verified requests
+	invalid  int
+}
+
+func newReceiver(t *testing.T, secret string) *receiver {
+	t.Helper()
+	rcv := &receiver{secret: secret}
+	rcv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		err := Verify(rcv.secret, r.Header.Get(HeaderTimestamp), r.Header.Get(HeaderSignature), body, 5*time.Minute, time.Now())
+
+		rcv.mu.Lock()
+		defer rcv.mu.Unlock()
+		if err != nil {
+			rcv.invalid++
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if rcv.failing.Load() > 0 {
+			rcv.failing.Add(-1)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		rcv.received = append(rcv.received, r.Header.Get(HeaderEventID))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(rcv.Close)
+	return rcv
+}
+
+func (rcv *receiver) eventIDs() []string {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	return append([]string(nil), rcv.received...)
+}
+
+func newTestManager(t *testing.T, maxAttempts int) *Manager {
+	t.Helper()
+	m := NewManager(&memoryRepository{}, Options{
+		Workers:      2,
+		PollInterval: 5 * time.Millisecond,
+		Timeout:      time.Second,
+		MaxAttempts:  maxAttempts,
+		BaseBackoff:  time.Millisecond,
+		MaxBackoff:   5 * time.Millisecond,
+	}, metrics.NewRegistry())
+	m.Start()
+	t.Cleanup(func() { _ = m.Stop(context.Background()) })
+	return m
+}
+
+func subscribe(t *testing.T, m *Manager, url string, types ...string) *model.WebhookSubscription {
+	t.Helper()
+	sub, err := m.Subscribe(context.Background(), &model.CreateWebhookRequest{URL: url, EventTypes: types, Secret: "test-secret"})
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	return sub
+}
+
+func publish(t *testing.T, m *Manager, id, eventType string) {
+	t.Helper()
+	event := events.Event{ID: id, Type: eventType, CouponName: "WEBHOOKS", OccurredAt: time.Now()}
+	if err := m.Publish(context.Background(), event); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func deliveries(t *testing.T, m *Manager, sub *model.WebhookSubscription, status model.WebhookDeliveryStatus) []*model.WebhookDelivery {
+	t.Helper()
+	list, err := m.Deliveries(context.Background(), sub.ID.Hex(), status, 100)
+	if err != nil {
+		t.Fatalf("list deliveries: %v", err)
+	}
+	return list
+}
+
+// TestDeliveryIsSignedAndFiltered checks that receivers get verifiable
+// requests for the event types they asked for, once per event
+func TestDeliveryIsSignedAndFiltered(t *testing.T) {
+	m := newTestManager(t, 3)
+	claims := newReceiver(t, "test-secret")
+	all := newReceiver(t, "test-secret")
+	claimsSub := subscribe(t, m, claims.URL, events.TypeCouponClaimed)
+	subscribe(t, m, all.URL)
+
+	publish(t, m, "e1", events.TypeCouponCreated)
+	publish(t, m, "e2", events.TypeCouponClaimed)
+	publish(t, m, "e2", events.TypeCouponClaimed) // Redelivered by the outbox relay
+
+	waitFor(t, func() bool { return len(all.eventIDs()) == 2 && len(claims.eventIDs()) == 1 })
+	time.Sleep(20 * time.Millisecond)
+
+	got := all.eventIDs()
+	sort.Strings(got)
+	if len(got) != 2 || got[0] != "e1" || got[1] != "e2" {
+		t.Errorf("subscriber to all events got %v, want [e1 e2]", got)
+	}
+	if got := claims.eventIDs(); len(got) != 1 || got[0] != "e2" {
+		t.Errorf("coupon.claimed subscriber got %v, want [e2]", got)
+	}
+	if claims.invalid+all.invalid != 0 {
+		t.Errorf("%d requests failed signature verification", claims.invalid+all.invalid)
+	}
+
+	log := deliveries(t, m, claimsSub, model.WebhookDeliverySucceeded)
+	if len(log) != 1 || len(log[0].Log) != 1 || log[0].Log[0].StatusCode != http.StatusNoContent {
+		t.Fatalf("delivery log = %+v, want one attempt answered 204", log)
+	}
+}
+
+// TestRetriesDeadLetterAndReplay checks that failed deliveries are retried,
+// dead-lettered when out of attempts, and delivered after a replay
+func TestRetriesDeadLetterAndReplay(t *testing.T) {
+	m := newTestManager(t, 3)
+	rcv := newReceiver(t, "test-secret")
+	sub := subscribe(t, m, rcv.URL)
+
+	// Fails twice, succeeds on the third and last attempt
+	rcv.failing.Store(2)
+	publish(t, m, "e1", events.TypeCouponClaimed)
+	waitFor(t, func() bool { return len(rcv.eventIDs()) == 1 })
+	waitFor(t, func() bool { return len(deliveries(t, m, sub, model.WebhookDeliverySucceeded)) == 1 })
+	if d := deliveries(t, m, sub, model.WebhookDeliverySucceeded)[0]; d.Attempts != 3 || len(d.Log) != 3 {
+		t.Fatalf("attempts = %d with %d logged, want 3", d.Attempts, len(d.Log))
+	}
+
+	// Fails every attempt and is dead-lettered
+	rcv.failing.Store(1000)
+	publish(t, m, "e2", events.TypeCouponClaimed)
+	waitFor(t, func() bool { return len(deliveries(t, m, sub, model.WebhookDeliveryDead)) == 1 })
+	dead := deliveries(t, m, sub, model.WebhookDeliveryDead)[0]
+	if dead.Attempts != 3 || dead.LastError == "" {
+		t.Fatalf("dead delivery attempts = %d, last error %q; want 3 and an error", dead.Attempts, dead.LastError)
+	}
+
+	if _, err := m.Replay(context.Background(), sub.ID.Hex(), deliveries(t, m, sub, model.WebhookDeliverySucceeded)[0].ID.Hex()); !errors.Is(err, apperrors.ErrDeliveryNotDead) {
+		t.Fatalf("replaying a delivered event: err = %v, want ErrDeliveryNotDead", err)
+	}
+
+	rcv.failing.Store(0)
+	if _, err := m.Replay(context.Background(), sub.ID.Hex(), dead.ID.Hex()); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	waitFor(t, func() bool { return len(rcv.eventIDs()) == 2 })
+	if got := rcv.eventIDs(); got[1] != "e2" {
+		t.Fatalf("received %v after replay, want e2 last", got)
+	}
+}
+
+// TestSubscribeValidation checks that bad subscriptions are rejected
+func TestSubscribeValidation(t *testing.T) {
+	m := newTestManager(t, 1)
+	for _, tc := range []struct {
+		req     model.CreateWebhookRequest
+		invalid []string
+	}{
+		{model.CreateWebhookRequest{URL: "ftp://example.com/hook"}, []string{"url"}},
+		{model.CreateWebhookRequest{URL: "/relative"}, []string{"url"}},
+		{model.CreateWebhookRequest{URL: "https://example.com/hook", EventTypes: []string{events.TypeCouponSoldOut, "coupon.exploded"}}, []string{"event_types[1]"}},
+		{model.CreateWebhookRequest{URL: "", EventTypes: []string{"x"}}, []string{"url", "event_types[0]"}},
+	} {
+		_, err := m.Subscribe(context.Background(), &tc.req)
+		if !errors.Is(err, apperrors.ErrValidation) {
+			t.Errorf("subscribe %+v: err = %v, want validation error", tc.req, err)
+			continue
+		}
+		var got []string
+		for _, p := range apperrors.From(err).Details["invalid_params"].([]validation.InvalidParam) {
+			got = append(got, p.Name)
+		}
+		if !reflect.DeepEqual(got, tc.invalid) {
+			t.Errorf("subscribe %+v: invalid params = %v, want %v", tc.req, got, tc.invalid)
+		}
+	}
+
+	sub, err := m.Subscribe(context.Background(), &model.CreateWebhookRequest{URL: "https://example.com/hook"})
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if sub.Secret == "" || !sub.Matches(events.TypeCouponSoldOut) {
+		t.Fatalf("subscription = %+v, want a generated secret and all event types", sub)
+	}
+}
+
+// TestVerify checks signature verification
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":"e1","type":"coupon.claimed"}`)
+	now := time.Now()
+	ts := now.Unix()
+	sig := Sign("secret", ts, body)
+	itoa := func(n int64) string { return strconv.FormatInt(n, 10) }
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		signature string
+		body      []byte
+		want      error
+	}{
+		{"valid", "secret", itoa(ts), sig, body, nil},
+		{"wrong secret", "other", itoa(ts), sig, body, ErrInvalidSignature},
+		{"tampered body", "secret", itoa(ts), sig, []byte(`{"id":"e2"}`), ErrInvalidSignature},
+		{"changed timestamp", "secret", itoa(ts + 1), sig, body, ErrInvalidSignature},
+		{"stale", "secret", itoa(ts - 600), Sign("secret", ts-600, body), body, ErrStaleTimestamp},
+		{"missing", "secret", "", "", body, ErrMissingSignature},
+	}
+	for _, tt := range tests {
+		if err := Verify(tt.secret, tt.timestamp, tt.signature, tt.body, 5*time.Minute, now); err != tt.want {
+			t.Errorf("%s: Verify = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+// Package client is a Go client for the coupon HTTP API
+//
+// Every call takes a context and returns errors that match the domain errors
+// of pkg/errors with errors.Is, for example errors.Is(err, client.ErrNoStock).
+// Reads and requests sent with an Idempotency-Key are retried on network
+// errors, 429 and 503 responses; the key is generated once per call, so a
+// retried claim or create is applied at most once.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	mrand "math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Doer sends HTTP requests; *http.Client implements it
+// Supply one to add tracing, mTLS or a test transport.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Options configures a Client
+type Options struct {
+	APIKey      string        // Sent as X-API-Key when set
+	HTTPClient  Doer          // Defaults to an *http.Client without a timeout of its own
+	Timeout     time.Duration // Per-attempt timeout of unary calls (streams are only bound by their context)
+	MaxAttempts int           // Total attempts of a retryable call including the first; 1 disables retries
+	BaseDelay   time.Duration // Backoff before the first retry, doubling per retry...
+	MaxDelay    time.Duration // ...up to this cap; a Retry-After header takes precedence
+	UserAgent   string
+}
+
+// Client calls the coupon API; it is safe for concurrent use
+type Client struct {
+	baseURL string
+	opts    Options
+}
+
+// New creates a client for the API at baseURL, e.g. http://localhost:8080
+func New(baseURL string, opts Options) *Client {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{}
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 3
+	}
+	if opts.BaseDelay <= 0 {
+		opts.BaseDelay = 100 * time.Millisecond
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = 2 * time.Second
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = "coupon-system-client"
+	}
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		opts:    opts,
+	}
+}
+
+// Health reports whether the server is up and whether it is shedding load
+func (c *Client) Health(ctx context.Context) (*Health, error) {
+	var health Health
+	if err := c.do(ctx, call{method: http.MethodGet, path: "/health", out: &health}); err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
+
+type idempotencyKeyContext struct{}
+
+// WithIdempotencyKey makes calls with ctx send key instead of a generated one
+// Use it to retry an operation across process restarts.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContext{}, key)
+}
+
+// call is one API operation
+type call struct {
+	method string
+	path   string
+	query  url.Values
+	body   interface{} // Sent as JSON when non-nil
+	out    interface{} // Decoded from a JSON response when non-nil
+
+	idempotent bool // Send an Idempotency-Key, which makes the call safe to retry
+	retry      bool // Safe to retry without a key: the operation has the same effect when repeated
+}
+
+// do runs a call, retrying transient failures of calls that are safe to repeat
+func (c *Client) do(ctx context.Context, cl call) error {
+	var body []byte
+	if cl.body != nil {
+		data, err := json.Marshal(cl.body)
+		if err != nil {
+			return err
+		}
+		body = data
+	}
+
+	var key string
+	if cl.idempotent {
+		key, _ = ctx.Value(idempotencyKeyContext{}).(string)
+		if key == "" {
+			key = newIdempotencyKey()
+		}
+	}
+	retryable := cl.retry || cl.idempotent || cl.method == http.MethodGet
+
+	for attempt := 1; ; attempt++ {
+		err := c.attempt(ctx, cl, body, key)
+		if err == nil || !retryable || attempt >= c.opts.MaxAttempts || !temporary(err) {
+			return err
+		}
+
+		delay := c.backoff(attempt)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			delay = apiErr.RetryAfter
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the wait before retry n (1-based): a random duration up to
+// BaseDelay doubled per retry and capped at MaxDelay
+func (c *Client) backoff(n int) time.Duration {
+	ceiling := c.opts.BaseDelay
+	for i := 1; i < n && ceiling < c.opts.MaxDelay; i++ {
+		ceiling *= 2
+	}
+	if ceiling > c.opts.MaxDelay {
+		ceiling = c.opts.MaxDelay
+	}
+	return time.Duration(mrand.Int63n(int64(ceiling) + 1))
+}
+
+// attempt sends a call once
+func (c *Client) attempt(ctx context.Context, cl call, body []byte, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+
+	resp, err := c.send(ctx, cl.method, cl.path, cl.query, body, key)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(cl.method, cl.path, resp); err != nil {
+		return err
+	}
+	if cl.out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(cl.out)
+}
+
+// send builds and sends a request
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body []byte, key string) (*http.Response, error) {
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	if c.opts.APIKey != "" {
+		req.Header.Set("X-API-Key", c.opts.APIKey)
+	}
+	req.Header.Set("User-Agent", c.opts.UserAgent)
+	return c.opts.HTTPClient.Do(req)
+}
+
+// checkResponse turns a non-2xx response into an *APIError
+func checkResponse(method, path string, resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return nil
+	}
+
+	// problem+json (RFC 7807); other bodies, e.g. from a proxy, leave the fields empty
+	var problem struct {
+		Code   string `json:"code"`
+		Detail string `json:"detail"`
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&problem)
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Code:       problem.Code,
+		Message:    problem.Detail,
+		Method:     method,
+		Path:       path,
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	apiErr.err = matchError(resp.StatusCode, problem.Code)
+	return apiErr
+}
+
+// temporary reports whether a failed attempt may succeed if sent again
+func temporary(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		// The first request with the same key is still running; a retry gets its response
+		return errors.Is(err, ErrRequestInProgress)
+	}
+	// Network errors; the caller's own cancellation is final
+	return !errors.Is(err, context.Canceled)
+}
+
+// newIdempotencyKey returns a random key
+func newIdempotencyKey() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// pathJoin builds a path from segments, escaping each one
+func pathJoin(segments ...string) string {
+	var b strings.Builder
+	for _, s := range segments {
+		b.WriteString("/")
+		b.WriteString(url.PathEscape(s))
+	}
+	return b.String()
+}
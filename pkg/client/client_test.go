@@ -0,0 +1,231 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestClient serves handler and returns a client for it with fast retries
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return New(server.URL, Options{BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond})
+}
+
+// writeProblem writes a problem+json error response like the server's
+func writeProblem(w http.ResponseWriter, status int, code, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"type":"urn:coupon-system:problem:%s","title":%q,"status":%d,"detail":%q,"code":%q}`,
+		code, http.StatusText(status), status, detail, code)
+}
+
+// TestErrorsMatchDomainErrors checks error responses unwrap to the error their code stands for
+func TestErrorsMatchDomainErrors(t *testing.T) {
+	cases := []struct {
+		status int
+		code   string
+		detail string
+		want   error
+	}{
+		{http.StatusBadRequest, "no_stock", "no stock available", ErrNoStock},
+		{http.StatusConflict, "already_claimed", "coupon already claimed by this user", ErrAlreadyClaimed},
+		{http.StatusNotFound, "coupon_not_found", "coupon not found", ErrCouponNotFound},
+		{http.StatusForbidden, "queue_token_required", "queue token required", ErrQueueTokenRequired},
+		{http.StatusBadRequest, "validation_failed", "request validation failed: url must be an absolute http or https URL", ErrValidation},
+		{http.StatusServiceUnavailable, "database_unavailable", "database unavailable", ErrDatabaseUnavailable},
+		{http.StatusServiceUnavailable, "too_many_stream_clients", "too many stream clients", ErrStreamUnavailable},
+		{http.StatusUnprocessableEntity, "idempotency_key_reused", "idempotency key was used with a different request", ErrIdempotencyKeyUsed},
+		{http.StatusBadRequest, "invalid_request", "invalid request body", ErrInvalidRequest},
+		{http.StatusInternalServerError, "internal_error", "internal server error", ErrInternal},
+		{http.StatusNotFound, "", "", ErrNotFound},
+		{http.StatusBadGateway, "", "", ErrServer},
+	}
+	for _, tc := range cases {
+		client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if tc.code == "" {
+				w.WriteHeader(tc.status)
+				return
+			}
+			writeProblem(w, tc.status, tc.code, tc.detail)
+		})
+		client.opts.MaxAttempts = 1
+
+		err := client.ClaimCoupon(context.Background(), &ClaimCouponRequest{UserID: "u", CouponName: "C"})
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%d %s: err = %v, want %v", tc.status, tc.code, err, tc.want)
+		}
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != tc.status || apiErr.Code != tc.code || apiErr.Message != tc.detail {
+			t.Errorf("%d %s: APIError = %+v", tc.status, tc.code, apiErr)
+		}
+	}
+}
+
+// TestRetriesReuseIdempotencyKey checks keyed requests, including deletes, are
+// retried with the same key and other POSTs and client errors are not retried
+func TestRetriesReuseIdempotencyKey(t *testing.T) {
+	var mu sync.Mutex
+	var keys []string
+	var calls int
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		switch r.URL.Path {
+		case "/api/coupons/claim":
+			keys = append(keys, r.Header.Get("Idempotency-Key"))
+			switch len(keys) {
+			case 1:
+				writeProblem(w, http.StatusServiceUnavailable, "overloaded", "server overloaded, retry later")
+			case 2:
+				writeProblem(w, http.StatusConflict, "request_in_progress", "a request with this idempotency key is in progress")
+			default:
+				fmt.Fprint(w, `{"message":"Coupon claimed successfully"}`)
+			}
+		case "/api/coupons/C/claims/u":
+			keys = append(keys, r.Header.Get("Idempotency-Key"))
+			if len(keys) == 1 {
+				writeProblem(w, http.StatusServiceUnavailable, "overloaded", "server overloaded, retry later")
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeProblem(w, http.StatusServiceUnavailable, "database_unavailable", "database unavailable")
+		}
+	})
+	ctx := context.Background()
+
+	if err := client.ClaimCoupon(ctx, &ClaimCouponRequest{UserID: "u", CouponName: "C"}); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if len(keys) != 3 || keys[0] == "" || keys[0] != keys[1] || keys[1] != keys[2] {
+		t.Fatalf("idempotency keys = %q, want the same key on 3 attempts", keys)
+	}
+
+	keys = nil
+	if err := client.ClaimCoupon(WithIdempotencyKey(ctx, "order-42"), &ClaimCouponRequest{UserID: "u", CouponName: "C"}); err != nil {
+		t.Fatalf("claim with key: %v", err)
+	}
+	if keys[0] != "order-42" {
+		t.Fatalf("idempotency key = %q, want order-42", keys[0])
+	}
+
+	calls = 0
+	if _, err := client.JoinWaitlist(ctx, "C", "u"); !errors.Is(err, ErrDatabaseUnavailable) || calls != 1 {
+		t.Fatalf("join waitlist: err = %v after %d calls, want 1 call", err, calls)
+	}
+	calls = 0
+	if _, err := client.GetCoupon(ctx, "C"); !errors.Is(err, ErrDatabaseUnavailable) || calls != 3 {
+		t.Fatalf("get coupon: err = %v after %d calls, want 3 calls", err, calls)
+	}
+
+	keys = nil
+	if err := client.CancelClaim(ctx, "C", "u"); err != nil {
+		t.Fatalf("cancel claim: %v", err)
+	}
+	if len(keys) != 2 || keys[0] == "" || keys[0] != keys[1] {
+		t.Fatalf("idempotency keys = %q, want the same key on 2 attempts", keys)
+	}
+}
+
+// TestBackoffIsCapped checks retry waits grow from BaseDelay and stay within MaxDelay
+func TestBackoffIsCapped(t *testing.T) {
+	c := New("http://localhost", Options{BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond})
+	for n, ceiling := range map[int]time.Duration{1: 10 * time.Millisecond, 2: 20 * time.Millisecond, 3: 40 * time.Millisecond, 10: 50 * time.Millisecond} {
+		for i := 0; i < 100; i++ {
+			if d := c.backoff(n); d < 0 || d > ceiling {
+				t.Fatalf("backoff(%d) = %v, want within [0, %v]", n, d, ceiling)
+			}
+		}
+	}
+}
+
+// TestRetryHonoursContext checks a cancelled context ends the wait for a retry
+func TestRetryHonoursContext(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		writeProblem(w, http.StatusTooManyRequests, "rate_limited", "rate limit exceeded")
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.ListCoupons(ctx)
+	var apiErr *APIError
+	if !errors.Is(err, ErrRateLimited) || !errors.As(err, &apiErr) || apiErr.RetryAfter != time.Minute {
+		t.Fatalf("err = %v, want rate limited with Retry-After 1m", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("returned after %v, want at the context deadline", elapsed)
+	}
+}
+
+// doerFunc adapts a function to Doer
+type doerFunc func(*http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(req *http.Request) (*http.Response, error) { return f(req) }
+
+// TestCustomTransport checks requests go through the supplied Doer with the client's headers
+func TestCustomTransport(t *testing.T) {
+	var got *http.Request
+	client := New("http://coupons.internal/", Options{
+		APIKey: "secret",
+		HTTPClient: doerFunc(func(req *http.Request) (*http.Response, error) {
+			got = req
+			rec := httptest.NewRecorder()
+			rec.WriteString(`[{"id":"65f000000000000000000001","status":"dead"}]`)
+			return rec.Result(), nil
+		}),
+	})
+
+	deliveries, err := client.ListDeliveries(context.Background(), "hook 1", WebhookDeliveryStatus("dead"), 10)
+	if err != nil || len(deliveries) != 1 || deliveries[0].Status != "dead" {
+		t.Fatalf("deliveries = %+v, %v", deliveries, err)
+	}
+	if got.URL.String() != "http://coupons.internal/api/webhooks/hook%201/deliveries?limit=10&status=dead" {
+		t.Errorf("URL = %s", got.URL)
+	}
+	if got.Header.Get("X-API-Key") != "secret" || got.Header.Get("Idempotency-Key") != "" {
+		t.Errorf("headers = %v", got.Header)
+	}
+}
+
+// TestStreamCoupon checks stock events are decoded and heartbeats skipped
+func TestStreamCoupon(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/coupons/LIVE/stream" {
+			writeProblem(w, http.StatusNotFound, "coupon_not_found", "coupon not found")
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "id: 1\nevent: stock\ndata: {\"name\":\"LIVE\",\"remaining_amount\":2,\"version\":1}\n\n")
+		fmt.Fprint(w, ": heartbeat\n\n")
+		fmt.Fprint(w, "id: 2\nevent: stock\ndata: {\"name\":\"LIVE\",\"remaining_amount\":1,\"version\":2}\n\n")
+	})
+
+	var updates []*StockUpdate
+	err := client.StreamCoupon(context.Background(), "LIVE", func(u *StockUpdate) error {
+		updates = append(updates, u)
+		return nil
+	})
+	if err != io.EOF {
+		t.Fatalf("err = %v, want io.EOF when the server ends the stream", err)
+	}
+	if len(updates) != 2 || updates[0].RemainingAmount != 2 || updates[1].Version != 2 {
+		t.Fatalf("updates = %+v", updates)
+	}
+
+	err = client.StreamCoupon(context.Background(), "MISSING", func(*StockUpdate) error { return nil })
+	if !errors.Is(err, ErrCouponNotFound) {
+		t.Fatalf("missing coupon: err = %v", err)
+	}
+}
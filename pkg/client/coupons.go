@@ -0,0 +1,99 @@
+package client
+
+import (
+	"context"
+	"coupon-system/internal/model"
+	"net/http"
+)
+
+// ListCoupons returns every coupon
+func (c *Client) ListCoupons(ctx context.Context) ([]*Coupon, error) {
+	var coupons []*Coupon
+	if err := c.do(ctx, call{method: http.MethodGet, path: "/api/coupons", out: &coupons}); err != nil {
+		return nil, err
+	}
+	return coupons, nil
+}
+
+// CreateCoupon creates a coupon
+func (c *Client) CreateCoupon(ctx context.Context, req *CreateCouponRequest) (*Coupon, error) {
+	var coupon Coupon
+	err := c.do(ctx, call{method: http.MethodPost, path: "/api/coupons", body: req, out: &coupon, idempotent: true})
+	if err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+// GetCoupon returns a coupon and the users who claimed it
+func (c *Client) GetCoupon(ctx context.Context, name string) (*CouponDetails, error) {
+	var details CouponDetails
+	if err := c.do(ctx, call{method: http.MethodGet, path: couponPath(name), out: &details}); err != nil {
+		return nil, err
+	}
+	return &details, nil
+}
+
+// PauseCoupon stops a coupon from being claimed
+func (c *Client) PauseCoupon(ctx context.Context, name string) (*Coupon, error) {
+	return c.setActive(ctx, name, "pause")
+}
+
+// ResumeCoupon allows a paused coupon to be claimed again
+func (c *Client) ResumeCoupon(ctx context.Context, name string) (*Coupon, error) {
+	return c.setActive(ctx, name, "resume")
+}
+
+func (c *Client) setActive(ctx context.Context, name, action string) (*Coupon, error) {
+	var coupon Coupon
+	if err := c.do(ctx, call{method: http.MethodPost, path: couponPath(name, action), out: &coupon, retry: true}); err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+// RestockCoupon adds amount to a coupon's stock
+func (c *Client) RestockCoupon(ctx context.Context, name string, amount int32) (*Coupon, error) {
+	var coupon Coupon
+	err := c.do(ctx, call{method: http.MethodPost, path: couponPath(name, "restock"),
+		body: model.RestockCouponRequest{Amount: amount}, out: &coupon, idempotent: true})
+	if err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+// ClaimCoupon claims a coupon for a user
+// Queue-enabled coupons need req.QueueToken from an admitted ticket.
+func (c *Client) ClaimCoupon(ctx context.Context, req *ClaimCouponRequest) error {
+	return c.do(ctx, call{method: http.MethodPost, path: "/api/coupons/claim", body: req, idempotent: true})
+}
+
+// BulkClaim grants a coupon to many users at once
+func (c *Client) BulkClaim(ctx context.Context, req *BulkClaimRequest) (*BulkClaimResponse, error) {
+	var resp BulkClaimResponse
+	err := c.do(ctx, call{method: http.MethodPost, path: "/api/coupons/claim/bulk", body: req, out: &resp, idempotent: true})
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ListClaims returns a coupon's claims
+func (c *Client) ListClaims(ctx context.Context, name string) ([]*Claim, error) {
+	var claims []*Claim
+	if err := c.do(ctx, call{method: http.MethodGet, path: couponPath(name, "claims"), out: &claims}); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// CancelClaim cancels a user's claim and returns its stock
+func (c *Client) CancelClaim(ctx context.Context, name, userID string) error {
+	return c.do(ctx, call{method: http.MethodDelete, path: couponPath(name, "claims", userID), idempotent: true})
+}
+
+// couponPath builds /api/coupons/{name}/{suffix...} with every segment escaped
+func couponPath(name string, suffix ...string) string {
+	return "/api/coupons" + pathJoin(append([]string{name}, suffix...)...)
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"coupon-system/internal/model"
+	"net/http"
+)
+
+// JoinWaitlist adds a user to a sold-out coupon's waitlist
+func (c *Client) JoinWaitlist(ctx context.Context, name, userID string) (*WaitlistStatus, error) {
+	var status WaitlistStatus
+	err := c.do(ctx, call{method: http.MethodPost, path: couponPath(name, "waitlist"),
+		body: model.JoinWaitlistRequest{UserID: userID}, out: &status})
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// GetWaitlistStatus returns a user's place on a waitlist
+func (c *Client) GetWaitlistStatus(ctx context.Context, name, userID string) (*WaitlistStatus, error) {
+	var status WaitlistStatus
+	if err := c.do(ctx, call{method: http.MethodGet, path: couponPath(name, "waitlist", userID), out: &status}); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// LeaveWaitlist removes a user from a waitlist
+func (c *Client) LeaveWaitlist(ctx context.Context, name, userID string) error {
+	return c.do(ctx, call{method: http.MethodDelete, path: couponPath(name, "waitlist", userID), idempotent: true})
+}
+
+// EnterRaffle enters a user into a coupon's raffle
+func (c *Client) EnterRaffle(ctx context.Context, name, userID string) (*RaffleEntry, error) {
+	var entry RaffleEntry
+	err := c.do(ctx, call{method: http.MethodPost, path: couponPath(name, "raffle", "entries"),
+		body: model.EnterRaffleRequest{UserID: userID}, out: &entry})
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// DrawRaffle draws a raffle's winners once its entry window has ended
+func (c *Client) DrawRaffle(ctx context.Context, name string) (*RaffleDraw, error) {
+	var draw RaffleDraw
+	if err := c.do(ctx, call{method: http.MethodPost, path: couponPath(name, "raffle", "draw"), out: &draw}); err != nil {
+		return nil, err
+	}
+	return &draw, nil
+}
+
+// GetRaffleDraw returns a raffle's draw
+func (c *Client) GetRaffleDraw(ctx context.Context, name string) (*RaffleDraw, error) {
+	var draw RaffleDraw
+	if err := c.do(ctx, call{method: http.MethodGet, path: couponPath(name, "raffle"), out: &draw}); err != nil {
+		return nil, err
+	}
+	return &draw, nil
+}
+
+// VerifyRaffleDraw re-runs a raffle draw from its seed
+func (c *Client) VerifyRaffleDraw(ctx context.Context, name string) (*RaffleVerification, error) {
+	var verification RaffleVerification
+	if err := c.do(ctx, call{method: http.MethodGet, path: couponPath(name, "raffle", "verify"), out: &verification}); err != nil {
+		return nil, err
+	}
+	return &verification, nil
+}
+
+// JoinQueue puts a user in a coupon's virtual queue
+// Poll the ticket with GetQueueTicket until it is admitted, then claim with its token.
+func (c *Client) JoinQueue(ctx context.Context, name, userID string) (*QueueTicket, error) {
+	var ticket QueueTicket
+	err := c.do(ctx, call{method: http.MethodPost, path: couponPath(name, "queue"),
+		body: model.JoinQueueRequest{UserID: userID}, out: &ticket})
+	if err != nil {
+		return nil, err
+	}
+	return &ticket, nil
+}
+
+// GetQueueTicket polls a queue ticket
+func (c *Client) GetQueueTicket(ctx context.Context, token string) (*QueueTicket, error) {
+	var ticket QueueTicket
+	if err := c.do(ctx, call{method: http.MethodGet, path: "/api/queue" + pathJoin(token), out: &ticket}); err != nil {
+		return nil, err
+	}
+	return &ticket, nil
+}
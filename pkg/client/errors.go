@@ -0,0 +1,127 @@
+package client
+
+import (
+	apperrors "coupon-system/pkg/errors"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Domain errors, the same values as pkg/errors
+var (
+	ErrCouponNotFound      = apperrors.ErrCouponNotFound
+	ErrCouponAlreadyExists = apperrors.ErrCouponAlreadyExists
+	ErrAlreadyClaimed      = apperrors.ErrAlreadyClaimed
+	ErrNoStock             = apperrors.ErrNoStock
+	ErrCouponInactive      = apperrors.ErrCouponInactive
+	ErrClaimNotFound       = apperrors.ErrClaimNotFound
+	ErrStockAvailable      = apperrors.ErrStockAvailable
+	ErrAlreadyWaitlisted   = apperrors.ErrAlreadyWaitlisted
+	ErrNotWaitlisted       = apperrors.ErrNotWaitlisted
+	ErrWaitlistDisabled    = apperrors.ErrWaitlistDisabled
+	ErrRaffleOnly          = apperrors.ErrRaffleOnly
+	ErrNotRaffle           = apperrors.ErrNotRaffle
+	ErrRaffleEntryClosed   = apperrors.ErrRaffleEntryClosed
+	ErrRaffleEntryOpen     = apperrors.ErrRaffleEntryOpen
+	ErrAlreadyEntered      = apperrors.ErrAlreadyEntered
+	ErrRaffleAlreadyDrawn  = apperrors.ErrRaffleAlreadyDrawn
+	ErrRaffleNotDrawn      = apperrors.ErrRaffleNotDrawn
+	ErrQueueNotEnabled     = apperrors.ErrQueueNotEnabled
+	ErrQueueTokenRequired  = apperrors.ErrQueueTokenRequired
+	ErrQueueTokenInvalid   = apperrors.ErrQueueTokenInvalid
+	ErrQueueTicketNotFound = apperrors.ErrQueueTicketNotFound
+	ErrQueueWrongInstance  = apperrors.ErrQueueWrongInstance
+	ErrJobNotFound         = apperrors.ErrJobNotFound
+	ErrUnknownJobType      = apperrors.ErrUnknownJobType
+	ErrDatabaseUnavailable = apperrors.ErrDatabaseUnavailable
+	ErrWebhookNotFound     = apperrors.ErrWebhookNotFound
+	ErrDeliveryNotFound    = apperrors.ErrDeliveryNotFound
+	ErrDeliveryNotDead     = apperrors.ErrDeliveryNotDead
+	ErrAPIKeyNotFound      = apperrors.ErrAPIKeyNotFound
+	ErrAPIKeyRevoked       = apperrors.ErrAPIKeyRevoked
+)
+
+// Errors raised by the API layer, the same values as pkg/errors
+var (
+	ErrInvalidRequest     = apperrors.ErrInvalidRequest
+	ErrValidation         = apperrors.ErrValidation
+	ErrUnauthorized       = apperrors.ErrUnauthorized
+	ErrForbidden          = apperrors.ErrForbidden
+	ErrRateLimited        = apperrors.ErrRateLimited
+	ErrOverloaded         = apperrors.ErrOverloaded
+	ErrRequestInProgress  = apperrors.ErrRequestInProgress
+	ErrIdempotencyKeyUsed = apperrors.ErrIdempotencyKeyUsed
+	ErrInternal           = apperrors.ErrInternal
+	ErrStreamUnavailable  = apperrors.ErrTooManyStreamClients
+)
+
+// Errors for responses without a problem code, e.g. from a proxy
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrUnavailable = errors.New("service unavailable")
+	ErrServer      = errors.New("server error")
+)
+
+// APIError is a non-2xx response
+// It unwraps to the error named by the response's problem code, or to one of
+// the errors above when the response does not name one.
+type APIError struct {
+	StatusCode int
+	Code       string        // Problem code, e.g. no_stock
+	Message    string        // The problem's detail
+	RetryAfter time.Duration // From the Retry-After header, 0 if absent
+	Method     string
+	Path       string
+
+	err error
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s %s: unexpected status %d", e.Method, e.Path, e.StatusCode)
+	}
+	return fmt.Sprintf("%s %s: %s (%d)", e.Method, e.Path, e.Message, e.StatusCode)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.err
+}
+
+// errorsByCode maps problem codes to errors
+var errorsByCode = func() map[string]error {
+	byCode := make(map[string]error)
+	for _, err := range []*apperrors.AppError{
+		ErrCouponNotFound, ErrCouponAlreadyExists, ErrAlreadyClaimed, ErrNoStock, ErrCouponInactive,
+		ErrClaimNotFound, ErrStockAvailable, ErrAlreadyWaitlisted, ErrNotWaitlisted, ErrWaitlistDisabled,
+		ErrRaffleOnly, ErrNotRaffle, ErrRaffleEntryClosed, ErrRaffleEntryOpen,
+		ErrAlreadyEntered, ErrRaffleAlreadyDrawn, ErrRaffleNotDrawn, ErrQueueNotEnabled,
+		ErrQueueTokenRequired, ErrQueueTokenInvalid, ErrQueueTicketNotFound, ErrQueueWrongInstance, ErrJobNotFound,
+		ErrUnknownJobType, ErrDatabaseUnavailable, ErrWebhookNotFound,
+		ErrDeliveryNotFound, ErrDeliveryNotDead, ErrAPIKeyNotFound, ErrAPIKeyRevoked, ErrInvalidRequest,
+		ErrValidation, ErrUnauthorized, ErrForbidden, ErrRateLimited, ErrOverloaded,
+		ErrRequestInProgress, ErrIdempotencyKeyUsed, ErrInternal, ErrStreamUnavailable, apperrors.ErrStreamClosed,
+	} {
+		byCode[err.Code] = err
+	}
+	return byCode
+}()
+
+// matchError returns the error a response stands for
+func matchError(status int, code string) error {
+	if err, ok := errorsByCode[code]; ok {
+		return err
+	}
+	switch {
+	case status == http.StatusNotFound:
+		return ErrNotFound
+	case status == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case status == http.StatusServiceUnavailable:
+		return ErrUnavailable
+	case status >= 500:
+		return ErrServer
+	default:
+		return ErrInvalidRequest
+	}
+}
@@ -0,0 +1,33 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// CreateJob starts a background job
+func (c *Client) CreateJob(ctx context.Context, req *CreateJobRequest) (*Job, error) {
+	var job Job
+	if err := c.do(ctx, call{method: http.MethodPost, path: "/api/jobs", body: req, out: &job, idempotent: true}); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetJob returns a job's status and progress
+func (c *Client) GetJob(ctx context.Context, id string) (*Job, error) {
+	var job Job
+	if err := c.do(ctx, call{method: http.MethodGet, path: "/api/jobs" + pathJoin(id), out: &job}); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// CancelJob asks a job to stop; it is cancelled once its worker notices
+func (c *Client) CancelJob(ctx context.Context, id string) (*Job, error) {
+	var job Job
+	if err := c.do(ctx, call{method: http.MethodPost, path: "/api/jobs" + pathJoin(id, "cancel"), out: &job, retry: true}); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
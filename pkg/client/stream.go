@@ -0,0 +1,57 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// StreamCoupon calls fn with a coupon's current stock and status, then with
+// every change, until ctx is done, fn returns an error, or the server ends
+// the stream. It returns ctx's error, fn's error, or io.EOF respectively;
+// reconnect on io.EOF to keep following the coupon.
+func (c *Client) StreamCoupon(ctx context.Context, name string, fn func(*StockUpdate) error) error {
+	path := couponPath(name, "stream")
+	resp, err := c.send(ctx, http.MethodGet, path, nil, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(http.MethodGet, path, resp); err != nil {
+		return err
+	}
+
+	// Server-Sent Events: "field: value" lines, an empty line ends an event
+	scanner := bufio.NewScanner(resp.Body)
+	var event, data string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if event == "stock" && data != "" {
+				var update StockUpdate
+				if err := json.Unmarshal([]byte(data), &update); err != nil {
+					return err
+				}
+				if err := fn(&update); err != nil {
+					return err
+				}
+			}
+			event, data = "", ""
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data += strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
@@ -0,0 +1,43 @@
+package client
+
+import (
+	"coupon-system/internal/model"
+)
+
+// Request and response bodies are the server's own models, so the client and
+// the API cannot drift apart
+type (
+	Coupon                = model.Coupon
+	CouponDetails         = model.CouponDetailsResponse
+	CreateCouponRequest   = model.CreateCouponRequest
+	Claim                 = model.Claim
+	ClaimCouponRequest    = model.ClaimCouponRequest
+	BulkClaimRequest      = model.BulkClaimRequest
+	BulkClaimResponse     = model.BulkClaimResponse
+	BulkClaimResult       = model.BulkClaimResult
+	WaitlistStatus        = model.WaitlistStatusResponse
+	RaffleEntry           = model.RaffleEntry
+	RaffleDraw            = model.RaffleDraw
+	RaffleVerification    = model.RaffleVerification
+	QueueTicket           = model.QueueTicket
+	Job                   = model.Job
+	JobStatus             = model.JobStatus
+	CreateJobRequest      = model.CreateJobRequest
+	WebhookSubscription   = model.WebhookSubscription
+	WebhookDelivery       = model.WebhookDelivery
+	WebhookDeliveryStatus = model.WebhookDeliveryStatus
+	CreateWebhookRequest  = model.CreateWebhookRequest
+	StockUpdate           = model.StockUpdate
+)
+
+// Health is the body of GET /health
+type Health struct {
+	Status   string                 `json:"status"`             // ok or degraded
+	Overload map[string]interface{} `json:"overload,omitempty"` // Concurrency limiter stats while degraded
+}
+
+// WebhookCreated is a new subscription with its signing secret, which is only returned once
+type WebhookCreated struct {
+	WebhookSubscription
+	Secret string `json:"secret"`
+}
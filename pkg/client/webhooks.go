@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// CreateWebhook subscribes a URL to domain events
+// The returned secret signs deliveries and cannot be fetched again.
+func (c *Client) CreateWebhook(ctx context.Context, req *CreateWebhookRequest) (*WebhookCreated, error) {
+	var created WebhookCreated
+	if err := c.do(ctx, call{method: http.MethodPost, path: "/api/webhooks", body: req, out: &created, idempotent: true}); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// ListWebhooks returns every webhook subscription
+func (c *Client) ListWebhooks(ctx context.Context) ([]*WebhookSubscription, error) {
+	var subs []*WebhookSubscription
+	if err := c.do(ctx, call{method: http.MethodGet, path: "/api/webhooks", out: &subs}); err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+// GetWebhook returns a webhook subscription
+func (c *Client) GetWebhook(ctx context.Context, id string) (*WebhookSubscription, error) {
+	var sub WebhookSubscription
+	if err := c.do(ctx, call{method: http.MethodGet, path: "/api/webhooks" + pathJoin(id), out: &sub}); err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// DeleteWebhook deletes a webhook subscription
+func (c *Client) DeleteWebhook(ctx context.Context, id string) error {
+	return c.do(ctx, call{method: http.MethodDelete, path: "/api/webhooks" + pathJoin(id), idempotent: true})
+}
+
+// ListDeliveries returns a subscription's deliveries, newest first
+// An empty status returns deliveries in any status; limit <= 0 uses the server's default.
+func (c *Client) ListDeliveries(ctx context.Context, id string, status WebhookDeliveryStatus, limit int) ([]*WebhookDelivery, error) {
+	query := url.Values{}
+	if status != "" {
+		query.Set("status", string(status))
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	var deliveries []*WebhookDelivery
+	err := c.do(ctx, call{method: http.MethodGet, path: "/api/webhooks" + pathJoin(id, "deliveries"), query: query, out: &deliveries})
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// ReplayDelivery sends a dead-lettered delivery again
+func (c *Client) ReplayDelivery(ctx context.Context, id, deliveryID string) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	err := c.do(ctx, call{method: http.MethodPost, path: "/api/webhooks" + pathJoin(id, "deliveries", deliveryID, "replay"), out: &delivery})
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// ReplayDeadDeliveries sends every dead-lettered delivery of a subscription again
+// It returns how many deliveries were queued.
+func (c *Client) ReplayDeadDeliveries(ctx context.Context, id string) (int64, error) {
+	var resp struct {
+		Replayed int64 `json:"replayed"`
+	}
+	if err := c.do(ctx, call{method: http.MethodPost, path: "/api/webhooks" + pathJoin(id, "replay"), out: &resp}); err != nil {
+		return 0, err
+	}
+	return resp.Replayed, nil
+}
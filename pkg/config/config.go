@@ -1,6 +1,11 @@
 package config
 
-import "os"
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // GetEnv retrieves an environment variable or returns a default value
 func GetEnv(key, defaultValue string) string {
@@ -10,3 +15,32 @@ func GetEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// GetEnvInt retrieves an integer environment variable or returns a default value
+// Unparseable values fall back to the default
+func GetEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// GetEnvDuration retrieves a duration environment variable (e.g. "30s") or returns a default value
+// Unparseable values fall back to the default
+func GetEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// GetEnvList retrieves a comma-separated environment variable as a list
+// Items are trimmed and empty items dropped; an unset variable gives nil
+func GetEnvList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
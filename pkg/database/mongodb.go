@@ -24,7 +24,7 @@ func (m *MongoDB) StartSession() (mongo.Session, error) {
 // Connect establishes a connection to MongoDB
 func Connect(ctx context.Context, uri, dbName string) (*MongoDB, error) {
 	clientOptions := options.Client().ApplyURI(uri)
-	
+
 	// Set connection timeout
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
@@ -98,11 +98,241 @@ func (m *MongoDB) CreateIndexes(ctx context.Context) error {
 		return fmt.Errorf("failed to create coupon_name index: %w", err)
 	}
 
+	// Create index on jobs(status, created_at) for workers picking the next job
+	jobsCollection := m.Database.Collection("jobs")
+	jobQueueIndex := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "status", Value: 1},
+			{Key: "created_at", Value: 1},
+		},
+		Options: options.Index().SetName("job_queue_index"),
+	}
+	if _, err := jobsCollection.Indexes().CreateOne(ctx, jobQueueIndex); err != nil {
+		return fmt.Errorf("failed to create job queue index: %w", err)
+	}
+
+	// Create unique compound index on waitlist(coupon_id, user_id)
+	// A user can only wait once per coupon
+	waitlistCollection := m.Database.Collection("waitlist")
+	waitlistUserIndex := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "coupon_id", Value: 1},
+			{Key: "user_id", Value: 1},
+		},
+		Options: options.Index().SetUnique(true).SetName("waitlist_user_unique"),
+	}
+	if _, err := waitlistCollection.Indexes().CreateOne(ctx, waitlistUserIndex); err != nil {
+		return fmt.Errorf("failed to create waitlist user index: %w", err)
+	}
+
+	// Create index on waitlist(coupon_id, status, _id) for FIFO promotion and positions
+	waitlistQueueIndex := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "coupon_id", Value: 1},
+			{Key: "status", Value: 1},
+			{Key: "_id", Value: 1},
+		},
+		Options: options.Index().SetName("waitlist_queue_index"),
+	}
+	if _, err := waitlistCollection.Indexes().CreateOne(ctx, waitlistQueueIndex); err != nil {
+		return fmt.Errorf("failed to create waitlist queue index: %w", err)
+	}
+
+	// Create index on waitlist(status, coupon_name) for the promotion sweep
+	waitlistStatusIndex := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "status", Value: 1},
+			{Key: "coupon_name", Value: 1},
+		},
+		Options: options.Index().SetName("waitlist_status_index"),
+	}
+	if _, err := waitlistCollection.Indexes().CreateOne(ctx, waitlistStatusIndex); err != nil {
+		return fmt.Errorf("failed to create waitlist status index: %w", err)
+	}
+
+	// Create unique compound index on raffle_entries(coupon_id, user_id)
+	// A user can only enter a raffle once
+	raffleEntryIndex := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "coupon_id", Value: 1},
+			{Key: "user_id", Value: 1},
+		},
+		Options: options.Index().SetUnique(true).SetName("raffle_entry_unique"),
+	}
+	if _, err := m.Database.Collection("raffle_entries").Indexes().CreateOne(ctx, raffleEntryIndex); err != nil {
+		return fmt.Errorf("failed to create raffle entry index: %w", err)
+	}
+
+	// Create unique index on raffle_draws.coupon_id
+	// This guarantees a raffle is only ever drawn once
+	raffleDrawIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "coupon_id", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("raffle_draw_unique"),
+	}
+	if _, err := m.Database.Collection("raffle_draws").Indexes().CreateOne(ctx, raffleDrawIndex); err != nil {
+		return fmt.Errorf("failed to create raffle draw index: %w", err)
+	}
+
+	// Create unique compound index on coupon_stock_shards(coupon_id, shard)
+	stockShardIndex := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "coupon_id", Value: 1},
+			{Key: "shard", Value: 1},
+		},
+		Options: options.Index().SetUnique(true).SetName("stock_shard_unique"),
+	}
+	if _, err := m.Database.Collection("coupon_stock_shards").Indexes().CreateOne(ctx, stockShardIndex); err != nil {
+		return fmt.Errorf("failed to create stock shard index: %w", err)
+	}
+
+	// Create sparse index on claims.lease_id for counting claims served from a stock lease
+	claimLeaseIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "lease_id", Value: 1}},
+		Options: options.Index().SetSparse(true).SetName("claim_lease_index"),
+	}
+	if _, err := claimsCollection.Indexes().CreateOne(ctx, claimLeaseIndex); err != nil {
+		return fmt.Errorf("failed to create claim lease index: %w", err)
+	}
+
+	// Create partial index on claims.created_at for the relay's sweep of claims
+	// whose events may not be stored yet; it only holds the few marked claims
+	claimEventPendingIndex := mongo.IndexModel{
+		Keys: bson.D{{Key: "created_at", Value: 1}},
+		Options: options.Index().
+			SetPartialFilterExpression(bson.M{"event_pending": true}).
+			SetName("claim_event_pending_index"),
+	}
+	if _, err := claimsCollection.Indexes().CreateOne(ctx, claimEventPendingIndex); err != nil {
+		return fmt.Errorf("failed to create claim event pending index: %w", err)
+	}
+
+	// Create index on stock_leases(status, expires_at) for reclaiming expired leases
+	leaseExpiryIndex := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "status", Value: 1},
+			{Key: "expires_at", Value: 1},
+		},
+		Options: options.Index().SetName("lease_expiry_index"),
+	}
+	if _, err := m.Database.Collection("stock_leases").Indexes().CreateOne(ctx, leaseExpiryIndex); err != nil {
+		return fmt.Errorf("failed to create lease expiry index: %w", err)
+	}
+
+	// Create unique sparse index on outbox.key so keyed events are stored once
+	outboxCollection := m.Database.Collection("outbox")
+	outboxKeyIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "key", Value: 1}},
+		Options: options.Index().SetUnique(true).SetSparse(true).SetName("outbox_key_unique"),
+	}
+	if _, err := outboxCollection.Indexes().CreateOne(ctx, outboxKeyIndex); err != nil {
+		return fmt.Errorf("failed to create outbox key index: %w", err)
+	}
+
+	// Create TTL index on outbox.published_at; published events are kept for a week
+	// Unpublished events (published_at null) never expire
+	outboxPublishedIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "published_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(7 * 24 * 3600).SetName("outbox_published_ttl"),
+	}
+	if _, err := outboxCollection.Indexes().CreateOne(ctx, outboxPublishedIndex); err != nil {
+		return fmt.Errorf("failed to create outbox published index: %w", err)
+	}
+
+	// Create unique index on webhook_deliveries(subscription_id, event_id) so an
+	// event redelivered by the outbox relay is sent to each subscription once
+	deliveriesCollection := m.Database.Collection("webhook_deliveries")
+	deliveryEventIndex := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "subscription_id", Value: 1},
+			{Key: "event_id", Value: 1},
+		},
+		Options: options.Index().SetUnique(true).SetName("webhook_delivery_event_unique"),
+	}
+	if _, err := deliveriesCollection.Indexes().CreateOne(ctx, deliveryEventIndex); err != nil {
+		return fmt.Errorf("failed to create webhook delivery event index: %w", err)
+	}
+
+	// Create index on webhook_deliveries(status, next_attempt_at) for workers picking due deliveries
+	deliveryDueIndex := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "status", Value: 1},
+			{Key: "next_attempt_at", Value: 1},
+		},
+		Options: options.Index().SetName("webhook_delivery_due_index"),
+	}
+	if _, err := deliveriesCollection.Indexes().CreateOne(ctx, deliveryDueIndex); err != nil {
+		return fmt.Errorf("failed to create webhook delivery due index: %w", err)
+	}
+
+	// Create TTL index on webhook_deliveries.delivered_at; successful deliveries
+	// are kept for a week, dead ones until they are replayed or deleted
+	deliveredIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "delivered_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(7 * 24 * 3600).SetName("webhook_delivered_ttl"),
+	}
+	if _, err := deliveriesCollection.Indexes().CreateOne(ctx, deliveredIndex); err != nil {
+		return fmt.Errorf("failed to create webhook delivered index: %w", err)
+	}
+
+	// Create unique index on api_keys.hash; requests look keys up by the hash of their secret
+	keyHashIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "hash", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("api_key_hash_unique"),
+	}
+	if _, err := m.Database.Collection("api_keys").Indexes().CreateOne(ctx, keyHashIndex); err != nil {
+		return fmt.Errorf("failed to create API key hash index: %w", err)
+	}
+
+	// Create index on api_key_audit(key_id, at) for a key's usage, newest first
+	auditCollection := m.Database.Collection("api_key_audit")
+	auditKeyIndex := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "key_id", Value: 1},
+			{Key: "at", Value: -1},
+		},
+		Options: options.Index().SetName("api_key_audit_key_index"),
+	}
+	if _, err := auditCollection.Indexes().CreateOne(ctx, auditKeyIndex); err != nil {
+		return fmt.Errorf("failed to create API key audit index: %w", err)
+	}
+
+	// Create TTL index on api_key_audit.at; the audit log is kept for 90 days
+	auditTTLIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(90 * 24 * 3600).SetName("api_key_audit_ttl"),
+	}
+	if _, err := auditCollection.Indexes().CreateOne(ctx, auditTTLIndex); err != nil {
+		return fmt.Errorf("failed to create API key audit TTL index: %w", err)
+	}
+
 	return nil
 }
 
+// SupportsTransactions reports whether the server can run multi-document
+// transactions (replica sets and sharded clusters, not standalone servers)
+func (m *MongoDB) SupportsTransactions(ctx context.Context) bool {
+	var hello bson.M
+	if err := m.Client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		return false
+	}
+	_, replicaSet := hello["setName"]
+	return replicaSet || hello["msg"] == "isdbgrid"
+}
+
+// Reset drops all application collections and recreates their indexes
+// Intended for local development and tests only. API keys and their audit log
+// are kept, so a wiped server can still be called with the same keys.
+func (m *MongoDB) Reset(ctx context.Context) error {
+	for _, name := range []string{"coupons", "claims", "jobs", "waitlist", "raffle_entries", "raffle_draws", "coupon_stock_shards", "stock_leases", "outbox", "outbox_leases", "webhook_subscriptions", "webhook_deliveries"} {
+		if err := m.Database.Collection(name).Drop(ctx); err != nil {
+			return fmt.Errorf("failed to drop collection %s: %w", name, err)
+		}
+	}
+
+	return m.CreateIndexes(ctx)
+}
+
 // Disconnect closes the MongoDB connection
 func (m *MongoDB) Disconnect(ctx context.Context) error {
 	return m.Client.Disconnect(ctx)
 }
-
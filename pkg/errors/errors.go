@@ -1,11 +1,117 @@
 package errors
 
-import "errors"
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// AppError is an error with a stable code and the HTTP status it is reported with
+// Errors match by code, so a copy made with WithMessage or WithDetails still
+// satisfies errors.Is against the original.
+type AppError struct {
+	Code       string                 // Stable, machine-readable identifier, e.g. no_stock
+	Status     int                    // HTTP status
+	Message    string                 // Human-readable description
+	Details    map[string]interface{} // Extra members of the problem response, e.g. invalid fields
+	RetryAfter time.Duration          // Sent as Retry-After when set
+}
+
+// New creates an application error
+func New(code string, status int, message string) *AppError {
+	return &AppError{Code: code, Status: status, Message: message}
+}
+
+func (e *AppError) Error() string {
+	return e.Message
+}
+
+// Is reports whether target is an AppError with the same code
+func (e *AppError) Is(target error) bool {
+	t, ok := target.(*AppError)
+	return ok && t.Code == e.Code
+}
+
+// WithMessage returns a copy of e with a more specific message
+func (e *AppError) WithMessage(message string) *AppError {
+	copied := *e
+	copied.Message = message
+	return &copied
+}
+
+// WithDetails returns a copy of e carrying details
+func (e *AppError) WithDetails(details map[string]interface{}) *AppError {
+	copied := *e
+	copied.Details = details
+	return &copied
+}
+
+// WithRetryAfter returns a copy of e telling the client when to retry
+func (e *AppError) WithRetryAfter(d time.Duration) *AppError {
+	copied := *e
+	copied.RetryAfter = d
+	return &copied
+}
+
+// From returns the AppError in err's chain, or ErrInternal if there is none
+func From(err error) *AppError {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return ErrInternal
+}
 
 // Domain errors for the coupon system
 var (
-	ErrCouponNotFound      = errors.New("coupon not found")
-	ErrCouponAlreadyExists = errors.New("coupon already exists")
-	ErrAlreadyClaimed      = errors.New("coupon already claimed by this user")
-	ErrNoStock             = errors.New("no stock available")
+	ErrCouponNotFound      = New("coupon_not_found", http.StatusNotFound, "coupon not found")
+	ErrCouponAlreadyExists = New("coupon_already_exists", http.StatusConflict, "coupon already exists")
+	ErrAlreadyClaimed      = New("already_claimed", http.StatusConflict, "coupon already claimed by this user")
+	ErrNoStock             = New("no_stock", http.StatusBadRequest, "no stock available")
+	ErrCouponInactive      = New("coupon_inactive", http.StatusBadRequest, "coupon is not active")
+	ErrClaimNotFound       = New("claim_not_found", http.StatusNotFound, "claim not found")
+	ErrStockAvailable      = New("stock_available", http.StatusBadRequest, "coupon still has stock available")
+	ErrAlreadyWaitlisted   = New("already_waitlisted", http.StatusConflict, "user is already on the waitlist")
+	ErrNotWaitlisted       = New("not_waitlisted", http.StatusNotFound, "user is not on the waitlist")
+	ErrWaitlistDisabled    = New("waitlist_disabled", http.StatusBadRequest, "waitlist is not enabled")
+	ErrRaffleOnly          = New("raffle_only", http.StatusBadRequest, "coupon is distributed by raffle")
+	ErrNotRaffle           = New("not_raffle", http.StatusBadRequest, "coupon is not a raffle")
+	ErrRaffleEntryClosed   = New("raffle_entry_closed", http.StatusBadRequest, "raffle entry window is closed")
+	ErrRaffleEntryOpen     = New("raffle_entry_open", http.StatusConflict, "raffle entry window has not ended")
+	ErrAlreadyEntered      = New("already_entered", http.StatusConflict, "user has already entered the raffle")
+	ErrRaffleAlreadyDrawn  = New("raffle_already_drawn", http.StatusConflict, "raffle has already been drawn")
+	ErrRaffleNotDrawn      = New("raffle_not_drawn", http.StatusNotFound, "raffle has not been drawn")
+	ErrQueueNotEnabled     = New("queue_not_enabled", http.StatusBadRequest, "coupon does not use a queue")
+	ErrQueueTokenRequired  = New("queue_token_required", http.StatusForbidden, "queue token required")
+	ErrQueueTokenInvalid   = New("queue_token_invalid", http.StatusForbidden, "queue token is invalid or not admitted")
+	ErrQueueTicketNotFound = New("queue_ticket_not_found", http.StatusNotFound, "queue ticket not found")
+	ErrQueueWrongInstance  = New("queue_wrong_instance", http.StatusMisdirectedRequest, "queue token was issued by another instance")
+	ErrJobNotFound         = New("job_not_found", http.StatusNotFound, "job not found")
+	ErrJobLeaseLost        = New("job_lease_lost", http.StatusConflict, "job lease lost")
+	ErrUnknownJobType      = New("unknown_job_type", http.StatusBadRequest, "unknown job type")
+	ErrDatabaseUnavailable = New("database_unavailable", http.StatusServiceUnavailable, "database unavailable").WithRetryAfter(time.Second)
+	ErrWebhookNotFound     = New("webhook_not_found", http.StatusNotFound, "webhook subscription not found")
+	ErrDeliveryNotFound    = New("delivery_not_found", http.StatusNotFound, "webhook delivery not found")
+	ErrDeliveryNotDead     = New("delivery_not_dead", http.StatusConflict, "webhook delivery is not in the dead-letter queue")
+	ErrAPIKeyNotFound      = New("api_key_not_found", http.StatusNotFound, "API key not found")
+	ErrAPIKeyRevoked       = New("api_key_revoked", http.StatusConflict, "API key has been revoked")
+)
+
+// Stream errors returned when a live stock stream cannot be opened
+var (
+	ErrTooManyStreamClients = New("too_many_stream_clients", http.StatusServiceUnavailable, "too many stream clients").WithRetryAfter(5 * time.Second)
+	ErrStreamClosed         = New("stream_closed", http.StatusServiceUnavailable, "stream hub closed").WithRetryAfter(5 * time.Second)
+)
+
+// Request errors raised by the API layer rather than the domain
+var (
+	ErrInvalidRequest     = New("invalid_request", http.StatusBadRequest, "invalid request body")
+	ErrValidation         = New("validation_failed", http.StatusBadRequest, "request validation failed")
+	ErrUnauthorized       = New("unauthorized", http.StatusUnauthorized, "a valid API key is required")
+	ErrForbidden          = New("forbidden", http.StatusForbidden, "API key is not allowed to perform this action")
+	ErrRateLimited        = New("rate_limited", http.StatusTooManyRequests, "rate limit exceeded")
+	ErrOverloaded         = New("overloaded", http.StatusServiceUnavailable, "server overloaded, retry later")
+	ErrRequestInProgress  = New("request_in_progress", http.StatusConflict, "a request with this idempotency key is in progress")
+	ErrIdempotencyKeyUsed = New("idempotency_key_reused", http.StatusUnprocessableEntity, "idempotency key was used with a different request")
+	ErrInternal           = New("internal_error", http.StatusInternalServerError, "internal server error")
 )
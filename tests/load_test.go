@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"context"
 	"coupon-system/internal/model"
+	"coupon-system/internal/repository"
+	"coupon-system/internal/seed"
 	"coupon-system/pkg/config"
 	"coupon-system/pkg/database"
 	"encoding/json"
@@ -13,16 +15,30 @@ import (
 	"sync/atomic"
 	"testing"
 	"time"
-
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 var (
 	testMongoURI = config.GetEnv("MONGO_URI", "mongodb://localhost:27017")
 	testDBName   = config.GetEnv("MONGO_DB", "coupon_system")
 	baseURL      = config.GetEnv("BASE_URL", "http://localhost:8080")
+	testAPIKey   = config.GetEnv("API_KEY", "") // Admin key of the server under test, e.g. its ADMIN_API_KEY
 )
 
+// apiRequest sends a request with the test API key
+func apiRequest(method, url string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if testAPIKey != "" {
+		req.Header.Set("X-API-Key", testAPIKey)
+	}
+	return http.DefaultClient.Do(req)
+}
+
 // TestResult tracks the result of a claim request
 type TestResult struct {
 	StatusCode int
@@ -41,57 +57,29 @@ func setupTestDatabase(t *testing.T) func() {
 		t.Fatalf("Failed to connect to MongoDB: %v", err)
 	}
 
-	// Clean database - drop all collections
-	collections := []string{"coupons", "claims"}
-	for _, collName := range collections {
-		collection := mongoDB.Database.Collection(collName)
-		if err := collection.Drop(ctx); err != nil {
-			t.Logf("Warning: Failed to drop collection %s: %v", collName, err)
-		}
-	}
-
-	// Recreate indexes
-	if err := mongoDB.CreateIndexes(ctx); err != nil {
-		t.Fatalf("Failed to create indexes: %v", err)
-	}
-
-	// Seed test data
-	couponsCollection := mongoDB.Database.Collection("coupons")
-
-	// Flash Sale coupon - 5 items in stock
-	flashSaleCoupon := &model.Coupon{
-		ID:              primitive.NewObjectID(),
-		Name:            "FLASH_SALE_2026",
-		Amount:          500,
-		RemainingAmount: 5,
-		IsActive:        true,
-		CreatedAt:       time.Now(),
-		ExpiresAt:       time.Now().Add(24 * time.Hour),
-		UpdatedAt:       time.Now(),
-	}
-	_, err = couponsCollection.InsertOne(ctx, flashSaleCoupon)
-	if err != nil {
-		t.Fatalf("Failed to seed flash sale coupon: %v", err)
+	// Clean database and recreate indexes
+	if err := mongoDB.Reset(ctx); err != nil {
+		t.Fatalf("Failed to reset database: %v", err)
 	}
 
-	// Promo coupon - for double dip test (plenty of stock)
-	promoCoupon := &model.Coupon{
-		ID:              primitive.NewObjectID(),
-		Name:            "PROMO_SUPER",
-		Amount:          10000,
-		RemainingAmount: 100,
-		IsActive:        true,
-		CreatedAt:       time.Now(),
-		ExpiresAt:       time.Now().Add(24 * time.Hour),
-		UpdatedAt:       time.Now(),
-	}
-	_, err = couponsCollection.InsertOne(ctx, promoCoupon)
-	if err != nil {
-		t.Fatalf("Failed to seed promo coupon: %v", err)
+	// Seed test data: FLASH_SALE_2026 with 5 items in stock and
+	// PROMO_SUPER with plenty of stock for the double dip test
+	seeder := seed.NewSeeder(
+		repository.NewCouponRepository(mongoDB.Database),
+		repository.NewClaimRepository(mongoDB.Database),
+	)
+	for _, scenario := range []string{"flash_sale", "double_dip"} {
+		fixture, err := seed.Scenario(scenario)
+		if err != nil {
+			t.Fatalf("Failed to load %s scenario: %v", scenario, err)
+		}
+		if _, err := seeder.Apply(ctx, fixture); err != nil {
+			t.Fatalf("Failed to seed %s scenario: %v", scenario, err)
+		}
 	}
 
 	t.Logf("✅ Database cleaned and seeded successfully")
-	
+
 	// Return cleanup function
 	return func() {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -116,11 +104,7 @@ func claimCoupon(baseURL, userID, couponName string) TestResult {
 		}
 	}
 
-	resp, err := http.Post(
-		fmt.Sprintf("%s/api/coupons/claim", baseURL),
-		"application/json",
-		bytes.NewBuffer(jsonData),
-	)
+	resp, err := apiRequest(http.MethodPost, fmt.Sprintf("%s/api/coupons/claim", baseURL), jsonData)
 	if err != nil {
 		return TestResult{
 			StatusCode: 0,
@@ -132,9 +116,11 @@ func claimCoupon(baseURL, userID, couponName string) TestResult {
 
 	var errorMsg string
 	if resp.StatusCode != http.StatusOK {
-		var errorResp map[string]string
-		if err := json.NewDecoder(resp.Body).Decode(&errorResp); err == nil {
-			errorMsg = errorResp["error"]
+		var problem struct {
+			Code string `json:"code"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&problem); err == nil {
+			errorMsg = problem.Code
 		}
 	}
 
@@ -147,7 +133,7 @@ func claimCoupon(baseURL, userID, couponName string) TestResult {
 
 // getCouponDetails retrieves coupon details from the API
 func getCouponDetails(baseURL, couponName string) (*model.CouponDetailsResponse, error) {
-	resp, err := http.Get(fmt.Sprintf("%s/api/coupons/%s", baseURL, couponName))
+	resp, err := apiRequest(http.MethodGet, fmt.Sprintf("%s/api/coupons/%s", baseURL, couponName), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -228,7 +214,7 @@ func TestFlashSaleAttack(t *testing.T) {
 			case http.StatusOK:
 				atomic.AddInt64(&successCount, 1)
 			case http.StatusBadRequest:
-				if result.Error == "no stock available" {
+				if result.Error == "no_stock" {
 					atomic.AddInt64(&noStockCount, 1)
 				} else {
 					atomic.AddInt64(&otherErrors, 1)
@@ -310,12 +296,12 @@ func TestDoubleDipAttack(t *testing.T) {
 
 	// Track results
 	var (
-		successCount int64
+		successCount  int64
 		conflictCount int64
-		otherErrors  int64
-		mu           sync.Mutex
-		wg           sync.WaitGroup
-		results      []TestResult
+		otherErrors   int64
+		mu            sync.Mutex
+		wg            sync.WaitGroup
+		results       []TestResult
 	)
 
 	t.Logf("Starting Double Dip Attack Test")
@@ -398,4 +384,3 @@ func TestDoubleDipAttack(t *testing.T) {
 		t.Logf("✅ PASSED: User appears exactly once in database")
 	}
 }
-
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"coupon-system/pkg/config"
+	"coupon-system/pkg/database"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TestHotCouponThroughput measures claim throughput on a single hot coupon
+// Run it twice to compare the single counter with sharded stock counters:
+//
+//	LOAD_TEST_SHARDS=0 go test -v -run TestHotCouponThroughput ./tests
+//	LOAD_TEST_SHARDS=16 go test -v -run TestHotCouponThroughput ./tests
+//
+// The server must run with RATE_LIMIT_ENABLED=false, since every request comes from one IP,
+// and API_KEY must be an admin key of the server unless it runs with AUTH_ENABLED=false
+//
+// Expected: exactly LOAD_TEST_STOCK successful claims and 0 remaining stock in both modes
+func TestHotCouponThroughput(t *testing.T) {
+	if err := waitForServer(baseURL, 10*time.Second); err != nil {
+		t.Fatalf("Server is not ready: %v. Make sure the server is running on %s", err, baseURL)
+	}
+
+	cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	var (
+		couponName  = "HOT_COUPON_2026"
+		shards      = config.GetEnvInt("LOAD_TEST_SHARDS", 0)
+		stock       = config.GetEnvInt("LOAD_TEST_STOCK", 2000)
+		requests    = config.GetEnvInt("LOAD_TEST_REQUESTS", 4000)
+		concurrency = config.GetEnvInt("LOAD_TEST_CONCURRENCY", 200)
+	)
+
+	// Create the hot coupon; stock equals amount on creation
+	body, _ := json.Marshal(map[string]interface{}{
+		"name":         couponName,
+		"amount":       stock,
+		"stock_shards": shards,
+	})
+	resp, err := apiRequest(http.MethodPost, baseURL+"/api/coupons", body)
+	if err != nil {
+		t.Fatalf("Failed to create coupon: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Failed to create coupon: status %d", resp.StatusCode)
+	}
+
+	t.Logf("Starting Hot Coupon Throughput Test")
+	t.Logf("   Stock Shards: %d", shards)
+	t.Logf("   Stock: %d", stock)
+	t.Logf("   Requests: %d (concurrency %d)", requests, concurrency)
+
+	var (
+		mu          sync.Mutex
+		wg          sync.WaitGroup
+		latencies   = make([]time.Duration, 0, requests)
+		successes   int
+		noStock     int
+		otherErrors int
+		work        = make(chan int)
+	)
+
+	start := time.Now()
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				begin := time.Now()
+				result := claimCoupon(baseURL, fmt.Sprintf("hot_user_%d", i), couponName)
+				elapsed := time.Since(begin)
+
+				mu.Lock()
+				latencies = append(latencies, elapsed)
+				switch {
+				case result.Success:
+					successes++
+				case result.StatusCode == http.StatusBadRequest && result.Error == "no_stock":
+					noStock++
+				default:
+					otherErrors++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := 0; i < requests; i++ {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+	duration := time.Since(start)
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(p float64) time.Duration {
+		return latencies[int(float64(len(latencies)-1)*p)]
+	}
+
+	t.Logf("📊 Results (shards=%d)", shards)
+	t.Logf("   Duration: %v", duration)
+	t.Logf("   Throughput: %.0f requests/s", float64(requests)/duration.Seconds())
+	t.Logf("   Latency p50: %v, p95: %v, p99: %v", percentile(0.50), percentile(0.95), percentile(0.99))
+	t.Logf("   Claimed: %d, no stock: %d, other errors: %d", successes, noStock, otherErrors)
+	// Ready to paste into the results table in the README
+	t.Logf("   | %d | %d | %d | %.0f/s | %v | %v | %v | %s |", shards, requests, concurrency,
+		float64(requests)/duration.Seconds(), percentile(0.50).Round(time.Millisecond),
+		percentile(0.95).Round(time.Millisecond), percentile(0.99).Round(time.Millisecond), environment())
+
+	details, err := getCouponDetails(baseURL, couponName)
+	if err != nil {
+		t.Fatalf("Failed to get coupon details: %v", err)
+	}
+
+	expectedSuccess := stock
+	if requests < stock {
+		expectedSuccess = requests
+	}
+	if successes != expectedSuccess {
+		t.Errorf("❌ FAILED: Expected %d successful claims, got %d", expectedSuccess, successes)
+	} else {
+		t.Logf("✅ PASSED: Success count is correct (%d)", successes)
+	}
+
+	if otherErrors != 0 {
+		t.Errorf("❌ FAILED: Expected 0 other errors, got %d", otherErrors)
+	}
+
+	if details.RemainingAmount != int32(stock-expectedSuccess) {
+		t.Errorf("❌ FAILED: Expected remaining stock to be %d, got %d", stock-expectedSuccess, details.RemainingAmount)
+	} else {
+		t.Logf("✅ PASSED: Remaining stock is %d", details.RemainingAmount)
+	}
+
+	if len(details.ClaimedBy) != expectedSuccess {
+		t.Errorf("❌ FAILED: Expected %d claims in database, got %d", expectedSuccess, len(details.ClaimedBy))
+	}
+}
+
+// environment describes where the load test ran: the client machine and the MongoDB deployment
+func environment() string {
+	env := fmt.Sprintf("%s/%s, %d CPUs", runtime.GOOS, runtime.GOARCH, runtime.NumCPU())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	mongoDB, err := database.Connect(ctx, testMongoURI, testDBName)
+	if err != nil {
+		return env
+	}
+	defer mongoDB.Disconnect(ctx)
+
+	var build struct {
+		Version string `bson:"version"`
+	}
+	var hello struct {
+		SetName string `bson:"setName"`
+	}
+	admin := mongoDB.Client.Database("admin")
+	if admin.RunCommand(ctx, bson.D{{Key: "buildInfo", Value: 1}}).Decode(&build) != nil ||
+		admin.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello) != nil {
+		return env
+	}
+	topology := "standalone"
+	if hello.SetName != "" {
+		topology = "replica set"
+	}
+	return fmt.Sprintf("%s, MongoDB %s %s", env, build.Version, topology)
+}
+
+// TestShardedSellOutAfterRestock checks a sharded coupon that is restocked and
+// sells out again records a second coupon.sold_out event
+// Needs the server to run with the outbox enabled (the default)
+func TestShardedSellOutAfterRestock(t *testing.T) {
+	if err := waitForServer(baseURL, 10*time.Second); err != nil {
+		t.Fatalf("Server is not ready: %v. Make sure the server is running on %s", err, baseURL)
+	}
+
+	cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	couponName := "SHARDED_RESTOCK_2026"
+	body, _ := json.Marshal(map[string]interface{}{"name": couponName, "amount": 2, "stock_shards": 4})
+	resp, err := apiRequest(http.MethodPost, baseURL+"/api/coupons", body)
+	if err != nil {
+		t.Fatalf("Failed to create coupon: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Failed to create coupon: status %d", resp.StatusCode)
+	}
+
+	// Every user but the last gets the coupon; the last one finds it sold out
+	claimUntilSoldOut := func(users ...string) {
+		t.Helper()
+		for i, userID := range users {
+			result := claimCoupon(baseURL, userID, couponName)
+			wantSoldOut := i == len(users)-1
+			if result.Success == wantSoldOut || wantSoldOut && result.Error != "no_stock" {
+				t.Fatalf("Claim by %s: status %d %s", userID, result.StatusCode, result.Error)
+			}
+		}
+	}
+
+	claimUntilSoldOut("restock_user_1", "restock_user_2", "restock_user_3")
+	resp, err = apiRequest(http.MethodPost, baseURL+"/api/coupons/"+couponName+"/restock", []byte(`{"amount": 1}`))
+	if err != nil {
+		t.Fatalf("Failed to restock: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to restock: status %d", resp.StatusCode)
+	}
+	claimUntilSoldOut("restock_user_4", "restock_user_5")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	mongoDB, err := database.Connect(ctx, testMongoURI, testDBName)
+	if err != nil {
+		t.Fatalf("Failed to connect to MongoDB: %v", err)
+	}
+	defer mongoDB.Disconnect(ctx)
+
+	soldOut, err := mongoDB.Database.Collection("outbox").CountDocuments(ctx, bson.M{"type": "coupon.sold_out", "coupon_name": couponName})
+	if err != nil {
+		t.Fatalf("Failed to count events: %v", err)
+	}
+	if soldOut != 2 {
+		t.Errorf("❌ FAILED: %d sold-out events, want one per sell-out (2)", soldOut)
+	} else {
+		t.Logf("✅ PASSED: Each sell-out recorded its own event")
+	}
+}